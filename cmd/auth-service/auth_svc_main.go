@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,6 +8,8 @@ import (
 	"syscall"
 
 	"california/internal/config"
+	"california/internal/helpers"
+	"california/internal/jwks"
 	"california/pkg/authsvc"
 	"california/pkg/repository"
 	"github.com/go-kit/kit/log"
@@ -23,18 +24,23 @@ func main() {
 	}
 	cfg := config.NewConfig()
 
+	keys, err := jwks.LoadKeyRing(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+	if err != nil {
+		logger.Log("component", "keys", "dir", cfg.JWTKeysDir, "err", err)
+		os.Exit(1)
+	}
+	helpers.UseSigningKeys(keys)
+
 	var svc authsvc.AuthService
-	signingKey := os.Getenv("SECRET_KEY")
-	c := context.WithValue(context.Background(), "foo", "bar")
 	{
-		store := repository.NewMongoStore(cfg)
-		svc = authsvc.NewAuthService(store, signingKey)
+		store := repository.NewStore(cfg)
+		svc = authsvc.NewAuthService(store, keys)
 		svc = authsvc.LoggingMiddleware(logger)(svc)
 	}
 
 	var h http.Handler
 	{
-		h = authsvc.MakeAuthHTTPHandler(c, svc, log.With(logger, "component", "HTTP"))
+		h = authsvc.MakeAuthHTTPHandler(svc, log.With(logger, "component", "HTTP"))
 	}
 
 	errs := make(chan error)
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,7 +8,9 @@ import (
 	"syscall"
 
 	"california/internal/config"
+	"california/internal/jwks"
 	charge_stationsvc "california/pkg/charge-stationsvc"
+	"california/pkg/ocpp"
 	"california/pkg/repository"
 	"github.com/go-kit/kit/log"
 )
@@ -23,19 +24,27 @@ func main() {
 	}
 	cfg := config.NewConfig()
 
+	store := repository.NewNotifyingStore(repository.NewStore(cfg))
+
 	var svc charge_stationsvc.StationService
-	signingKey := os.Getenv("SECRET_KEY")
-	c := context.WithValue(context.Background(), "foo", "bar")
+	// The tokens are verified with the public keys of the auth service, so this service cannot issue any.
+	if cfg.AuthJWKSURL == "" {
+		logger.Log("component", "keys", "err", "AUTH_JWKS_URL is not set, so no access token could be verified")
+		os.Exit(1)
+	}
+	keys := jwks.NewClient(cfg.AuthJWKSURL)
 	{
-		store := repository.NewMongoStore(cfg)
 		svc = charge_stationsvc.NewStationService(store)
-		svc = charge_stationsvc.AuthMiddleware(signingKey)(svc)
+		svc = charge_stationsvc.AuthorizationMiddleware()(svc)
 		svc = charge_stationsvc.LoggingMiddleware(logger)(svc)
 	}
 
 	var h http.Handler
 	{
-		h = charge_stationsvc.MakeStationHTTPHandlers(c, svc, log.With(logger, "component", "HTTP"))
+		mux := http.NewServeMux()
+		mux.Handle(ocpp.Path, ocpp.NewCentralSystem(store, log.With(logger, "component", "OCPP")))
+		mux.Handle("/", charge_stationsvc.MakeStationHTTPHandlers(svc, keys.Keyfunc, log.With(logger, "component", "HTTP")))
+		h = mux
 	}
 
 	errs := make(chan error)
@@ -0,0 +1,68 @@
+// Command keygen writes a new private key to sign the access tokens with into the keys directory,
+// as <kid>.pem. See package jwks for rotating keys.
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	dir := flag.String("dir", "keys", "directory of the keys")
+	alg := flag.String("alg", "EdDSA", "algorithm of the key, EdDSA or RS256")
+	kid := flag.String("kid", time.Now().UTC().Format("20060102-150405"), "id of the key")
+	flag.Parse()
+
+	if err := generate(*dir, *alg, *kid); err != nil {
+		fmt.Fprintln(os.Stderr, "keygen:", err)
+		os.Exit(1)
+	}
+}
+
+func generate(dir, alg, kid string) error {
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		key, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return fmt.Errorf("unknown algorithm %q", alg)
+	}
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, kid+".pem")
+	// O_EXCL keeps an existing key from being overwritten.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if err = pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
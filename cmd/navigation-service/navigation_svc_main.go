@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,6 +8,7 @@ import (
 	"syscall"
 
 	"california/internal/config"
+	"california/internal/jwks"
 	"california/pkg/navigationsvc"
 	"california/pkg/repository"
 	"github.com/go-kit/kit/log"
@@ -24,18 +24,22 @@ func main() {
 	cfg := config.NewConfig()
 
 	var svc navigationsvc.NavigationService
-	signingKey := os.Getenv("SECRET_KEY")
-	c := context.WithValue(context.Background(), "foo", "bar")
+	// The tokens are verified with the public keys of the auth service, so this service cannot issue any.
+	if cfg.AuthJWKSURL == "" {
+		logger.Log("component", "keys", "err", "AUTH_JWKS_URL is not set, so no access token could be verified")
+		os.Exit(1)
+	}
+	keys := jwks.NewClient(cfg.AuthJWKSURL)
 	{
-		store := repository.NewMongoStore(cfg)
+		store := repository.NewStore(cfg)
 		svc = navigationsvc.NewNavigationService(store)
-		svc = navigationsvc.AuthMiddleware(signingKey)(svc)
+		svc = navigationsvc.AuthorizationMiddleware()(svc)
 		svc = navigationsvc.LoggingMiddleware(logger)(svc)
 	}
 
 	var h http.Handler
 	{
-		h = navigationsvc.MakeHTTPHandler(c, svc, log.With(logger, "component", "HTTP"))
+		h = navigationsvc.MakeHTTPHandler(svc, keys.Keyfunc, log.With(logger, "component", "HTTP"))
 	}
 
 	errs := make(chan error)
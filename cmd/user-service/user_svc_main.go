@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,8 +8,12 @@ import (
 	"syscall"
 
 	"california/internal/config"
+	"california/internal/helpers"
+	"california/internal/jwks"
+	"california/pkg/mailer"
 	"california/pkg/repository"
 	"california/pkg/usersvc"
+	"california/pkg/vehiclecatalog"
 	"github.com/go-kit/kit/log"
 )
 
@@ -24,19 +27,39 @@ func main() {
 	}
 	cfg := config.NewConfig()
 
+	var catalog *vehiclecatalog.Catalog
+	if cfg.VehicleCatalogPath != "" {
+		var err error
+		if catalog, err = vehiclecatalog.Load(cfg.VehicleCatalogPath); err != nil {
+			logger.Log("component", "catalog", "path", cfg.VehicleCatalogPath, "err", err)
+			os.Exit(1)
+		}
+	}
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		logger.Log("component", "mailer", "err", err)
+		os.Exit(1)
+	}
+
+	keys, err := jwks.LoadKeyRing(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+	if err != nil {
+		logger.Log("component", "keys", "dir", cfg.JWTKeysDir, "err", err)
+		os.Exit(1)
+	}
+	helpers.UseSigningKeys(keys)
+
 	var svc usersvc.UserService
-	signingKey := os.Getenv("SECRET_KEY")
-	c := context.WithValue(context.Background(), "foo", "bar")
 	{
-		store := repository.NewMongoStore(cfg)
-		svc = usersvc.NewUserService(store)
-		svc = usersvc.AuthMiddleware(signingKey)(svc)
+		store := repository.NewStore(cfg)
+		svc = usersvc.NewUserService(store, catalog, mail, cfg.AppURL)
+		svc = usersvc.AuthorizationMiddleware()(svc)
 		svc = usersvc.LoggingMiddleware(logger)(svc)
 	}
 
 	var h http.Handler
 	{
-		h = usersvc.MakeHTTPHandler(c, svc, log.With(logger, "component", "HTTP"))
+		h = usersvc.MakeHTTPHandler(svc, keys.Keyfunc, log.With(logger, "component", "HTTP"))
 	}
 
 	errs := make(chan error)
@@ -8,17 +8,49 @@ import (
 )
 
 type Config struct {
+	StoreDriver  string // "mongo" (default) or "memory"
 	MongoDBUri   string
 	DatabaseName string
 
-	UsersCollectionName    string
-	StationsCollectionName string
-	SocketsCollectionName  string
+	UsersCollectionName         string
+	StationsCollectionName      string
+	SocketsCollectionName       string
+	RefreshTokensCollectionName string
+	ReservationsCollectionName  string
+	SessionsCollectionName      string
+	TariffsCollectionName       string
+	EnergyPricesCollectionName  string
+	ReviewsCollectionName       string
+	IssueReportsCollectionName  string
+	ImportJobsCollectionName    string
+	UserTokensCollectionName    string
+	LoginAttemptsCollectionName string
+	TransactionsCollectionName  string
 
 	UsersHttpAddr      string
 	StationsHttpAddr   string
 	NavigationHttpAddr string
 	AuthHttpAddr       string
+
+	VehicleCatalogPath string // A .json or .csv dataset; the catalog is empty when not set.
+
+	// The emails are sent through the SMTP server when SMTPHost is set. Otherwise they are written
+	// to MailFile, or to the standard error when MailLog is set, for local development.
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	MailFile     string
+	MailLog      bool
+	// AppURL is the address of the client the links of the emails point to.
+	AppURL string
+
+	// The auth and user services sign the access tokens with the private keys of JWTKeysDir, using the
+	// one of JWTSigningKeyID. The other services verify them with the keys published at AuthJWKSURL.
+	JWTKeysDir      string
+	JWTSigningKeyID string
+	AuthJWKSURL     string
 }
 
 func NewConfig() *Config {
@@ -27,17 +59,44 @@ func NewConfig() *Config {
 		fmt.Println("Error loading .env file")
 	}
 	return &Config{
+		StoreDriver:  os.Getenv("STORE_DRIVER"),
 		MongoDBUri:   os.Getenv("MONGO_DB_CONNECTION_URI"),
 		DatabaseName: os.Getenv("MONGO_DATABASE_NAME"),
 
-		UsersCollectionName:    os.Getenv("MONGO_USERS_COLLECTION_NAME"),
-		StationsCollectionName: os.Getenv("MONGO_STATIONS_COLLECTION_NAME"),
-		SocketsCollectionName:  os.Getenv("MONGO_SOCKETS_COLLECTION_NAME"),
+		UsersCollectionName:         os.Getenv("MONGO_USERS_COLLECTION_NAME"),
+		StationsCollectionName:      os.Getenv("MONGO_STATIONS_COLLECTION_NAME"),
+		SocketsCollectionName:       os.Getenv("MONGO_SOCKETS_COLLECTION_NAME"),
+		RefreshTokensCollectionName: os.Getenv("MONGO_REFRESH_TOKENS_COLLECTION_NAME"),
+		ReservationsCollectionName:  os.Getenv("MONGO_RESERVATIONS_COLLECTION_NAME"),
+		SessionsCollectionName:      os.Getenv("MONGO_SESSIONS_COLLECTION_NAME"),
+		TariffsCollectionName:       os.Getenv("MONGO_TARIFFS_COLLECTION_NAME"),
+		EnergyPricesCollectionName:  os.Getenv("MONGO_ENERGY_PRICES_COLLECTION_NAME"),
+		ReviewsCollectionName:       os.Getenv("MONGO_REVIEWS_COLLECTION_NAME"),
+		IssueReportsCollectionName:  os.Getenv("MONGO_ISSUE_REPORTS_COLLECTION_NAME"),
+		ImportJobsCollectionName:    os.Getenv("MONGO_IMPORT_JOBS_COLLECTION_NAME"),
+		UserTokensCollectionName:    os.Getenv("MONGO_USER_TOKENS_COLLECTION_NAME"),
+		LoginAttemptsCollectionName: os.Getenv("MONGO_LOGIN_ATTEMPTS_COLLECTION_NAME"),
+		TransactionsCollectionName:  os.Getenv("MONGO_TRANSACTIONS_COLLECTION_NAME"),
 
 		UsersHttpAddr:      os.Getenv("USER_HTTP_ADDRESS"),
 		StationsHttpAddr:   os.Getenv("STATIONS_HTTP_ADDRESS"),
 		NavigationHttpAddr: os.Getenv("NAVIGATION_HTTP_ADDRESS"),
 		AuthHttpAddr:       os.Getenv("AUTH_HTTP_ADDRESS"),
+
+		VehicleCatalogPath: os.Getenv("VEHICLE_CATALOG_PATH"),
+
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     os.Getenv("SMTP_PORT"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		MailFrom:     os.Getenv("MAIL_FROM"),
+		MailFile:     os.Getenv("MAIL_FILE"),
+		MailLog:      os.Getenv("MAIL_LOG") == "1",
+		AppURL:       os.Getenv("APP_URL"),
+
+		JWTKeysDir:      os.Getenv("JWT_KEYS_DIR"),
+		JWTSigningKeyID: os.Getenv("JWT_SIGNING_KEY_ID"),
+		AuthJWKSURL:     os.Getenv("AUTH_JWKS_URL"),
 	}
 
 }
@@ -1,19 +1,58 @@
 package helpers
 
 import (
-	"os"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"time"
 
+	"california/internal/jwks"
+	"california/pkg/auth"
+	"california/pkg/model"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(email, id string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"Email":  email,
-		"userId": id,
-		"exp":    time.Now().Add(time.Hour * 72).Unix(),
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// signingKeys sign the access tokens. Only the services that issue tokens have them.
+var signingKeys *jwks.KeyRing
+
+// UseSigningKeys makes GenerateToken sign with the active key of the ring.
+func UseSigningKeys(keys *jwks.KeyRing) {
+	signingKeys = keys
+}
+
+// GenerateToken returns a short-lived access token. Longer sessions are kept alive with refresh tokens.
+func GenerateToken(email, id string, userType model.UserType) (string, error) {
+	if signingKeys == nil {
+		return "", jwks.ErrNoSigningKey
+	}
+	return signingKeys.Sign(&auth.Claims{
+		Email:    email,
+		UserID:   id,
+		UserType: userType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+		},
 	})
+}
+
+// GenerateRefreshToken returns a random opaque token. It carries no claims and is only
+// meaningful together with the record stored for its hash.
+func GenerateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	tokenStr, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
-	return tokenStr, err
+// HashToken returns the hash an opaque token is stored and looked up by.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
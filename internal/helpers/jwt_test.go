@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"california/internal/jwks"
+	"california/pkg/auth"
+	"california/pkg/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useKey makes GenerateToken sign with a new key of the algorithm, until the test ends.
+func useKey(t *testing.T, alg string) *jwks.KeyRing {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, "k1.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := jwks.LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	UseSigningKeys(ring)
+	t.Cleanup(func() { UseSigningKeys(nil) })
+	return ring
+}
+
+func TestGenerateToken(t *testing.T) {
+	for _, alg := range jwks.Methods {
+		t.Run(alg, func(t *testing.T) {
+			ring := useKey(t, alg)
+			token, err := GenerateToken("driver@example.com", "64b7f0c2a1e4d3b2c1a09f8e", model.Normal)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+
+			ctx, err := auth.Authenticate(auth.WithToken(context.Background(), token), ring.Keyfunc)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if auth.Email(ctx) != "driver@example.com" || auth.UserID(ctx) != "64b7f0c2a1e4d3b2c1a09f8e" || auth.UserType(ctx) != model.Normal {
+				t.Errorf("claims = %q, %q, %d", auth.Email(ctx), auth.UserID(ctx), auth.UserType(ctx))
+			}
+			claims, _ := auth.ClaimsFrom(ctx)
+			if ttl := time.Until(claims.ExpiresAt.Time); ttl <= 0 || ttl > AccessTokenTTL {
+				t.Errorf("token expires in %v, want at most %v", ttl, AccessTokenTTL)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Method.Alg() != alg || parsed.Header["kid"] != "k1" {
+				t.Errorf("token signed with %s by %v, want %s by k1", parsed.Method.Alg(), parsed.Header["kid"], alg)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenWithoutKeys(t *testing.T) {
+	if _, err := GenerateToken("driver@example.com", "id", model.Normal); !errors.Is(err, jwks.ErrNoSigningKey) {
+		t.Errorf("GenerateToken: got %v, want %v", err, jwks.ErrNoSigningKey)
+	}
+}
+
+func TestGenerateRefreshToken(t *testing.T) {
+	a, err := GenerateRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("two refresh tokens are the same")
+	}
+	if HashToken(a) != HashToken(a) || HashToken(a) == HashToken(b) {
+		t.Error("HashToken does not identify the tokens")
+	}
+}
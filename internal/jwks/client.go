@@ -0,0 +1,120 @@
+package jwks
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// cacheTTL is how long the fetched keys are used before they are fetched again.
+	cacheTTL = 10 * time.Minute
+	// minRefreshInterval limits the fetches caused by tokens with unknown kids, which anybody can forge.
+	minRefreshInterval = 30 * time.Second
+	fetchTimeout       = 5 * time.Second
+)
+
+// Client verifies tokens with the public keys fetched from the key set URL of the auth service, which it caches.
+// When a fetch fails, the keys fetched before are still used.
+type Client struct {
+	url  string
+	http *http.Client
+	// fetches lets the callers meeting an unknown kid at the same time wait for a single fetch.
+	fetches singleflight.Group
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+func NewClient(url string) *Client {
+	return &Client{
+		url:  url,
+		http: &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+// Keyfunc returns the public key the token was signed with.
+func (c *Client) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key, err := c.key(kidOf(token))
+	if err != nil {
+		return nil, err
+	}
+	return verificationKey(token, key)
+}
+
+// key returns the public key of the kid. The keys are fetched without holding the lock, so that a slow fetch
+// only holds up the tokens of a kid which is not known yet; an expired cache is refreshed in the background.
+func (c *Client) key(kid string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > cacheTTL
+	c.mu.RUnlock()
+
+	switch {
+	case ok && stale:
+		c.fetches.DoChan("", c.refresh)
+	case !ok:
+		c.fetches.Do("", c.refresh)
+		c.mu.RLock()
+		key, ok = c.keys[kid]
+		c.mu.RUnlock()
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh fetches the keys again, unless they were tried less than minRefreshInterval ago.
+func (c *Client) refresh() (interface{}, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if now.Sub(c.triedAt) < minRefreshInterval {
+		c.mu.Unlock()
+		return nil, nil
+	}
+	c.triedAt = now
+	c.mu.Unlock()
+
+	keys, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = now
+	c.mu.Unlock()
+	return nil, nil
+}
+
+func (c *Client) fetch() (map[string]crypto.PublicKey, error) {
+	res, err := c.http.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", c.url, res.Status)
+	}
+
+	var set Set
+	if err = json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	// Keys of other types are skipped, as they cannot have signed our tokens.
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,136 @@
+// Package jwks signs the access tokens with asymmetric keys and publishes their public halves as a JSON Web Key Set,
+// which the services verify the tokens with.
+//
+// A key is identified by its kid, which is put in the header of the tokens it signs. Keys are rotated without
+// invalidating live tokens:
+//
+//  1. Add the new key to the keys directory and restart the auth service, which publishes both keys.
+//  2. Set JWT_SIGNING_KEY_ID to the new kid and restart the services that sign tokens.
+//  3. Remove the old key once the last token it signed has expired, after helpers.AccessTokenTTL.
+//
+// Services verifying with a Client fetch the key set again when they meet a kid they do not know.
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey              = errors.New("unknown signing key")
+	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+	ErrUnsupportedKey          = errors.New("unsupported key type")
+)
+
+// Methods are the algorithms tokens may be signed with.
+var Methods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// Set is a JSON Web Key Set, as served at /.well-known/jwks.json.
+type Set struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the public key of an RSA or Ed25519 key pair.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// NewJWK returns the JWK of the public key.
+func NewJWK(kid string, key crypto.PublicKey) (JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	}
+	return JWK{}, ErrUnsupportedKey
+}
+
+// PublicKey returns the public key of the JWK.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %s: invalid RSA key", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.Kid, err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %s: invalid Ed25519 key", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// methodOf returns the signing method of the key, either public or private.
+func methodOf(key interface{}) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// verificationKey returns the key to verify the token with, after checking that the token was signed with the
+// algorithm of the key, so that a public key is never used as the secret of another algorithm.
+func verificationKey(token *jwt.Token, key crypto.PublicKey) (interface{}, error) {
+	method, err := methodOf(key)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return key, nil
+}
+
+func kidOf(token *jwt.Token) string {
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
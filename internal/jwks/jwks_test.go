@@ -0,0 +1,456 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey writes a new private key of the algorithm to <dir>/<kid>.pem, the way cmd/keygen does.
+func writeKey(t *testing.T, dir, kid, alg string) {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		key, err = rsa.GenerateKey(rand.Reader, minRSABits)
+	default:
+		t.Fatalf("unknown algorithm %q", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, kid+".pem"), "PRIVATE KEY", der)
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadRing(t *testing.T, dir, activeID string) *KeyRing {
+	t.Helper()
+	ring, err := LoadKeyRing(dir, activeID)
+	if err != nil {
+		t.Fatalf("LoadKeyRing: %v", err)
+	}
+	return ring
+}
+
+// keySet serves a key set, which the test may replace, and counts its fetches.
+type keySet struct {
+	mu      sync.Mutex
+	set     *Set
+	fetches atomic.Int32
+}
+
+func (s *keySet) use(t *testing.T, ring *KeyRing) {
+	t.Helper()
+	set, err := ring.Set()
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set = set
+}
+
+func (s *keySet) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	json.NewEncoder(w).Encode(s.set)
+}
+
+// verifiers returns the ways a service verifies the tokens of the ring: with the ring itself, with the key set
+// it publishes, and with a client fetching that key set.
+func verifiers(t *testing.T, ring *KeyRing) map[string]jwt.Keyfunc {
+	t.Helper()
+	set, err := ring.Set()
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	// The key set goes through JSON, as it does when it is served.
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var published Set
+	if err = json.Unmarshal(b, &published); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &keySet{}
+	server.use(t, ring)
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	return map[string]jwt.Keyfunc{
+		"ring":   ring.Keyfunc,
+		"set":    setKeyfunc(&published),
+		"client": NewClient(srv.URL).Keyfunc,
+	}
+}
+
+func setKeyfunc(set *Set) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		for _, jwk := range set.Keys {
+			if jwk.Kid != kidOf(token) {
+				continue
+			}
+			key, err := jwk.PublicKey()
+			if err != nil {
+				return nil, err
+			}
+			return verificationKey(token, key)
+		}
+		return nil, ErrUnknownKey
+	}
+}
+
+func claims() jwt.Claims {
+	return jwt.RegisteredClaims{
+		Subject:   "driver",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, alg := range Methods {
+		t.Run(alg, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKey(t, dir, "k1", alg)
+			ring := loadRing(t, dir, "")
+			if ring.ActiveID() != "k1" {
+				t.Fatalf("ActiveID = %q, want k1", ring.ActiveID())
+			}
+			signed, err := ring.Sign(claims())
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			for name, keyfunc := range verifiers(t, ring) {
+				t.Run(name, func(t *testing.T) {
+					var got jwt.RegisteredClaims
+					token, err := jwt.ParseWithClaims(signed, &got, keyfunc, jwt.WithValidMethods(Methods))
+					if err != nil {
+						t.Fatalf("Parse: %v", err)
+					}
+					if token.Method.Alg() != alg || kidOf(token) != "k1" {
+						t.Errorf("token signed with %s by %q, want %s by k1", token.Method.Alg(), kidOf(token), alg)
+					}
+					if got.Subject != "driver" {
+						t.Errorf("subject = %q, want driver", got.Subject)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	signWith := func(kid, alg string) func(t *testing.T) string {
+		return func(t *testing.T) string {
+			dir := t.TempDir()
+			writeKey(t, dir, kid, alg)
+			signed, err := loadRing(t, dir, kid).Sign(claims())
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			return signed
+		}
+	}
+	tests := []struct {
+		name    string
+		ringAlg string
+		sign    func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name:    "unknown kid",
+			ringAlg: "RS256",
+			sign:    signWith("k2", "RS256"),
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "EdDSA token for an RSA key",
+			ringAlg: "RS256",
+			sign:    signWith("k1", "EdDSA"),
+			wantErr: ErrUnexpectedSigningMethod,
+		},
+		{
+			name:    "RS256 token for an Ed25519 key",
+			ringAlg: "EdDSA",
+			sign:    signWith("k1", "RS256"),
+			wantErr: ErrUnexpectedSigningMethod,
+		},
+		{
+			name:    "HS256 token with the public key as secret",
+			ringAlg: "RS256",
+			wantErr: ErrUnexpectedSigningMethod,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKey(t, dir, "k1", tt.ringAlg)
+			ring := loadRing(t, dir, "k1")
+
+			var signed string
+			if tt.sign != nil {
+				signed = tt.sign(t)
+			} else {
+				// The public key is known to anybody, so it must not be accepted as an HMAC secret.
+				public, err := x509.MarshalPKIXPublicKey(ring.keys["k1"].Public())
+				if err != nil {
+					t.Fatal(err)
+				}
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+				token.Header["kid"] = "k1"
+				if signed, err = token.SignedString(public); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for name, keyfunc := range verifiers(t, ring) {
+				t.Run(name, func(t *testing.T) {
+					// The methods are not restricted, so that the key function alone has to refuse the token.
+					_, err := jwt.Parse(signed, keyfunc)
+					if !errors.Is(err, tt.wantErr) {
+						t.Errorf("Parse: got %v, want %v", err, tt.wantErr)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "old", "RS256")
+	signed, err := loadRing(t, dir, "").Sign(claims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// The new key is added and made active: the tokens of the old one stay valid until it is removed.
+	writeKey(t, dir, "new", "EdDSA")
+	ring := loadRing(t, dir, "new")
+	if ring.ActiveID() != "new" {
+		t.Fatalf("ActiveID = %q, want new", ring.ActiveID())
+	}
+	set, err := ring.Set()
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if len(set.Keys) != 2 || set.Keys[0].Kid != "new" || set.Keys[1].Kid != "old" {
+		t.Fatalf("Set = %+v, want the keys new and old", set.Keys)
+	}
+	for name, keyfunc := range verifiers(t, ring) {
+		if _, err := jwt.Parse(signed, keyfunc, jwt.WithValidMethods(Methods)); err != nil {
+			t.Errorf("%s: token of the key rotated out: %v", name, err)
+		}
+	}
+
+	if err = os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatal(err)
+	}
+	ring = loadRing(t, dir, "new")
+	if _, err = jwt.Parse(signed, ring.Keyfunc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of the removed key: got %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestClientFetchesNewKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "old", "EdDSA")
+	server := &keySet{}
+	server.use(t, loadRing(t, dir, ""))
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	oldToken, err := loadRing(t, dir, "").Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.Parse(oldToken, client.Keyfunc); err != nil {
+		t.Fatalf("token of the old key: %v", err)
+	}
+	if _, err = jwt.Parse(oldToken, client.Keyfunc); err != nil {
+		t.Fatalf("token of the old key again: %v", err)
+	}
+	if n := server.fetches.Load(); n != 1 {
+		t.Fatalf("%d fetches, want the keys to be cached after the first one", n)
+	}
+
+	writeKey(t, dir, "new", "EdDSA")
+	ring := loadRing(t, dir, "new")
+	server.use(t, ring)
+	newToken, err := ring.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The keys were just fetched, so the unknown kid does not cause another fetch yet.
+	if _, err = jwt.Parse(newToken, client.Keyfunc); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("token of the new key right after a fetch: got %v, want %v", err, ErrUnknownKey)
+	}
+
+	client.triedAt = time.Now().Add(-minRefreshInterval)
+	if _, err = jwt.Parse(newToken, client.Keyfunc); err != nil {
+		t.Fatalf("token of the new key: %v", err)
+	}
+	if n := server.fetches.Load(); n != 2 {
+		t.Errorf("%d fetches, want 2", n)
+	}
+}
+
+func TestClientVerifiesCachedKeysDuringFetch(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "old", "EdDSA")
+	ring := loadRing(t, dir, "")
+	server := &keySet{}
+	server.use(t, ring)
+	release := make(chan struct{})
+	var slow atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			<-release
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	defer close(release)
+	client := NewClient(srv.URL)
+
+	oldToken, err := ring.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.Parse(oldToken, client.Keyfunc); err != nil {
+		t.Fatalf("token of the old key: %v", err)
+	}
+
+	// A token of an unknown kid makes the client fetch the keys again, from a server which does not answer.
+	writeKey(t, dir, "new", "EdDSA")
+	newToken, err := loadRing(t, dir, "new").Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow.Store(true)
+	client.mu.Lock()
+	client.triedAt = time.Time{}
+	client.mu.Unlock()
+	fetching := make(chan struct{})
+	go func() {
+		defer close(fetching)
+		jwt.Parse(newToken, client.Keyfunc)
+	}()
+	for server.fetches.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	verified := make(chan error, 1)
+	go func() {
+		_, err := jwt.Parse(oldToken, client.Keyfunc)
+		verified <- err
+	}()
+	select {
+	case err = <-verified:
+		if err != nil {
+			t.Errorf("token of the cached key during a fetch: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the token of a cached key waited for the fetch of the keys")
+	}
+	release <- struct{}{}
+	<-fetching
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, dir string)
+		activeID string
+		wantErr  error
+	}{
+		{
+			name:    "empty directory",
+			setup:   func(t *testing.T, dir string) {},
+			wantErr: ErrNoSigningKey,
+		},
+		{
+			name: "several keys and no active one",
+			setup: func(t *testing.T, dir string) {
+				writeKey(t, dir, "k1", "EdDSA")
+				writeKey(t, dir, "k2", "EdDSA")
+			},
+			wantErr: ErrNoSigningKey,
+		},
+		{
+			name:     "active key is missing",
+			setup:    func(t *testing.T, dir string) { writeKey(t, dir, "k1", "EdDSA") },
+			activeID: "k2",
+			wantErr:  ErrNoSigningKey,
+		},
+		{
+			name: "PKCS1 RSA key",
+			setup: func(t *testing.T, dir string) {
+				key, err := rsa.GenerateKey(rand.Reader, minRSABits)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writePEM(t, filepath.Join(dir, "k1.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+			_, err := LoadKeyRing(dir, tt.activeID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("LoadKeyRing: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadKeyRingRejectsSmallRSAKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writePEM(t, filepath.Join(dir, "k1.pem"), "PRIVATE KEY", der)
+	if _, err = LoadKeyRing(dir, ""); err == nil {
+		t.Error("LoadKeyRing accepted an RSA key of 1024 bits")
+	}
+}
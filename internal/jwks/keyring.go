@@ -0,0 +1,140 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key accepted for signing.
+const minRSABits = 2048
+
+var ErrNoSigningKey = errors.New("no signing key")
+
+// KeyRing holds the private keys of the services that sign tokens. It signs with its active key and verifies
+// with any of its keys.
+type KeyRing struct {
+	keys   map[string]crypto.Signer
+	active string
+}
+
+// LoadKeyRing reads the keys of the directory, one PEM encoded RSA or Ed25519 private key per <kid>.pem file.
+// The active key is the one with the given kid, which may be empty when the directory holds a single key.
+func LoadKeyRing(dir, activeID string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	r := &KeyRing{keys: make(map[string]crypto.Signer)}
+	for _, path := range paths {
+		key, err := readPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		r.keys[strings.TrimSuffix(filepath.Base(path), ".pem")] = key
+	}
+	if len(r.keys) == 0 {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNoSigningKey)
+	}
+
+	if activeID == "" && len(r.keys) == 1 {
+		for kid := range r.keys {
+			activeID = kid
+		}
+	}
+	if _, ok := r.keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in %s: %w", activeID, dir, ErrNoSigningKey)
+	}
+	r.active = activeID
+	return r, nil
+}
+
+// ActiveID returns the kid of the key tokens are signed with.
+func (r *KeyRing) ActiveID() string {
+	return r.active
+}
+
+// Sign returns the token of the claims signed with the active key.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	key := r.keys[r.active]
+	method, err := methodOf(key)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = r.active
+	return token.SignedString(key)
+}
+
+// Keyfunc returns the public key of the ring the token was signed with.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key, ok := r.keys[kidOf(token)]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return verificationKey(token, key.Public())
+}
+
+// Set returns the public keys of the ring, sorted by kid.
+func (r *KeyRing) Set() (*Set, error) {
+	kids := make([]string, 0, len(r.keys))
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := &Set{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		jwk, err := NewJWK(kid, r.keys[kid].Public())
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key of %d bits, at least %d are needed", k.N.BitLen(), minRSABits)
+		}
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	}
+	return nil, ErrUnsupportedKey
+}
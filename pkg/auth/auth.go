@@ -0,0 +1,136 @@
+// Package auth authenticates the requests of every service with the access tokens issued at login, and
+// authorizes them by the type of their user.
+//
+// The token is taken from the Authorization header by HTTPToContext, verified by the NewParser endpoint
+// middleware, and its claims are read from the context of the request with ClaimsFrom, UserID, Email and UserType.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"california/internal/jwks"
+	"california/pkg/model"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoAuthTokenHeader = errors.New("no auth token in the header")
+	ErrInvalidToken      = errors.New("invalid token")
+	ErrForbidden         = errors.New("permission denied")
+)
+
+// Claims are the claims of an access token.
+type Claims struct {
+	Email    string         `json:"Email"`
+	UserID   string         `json:"userId"`
+	UserType model.UserType `json:"userType"`
+	jwt.RegisteredClaims
+}
+
+type contextKey int
+
+const (
+	tokenKey contextKey = iota
+	claimsKey
+)
+
+// HTTPToContext puts the bearer token of the Authorization header into the context. It is a go-kit request
+// function, given to the servers with httptransport.ServerBefore.
+func HTTPToContext(ctx context.Context, r *http.Request) context.Context {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return ctx
+	}
+	return WithToken(ctx, strings.TrimPrefix(header, "Bearer "))
+}
+
+// WithToken returns a copy of the context carrying the token.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey, token)
+}
+
+// TokenFrom returns the token of the context.
+func TokenFrom(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenKey).(string)
+	return token, ok && token != ""
+}
+
+// WithClaims returns a copy of the context carrying the claims of an authenticated user.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFrom returns the claims of the authenticated user of the context.
+func ClaimsFrom(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+// UserID returns the id of the authenticated user, or "" when the context is not authenticated.
+func UserID(ctx context.Context) string {
+	if claims, ok := ClaimsFrom(ctx); ok {
+		return claims.UserID
+	}
+	return ""
+}
+
+// Email returns the email of the authenticated user, or "" when the context is not authenticated.
+func Email(ctx context.Context) string {
+	if claims, ok := ClaimsFrom(ctx); ok {
+		return claims.Email
+	}
+	return ""
+}
+
+// UserType returns the type of the authenticated user, or zero when the context is not authenticated.
+func UserType(ctx context.Context) model.UserType {
+	if claims, ok := ClaimsFrom(ctx); ok {
+		return claims.UserType
+	}
+	return 0
+}
+
+// Authenticate verifies the token of the context with the keys and returns a copy of the context carrying its claims.
+func Authenticate(ctx context.Context, keys jwt.Keyfunc) (context.Context, error) {
+	tokenString, ok := TokenFrom(ctx)
+	if !ok {
+		return nil, ErrNoAuthTokenHeader
+	}
+	// The token must be signed by the key of its kid, with the algorithm of that key.
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys, jwt.WithValidMethods(jwks.Methods))
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return WithClaims(ctx, claims), nil
+}
+
+// NewParser returns an endpoint middleware which authenticates the requests with the keys before they reach
+// the endpoint.
+func NewParser(keys jwt.Keyfunc) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, err := Authenticate(ctx, keys)
+			if err != nil {
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// Authorize returns ErrForbidden unless the authenticated user has one of the allowed user types.
+// Tokens issued before user types were added to the claims carry a zero type and are not granted any role.
+func Authorize(ctx context.Context, allowed ...model.UserType) error {
+	userType := UserType(ctx)
+	for _, t := range allowed {
+		if userType != 0 && userType == t {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
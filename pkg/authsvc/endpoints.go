@@ -3,11 +3,17 @@ package authsvc
 import (
 	"context"
 
+	"california/internal/jwks"
+	"california/pkg/auth"
+	"california/pkg/model"
 	"github.com/go-kit/kit/endpoint"
 )
 
 type Endpoints struct {
 	AuthenticateEndpoint endpoint.Endpoint
+	RefreshTokenEndpoint endpoint.Endpoint
+	LogoutEndpoint       endpoint.Endpoint
+	KeysEndpoint         endpoint.Endpoint
 }
 
 type BaseResponse struct {
@@ -15,18 +21,19 @@ type BaseResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-func MakeServerEndpoints(c context.Context, s AuthService) Endpoints {
+func MakeServerEndpoints(s AuthService) Endpoints {
 	return Endpoints{
-		AuthenticateEndpoint: MakeAuthenticateEndpoint(c, s),
+		AuthenticateEndpoint: MakeAuthenticateEndpoint(s),
+		RefreshTokenEndpoint: MakeRefreshTokenEndpoint(s),
+		LogoutEndpoint:       MakeLogoutEndpoint(s),
+		KeysEndpoint:         MakeKeysEndpoint(s),
 	}
 }
 
-func MakeAuthenticateEndpoint(ctx context.Context, s AuthService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(authenticateRequest)
-		jwt := req.Context.Value("jwt")
-		ctx = context.WithValue(ctx, "Authorization", jwt)
+func MakeAuthenticateEndpoint(s AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		e := s.Authenticate(ctx)
+		token, _ := auth.TokenFrom(ctx)
 		if e != nil {
 			return authenticateResponse{
 				Err: e,
@@ -35,7 +42,7 @@ func MakeAuthenticateEndpoint(ctx context.Context, s AuthService) endpoint.Endpo
 		return BaseResponse{
 			Message: "success",
 			Data: authenticateResponse{
-				Token: jwt.(string),
+				Token: token,
 				Err:   e,
 			},
 		}, nil
@@ -43,8 +50,7 @@ func MakeAuthenticateEndpoint(ctx context.Context, s AuthService) endpoint.Endpo
 }
 
 type authenticateRequest struct {
-	Context context.Context
-	Token   string `json:"token"`
+	Token string `json:"token"`
 }
 
 type authenticateResponse struct {
@@ -54,3 +60,85 @@ type authenticateResponse struct {
 }
 
 func (e authenticateResponse) error() error { return e.Err }
+
+func MakeRefreshTokenEndpoint(s AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(refreshTokenRequest)
+		tokens, e := s.RefreshToken(ctx, req.RefreshToken)
+		if e != nil {
+			return refreshTokenResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: refreshTokenResponse{
+				TokenPair: tokens,
+				Err:       e,
+			},
+		}, nil
+	}
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type refreshTokenResponse struct {
+	*BaseResponse
+	*model.TokenPair
+	Err error `json:"err,omitempty"`
+}
+
+func (e refreshTokenResponse) error() error { return e.Err }
+
+func MakeLogoutEndpoint(s AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(logoutRequest)
+		e := s.Logout(ctx, req.RefreshToken)
+		if e != nil {
+			return logoutResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: logoutResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type logoutResponse struct {
+	*BaseResponse
+	Err error `json:"err,omitempty"`
+}
+
+func (e logoutResponse) error() error { return e.Err }
+
+// MakeKeysEndpoint returns the key set itself rather than a BaseResponse, as JWKS clients expect it.
+func MakeKeysEndpoint(s AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		set, e := s.Keys(ctx)
+		if e != nil {
+			return keysResponse{
+				Err: e,
+			}, e
+		}
+		return keysResponse{
+			Set: set,
+		}, nil
+	}
+}
+
+type keysResponse struct {
+	*jwks.Set
+	Err error `json:"-"`
+}
+
+func (e keysResponse) error() error { return e.Err }
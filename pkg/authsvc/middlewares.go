@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"california/internal/jwks"
+	"california/pkg/model"
 	"github.com/go-kit/kit/log"
 )
 
@@ -32,3 +34,33 @@ func (mw loggingMiddleware) Authenticate(ctx context.Context) (err error) {
 	}(time.Now())
 	return mw.next.Authenticate(ctx)
 }
+
+func (mw loggingMiddleware) RefreshToken(ctx context.Context, refreshToken string) (tokens *model.TokenPair, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "RefreshToken",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.RefreshToken(ctx, refreshToken)
+}
+
+func (mw loggingMiddleware) Logout(ctx context.Context, refreshToken string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Logout",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.Logout(ctx, refreshToken)
+}
+
+func (mw loggingMiddleware) Keys(ctx context.Context) (set *jwks.Set, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Keys",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.Keys(ctx)
+}
@@ -3,66 +3,102 @@ package authsvc
 import (
 	"context"
 	"errors"
-	"strings"
+	"time"
 
+	"california/internal/helpers"
+	"california/internal/jwks"
+	"california/pkg/auth"
+	"california/pkg/model"
 	"california/pkg/repository"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthService interface {
 	Authenticate(ctx context.Context) error
+
+	// RefreshToken rotates the given refresh token and returns a new token pair.
+	RefreshToken(ctx context.Context, refreshToken string) (*model.TokenPair, error)
+
+	// Logout revokes the given refresh token together with every token rotated from the same login.
+	Logout(ctx context.Context, refreshToken string) error
+
+	// Keys returns the public keys the access tokens are verified with, including the ones being rotated out.
+	Keys(ctx context.Context) (*jwks.Set, error)
 }
 
 var (
-	ErrAuthentication          = errors.New("authentication failed")
-	ErrNoAuthTokenHeader       = errors.New("no auth token in the header")
-	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
-	ErrInvalidToken            = errors.New("invalid token")
+	ErrAuthentication      = errors.New("authentication failed")
+	ErrNoAuthTokenHeader   = auth.ErrNoAuthTokenHeader
+	ErrInvalidToken        = auth.ErrInvalidToken
+	ErrForbidden           = auth.ErrForbidden
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused, session revoked")
 )
 
 type authService struct {
-	signingKey string
-	store      repository.Store
+	keys  *jwks.KeyRing
+	store repository.Store
 }
 
-func NewAuthService(store repository.Store, signingKey string) AuthService {
+func NewAuthService(store repository.Store, keys *jwks.KeyRing) AuthService {
 	return &authService{
-		signingKey: signingKey,
-		store:      store,
+		keys:  keys,
+		store: store,
 	}
 }
 
 func (s *authService) Authenticate(ctx context.Context) error {
-	_, err := isAuthenticated(ctx, s.signingKey)
+	_, err := auth.Authenticate(ctx, s.keys.Keyfunc)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func isAuthenticated(ctx context.Context, signingKey string) (context.Context, error) {
-	// Extract the JWT token from the request header and validate it.
-	tokenString := ctx.Value("Authorization").(string)
-	if tokenString == "" {
-		return nil, ErrNoAuthTokenHeader
+func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	token, err := s.store.GetRefreshTokenByHash(ctx, helpers.HashToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
 	}
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the token algorithm is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrUnexpectedSigningMethod
+
+	// A revoked token being presented again means it was stolen, or the legitimate
+	// client was raced by whoever holds a copy. Either way the whole session goes.
+	revoked, err := s.store.RevokeRefreshToken(ctx, token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		if err = s.store.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+			return nil, err
 		}
-		return []byte(signingKey), nil
-	})
+		return nil, ErrRefreshTokenReused
+	}
+
+	user, err := s.store.GetUserById(ctx, token.UserID.Hex())
 	if err != nil {
-		return nil, ErrInvalidToken
+		return nil, ErrInvalidRefreshToken
+	}
+	// The sessions of the users who must have a second factor but have not enrolled yet are not extended,
+	// so they log in again and enrol.
+	if user.RequiresTwoFactor() && !user.TwoFactorEnabled() {
+		return nil, ErrInvalidRefreshToken
 	}
+	return IssueTokenPair(ctx, s.store, user, token.DeviceID, token.FamilyID)
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		ctx = context.WithValue(ctx, "email", claims["Email"])
-		ctx = context.WithValue(ctx, "userId", claims["userId"])
-		return ctx, nil
+func (s *authService) Logout(ctx context.Context, refreshToken string) error {
+	token, err := s.store.GetRefreshTokenByHash(ctx, helpers.HashToken(refreshToken))
+	if err != nil {
+		return ErrInvalidRefreshToken
 	}
-	return nil, ErrInvalidToken
+	if err = s.store.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *authService) Keys(ctx context.Context) (*jwks.Set, error) {
+	return s.keys.Set()
 }
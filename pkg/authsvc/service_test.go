@@ -0,0 +1,159 @@
+package authsvc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"california/internal/helpers"
+	"california/internal/jwks"
+	"california/pkg/auth"
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newLogin returns a service over a memory store signing with a new key, and the tokens of a user who logged in.
+func newLogin(t *testing.T) (AuthService, repository.Store, *model.TokenPair) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, "k1.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := jwks.LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	helpers.UseSigningKeys(ring)
+	t.Cleanup(func() { helpers.UseSigningKeys(nil) })
+
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	user, err := store.InsertUser(ctx, &model.User{ID: primitive.NewObjectID(), Email: "driver@example.com", UserType: model.Normal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := IssueTokenPair(ctx, store, user, "phone", primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthService(store, ring), store, tokens
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	s, store, tokens := newLogin(t)
+	ctx := context.Background()
+
+	rotated, err := s.RefreshToken(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if rotated.RefreshToken == tokens.RefreshToken {
+		t.Error("the refresh token was not rotated")
+	}
+	if err = s.Authenticate(auth.WithToken(ctx, rotated.AccessToken)); err != nil {
+		t.Errorf("Authenticate with the new access token: %v", err)
+	}
+
+	old, err := store.GetRefreshTokenByHash(ctx, helpers.HashToken(tokens.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := store.GetRefreshTokenByHash(ctx, helpers.HashToken(rotated.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.RevokedAt == nil {
+		t.Error("the rotated refresh token was not revoked")
+	}
+	if current.RevokedAt != nil || current.FamilyID != old.FamilyID || current.DeviceID != "phone" {
+		t.Errorf("new refresh token = %+v, want an active one in family %s", current, old.FamilyID.Hex())
+	}
+}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	s, store, tokens := newLogin(t)
+	ctx := context.Background()
+	rotated, err := s.RefreshToken(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.RefreshToken(ctx, tokens.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RefreshToken with a rotated token: got %v, want %v", err, ErrRefreshTokenReused)
+	}
+	current, err := store.GetRefreshTokenByHash(ctx, helpers.HashToken(rotated.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.RevokedAt == nil {
+		t.Error("the reuse did not revoke the rest of the family")
+	}
+	if _, err = s.RefreshToken(ctx, rotated.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("RefreshToken after the reuse: got %v, want %v", err, ErrRefreshTokenReused)
+	}
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	s, store, _ := newLogin(t)
+	ctx := context.Background()
+	expired := &model.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    primitive.NewObjectID(),
+		FamilyID:  primitive.NewObjectID(),
+		TokenHash: helpers.HashToken("expired"),
+		CreatedAt: time.Now().Add(-helpers.RefreshTokenTTL - time.Hour),
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}
+	if err := store.InsertRefreshToken(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RefreshToken(ctx, "expired"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshToken with an expired token: got %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := s.RefreshToken(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshToken with an unknown token: got %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s, store, tokens := newLogin(t)
+	ctx := context.Background()
+	rotated, err := s.RefreshToken(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Logout(ctx, rotated.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	current, err := store.GetRefreshTokenByHash(ctx, helpers.HashToken(rotated.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.RevokedAt == nil {
+		t.Error("Logout did not revoke the refresh token")
+	}
+	if _, err = s.RefreshToken(ctx, rotated.RefreshToken); err == nil {
+		t.Error("RefreshToken after the logout issued new tokens")
+	}
+	if err = s.Logout(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Logout with an unknown token: got %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,49 @@
+package authsvc
+
+import (
+	"context"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IssueTokenPair signs a new access token for the user and stores a new refresh token for the device.
+// A zero familyId starts a new family, which is what a login does; rotations keep the family of the
+// refresh token they replace.
+func IssueTokenPair(ctx context.Context, store repository.Store, user *model.User, deviceId string, familyId primitive.ObjectID) (*model.TokenPair, error) {
+	accessToken, err := helpers.GenerateToken(user.Email, user.ID.Hex(), user.UserType)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := helpers.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if familyId.IsZero() {
+		familyId = primitive.NewObjectID()
+	}
+	now := time.Now()
+	err = store.InsertRefreshToken(ctx, &model.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		FamilyID:  familyId,
+		TokenHash: helpers.HashToken(refreshToken),
+		DeviceID:  deviceId,
+		CreatedAt: now,
+		ExpiresAt: now.Add(helpers.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(helpers.AccessTokenTTL.Seconds()),
+	}, nil
+}
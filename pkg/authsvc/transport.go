@@ -4,19 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
-	"strings"
+	"time"
 
+	"california/pkg/auth"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
 )
 
-func MakeAuthHTTPHandler(c context.Context, s AuthService, log log.Logger) http.Handler {
+func MakeAuthHTTPHandler(s AuthService, log log.Logger) http.Handler {
 	r := mux.NewRouter()
-	e := MakeServerEndpoints(c, s)
+	e := MakeServerEndpoints(s)
 	options := []httptransport.ServerOption{
+		httptransport.ServerBefore(auth.HTTPToContext),
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(log)),
 		httptransport.ServerErrorEncoder(encodeError),
 	}
@@ -28,22 +31,78 @@ func MakeAuthHTTPHandler(c context.Context, s AuthService, log log.Logger) http.
 		encodeResponse,
 		options...,
 	))
+
+	// POST /token/refresh rotates a refresh token and returns a new token pair.
+	r.Methods("POST").Path("/token/refresh").Handler(httptransport.NewServer(
+		e.RefreshTokenEndpoint,
+		decodeRefreshTokenRequest,
+		encodeResponse,
+		options...,
+	))
+
+	// POST /logout revokes a refresh token and every token of the same login.
+	r.Methods("POST").Path("/logout").Handler(httptransport.NewServer(
+		e.LogoutEndpoint,
+		decodeLogoutRequest,
+		encodeResponse,
+		options...,
+	))
+
+	// GET /.well-known/jwks.json returns the public keys the access tokens are verified with.
+	r.Methods("GET").Path("/.well-known/jwks.json").Handler(httptransport.NewServer(
+		e.KeysEndpoint,
+		decodeKeysRequest,
+		encodeKeysResponse,
+		options...,
+	))
 	return r
 }
 
-func decodeAuthenticateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
+// decodeAuthenticateRequest has nothing to decode, the token is put into the context by auth.HTTPToContext.
+func decodeAuthenticateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return authenticateRequest{}, nil
+}
+
+func decodeRefreshTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req refreshTokenRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	if req.RefreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	return req, nil
+}
+
+func decodeLogoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req logoutRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	if req.RefreshToken == "" {
+		return nil, ErrInvalidRefreshToken
 	}
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	//c := context.WithValue(r.Context(), "jwt", jwtToken)
-	var req authenticateRequest
-	req.Context = ctx
 	return req, nil
 }
 
+func decodeKeysRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+// keysMaxAge is how long clients may cache the key set. Keys are published before they sign any token,
+// and clients fetch the set again when they meet an unknown kid.
+const keysMaxAge = 5 * time.Minute
+
+func encodeKeysResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		encodeError(ctx, e.error(), w)
+		return nil
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(keysMaxAge.Seconds())))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
 type errorer interface {
 	error() error
 }
@@ -79,10 +138,12 @@ func codeFrom(err error) int {
 		return http.StatusUnauthorized // 401
 	case errors.Is(err, ErrNoAuthTokenHeader):
 		return http.StatusUnauthorized
-	case errors.Is(err, ErrUnexpectedSigningMethod):
-		return http.StatusUnauthorized
 	case errors.Is(err, ErrInvalidToken):
 		return http.StatusUnauthorized
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden // 403
 	default:
 		return http.StatusInternalServerError // 500
 	}
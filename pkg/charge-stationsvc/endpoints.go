@@ -2,14 +2,20 @@ package charge_stationsvc
 
 import (
 	"context"
+	"io"
+	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
+	"california/pkg/repository"
 	"github.com/go-kit/kit/endpoint"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type BaseResponse struct {
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"` // Asks for the next page of a list as its after parameter.
 }
 
 type StationEndpoints struct {
@@ -24,31 +30,82 @@ type StationEndpoints struct {
 	ListSocketsEndpoint       endpoint.Endpoint
 	FilterStationsEndpoint    endpoint.Endpoint
 	DeleteSocketEndpoint      endpoint.Endpoint
+	ChargePointKeyEndpoint    endpoint.Endpoint
+	NearbyStationsEndpoint    endpoint.Endpoint
+	StreamStationsEndpoint    endpoint.Endpoint
+	StreamTicketEndpoint      endpoint.Endpoint
+	ReserveSocketEndpoint     endpoint.Endpoint
+	CancelReservationEndpoint endpoint.Endpoint
+	MyReservationsEndpoint    endpoint.Endpoint
+	StartSessionEndpoint      endpoint.Endpoint
+	StopSessionEndpoint       endpoint.Endpoint
+	MySessionsEndpoint        endpoint.Endpoint
+	CreateTariffEndpoint      endpoint.Endpoint
+	ListTariffsEndpoint       endpoint.Endpoint
+	UpdateTariffEndpoint      endpoint.Endpoint
+	DeleteTariffEndpoint      endpoint.Endpoint
+	QuoteSocketEndpoint       endpoint.Endpoint
+	AddReviewEndpoint         endpoint.Endpoint
+	StationReviewsEndpoint    endpoint.Endpoint
+	DeleteReviewEndpoint      endpoint.Endpoint
+	ListReviewsEndpoint       endpoint.Endpoint
+	ModerateReviewEndpoint    endpoint.Endpoint
+	ReportIssueEndpoint       endpoint.Endpoint
+	ListIssueReportsEndpoint  endpoint.Endpoint
+	CloseIssueReportEndpoint  endpoint.Endpoint
+	ImportStationsEndpoint    endpoint.Endpoint
+	ListImportJobsEndpoint    endpoint.Endpoint
+	GetImportJobEndpoint      endpoint.Endpoint
 }
 
-func MakeServerEndpoints(c context.Context, s StationService) StationEndpoints {
+func MakeServerEndpoints(s StationService, keys jwt.Keyfunc) StationEndpoints {
+	authenticated := auth.NewParser(keys)
 	return StationEndpoints{
-		StationRegisterEndpoint:   MakeRegisterStationEndpoint(c, s),
-		InsertStationsEndpoint:    MakeInsertStationsEndpoint(c, s),
-		GetAllStationsEndpoint:    MakeGetAllStationsEndpoint(c, s),
-		GetStationEndpoint:        MakeGetStationEndpoint(c, s),
-		UpdateStationInfoEndpoint: MakeUpdateStationInfoEndpoint(c, s),
-		RemoveStationEndpoint:     MakeRemoveStationEndpoint(c, s),
-		SearchStationEndpoint:     MakeSearchStationEndpoint(c, s),
-		ListBrandsEndpoint:        MakeListBrandsEndpoint(c, s),
-		ListSocketsEndpoint:       MakeListSocketsEndpoint(c, s),
-		FilterStationsEndpoint:    MakeFilterStationsEndpoint(c, s),
-		DeleteSocketEndpoint:      MakeDeleteSocketEndpoint(c, s),
-	}
-}
-
-func MakeInsertStationsEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+		StationRegisterEndpoint:   authenticated(MakeRegisterStationEndpoint(s)),
+		InsertStationsEndpoint:    authenticated(MakeInsertStationsEndpoint(s)),
+		GetAllStationsEndpoint:    authenticated(MakeGetAllStationsEndpoint(s)),
+		GetStationEndpoint:        authenticated(MakeGetStationEndpoint(s)),
+		UpdateStationInfoEndpoint: authenticated(MakeUpdateStationInfoEndpoint(s)),
+		RemoveStationEndpoint:     authenticated(MakeRemoveStationEndpoint(s)),
+		SearchStationEndpoint:     authenticated(MakeSearchStationEndpoint(s)),
+		ListBrandsEndpoint:        authenticated(MakeListBrandsEndpoint(s)),
+		ListSocketsEndpoint:       authenticated(MakeListSocketsEndpoint(s)),
+		FilterStationsEndpoint:    authenticated(MakeFilterStationsEndpoint(s)),
+		DeleteSocketEndpoint:      authenticated(MakeDeleteSocketEndpoint(s)),
+		ChargePointKeyEndpoint:    authenticated(MakeChargePointKeyEndpoint(s)),
+		NearbyStationsEndpoint:    authenticated(MakeNearbyStationsEndpoint(s)),
+		StreamStationsEndpoint:    streamAuthenticated(s, authenticated)(MakeStreamStationsEndpoint(s)),
+		StreamTicketEndpoint:      authenticated(MakeStreamTicketEndpoint(s)),
+		ReserveSocketEndpoint:     authenticated(MakeReserveSocketEndpoint(s)),
+		CancelReservationEndpoint: authenticated(MakeCancelReservationEndpoint(s)),
+		MyReservationsEndpoint:    authenticated(MakeMyReservationsEndpoint(s)),
+		StartSessionEndpoint:      authenticated(MakeStartSessionEndpoint(s)),
+		StopSessionEndpoint:       authenticated(MakeStopSessionEndpoint(s)),
+		MySessionsEndpoint:        authenticated(MakeMySessionsEndpoint(s)),
+		CreateTariffEndpoint:      authenticated(MakeCreateTariffEndpoint(s)),
+		ListTariffsEndpoint:       authenticated(MakeListTariffsEndpoint(s)),
+		UpdateTariffEndpoint:      authenticated(MakeUpdateTariffEndpoint(s)),
+		DeleteTariffEndpoint:      authenticated(MakeDeleteTariffEndpoint(s)),
+		QuoteSocketEndpoint:       authenticated(MakeQuoteSocketEndpoint(s)),
+		AddReviewEndpoint:         authenticated(MakeAddReviewEndpoint(s)),
+		StationReviewsEndpoint:    authenticated(MakeStationReviewsEndpoint(s)),
+		DeleteReviewEndpoint:      authenticated(MakeDeleteReviewEndpoint(s)),
+		ListReviewsEndpoint:       authenticated(MakeListReviewsEndpoint(s)),
+		ModerateReviewEndpoint:    authenticated(MakeModerateReviewEndpoint(s)),
+		ReportIssueEndpoint:       authenticated(MakeReportIssueEndpoint(s)),
+		ListIssueReportsEndpoint:  authenticated(MakeListIssueReportsEndpoint(s)),
+		CloseIssueReportEndpoint:  authenticated(MakeCloseIssueReportEndpoint(s)),
+		ImportStationsEndpoint:    authenticated(MakeImportStationsEndpoint(s)),
+		ListImportJobsEndpoint:    authenticated(MakeListImportJobsEndpoint(s)),
+		GetImportJobEndpoint:      authenticated(MakeGetImportJobEndpoint(s)),
+	}
+}
+
+func MakeInsertStationsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(insertStationsRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		e := s.InsertStations(c, req.Stations)
+		e := s.InsertStations(ctx, req.Stations)
 		if e != nil {
 			return insertStationsResponse{
 				Err: e,
@@ -64,7 +121,6 @@ func MakeInsertStationsEndpoint(c context.Context, s StationService) endpoint.En
 }
 
 type insertStationsRequest struct {
-	Context  context.Context
 	Stations []*model.Station `json:"stations,omitempty"`
 }
 
@@ -75,13 +131,44 @@ type insertStationsResponse struct {
 
 func (r insertStationsResponse) Failed() error { return r.Err }
 
-func MakeDeleteSocketEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeChargePointKeyEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(chargePointKeyRequest)
+
+		key, e := s.ResetChargePointKey(ctx, req.StationID)
+		if e != nil {
+			return chargePointKeyResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: chargePointKeyResponse{
+				Key: key,
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type chargePointKeyRequest struct {
+	StationID string
+}
+
+// chargePointKeyResponse carries the only copy of the key, which is given to the charge point as its AuthorizationKey.
+type chargePointKeyResponse struct {
+	*BaseResponse
+	Key string `json:"key,omitempty"`
+	Err error  `json:"err,omitempty"`
+}
+
+func (r chargePointKeyResponse) Failed() error { return r.Err }
+
+func MakeDeleteSocketEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(deleteSocketRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		e := s.DeleteSocket(c, req.SocketID)
+		e := s.DeleteSocket(ctx, req.SocketID)
 		if e != nil {
 			return deleteSocketResponse{
 				Err: e,
@@ -97,7 +184,6 @@ func MakeDeleteSocketEndpoint(c context.Context, s StationService) endpoint.Endp
 }
 
 type deleteSocketRequest struct {
-	Context  context.Context
 	SocketID string
 }
 
@@ -108,15 +194,10 @@ type deleteSocketResponse struct {
 
 func (r deleteSocketResponse) Failed() error { return r.Err }
 
-func MakeGetStationEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeGetStationEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(getStationRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-
-		stationId := req.Context.Value("stationId").(string)
-
-		station, e := s.GetStation(c, stationId)
+		station, e := s.GetStation(ctx, req.StationID)
 		if e != nil {
 			return getStationResponse{
 				Err: e,
@@ -133,7 +214,7 @@ func MakeGetStationEndpoint(c context.Context, s StationService) endpoint.Endpoi
 }
 
 type getStationRequest struct {
-	Context context.Context
+	StationID string
 }
 
 type getStationResponse struct {
@@ -144,20 +225,19 @@ type getStationResponse struct {
 
 func (r getStationResponse) Failed() error { return r.Err }
 
-func MakeFilterStationsEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeFilterStationsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(filterStationsRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		stations, e := s.FilterStation(c, req.BrandNames, req.SocketNames, req.CurrentType)
+		stations, nextCursor, e := s.FilterStation(ctx, req.Filter, req.Page)
 		if e != nil {
 			return filterStationsResponse{
 				Err: e,
 			}, e
 		}
 		return BaseResponse{
-			Message: "success",
+			Message:    "success",
+			NextCursor: nextCursor,
 			Data: filterStationsResponse{
 				Stations: stations,
 				Err:      e,
@@ -167,10 +247,8 @@ func MakeFilterStationsEndpoint(c context.Context, s StationService) endpoint.En
 }
 
 type filterStationsRequest struct {
-	Context     context.Context
-	BrandNames  []string
-	SocketNames []string
-	CurrentType int
+	Filter StationFilter
+	Page   repository.Page
 }
 
 type filterStationsResponse struct {
@@ -181,20 +259,19 @@ type filterStationsResponse struct {
 
 func (r filterStationsResponse) Failed() error { return r.Err }
 
-func MakeListSocketsEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeListSocketsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(listSocketsRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		sockets, e := s.ListSockets(c)
+		sockets, nextCursor, e := s.ListSockets(ctx, req.Page)
 		if e != nil {
 			return listSocketsResponse{
 				Err: e,
 			}, e
 		}
 		return BaseResponse{
-			Message: "success",
+			Message:    "success",
+			NextCursor: nextCursor,
 			Data: listSocketsResponse{
 				Sockets: sockets,
 				Err:     e,
@@ -204,7 +281,7 @@ func MakeListSocketsEndpoint(c context.Context, s StationService) endpoint.Endpo
 }
 
 type listSocketsRequest struct {
-	Context context.Context
+	Page repository.Page
 }
 type listSocketsResponse struct {
 	*BaseResponse
@@ -214,13 +291,10 @@ type listSocketsResponse struct {
 
 func (r listSocketsResponse) Failed() error { return r.Err }
 
-func MakeListBrandsEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(listBrandsRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
+func MakeListBrandsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 
-		brands, e := s.ListBrands(c)
+		brands, e := s.ListBrands(ctx)
 		if e != nil {
 			return listBrandsResponse{
 				Err: e,
@@ -236,9 +310,7 @@ func MakeListBrandsEndpoint(c context.Context, s StationService) endpoint.Endpoi
 	}
 }
 
-type listBrandsRequest struct {
-	Context context.Context
-}
+type listBrandsRequest struct{}
 
 type listBrandsResponse struct {
 	*BaseResponse
@@ -248,12 +320,10 @@ type listBrandsResponse struct {
 
 func (r listBrandsResponse) Failed() error { return r.Err }
 
-func MakeRegisterStationEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeRegisterStationEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(registerStationRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		insertedStation, e := s.StationRegister(c, req.Station)
+		insertedStation, e := s.StationRegister(ctx, req.Station)
 		if e != nil {
 			return registerStationResponse{
 				Err: e,
@@ -270,7 +340,6 @@ func MakeRegisterStationEndpoint(c context.Context, s StationService) endpoint.E
 }
 
 type registerStationRequest struct {
-	Context context.Context
 	Station *model.Station
 	Sockets []*model.Socket
 }
@@ -283,20 +352,19 @@ type registerStationResponse struct {
 
 func (r registerStationResponse) Failed() error { return r.Err }
 
-func MakeGetAllStationsEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeGetAllStationsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(getAllStationsRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		stations, e := s.GetStations(c)
+		stations, nextCursor, e := s.GetStations(ctx, req.Page)
 		if e != nil {
 			return getAllStationsResponse{
 				Err: e,
 			}, e
 		}
 		return BaseResponse{
-			Message: "success",
+			Message:    "success",
+			NextCursor: nextCursor,
 			Data: getAllStationsResponse{
 				Stations: stations,
 				Err:      e,
@@ -306,7 +374,7 @@ func MakeGetAllStationsEndpoint(c context.Context, s StationService) endpoint.En
 }
 
 type getAllStationsRequest struct {
-	Context context.Context
+	Page repository.Page
 }
 
 type getAllStationsResponse struct {
@@ -317,13 +385,11 @@ type getAllStationsResponse struct {
 
 func (r getAllStationsResponse) Failed() error { return r.Err }
 
-func MakeUpdateStationInfoEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeUpdateStationInfoEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(updateStationInfoRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		e := s.UpdateStation(c, req.Station, req.StationID)
+		e := s.UpdateStation(ctx, req.Station, req.StationID)
 		if e != nil {
 			return updateStationInfoResponse{
 				Err: e,
@@ -339,7 +405,6 @@ func MakeUpdateStationInfoEndpoint(c context.Context, s StationService) endpoint
 }
 
 type updateStationInfoRequest struct {
-	Context   context.Context
 	StationID string
 	Station   *model.Station
 }
@@ -351,13 +416,11 @@ type updateStationInfoResponse struct {
 
 func (r updateStationInfoResponse) Failed() error { return r.Err }
 
-func MakeRemoveStationEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeRemoveStationEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(removeStationRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		e := s.RemoveStation(c, req.StationID)
+		e := s.RemoveStation(ctx, req.StationID)
 		if e != nil {
 			return removeStationResponse{
 				Err: e,
@@ -373,7 +436,6 @@ func MakeRemoveStationEndpoint(c context.Context, s StationService) endpoint.End
 }
 
 type removeStationRequest struct {
-	Context   context.Context
 	StationID string
 }
 
@@ -384,13 +446,11 @@ type removeStationResponse struct {
 
 func (r removeStationResponse) Failed() error { return r.Err }
 
-func MakeSearchStationEndpoint(c context.Context, s StationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeSearchStationEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(searchStationRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
 
-		stations, e := s.SearchStation(c, req.Brand)
+		stations, e := s.SearchStation(ctx, req.Brand)
 		if e != nil {
 			return searchStationResponse{
 				Err: e,
@@ -407,7 +467,6 @@ func MakeSearchStationEndpoint(c context.Context, s StationService) endpoint.End
 }
 
 type searchStationRequest struct {
-	Context context.Context
 	Station *model.Station
 	Brand   string
 }
@@ -419,3 +478,720 @@ type searchStationResponse struct {
 }
 
 func (r searchStationResponse) Failed() error { return r.Err }
+
+func MakeNearbyStationsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(nearbyStationsRequest)
+
+		stations, e := s.NearbyStations(ctx, req.Point, req.RadiusKm, req.Limit)
+		if e != nil {
+			return nearbyStationsResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: nearbyStationsResponse{
+				Stations: stations,
+				Err:      e,
+			},
+		}, nil
+	}
+}
+
+type nearbyStationsRequest struct {
+	Point    model.Coordinate
+	RadiusKm float64
+	Limit    int
+}
+
+type nearbyStationsResponse struct {
+	*BaseResponse
+	Stations []*model.Station `json:"stations,omitempty"`
+	Err      error            `json:"err,omitempty"`
+}
+
+func (r nearbyStationsResponse) Failed() error { return r.Err }
+
+// MakeStreamStationsEndpoint subscribes with the request context, so the subscription ends when the client goes away.
+func MakeStreamStationsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(streamStationsRequest)
+		events, e := s.StreamStations(ctx, req.Filter)
+		if e != nil {
+			return streamStationsResponse{
+				Err: e,
+			}, e
+		}
+		return streamStationsResponse{
+			Events: events,
+		}, nil
+	}
+}
+
+type streamStationsRequest struct {
+	Filter StreamFilter
+	Ticket string
+}
+
+type streamStationsResponse struct {
+	Events <-chan *model.StationEvent
+	Err    error
+}
+
+func (r streamStationsResponse) Failed() error { return r.Err }
+
+// streamAuthenticated authenticates the stream with its ticket when it has one, and with the access token otherwise.
+func streamAuthenticated(s StationService, authenticated endpoint.Middleware) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		withToken := authenticated(next)
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			req := request.(streamStationsRequest)
+			if req.Ticket == "" {
+				return withToken(ctx, request)
+			}
+			claims, err := s.RedeemStreamTicket(ctx, req.Ticket)
+			if err != nil {
+				return nil, err
+			}
+			return next(auth.WithClaims(ctx, claims), request)
+		}
+	}
+}
+
+func MakeStreamTicketEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		ticket, e := s.IssueStreamTicket(ctx)
+		if e != nil {
+			return streamTicketResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: streamTicketResponse{
+				Ticket:    ticket,
+				ExpiresIn: int64(streamTicketTTL / time.Second),
+			},
+		}, nil
+	}
+}
+
+type streamTicketRequest struct{}
+
+type streamTicketResponse struct {
+	Ticket    string `json:"ticket,omitempty"`
+	ExpiresIn int64  `json:"expires_in,omitempty"` // Lifetime of the ticket in seconds.
+	Err       error  `json:"err,omitempty"`
+}
+
+func (r streamTicketResponse) Failed() error { return r.Err }
+
+func MakeReserveSocketEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reserveSocketRequest)
+
+		reservation, e := s.ReserveSocket(ctx, req.SocketID, req.StartsAt, req.EndsAt)
+		if e != nil {
+			return reserveSocketResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: reserveSocketResponse{
+				Reservation: reservation,
+				Err:         e,
+			},
+		}, nil
+	}
+}
+
+type reserveSocketRequest struct {
+	SocketID string    `json:"socket_id"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type reserveSocketResponse struct {
+	*BaseResponse
+	Reservation *model.Reservation `json:"reservation,omitempty"`
+	Err         error              `json:"err,omitempty"`
+}
+
+func (r reserveSocketResponse) Failed() error { return r.Err }
+
+func MakeCancelReservationEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(cancelReservationRequest)
+
+		e := s.CancelReservation(ctx, req.ReservationID)
+		if e != nil {
+			return cancelReservationResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: cancelReservationResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type cancelReservationRequest struct {
+	ReservationID string
+}
+
+type cancelReservationResponse struct {
+	*BaseResponse
+	Err error `json:"err,omitempty"`
+}
+
+func (r cancelReservationResponse) Failed() error { return r.Err }
+
+func MakeMyReservationsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+
+		reservations, e := s.MyReservations(ctx)
+		if e != nil {
+			return myReservationsResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: myReservationsResponse{
+				Reservations: reservations,
+				Err:          e,
+			},
+		}, nil
+	}
+}
+
+type myReservationsRequest struct{}
+
+type myReservationsResponse struct {
+	*BaseResponse
+	Reservations []*model.Reservation `json:"reservations,omitempty"`
+	Err          error                `json:"err,omitempty"`
+}
+
+func (r myReservationsResponse) Failed() error { return r.Err }
+
+func MakeStartSessionEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(startSessionRequest)
+
+		session, e := s.StartChargingSession(ctx, req.SocketID)
+		if e != nil {
+			return sessionResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: sessionResponse{
+				Session: session,
+				Err:     e,
+			},
+		}, nil
+	}
+}
+
+type startSessionRequest struct {
+	SocketID string `json:"socket_id"`
+}
+
+type sessionResponse struct {
+	*BaseResponse
+	Session *model.ChargingSession `json:"session,omitempty"`
+	Err     error                  `json:"err,omitempty"`
+}
+
+func (r sessionResponse) Failed() error { return r.Err }
+
+func MakeStopSessionEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(stopSessionRequest)
+
+		session, e := s.StopChargingSession(ctx, req.SessionID, req.EnergyKWh)
+		if e != nil {
+			return sessionResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: sessionResponse{
+				Session: session,
+				Err:     e,
+			},
+		}, nil
+	}
+}
+
+type stopSessionRequest struct {
+	SessionID string
+	// EnergyKWh corrects the energy delivered. Only admins may give it, and the meter of the charge point wins over it.
+	EnergyKWh *float64 `json:"energy_kwh,omitempty"`
+}
+
+func MakeMySessionsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+
+		sessions, monthly, e := s.MySessions(ctx)
+		if e != nil {
+			return mySessionsResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: mySessionsResponse{
+				Sessions: sessions,
+				Monthly:  monthly,
+				Err:      e,
+			},
+		}, nil
+	}
+}
+
+type mySessionsRequest struct{}
+
+type mySessionsResponse struct {
+	*BaseResponse
+	Sessions []*model.ChargingSession     `json:"sessions,omitempty"`
+	Monthly  []model.MonthlyChargingTotal `json:"monthly"`
+	Err      error                        `json:"err,omitempty"`
+}
+
+func (r mySessionsResponse) Failed() error { return r.Err }
+
+func MakeCreateTariffEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(tariffRequest)
+
+		tariff, e := s.CreateTariff(ctx, req.Tariff)
+		if e != nil {
+			return tariffResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: tariffResponse{
+				Tariff: tariff,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+type tariffRequest struct {
+	TariffID string
+	Tariff   *model.Tariff
+}
+
+type tariffResponse struct {
+	*BaseResponse
+	Tariff *model.Tariff `json:"tariff,omitempty"`
+	Err    error         `json:"err,omitempty"`
+}
+
+func (r tariffResponse) Failed() error { return r.Err }
+
+func MakeListTariffsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+
+		tariffs, e := s.ListTariffs(ctx)
+		if e != nil {
+			return listTariffsResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: listTariffsResponse{
+				Tariffs: tariffs,
+				Err:     e,
+			},
+		}, nil
+	}
+}
+
+type listTariffsRequest struct{}
+
+type listTariffsResponse struct {
+	*BaseResponse
+	Tariffs []*model.Tariff `json:"tariffs,omitempty"`
+	Err     error           `json:"err,omitempty"`
+}
+
+func (r listTariffsResponse) Failed() error { return r.Err }
+
+func MakeUpdateTariffEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(tariffRequest)
+
+		e := s.UpdateTariff(ctx, req.Tariff, req.TariffID)
+		if e != nil {
+			return tariffResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: tariffResponse{
+				Tariff: req.Tariff,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+func MakeDeleteTariffEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(tariffRequest)
+
+		e := s.DeleteTariff(ctx, req.TariffID)
+		if e != nil {
+			return tariffResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: tariffResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeQuoteSocketEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(quoteSocketRequest)
+
+		quote, e := s.QuoteSocket(ctx, req.SocketID, req.EnergyKWh, req.StartsAt)
+		if e != nil {
+			return quoteSocketResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: quoteSocketResponse{
+				Quote: quote,
+				Err:   e,
+			},
+		}, nil
+	}
+}
+
+type quoteSocketRequest struct {
+	SocketID  string
+	EnergyKWh float64
+	StartsAt  time.Time
+}
+
+type quoteSocketResponse struct {
+	*BaseResponse
+	Quote *model.PriceQuote `json:"quote,omitempty"`
+	Err   error             `json:"err,omitempty"`
+}
+
+func (r quoteSocketResponse) Failed() error { return r.Err }
+
+func MakeAddReviewEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reviewRequest)
+
+		review, e := s.AddReview(ctx, req.StationID, req.Review)
+		if e != nil {
+			return reviewResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: reviewResponse{
+				Review: review,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+func MakeDeleteReviewEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reviewRequest)
+
+		e := s.DeleteReview(ctx, req.ReviewID)
+		if e != nil {
+			return reviewResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: reviewResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeModerateReviewEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reviewRequest)
+
+		e := s.ModerateReview(ctx, req.ReviewID, req.Status, req.Note)
+		if e != nil {
+			return reviewResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: reviewResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type reviewRequest struct {
+	StationID string
+	ReviewID  string
+	Review    *model.Review
+	Status    model.ReviewStatus `json:"status"`
+	Note      string             `json:"note"`
+}
+
+type reviewResponse struct {
+	*BaseResponse
+	Review *model.Review `json:"review,omitempty"`
+	Err    error         `json:"err,omitempty"`
+}
+
+func (r reviewResponse) Failed() error { return r.Err }
+
+func MakeStationReviewsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listReviewsRequest)
+
+		reviews, e := s.StationReviews(ctx, req.StationID)
+		if e != nil {
+			return listReviewsResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: listReviewsResponse{
+				Reviews: reviews,
+				Err:     e,
+			},
+		}, nil
+	}
+}
+
+func MakeListReviewsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listReviewsRequest)
+
+		reviews, e := s.ListReviews(ctx, req.Status)
+		if e != nil {
+			return listReviewsResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: listReviewsResponse{
+				Reviews: reviews,
+				Err:     e,
+			},
+		}, nil
+	}
+}
+
+type listReviewsRequest struct {
+	StationID string
+	Status    model.ReviewStatus
+}
+
+type listReviewsResponse struct {
+	*BaseResponse
+	Reviews []*model.Review `json:"reviews,omitempty"`
+	Err     error           `json:"err,omitempty"`
+}
+
+func (r listReviewsResponse) Failed() error { return r.Err }
+
+func MakeReportIssueEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(issueReportRequest)
+
+		report, e := s.ReportIssue(ctx, req.SocketID, req.Report)
+		if e != nil {
+			return issueReportResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: issueReportResponse{
+				Report: report,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+func MakeCloseIssueReportEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(issueReportRequest)
+
+		e := s.CloseIssueReport(ctx, req.ReportID, req.Status)
+		if e != nil {
+			return issueReportResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: issueReportResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type issueReportRequest struct {
+	SocketID string
+	ReportID string
+	Report   *model.IssueReport
+	Status   model.IssueStatus `json:"status"`
+}
+
+type issueReportResponse struct {
+	*BaseResponse
+	Report *model.IssueReport `json:"report,omitempty"`
+	Err    error              `json:"err,omitempty"`
+}
+
+func (r issueReportResponse) Failed() error { return r.Err }
+
+func MakeListIssueReportsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listIssueReportsRequest)
+
+		reports, e := s.ListIssueReports(ctx, req.Status, req.StationID)
+		if e != nil {
+			return listIssueReportsResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: listIssueReportsResponse{
+				Reports: reports,
+				Err:     e,
+			},
+		}, nil
+	}
+}
+
+type listIssueReportsRequest struct {
+	StationID string
+	Status    model.IssueStatus
+}
+
+type listIssueReportsResponse struct {
+	*BaseResponse
+	Reports []*model.IssueReport `json:"reports,omitempty"`
+	Err     error                `json:"err,omitempty"`
+}
+
+func (r listIssueReportsResponse) Failed() error { return r.Err }
+
+func MakeImportStationsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(importStationsRequest)
+
+		job, e := s.ImportStations(ctx, req.Format, req.File, req.DryRun)
+		if e != nil {
+			return importJobResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: importJobResponse{
+				Job: job,
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type importStationsRequest struct {
+	Format model.ImportFormat
+	File   io.Reader
+	DryRun bool
+}
+
+func MakeListImportJobsEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+
+		jobs, e := s.ListImportJobs(ctx)
+		if e != nil {
+			return importJobResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: importJobResponse{
+				Jobs: jobs,
+				Err:  e,
+			},
+		}, nil
+	}
+}
+
+func MakeGetImportJobEndpoint(s StationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(importJobRequest)
+
+		job, e := s.GetImportJob(ctx, req.JobID)
+		if e != nil {
+			return importJobResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: importJobResponse{
+				Job: job,
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type importJobRequest struct {
+	JobID string
+}
+
+type importJobResponse struct {
+	*BaseResponse
+	Job  *model.ImportJob   `json:"job,omitempty"`
+	Jobs []*model.ImportJob `json:"jobs,omitempty"`
+	Err  error              `json:"err,omitempty"`
+}
+
+func (r importJobResponse) Failed() error { return r.Err }
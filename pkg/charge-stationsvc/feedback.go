@@ -0,0 +1,306 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"california/pkg/auth"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxReviewLength      = 2000
+	maxIssueDescription  = 1000
+	maxIssuePhotos       = 5
+	maxIssuePhotoSize    = 10 << 20 // 10 MB
+	suspectedReportCount = 3
+	// suspectedReportWindow is how recent the reports agreeing a socket is out of order must be.
+	suspectedReportWindow = 24 * time.Hour
+)
+
+var (
+	ErrStationNotFound     = errors.New("station not found")
+	ErrInvalidReview       = errors.New("invalid review")
+	ErrReviewNotFound      = errors.New("review not found")
+	ErrInvalidIssueReport  = errors.New("invalid issue report")
+	ErrIssueReportNotFound = errors.New("issue report not found")
+	ErrIssueReportClosed   = errors.New("issue report is already closed")
+)
+
+var photoContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+	"image/heic": true,
+}
+
+// AddReview publishes the review of the current user for the station. A user has a single review
+// per station, which is replaced when the user reviews the station again.
+func (s *chargeStationService) AddReview(ctx context.Context, stationId string, review *model.Review) (*model.Review, error) {
+	userId := auth.UserID(ctx)
+	if userId == "" {
+		return nil, auth.ErrInvalidToken
+	}
+	review.Text = strings.TrimSpace(review.Text)
+	if review.Rating < 1 || review.Rating > 5 || len(review.Text) > maxReviewLength {
+		return nil, ErrInvalidReview
+	}
+	station, err := s.findStation(ctx, stationId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	existing, err := s.store.FindReviewsByFilter(ctx, bson.M{"StationID": station.ID, "UserID": userId})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		// A review hidden by an admin stays hidden when it is edited.
+		updated := existing[0]
+		updated.Rating = review.Rating
+		updated.Text = review.Text
+		updated.UpdatedAt = now
+		if err = s.store.UpdateReview(ctx, updated); err != nil {
+			return nil, err
+		}
+		review = updated
+	} else {
+		review.ID = primitive.NewObjectID()
+		review.StationID = station.ID
+		review.UserID = userId
+		review.Status = model.ReviewPublished
+		review.ModerationNote = ""
+		review.CreatedAt = now
+		review.UpdatedAt = now
+		if err = s.store.InsertReview(ctx, review); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.refreshRating(ctx, station.ID); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// StationReviews lists the published reviews of the station, the most recent first.
+func (s *chargeStationService) StationReviews(ctx context.Context, stationId string) ([]*model.Review, error) {
+	station, err := s.findStation(ctx, stationId)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.FindReviewsByFilter(ctx, bson.M{"StationID": station.ID, "Status": model.ReviewPublished})
+}
+
+// DeleteReview deletes a review of the current user. Admins can delete any review.
+func (s *chargeStationService) DeleteReview(ctx context.Context, reviewId string) error {
+	review, err := s.store.GetReviewById(ctx, reviewId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrReviewNotFound
+	} else if err != nil {
+		return err
+	}
+
+	userId := auth.UserID(ctx)
+	if review.UserID != userId {
+		if err = auth.Authorize(ctx, model.Admin); err != nil {
+			return err
+		}
+	}
+	if err = s.store.DeleteReview(ctx, review.ID); err != nil {
+		return err
+	}
+	return s.refreshRating(ctx, review.StationID)
+}
+
+// ListReviews lists the reviews with the given status, or all of them when it is zero, the most recent first.
+func (s *chargeStationService) ListReviews(ctx context.Context, status model.ReviewStatus) ([]*model.Review, error) {
+	filter := bson.M{}
+	if status != 0 {
+		filter["Status"] = status
+	}
+	return s.store.FindReviewsByFilter(ctx, filter)
+}
+
+// ModerateReview hides a review, with the reason given in the note, or publishes it again.
+func (s *chargeStationService) ModerateReview(ctx context.Context, reviewId string, status model.ReviewStatus, note string) error {
+	if status != model.ReviewPublished && status != model.ReviewHidden {
+		return ErrInvalidReview
+	}
+	review, err := s.store.GetReviewById(ctx, reviewId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrReviewNotFound
+	} else if err != nil {
+		return err
+	}
+	review.Status = status
+	review.ModerationNote = ""
+	if status == model.ReviewHidden {
+		review.ModerationNote = strings.TrimSpace(note)
+	}
+	if err = s.store.UpdateReview(ctx, review); err != nil {
+		return err
+	}
+	return s.refreshRating(ctx, review.StationID)
+}
+
+// refreshRating recomputes the rating of the station from its published reviews.
+func (s *chargeStationService) refreshRating(ctx context.Context, stationId primitive.ObjectID) error {
+	reviews, err := s.store.FindReviewsByFilter(ctx, bson.M{"StationID": stationId, "Status": model.ReviewPublished})
+	if err != nil {
+		return err
+	}
+	if len(reviews) == 0 {
+		return s.store.SetStationRating(ctx, stationId, nil)
+	}
+	total := 0
+	for _, review := range reviews {
+		total += review.Rating
+	}
+	average := float64(total) / float64(len(reviews))
+	return s.store.SetStationRating(ctx, stationId, &model.StationRating{
+		Average: math.Round(average*10) / 10,
+		Count:   len(reviews),
+	})
+}
+
+// ReportIssue records a problem with the socket reported by the current user. The socket is marked
+// as suspected broken once enough users report it out of order within a short time.
+func (s *chargeStationService) ReportIssue(ctx context.Context, socketId string, report *model.IssueReport) (*model.IssueReport, error) {
+	userId := auth.UserID(ctx)
+	if userId == "" {
+		return nil, auth.ErrInvalidToken
+	}
+	report.Description = strings.TrimSpace(report.Description)
+	if err := validateIssueReport(report); err != nil {
+		return nil, err
+	}
+	station, socket, err := s.findSocket(ctx, socketId)
+	if err != nil {
+		return nil, err
+	}
+
+	report.ID = primitive.NewObjectID()
+	report.StationID = station.ID
+	report.SocketID = socket.ID
+	report.UserID = userId
+	report.Status = model.IssueOpen
+	report.CreatedAt = time.Now().UTC()
+	report.ClosedAt = nil
+	if err = s.store.InsertIssueReport(ctx, report); err != nil {
+		return nil, err
+	}
+	if err = s.refreshSuspicion(ctx, socket); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ListIssueReports lists the reports with the given status, or all of them when it is zero, the most recent first.
+// The reports are limited to the station when its id is given.
+func (s *chargeStationService) ListIssueReports(ctx context.Context, status model.IssueStatus, stationId string) ([]*model.IssueReport, error) {
+	filter := bson.M{}
+	if status != 0 {
+		filter["Status"] = status
+	}
+	if stationId != "" {
+		station, err := s.findStation(ctx, stationId)
+		if err != nil {
+			return nil, err
+		}
+		filter["StationID"] = station.ID
+	}
+	return s.store.FindIssueReportsByFilter(ctx, filter)
+}
+
+// CloseIssueReport resolves or rejects an open report. The socket is no longer suspected broken
+// once too few open reports are left to agree on it.
+func (s *chargeStationService) CloseIssueReport(ctx context.Context, reportId string, status model.IssueStatus) error {
+	if status != model.IssueResolved && status != model.IssueRejected {
+		return ErrInvalidIssueReport
+	}
+	report, err := s.store.GetIssueReportById(ctx, reportId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrIssueReportNotFound
+	} else if err != nil {
+		return err
+	}
+	closed, err := s.store.CloseIssueReport(ctx, report.ID, status, time.Now())
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return ErrIssueReportClosed
+	}
+
+	_, socket, err := s.findSocket(ctx, report.SocketID.Hex())
+	if errors.Is(err, ErrSocketNotFound) {
+		// The socket has been removed since it was reported.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return s.refreshSuspicion(ctx, socket)
+}
+
+// refreshSuspicion marks the socket as suspected broken when enough different users recently reported it out of order
+// in reports which are still open, and clears the mark otherwise.
+func (s *chargeStationService) refreshSuspicion(ctx context.Context, socket *model.Socket) error {
+	reports, err := s.store.FindIssueReportsByFilter(ctx, bson.M{
+		"SocketID":  socket.ID,
+		"Status":    model.IssueOpen,
+		"CreatedAt": bson.M{"$gte": time.Now().Add(-suspectedReportWindow)},
+	})
+	if err != nil {
+		return err
+	}
+	reporters := make(map[string]bool)
+	for _, report := range reports {
+		if report.Kind.OutOfOrder() {
+			reporters[report.UserID] = true
+		}
+	}
+	suspected := len(reporters) >= suspectedReportCount
+	if suspected == socket.SuspectedBroken {
+		return nil
+	}
+	return s.store.SetSocketSuspected(ctx, socket.ID, suspected)
+}
+
+func validateIssueReport(report *model.IssueReport) error {
+	if !report.Kind.Valid() || len(report.Description) > maxIssueDescription || len(report.Photos) > maxIssuePhotos {
+		return ErrInvalidIssueReport
+	}
+	for _, photo := range report.Photos {
+		u, err := url.Parse(photo.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return ErrInvalidIssueReport
+		}
+		if !photoContentTypes[photo.ContentType] || photo.Size <= 0 || photo.Size > maxIssuePhotoSize ||
+			photo.Width < 0 || photo.Height < 0 {
+			return ErrInvalidIssueReport
+		}
+	}
+	return nil
+}
+
+// findStation returns the station with the given id.
+func (s *chargeStationService) findStation(ctx context.Context, stationId string) (*model.Station, error) {
+	if _, err := primitive.ObjectIDFromHex(stationId); err != nil {
+		return nil, ErrStationNotFound
+	}
+	station, err := s.store.GetStationById(ctx, stationId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrStationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return station, nil
+}
@@ -0,0 +1,186 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/pricing"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const earthRadiusKm = 6371
+
+var ErrInvalidFilter = errors.New("invalid station filter")
+
+// StationFilter selects the stations matching all of its fields. The socket fields apply together
+// to a single socket: a station matches when one of its sockets matches all of them.
+// Empty fields do not filter anything.
+type StationFilter struct {
+	Brands      []string
+	SocketNames []string
+	SocketTypes []string // e.g. CCS, Type 2
+	CurrentType int
+	MinKW       float64
+	MaxKW       float64
+	// MaxPrice is the highest price per kWh at the time of the search, with the tariff of the socket.
+	MaxPrice      float64
+	AvailableOnly bool
+	// OpenNow leaves out the stations which are closed at the time of the search.
+	OpenNow   bool
+	Amenities []model.Amenity // The stations have all of them.
+
+	// The stations within RadiusKm of Near.
+	Near     *model.Coordinate
+	RadiusKm float64
+}
+
+func (f StationFilter) validate() error {
+	if f.MinKW < 0 || f.MaxKW < 0 || f.MaxPrice < 0 || f.RadiusKm < 0 {
+		return ErrInvalidFilter
+	}
+	if f.MaxKW > 0 && f.MinKW > f.MaxKW {
+		return ErrInvalidFilter
+	}
+	if f.RadiusKm > 0 && f.Near == nil {
+		return ErrInvalidLocation
+	}
+	if f.Near != nil && (f.Near.Lat < -90 || f.Near.Lat > 90 || f.Near.Long < -180 || f.Near.Long > 180) {
+		return ErrInvalidLocation
+	}
+	if f.RadiusKm > maxNearbyRadiusKm {
+		return ErrInvalidFilter
+	}
+	for _, amenity := range f.Amenities {
+		if !amenity.Valid() {
+			return ErrInvalidAmenity
+		}
+	}
+	return nil
+}
+
+func (f StationFilter) filtersSockets() bool {
+	return len(f.SocketNames) > 0 || len(f.SocketTypes) > 0 || f.CurrentType == int(model.DC) || f.CurrentType == int(model.AC) ||
+		f.MinKW > 0 || f.MaxKW > 0 || f.MaxPrice > 0 || f.AvailableOnly
+}
+
+// query returns the MongoDB filter of the stations. The price of a socket depends on its tariff,
+// which the stations do not hold, so the price is left to matchSockets, and the opening hours to FilterStation.
+func (f StationFilter) query() bson.M {
+	filter := bson.M{}
+	if len(f.Brands) > 0 {
+		filter["Brand"] = bson.M{"$in": f.Brands}
+	}
+	if len(f.Amenities) > 0 {
+		amenities := bson.A{}
+		for _, amenity := range f.Amenities {
+			amenities = append(amenities, bson.M{"Amenities": string(amenity)})
+		}
+		filter["$and"] = amenities
+	}
+
+	socket := bson.M{}
+	if len(f.SocketNames) > 0 {
+		socket["Name"] = bson.M{"$in": f.SocketNames}
+	}
+	if len(f.SocketTypes) > 0 {
+		socket["SocketType"] = bson.M{"$in": f.SocketTypes}
+	}
+	if f.CurrentType == int(model.DC) || f.CurrentType == int(model.AC) {
+		socket["CurrentType"] = f.CurrentType
+	}
+	if f.MinKW > 0 || f.MaxKW > 0 {
+		power := bson.M{}
+		if f.MinKW > 0 {
+			power["$gte"] = f.MinKW
+		}
+		if f.MaxKW > 0 {
+			power["$lte"] = f.MaxKW
+		}
+		socket["KW"] = power
+	}
+	if f.AvailableOnly {
+		socket["Status"] = model.Available
+	}
+	if len(socket) > 0 {
+		filter["Sockets"] = bson.M{"$elemMatch": socket}
+	}
+
+	if f.Near != nil && f.RadiusKm > 0 {
+		filter["Location"] = bson.M{"$geoWithin": bson.M{
+			"$centerSphere": bson.A{bson.A{f.Near.Long, f.Near.Lat}, f.RadiusKm / earthRadiusKm},
+		}}
+	}
+	return filter
+}
+
+// matchSockets returns the sockets of the station matching the filter, the way query does, and by price.
+// It is called after the reserved sockets are marked, so they are not available.
+func (f StationFilter) matchSockets(station *model.Station, tariffs *pricing.Tariffs, now time.Time) []primitive.ObjectID {
+	var matched []primitive.ObjectID
+	for i := range station.Sockets {
+		socket := &station.Sockets[i]
+		if len(f.SocketNames) > 0 && !contains(f.SocketNames, socket.Name) ||
+			len(f.SocketTypes) > 0 && !contains(f.SocketTypes, socket.SocketType) ||
+			(f.CurrentType == int(model.DC) || f.CurrentType == int(model.AC)) && int(socket.CurrentType) != f.CurrentType ||
+			f.MinKW > 0 && socket.KW < f.MinKW ||
+			f.MaxKW > 0 && socket.KW > f.MaxKW ||
+			f.AvailableOnly && socket.Status != model.Available {
+			continue
+		}
+		if f.MaxPrice > 0 && pricing.PriceAt(tariffs.For(station, socket), now) > f.MaxPrice {
+			continue
+		}
+		matched = append(matched, socket.ID)
+	}
+	return matched
+}
+
+// FilterStation returns a page of the stations matching the filter, with the sockets which matched it.
+// A page may hold fewer stations than its limit when the price, a reservation or the opening hours rule some out.
+func (s *chargeStationService) FilterStation(ctx context.Context, filter StationFilter, page repository.Page) ([]*model.Station, string, error) {
+	if err := filter.validate(); err != nil {
+		return nil, "", err
+	}
+	if page.Sort == repository.SortDistance && page.Near == nil {
+		page.Near = filter.Near
+	}
+
+	stations, nextCursor, err := s.store.FindStationsPage(ctx, filter.query(), page)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = s.markReserved(ctx, stations...); err != nil {
+		return nil, "", err
+	}
+	tariffs, err := pricing.LoadTariffs(ctx, s.store)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	matched := stations[:0]
+	for _, station := range stations {
+		if filter.OpenNow && !station.OpenAt(now) {
+			continue
+		}
+		station.MatchedSockets = filter.matchSockets(station, tariffs, now)
+		if filter.filtersSockets() && len(station.MatchedSockets) == 0 {
+			continue
+		}
+		matched = append(matched, station)
+	}
+	return matched, nextCursor, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"time"
+
+	"california/pkg/auth"
+	"california/pkg/model"
+	"california/pkg/stationimport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxImportStations is the number of stations a single import file may hold.
+const maxImportStations = 5000
+
+var (
+	ErrTooManyImportStations = fmt.Errorf("%w: more than %d stations", stationimport.ErrInvalidFile, maxImportStations)
+	ErrImportJobNotFound     = errors.New("import job not found")
+	ErrImportFileTooLarge    = errors.New("import file is too large")
+)
+
+// ImportStations imports the stations of a CSV or OCPI file. A station of the file is created, or updates
+// the station with the same external id or, without one, at the same coordinates. An update replaces the details
+// of the station and adds the sockets it does not have yet; its other sockets are kept, as they may be in use.
+// Invalid stations are reported and left out, so they do not keep the others from being imported.
+// On a dry run nothing is stored but the job, which tells what the import would do.
+func (s *chargeStationService) ImportStations(ctx context.Context, format model.ImportFormat, file io.Reader, dryRun bool) (*model.ImportJob, error) {
+	records, err := stationimport.Read(format, file)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > maxImportStations {
+		return nil, ErrTooManyImportStations
+	}
+
+	userId := auth.UserID(ctx)
+	job := &model.ImportJob{
+		ID:        primitive.NewObjectID(),
+		Format:    format,
+		DryRun:    dryRun,
+		UserID:    userId,
+		CreatedAt: time.Now().UTC(),
+	}
+	seen := make(map[string]int)
+	targets := make(map[primitive.ObjectID]int)
+	for _, record := range records {
+		station := record.Station
+		row := model.ImportRow{
+			Lines:      record.Lines,
+			ExternalID: station.ExternalID,
+			Brand:      station.Brand,
+			Errors:     record.Errors,
+		}
+		if record.Skip != "" {
+			row.Action = model.ImportSkipped
+			row.Errors = []string{record.Skip}
+			job.Count(row)
+			continue
+		}
+		if len(row.Errors) == 0 {
+			row.Errors = validateImported(station)
+		}
+		if len(row.Errors) > 0 {
+			row.Action = model.ImportFailed
+			job.Count(row)
+			continue
+		}
+
+		key := importKey(station)
+		if first, ok := seen[key]; ok {
+			row.Action = model.ImportSkipped
+			row.Errors = []string{fmt.Sprintf("duplicate of row %d", first)}
+			job.Count(row)
+			continue
+		}
+		seen[key] = record.Lines[0]
+
+		// A store error fails the row like an invalid station, so the job still records what was imported before it.
+		existing, err := s.findImported(ctx, station)
+		if err != nil {
+			row.Action = model.ImportFailed
+			row.Errors = []string{err.Error()}
+			job.Count(row)
+			continue
+		}
+		if existing != nil {
+			if first, ok := targets[existing.ID]; ok {
+				row.Action = model.ImportSkipped
+				row.Errors = []string{fmt.Sprintf("updates the same station as row %d", first)}
+				job.Count(row)
+				continue
+			}
+			targets[existing.ID] = record.Lines[0]
+		}
+
+		row.Action, row.StationID, err = s.importStation(ctx, station, existing, dryRun)
+		if err != nil {
+			row.Action = model.ImportFailed
+			row.Errors = []string{err.Error()}
+		}
+		job.Count(row)
+	}
+
+	job.FinishedAt = time.Now().UTC()
+	if err = s.store.InsertImportJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ListImportJobs lists the import jobs, the most recent first, without their rows.
+func (s *chargeStationService) ListImportJobs(ctx context.Context) ([]*model.ImportJob, error) {
+	jobs, err := s.store.FindImportJobsByFilter(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		job.Rows = nil
+	}
+	return jobs, nil
+}
+
+func (s *chargeStationService) GetImportJob(ctx context.Context, jobId string) (*model.ImportJob, error) {
+	job, err := s.store.GetImportJobById(ctx, jobId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrImportJobNotFound
+	}
+	return job, err
+}
+
+// importStation creates the station, or updates the existing one with it, unless it is a dry run.
+func (s *chargeStationService) importStation(ctx context.Context, station, existing *model.Station, dryRun bool) (model.ImportAction, primitive.ObjectID, error) {
+	if existing == nil {
+		if dryRun {
+			return model.ImportCreated, primitive.NilObjectID, nil
+		}
+		for i := range station.Sockets {
+			station.Sockets[i].ID = primitive.NewObjectID()
+		}
+		station.ID = primitive.NewObjectID()
+		station.SetLocation()
+		station.Rating = nil
+		if _, err := s.store.InsertStation(ctx, station); err != nil {
+			return "", primitive.NilObjectID, err
+		}
+		for i := range station.Sockets {
+			if err := s.store.InsertSocket(ctx, &station.Sockets[i]); err != nil {
+				return "", station.ID, err
+			}
+		}
+		return model.ImportCreated, station.ID, nil
+	}
+
+	updated := *existing
+	updated.Brand = station.Brand
+	updated.Address = station.Address
+	updated.Latitude = station.Latitude
+	updated.Longitude = station.Longitude
+	updated.SetLocation()
+	updated.OpeningHours = station.OpeningHours
+	updated.Amenities = station.Amenities
+	updated.Access = station.Access
+	if station.ExternalID != "" {
+		updated.ExternalID = station.ExternalID
+	}
+	if station.ChargePointID != "" {
+		updated.ChargePointID = station.ChargePointID
+	}
+	added := newSockets(existing.Sockets, station.Sockets)
+	if len(added) == 0 && sameDetails(existing, &updated) {
+		return model.ImportSkipped, existing.ID, nil
+	}
+	if dryRun {
+		return model.ImportUpdated, existing.ID, nil
+	}
+
+	for i := range added {
+		added[i].ID = primitive.NewObjectID()
+	}
+	updated.Sockets = append(slices.Clone(existing.Sockets), added...)
+	if err := s.store.UpdateStationInfo(ctx, &updated, existing.ID.Hex()); err != nil {
+		return "", existing.ID, err
+	}
+	for i := range added {
+		if err := s.store.InsertSocket(ctx, &added[i]); err != nil {
+			return "", existing.ID, err
+		}
+	}
+	return model.ImportUpdated, existing.ID, nil
+}
+
+// findImported returns the station the imported one updates: the station with its external id or,
+// failing that, a station at its coordinates which has no other external id. It returns nil when there is none.
+func (s *chargeStationService) findImported(ctx context.Context, station *model.Station) (*model.Station, error) {
+	if station.ExternalID != "" {
+		stations, err := s.store.FindStationByFilter(ctx, bson.M{"ExternalID": station.ExternalID})
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		if len(stations) > 0 {
+			return stations[0], nil
+		}
+	}
+	stations, err := s.store.FindStationByFilter(ctx, bson.M{"Latitude": station.Latitude, "Longitude": station.Longitude})
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	for _, existing := range stations {
+		if existing.ExternalID == "" || existing.ExternalID == station.ExternalID {
+			return existing, nil
+		}
+	}
+	return nil, nil
+}
+
+// newSockets returns the imported sockets the station does not have. Sockets with an external id are matched by it,
+// the others by their type, power and current.
+func newSockets(existing, imported []model.Socket) []model.Socket {
+	matched := make([]bool, len(existing))
+	var added []model.Socket
+	for _, socket := range imported {
+		found := false
+		for i, other := range existing {
+			if matched[i] {
+				continue
+			}
+			if socket.ExternalID != "" && socket.ExternalID == other.ExternalID ||
+				socket.ExternalID == "" && socket.SocketType == other.SocketType && socket.KW == other.KW && socket.CurrentType == other.CurrentType {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			added = append(added, socket)
+		}
+	}
+	return added
+}
+
+func sameDetails(a, b *model.Station) bool {
+	return a.Brand == b.Brand && a.Address == b.Address && a.Latitude == b.Latitude && a.Longitude == b.Longitude &&
+		a.ExternalID == b.ExternalID && a.ChargePointID == b.ChargePointID &&
+		slices.Equal(a.Amenities, b.Amenities) &&
+		reflect.DeepEqual(a.OpeningHours, b.OpeningHours) && reflect.DeepEqual(a.Access, b.Access)
+}
+
+// validateImported returns what is wrong with an imported station.
+func validateImported(station *model.Station) []string {
+	var problems []string
+	if station.Brand == "" {
+		problems = append(problems, "missing brand")
+	}
+	if station.Latitude < -90 || station.Latitude > 90 || station.Longitude < -180 || station.Longitude > 180 ||
+		station.Latitude == 0 && station.Longitude == 0 {
+		problems = append(problems, ErrInvalidLocation.Error())
+	}
+	if len(station.Sockets) == 0 {
+		problems = append(problems, "station has no socket")
+	}
+	for _, socket := range station.Sockets {
+		if socket.SocketType == "" || socket.KW <= 0 || socket.Price < 0 {
+			problems = append(problems, "every socket needs a type, a positive power and a price which is not negative")
+			break
+		}
+	}
+	if err := validateDetails(station); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+func importKey(station *model.Station) string {
+	if station.ExternalID != "" {
+		return station.ExternalID
+	}
+	return fmt.Sprintf("%g,%g", station.Latitude, station.Longitude)
+}
@@ -0,0 +1,56 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// lookupFailingStore fails to look up the stations with the external id.
+type lookupFailingStore struct {
+	repository.Store
+	externalId string
+}
+
+var errLookup = errors.New("lookup failed")
+
+func (s *lookupFailingStore) FindStationByFilter(ctx context.Context, filter bson.M) ([]*model.Station, error) {
+	if filter["ExternalID"] == s.externalId {
+		return nil, errLookup
+	}
+	return s.Store.FindStationByFilter(ctx, filter)
+}
+
+func TestImportStationsRecordsStoreErrors(t *testing.T) {
+	store := &lookupFailingStore{Store: repository.NewMemoryStore(), externalId: "B"}
+	s := NewStationService(store)
+	ctx := userContext("admin", model.Admin)
+	file := strings.NewReader("id,brand,latitude,longitude,socket_type,kw,current_type,price\n" +
+		"A,ZES,41.01,29.01,CCS,50,DC,10\n" +
+		"B,ZES,41.02,29.02,CCS,50,DC,10\n" +
+		"C,ZES,41.03,29.03,CCS,50,DC,10\n")
+
+	job, err := s.ImportStations(ctx, model.ImportCSV, file, false)
+	if err != nil {
+		t.Fatalf("ImportStations: %v", err)
+	}
+	if job.Total != 3 || job.Created != 2 || job.Failed != 1 {
+		t.Fatalf("job counts = %d total, %d created, %d failed; want 3, 2 and 1", job.Total, job.Created, job.Failed)
+	}
+	if row := job.Rows[1]; row.ExternalID != "B" || row.Action != model.ImportFailed || len(row.Errors) != 1 || row.Errors[0] != errLookup.Error() {
+		t.Errorf("row of the failed lookup = %+v", row)
+	}
+
+	stored, err := s.GetImportJob(ctx, job.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetImportJob: %v", err)
+	}
+	if stored.Created != 2 || stored.Failed != 1 || len(stored.Rows) != 3 {
+		t.Errorf("stored job = %+v", stored)
+	}
+}
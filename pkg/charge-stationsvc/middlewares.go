@@ -2,13 +2,14 @@ package charge_stationsvc
 
 import (
 	"context"
+	"io"
 	"strings"
 	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
-	"california/pkg/usersvc"
+	"california/pkg/repository"
 	"github.com/go-kit/kit/log"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type Middleware func(service StationService) StationService
@@ -38,6 +39,17 @@ func (mw loggingMiddleware) DeleteSocket(ctx context.Context, socketId string) (
 	return mw.next.DeleteSocket(ctx, socketId)
 }
 
+func (mw loggingMiddleware) ResetChargePointKey(ctx context.Context, stationId string) (key string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ResetChargePointKey",
+			"station_id", stationId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ResetChargePointKey(ctx, stationId)
+}
+
 func (mw loggingMiddleware) InsertStations(ctx context.Context, stations []*model.Station) (err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
@@ -48,14 +60,14 @@ func (mw loggingMiddleware) InsertStations(ctx context.Context, stations []*mode
 	return mw.next.InsertStations(ctx, stations)
 }
 
-func (mw loggingMiddleware) FilterStation(ctx context.Context, brandName []string, socketType []string, currentType int) (stations []*model.Station, err error) {
+func (mw loggingMiddleware) FilterStation(ctx context.Context, filter StationFilter, page repository.Page) (stations []*model.Station, nextCursor string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "FilterStation",
 			"took", time.Since(begin),
 			"err", err)
 	}(time.Now())
-	return mw.next.FilterStation(ctx, brandName, socketType, currentType)
+	return mw.next.FilterStation(ctx, filter, page)
 }
 
 func (mw loggingMiddleware) GetStation(ctx context.Context, stationId string) (station *model.Station, err error) {
@@ -79,14 +91,14 @@ func (mw loggingMiddleware) ListBrands(ctx context.Context) (brands []string, er
 	return mw.next.ListBrands(ctx)
 }
 
-func (mw loggingMiddleware) ListSockets(ctx context.Context) (sockets []*model.Socket, err error) {
+func (mw loggingMiddleware) ListSockets(ctx context.Context, page repository.Page) (sockets []*model.Socket, nextCursor string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "ListSockets",
 			"took", time.Since(begin),
 			"err", err)
 	}(time.Now())
-	return mw.next.ListSockets(ctx)
+	return mw.next.ListSockets(ctx, page)
 }
 
 func (mw loggingMiddleware) StationRegister(ctx context.Context, station *model.Station) (insertedStation *model.Station, err error) {
@@ -111,14 +123,14 @@ func (mw loggingMiddleware) SearchStation(ctx context.Context, brandName string)
 	return mw.next.SearchStation(ctx, brandName)
 }
 
-func (mw loggingMiddleware) GetStations(ctx context.Context) (stations []*model.Station, err error) {
+func (mw loggingMiddleware) GetStations(ctx context.Context, page repository.Page) (stations []*model.Station, nextCursor string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "GetAllStations",
 			"took", time.Since(begin),
 			"err", err)
 	}(time.Now())
-	return mw.next.GetStations(ctx)
+	return mw.next.GetStations(ctx, page)
 }
 
 func (mw loggingMiddleware) UpdateStation(ctx context.Context, station *model.Station, stationId string) (err error) {
@@ -143,132 +155,509 @@ func (mw loggingMiddleware) RemoveStation(ctx context.Context, stationId string)
 	return mw.next.RemoveStation(ctx, stationId)
 }
 
-type authMiddleware struct {
-	next       StationService
-	signingKey string
-	c          context.Context
+func (mw loggingMiddleware) NearbyStations(ctx context.Context, point model.Coordinate, radiusKm float64, limit int) (stations []*model.Station, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "NearbyStations",
+			"lat", point.Lat,
+			"long", point.Long,
+			"radius_km", radiusKm,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.NearbyStations(ctx, point, radiusKm, limit)
+}
+
+func (mw loggingMiddleware) StreamStations(ctx context.Context, filter StreamFilter) (events <-chan *model.StationEvent, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "StreamStations",
+			"brands", strings.Join(filter.Brands, ","),
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.StreamStations(ctx, filter)
+}
+
+func (mw loggingMiddleware) IssueStreamTicket(ctx context.Context) (ticket string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "IssueStreamTicket",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.IssueStreamTicket(ctx)
+}
+
+func (mw loggingMiddleware) RedeemStreamTicket(ctx context.Context, ticket string) (claims *auth.Claims, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "RedeemStreamTicket",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.RedeemStreamTicket(ctx, ticket)
+}
+
+func (mw loggingMiddleware) ReserveSocket(ctx context.Context, socketId string, startsAt, endsAt time.Time) (reservation *model.Reservation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ReserveSocket",
+			"socket_id", socketId,
+			"starts_at", startsAt,
+			"ends_at", endsAt,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ReserveSocket(ctx, socketId, startsAt, endsAt)
+}
+
+func (mw loggingMiddleware) CancelReservation(ctx context.Context, reservationId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CancelReservation",
+			"reservation_id", reservationId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.CancelReservation(ctx, reservationId)
+}
+
+func (mw loggingMiddleware) MyReservations(ctx context.Context) (reservations []*model.Reservation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "MyReservations",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.MyReservations(ctx)
+}
+
+func (mw loggingMiddleware) StartChargingSession(ctx context.Context, socketId string) (session *model.ChargingSession, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "StartChargingSession",
+			"socket_id", socketId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.StartChargingSession(ctx, socketId)
+}
+
+func (mw loggingMiddleware) StopChargingSession(ctx context.Context, sessionId string, energyKWh *float64) (session *model.ChargingSession, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "StopChargingSession",
+			"session_id", sessionId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.StopChargingSession(ctx, sessionId, energyKWh)
+}
+
+func (mw loggingMiddleware) MySessions(ctx context.Context) (sessions []*model.ChargingSession, monthly []model.MonthlyChargingTotal, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "MySessions",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.MySessions(ctx)
+}
+
+func (mw loggingMiddleware) CreateTariff(ctx context.Context, tariff *model.Tariff) (insertedTariff *model.Tariff, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CreateTariff",
+			"name", tariff.Name,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.CreateTariff(ctx, tariff)
+}
+
+func (mw loggingMiddleware) ListTariffs(ctx context.Context) (tariffs []*model.Tariff, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListTariffs",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ListTariffs(ctx)
 }
 
-func (aw authMiddleware) StationRegister(ctx context.Context, station *model.Station) (insertedStation *model.Station, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (mw loggingMiddleware) UpdateTariff(ctx context.Context, tariff *model.Tariff, tariffId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "UpdateTariff",
+			"tariff_id", tariffId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.UpdateTariff(ctx, tariff, tariffId)
+}
+
+func (mw loggingMiddleware) DeleteTariff(ctx context.Context, tariffId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "DeleteTariff",
+			"tariff_id", tariffId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.DeleteTariff(ctx, tariffId)
+}
+
+func (mw loggingMiddleware) QuoteSocket(ctx context.Context, socketId string, energyKWh float64, startsAt time.Time) (quote *model.PriceQuote, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "QuoteSocket",
+			"socket_id", socketId,
+			"energy_kwh", energyKWh,
+			"starts_at", startsAt,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.QuoteSocket(ctx, socketId, energyKWh, startsAt)
+}
+
+func (mw loggingMiddleware) AddReview(ctx context.Context, stationId string, review *model.Review) (insertedReview *model.Review, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "AddReview",
+			"station_id", stationId,
+			"rating", review.Rating,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.AddReview(ctx, stationId, review)
+}
+
+func (mw loggingMiddleware) StationReviews(ctx context.Context, stationId string) (reviews []*model.Review, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "StationReviews",
+			"station_id", stationId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.StationReviews(ctx, stationId)
+}
+
+func (mw loggingMiddleware) DeleteReview(ctx context.Context, reviewId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "DeleteReview",
+			"review_id", reviewId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.DeleteReview(ctx, reviewId)
+}
+
+func (mw loggingMiddleware) ListReviews(ctx context.Context, status model.ReviewStatus) (reviews []*model.Review, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListReviews",
+			"status", status,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ListReviews(ctx, status)
+}
+
+func (mw loggingMiddleware) ModerateReview(ctx context.Context, reviewId string, status model.ReviewStatus, note string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ModerateReview",
+			"review_id", reviewId,
+			"status", status,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ModerateReview(ctx, reviewId, status, note)
+}
+
+func (mw loggingMiddleware) ReportIssue(ctx context.Context, socketId string, report *model.IssueReport) (insertedReport *model.IssueReport, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ReportIssue",
+			"socket_id", socketId,
+			"kind", report.Kind,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ReportIssue(ctx, socketId, report)
+}
+
+func (mw loggingMiddleware) ListIssueReports(ctx context.Context, status model.IssueStatus, stationId string) (reports []*model.IssueReport, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListIssueReports",
+			"status", status,
+			"station_id", stationId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ListIssueReports(ctx, status, stationId)
+}
+
+func (mw loggingMiddleware) CloseIssueReport(ctx context.Context, reportId string, status model.IssueStatus) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CloseIssueReport",
+			"report_id", reportId,
+			"status", status,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.CloseIssueReport(ctx, reportId, status)
+}
+
+func (mw loggingMiddleware) ImportStations(ctx context.Context, format model.ImportFormat, file io.Reader, dryRun bool) (job *model.ImportJob, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ImportStations",
+			"format", format,
+			"dry_run", dryRun,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ImportStations(ctx, format, file, dryRun)
+}
+
+func (mw loggingMiddleware) ListImportJobs(ctx context.Context) (jobs []*model.ImportJob, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListImportJobs",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ListImportJobs(ctx)
+}
+
+func (mw loggingMiddleware) GetImportJob(ctx context.Context, jobId string) (job *model.ImportJob, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "GetImportJob",
+			"job_id", jobId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.GetImportJob(ctx, jobId)
+}
+
+type authorizationMiddleware struct {
+	next StationService
+}
+
+func (am authorizationMiddleware) StationRegister(ctx context.Context, station *model.Station) (insertedStation *model.Station, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return nil, e
 	}
-	return aw.next.StationRegister(ctx, station)
+	return am.next.StationRegister(ctx, station)
 }
 
-func (aw authMiddleware) GetStations(ctx context.Context) (stations []*model.Station, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return nil, e
+func (am authorizationMiddleware) InsertStations(ctx context.Context, stations []*model.Station) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return e
 	}
-	return aw.next.GetStations(ctx)
+	return am.next.InsertStations(ctx, stations)
+}
+
+func (am authorizationMiddleware) GetStations(ctx context.Context, page repository.Page) (stations []*model.Station, nextCursor string, err error) {
+	return am.next.GetStations(ctx, page)
 }
 
-func (aw authMiddleware) UpdateStation(ctx context.Context, station *model.Station, stationId string) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) GetStation(ctx context.Context, stationId string) (station *model.Station, err error) {
+	return am.next.GetStation(ctx, stationId)
+}
+
+func (am authorizationMiddleware) UpdateStation(ctx context.Context, station *model.Station, stationId string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return e
 	}
-	return aw.next.UpdateStation(ctx, station, stationId)
+	return am.next.UpdateStation(ctx, station, stationId)
 }
 
-func (aw authMiddleware) RemoveStation(ctx context.Context, stationId string) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) RemoveStation(ctx context.Context, stationId string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return e
 	}
-	return aw.next.RemoveStation(ctx, stationId)
+	return am.next.RemoveStation(ctx, stationId)
 }
 
-func (aw authMiddleware) SearchStation(ctx context.Context, brandName string) (stations []*model.Station, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return nil, e
+func (am authorizationMiddleware) DeleteSocket(ctx context.Context, socketId string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return e
 	}
-	return aw.next.SearchStation(ctx, brandName)
+	return am.next.DeleteSocket(ctx, socketId)
 }
 
-func (aw authMiddleware) ListBrands(ctx context.Context) (brands []string, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return nil, e
+func (am authorizationMiddleware) ResetChargePointKey(ctx context.Context, stationId string) (key string, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return "", e
 	}
-	return aw.next.ListBrands(ctx)
+	return am.next.ResetChargePointKey(ctx, stationId)
+}
+
+func (am authorizationMiddleware) SearchStation(ctx context.Context, brandName string) (stations []*model.Station, err error) {
+	return am.next.SearchStation(ctx, brandName)
+}
+
+func (am authorizationMiddleware) ListBrands(ctx context.Context) (brands []string, err error) {
+	return am.next.ListBrands(ctx)
+}
+
+func (am authorizationMiddleware) ListSockets(ctx context.Context, page repository.Page) (sockets []*model.Socket, nextCursor string, err error) {
+	return am.next.ListSockets(ctx, page)
+}
+
+func (am authorizationMiddleware) FilterStation(ctx context.Context, filter StationFilter, page repository.Page) (stations []*model.Station, nextCursor string, err error) {
+	return am.next.FilterStation(ctx, filter, page)
+}
+
+func (am authorizationMiddleware) NearbyStations(ctx context.Context, point model.Coordinate, radiusKm float64, limit int) (stations []*model.Station, err error) {
+	return am.next.NearbyStations(ctx, point, radiusKm, limit)
+}
+
+func (am authorizationMiddleware) StreamStations(ctx context.Context, filter StreamFilter) (events <-chan *model.StationEvent, err error) {
+	return am.next.StreamStations(ctx, filter)
+}
+
+func (am authorizationMiddleware) IssueStreamTicket(ctx context.Context) (ticket string, err error) {
+	return am.next.IssueStreamTicket(ctx)
+}
+
+func (am authorizationMiddleware) RedeemStreamTicket(ctx context.Context, ticket string) (claims *auth.Claims, err error) {
+	return am.next.RedeemStreamTicket(ctx, ticket)
+}
+
+func (am authorizationMiddleware) ReserveSocket(ctx context.Context, socketId string, startsAt, endsAt time.Time) (reservation *model.Reservation, err error) {
+	return am.next.ReserveSocket(ctx, socketId, startsAt, endsAt)
+}
+
+func (am authorizationMiddleware) CancelReservation(ctx context.Context, reservationId string) (err error) {
+	return am.next.CancelReservation(ctx, reservationId)
+}
+
+func (am authorizationMiddleware) MyReservations(ctx context.Context) (reservations []*model.Reservation, err error) {
+	return am.next.MyReservations(ctx)
+}
+
+func (am authorizationMiddleware) StartChargingSession(ctx context.Context, socketId string) (session *model.ChargingSession, err error) {
+	return am.next.StartChargingSession(ctx, socketId)
+}
+
+func (am authorizationMiddleware) StopChargingSession(ctx context.Context, sessionId string, energyKWh *float64) (session *model.ChargingSession, err error) {
+	return am.next.StopChargingSession(ctx, sessionId, energyKWh)
+}
+
+func (am authorizationMiddleware) MySessions(ctx context.Context) (sessions []*model.ChargingSession, monthly []model.MonthlyChargingTotal, err error) {
+	return am.next.MySessions(ctx)
 }
 
-func (aw authMiddleware) ListSockets(ctx context.Context) (sockets []*model.Socket, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) CreateTariff(ctx context.Context, tariff *model.Tariff) (insertedTariff *model.Tariff, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return nil, e
 	}
-	return aw.next.ListSockets(ctx)
+	return am.next.CreateTariff(ctx, tariff)
 }
 
-func (aw authMiddleware) FilterStation(ctx context.Context, brandName []string, socketType []string, currentType int) (stations []*model.Station, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return nil, e
+func (am authorizationMiddleware) ListTariffs(ctx context.Context) (tariffs []*model.Tariff, err error) {
+	return am.next.ListTariffs(ctx)
+}
+
+func (am authorizationMiddleware) UpdateTariff(ctx context.Context, tariff *model.Tariff, tariffId string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return e
 	}
-	return aw.next.FilterStation(ctx, brandName, socketType, currentType)
+	return am.next.UpdateTariff(ctx, tariff, tariffId)
 }
 
-func (aw authMiddleware) GetStation(ctx context.Context, stationId string) (station *model.Station, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) DeleteTariff(ctx context.Context, tariffId string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return e
+	}
+	return am.next.DeleteTariff(ctx, tariffId)
+}
+
+func (am authorizationMiddleware) QuoteSocket(ctx context.Context, socketId string, energyKWh float64, startsAt time.Time) (quote *model.PriceQuote, err error) {
+	return am.next.QuoteSocket(ctx, socketId, energyKWh, startsAt)
+}
+
+func (am authorizationMiddleware) AddReview(ctx context.Context, stationId string, review *model.Review) (insertedReview *model.Review, err error) {
+	return am.next.AddReview(ctx, stationId, review)
+}
+
+func (am authorizationMiddleware) StationReviews(ctx context.Context, stationId string) (reviews []*model.Review, err error) {
+	return am.next.StationReviews(ctx, stationId)
+}
+
+func (am authorizationMiddleware) DeleteReview(ctx context.Context, reviewId string) (err error) {
+	return am.next.DeleteReview(ctx, reviewId)
+}
+
+func (am authorizationMiddleware) ListReviews(ctx context.Context, status model.ReviewStatus) (reviews []*model.Review, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return nil, e
 	}
-	return aw.next.GetStation(ctx, stationId)
+	return am.next.ListReviews(ctx, status)
 }
 
-func (aw authMiddleware) DeleteSocket(ctx context.Context, socketId string) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) ModerateReview(ctx context.Context, reviewId string, status model.ReviewStatus, note string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return e
 	}
-	return aw.next.DeleteSocket(ctx, socketId)
+	return am.next.ModerateReview(ctx, reviewId, status, note)
 }
 
-func (aw authMiddleware) InsertStations(ctx context.Context, stations []*model.Station) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) ReportIssue(ctx context.Context, socketId string, report *model.IssueReport) (insertedReport *model.IssueReport, err error) {
+	return am.next.ReportIssue(ctx, socketId, report)
+}
+
+func (am authorizationMiddleware) ListIssueReports(ctx context.Context, status model.IssueStatus, stationId string) (reports []*model.IssueReport, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return nil, e
+	}
+	return am.next.ListIssueReports(ctx, status, stationId)
+}
+
+func (am authorizationMiddleware) CloseIssueReport(ctx context.Context, reportId string, status model.IssueStatus) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return e
 	}
-	return aw.next.InsertStations(ctx, stations)
+	return am.next.CloseIssueReport(ctx, reportId, status)
 }
 
-func AuthMiddleware(signingKey string) Middleware {
-	return func(next StationService) StationService {
-		return &authMiddleware{
-			next:       next,
-			signingKey: signingKey,
-		}
+func (am authorizationMiddleware) ImportStations(ctx context.Context, format model.ImportFormat, file io.Reader, dryRun bool) (job *model.ImportJob, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return nil, e
 	}
+	return am.next.ImportStations(ctx, format, file, dryRun)
 }
 
-func isAuthenticated(ctx context.Context, signingKey string) (context.Context, error) {
-	// Extract the JWT token from the request header and validate it.
-	tokenString := ctx.Value("Authorization").(string)
-	if tokenString == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
+func (am authorizationMiddleware) ListImportJobs(ctx context.Context) (jobs []*model.ImportJob, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return nil, e
 	}
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the token algorithm is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, usersvc.ErrUnexpectedSigningMethod
-		}
-		return []byte(signingKey), nil
-	})
-	if err != nil {
-		return nil, usersvc.ErrInvalidToken
+	return am.next.ListImportJobs(ctx)
+}
+
+func (am authorizationMiddleware) GetImportJob(ctx context.Context, jobId string) (job *model.ImportJob, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return nil, e
 	}
+	return am.next.GetImportJob(ctx, jobId)
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		ctx = context.WithValue(ctx, "email", claims["Email"])
-		ctx = context.WithValue(ctx, "userId", claims["userId"])
-		return ctx, nil
+// AuthorizationMiddleware restricts the station writes, the station imports and the moderation of the reviews
+// and issue reports to admins.
+// It relies on the claims put into the context by the auth.NewParser endpoint middleware.
+func AuthorizationMiddleware() Middleware {
+	return func(next StationService) StationService {
+		return &authorizationMiddleware{
+			next: next,
+		}
 	}
-	return nil, usersvc.ErrInvalidToken
 }
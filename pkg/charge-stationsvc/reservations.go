@@ -0,0 +1,178 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"california/pkg/auth"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	minReservationDuration = 15 * time.Minute
+	maxReservationDuration = 2 * time.Hour
+	// Requests are accepted slightly in the past to allow for clock differences with the clients.
+	reservationClockSkew = time.Minute
+
+	bookingHorizon        = 24 * time.Hour
+	premiumBookingHorizon = 7 * 24 * time.Hour
+)
+
+var (
+	ErrSocketNotFound       = errors.New("socket not found")
+	ErrSocketUnavailable    = errors.New("socket is out of service")
+	ErrInvalidReservation   = errors.New("invalid reservation window")
+	ErrBeyondBookingHorizon = errors.New("reservation starts beyond the booking horizon")
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrReservationNotActive = errors.New("reservation is not active")
+)
+
+// ReserveSocket books the socket for the current user between startsAt and endsAt.
+// Normal users can book up to a day ahead, premium users and admins up to a week ahead.
+func (s *chargeStationService) ReserveSocket(ctx context.Context, socketId string, startsAt, endsAt time.Time) (*model.Reservation, error) {
+	userId := auth.UserID(ctx)
+	if userId == "" {
+		return nil, auth.ErrInvalidToken
+	}
+
+	now := time.Now()
+	duration := endsAt.Sub(startsAt)
+	if startsAt.Before(now.Add(-reservationClockSkew)) || duration < minReservationDuration || duration > maxReservationDuration {
+		return nil, ErrInvalidReservation
+	}
+	horizon := bookingHorizon
+	if auth.Authorize(ctx, model.Premium, model.Admin) == nil {
+		horizon = premiumBookingHorizon
+	}
+	if startsAt.After(now.Add(horizon)) {
+		return nil, ErrBeyondBookingHorizon
+	}
+
+	station, socket, err := s.findSocket(ctx, socketId)
+	if err != nil {
+		return nil, err
+	}
+	if socket.Status == model.UnAvailable || socket.Status == model.Faulted {
+		return nil, ErrSocketUnavailable
+	}
+
+	// No-shows still hold the socket until they are expired.
+	if err = s.expireNoShows(ctx, bson.M{"SocketID": socket.ID}); err != nil {
+		return nil, err
+	}
+
+	reservation := &model.Reservation{
+		ID:        primitive.NewObjectID(),
+		UserID:    userId,
+		StationID: station.ID,
+		SocketID:  socket.ID,
+		StartsAt:  startsAt.UTC(),
+		EndsAt:    endsAt.UTC(),
+		Status:    model.ReservationActive,
+		CreatedAt: now.UTC(),
+	}
+	if err = s.store.InsertReservation(ctx, reservation); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// CancelReservation cancels an active reservation of the current user. Admins can cancel any reservation.
+func (s *chargeStationService) CancelReservation(ctx context.Context, reservationId string) error {
+	reservation, err := s.store.GetReservationById(ctx, reservationId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrReservationNotFound
+	} else if err != nil {
+		return err
+	}
+
+	userId := auth.UserID(ctx)
+	if reservation.UserID != userId {
+		if err = auth.Authorize(ctx, model.Admin); err != nil {
+			// Other users' reservations are not disclosed.
+			return ErrReservationNotFound
+		}
+	}
+
+	cancelled, err := s.store.UpdateReservationStatus(ctx, reservation.ID, model.ReservationActive, model.ReservationCancelled)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrReservationNotActive
+	}
+	return nil
+}
+
+// MyReservations lists the reservations of the current user, oldest first.
+func (s *chargeStationService) MyReservations(ctx context.Context) ([]*model.Reservation, error) {
+	userId := auth.UserID(ctx)
+	if userId == "" {
+		return nil, auth.ErrInvalidToken
+	}
+	if err := s.expireNoShows(ctx, bson.M{"UserID": userId}); err != nil {
+		return nil, err
+	}
+	return s.store.FindReservationsByFilter(ctx, bson.M{"UserID": userId})
+}
+
+// expireNoShows expires the active reservations matching the filter whose driver did not arrive within the grace period.
+// Reservations are expired lazily, when the sockets or the users they belong to are looked at.
+func (s *chargeStationService) expireNoShows(ctx context.Context, filter bson.M) error {
+	filter["Status"] = model.ReservationActive
+	filter["StartsAt"] = bson.M{"$lt": time.Now().Add(-model.ReservationNoShowGrace)}
+	reservations, err := s.store.FindReservationsByFilter(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, reservation := range reservations {
+		if _, err = s.store.UpdateReservationStatus(ctx, reservation.ID, model.ReservationActive, model.ReservationExpired); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markReserved reports the available sockets held by an active reservation as reserved.
+// The stored status is left untouched, it is only changed in the returned stations.
+func (s *chargeStationService) markReserved(ctx context.Context, stations ...*model.Station) error {
+	// Only the sockets which look available can be shown as reserved, so only their reservations are looked up.
+	var socketIds bson.A
+	for _, station := range stations {
+		for _, socket := range station.Sockets {
+			if socket.Status == model.Available {
+				socketIds = append(socketIds, socket.ID)
+			}
+		}
+	}
+	if len(socketIds) == 0 {
+		return nil
+	}
+	now := time.Now()
+	reservations, err := s.store.FindReservationsByFilter(ctx, bson.M{
+		"SocketID": bson.M{"$in": socketIds},
+		"Status":   model.ReservationActive,
+		"StartsAt": bson.M{"$lte": now.Add(model.ReservationLeadTime), "$gte": now.Add(-model.ReservationNoShowGrace)},
+		"EndsAt":   bson.M{"$gt": now},
+	})
+	if err != nil {
+		return err
+	}
+
+	reserved := make(map[primitive.ObjectID]bool, len(reservations))
+	for _, reservation := range reservations {
+		reserved[reservation.SocketID] = true
+	}
+	for _, station := range stations {
+		for i := range station.Sockets {
+			if station.Sockets[i].Status == model.Available && reserved[station.Sockets[i].ID] {
+				station.Sockets[i].Status = model.Reserved
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReserveSocket(t *testing.T) {
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+	tests := []struct {
+		name     string
+		userType model.UserType
+		startsAt time.Time
+		duration time.Duration
+		wantErr  error
+	}{
+		{name: "an hour", userType: model.Normal, startsAt: start, duration: time.Hour},
+		{name: "too short", userType: model.Normal, startsAt: start, duration: 10 * time.Minute, wantErr: ErrInvalidReservation},
+		{name: "too long", userType: model.Normal, startsAt: start, duration: 3 * time.Hour, wantErr: ErrInvalidReservation},
+		{name: "in the past", userType: model.Normal, startsAt: time.Now().Add(-time.Hour), duration: time.Hour, wantErr: ErrInvalidReservation},
+		{name: "beyond a day", userType: model.Normal, startsAt: start.Add(24 * time.Hour), duration: time.Hour, wantErr: ErrBeyondBookingHorizon},
+		{name: "premium beyond a day", userType: model.Premium, startsAt: start.Add(24 * time.Hour), duration: time.Hour},
+		{name: "admin beyond a day", userType: model.Admin, startsAt: start.Add(24 * time.Hour), duration: time.Hour},
+		{name: "premium beyond a week", userType: model.Premium, startsAt: start.Add(7 * 24 * time.Hour), duration: time.Hour, wantErr: ErrBeyondBookingHorizon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, socket := newSocket(t)
+			reservation, err := s.ReserveSocket(userContext("alice", tt.userType), socket.ID.Hex(), tt.startsAt, tt.startsAt.Add(tt.duration))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReserveSocket: got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (reservation.UserID != "alice" || reservation.Status != model.ReservationActive || !reservation.StartsAt.Equal(tt.startsAt)) {
+				t.Errorf("reservation = %+v", reservation)
+			}
+		})
+	}
+}
+
+func TestReserveSocketOverlap(t *testing.T) {
+	s, _, socket := newSocket(t)
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+	if _, err := s.ReserveSocket(userContext("alice", model.Normal), socket.ID.Hex(), start, start.Add(time.Hour)); err != nil {
+		t.Fatalf("ReserveSocket: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		startsAt time.Time
+		wantErr  error
+	}{
+		{name: "inside", startsAt: start.Add(15 * time.Minute), wantErr: repository.ErrReservationConflict},
+		{name: "over the start", startsAt: start.Add(-15 * time.Minute), wantErr: repository.ErrReservationConflict},
+		{name: "over the end", startsAt: start.Add(45 * time.Minute), wantErr: repository.ErrReservationConflict},
+		{name: "right after", startsAt: start.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ReserveSocket(userContext("bob", model.Normal), socket.ID.Hex(), tt.startsAt, tt.startsAt.Add(30*time.Minute))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReserveSocket: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReserveSocketUnavailable(t *testing.T) {
+	s, store, socket := newSocket(t)
+	if err := store.UpdateSocketStatus(context.Background(), socket.ID.Hex(), model.Faulted); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(time.Hour)
+	if _, err := s.ReserveSocket(userContext("alice", model.Normal), socket.ID.Hex(), start, start.Add(time.Hour)); !errors.Is(err, ErrSocketUnavailable) {
+		t.Errorf("ReserveSocket of a faulted socket: got %v, want %v", err, ErrSocketUnavailable)
+	}
+	if _, err := s.ReserveSocket(userContext("alice", model.Normal), primitive.NewObjectID().Hex(), start, start.Add(time.Hour)); !errors.Is(err, ErrSocketNotFound) {
+		t.Errorf("ReserveSocket of a missing socket: got %v, want %v", err, ErrSocketNotFound)
+	}
+}
+
+func TestNoShowsAreExpired(t *testing.T) {
+	s, store, socket := newSocket(t)
+	ctx := context.Background()
+	// The reservation of alice started longer ago than the grace period and the driver has not arrived.
+	noShow := &model.Reservation{
+		ID:        primitive.NewObjectID(),
+		UserID:    "alice",
+		SocketID:  socket.ID,
+		StartsAt:  time.Now().Add(-model.ReservationNoShowGrace - time.Minute).UTC(),
+		EndsAt:    time.Now().Add(time.Hour).UTC(),
+		Status:    model.ReservationActive,
+		CreatedAt: time.Now().Add(-time.Hour).UTC(),
+	}
+	if err := store.InsertReservation(ctx, noShow); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := s.ReserveSocket(userContext("bob", model.Normal), socket.ID.Hex(), start, start.Add(30*time.Minute)); err != nil {
+		t.Fatalf("ReserveSocket over a no-show: %v", err)
+	}
+	stored, err := store.GetReservationById(ctx, noShow.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != model.ReservationExpired {
+		t.Errorf("no-show status = %v, want expired", stored.Status)
+	}
+}
+
+func TestMyReservationsExpiresNoShows(t *testing.T) {
+	s, store, socket := newSocket(t)
+	noShow := &model.Reservation{
+		ID:        primitive.NewObjectID(),
+		UserID:    "alice",
+		SocketID:  socket.ID,
+		StartsAt:  time.Now().Add(-model.ReservationNoShowGrace - time.Minute).UTC(),
+		EndsAt:    time.Now().Add(time.Hour).UTC(),
+		Status:    model.ReservationActive,
+		CreatedAt: time.Now().Add(-time.Hour).UTC(),
+	}
+	if err := store.InsertReservation(context.Background(), noShow); err != nil {
+		t.Fatal(err)
+	}
+
+	reservations, err := s.MyReservations(userContext("alice", model.Normal))
+	if err != nil {
+		t.Fatalf("MyReservations: %v", err)
+	}
+	if len(reservations) != 1 || reservations[0].Status != model.ReservationExpired {
+		t.Errorf("reservations = %+v, want the no-show expired", reservations)
+	}
+}
+
+func TestCancelReservation(t *testing.T) {
+	s, _, socket := newSocket(t)
+	start := time.Now().Add(time.Hour)
+	reservation, err := s.ReserveSocket(userContext("alice", model.Normal), socket.ID.Hex(), start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.CancelReservation(userContext("bob", model.Normal), reservation.ID.Hex()); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("CancelReservation by another user: got %v, want %v", err, ErrReservationNotFound)
+	}
+	if err = s.CancelReservation(userContext("admin", model.Admin), reservation.ID.Hex()); err != nil {
+		t.Errorf("CancelReservation by an admin: %v", err)
+	}
+	if err = s.CancelReservation(userContext("alice", model.Normal), reservation.ID.Hex()); !errors.Is(err, ErrReservationNotActive) {
+		t.Errorf("CancelReservation twice: got %v, want %v", err, ErrReservationNotActive)
+	}
+	// The cancelled reservation no longer holds the socket.
+	if _, err = s.ReserveSocket(userContext("bob", model.Normal), socket.ID.Hex(), start, start.Add(time.Hour)); err != nil {
+		t.Errorf("ReserveSocket after the cancellation: %v", err)
+	}
+}
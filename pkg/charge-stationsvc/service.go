@@ -3,9 +3,14 @@ package charge_stationsvc
 import (
 	"context"
 	"errors"
-	"fmt"
+	"io"
+	"strings"
+	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
+	"california/pkg/ocpp"
+	"california/pkg/pricing"
 	"california/pkg/repository"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,15 +20,100 @@ import (
 type StationService interface {
 	StationRegister(context.Context, *model.Station) (insertedStation *model.Station, err error)
 	InsertStations(context.Context, []*model.Station) (err error)
-	GetStations(context.Context) (stations []*model.Station, err error)
+	// GetStations, ListSockets and FilterStation return a page of their results and the cursor of the next page.
+	GetStations(ctx context.Context, page repository.Page) (stations []*model.Station, nextCursor string, err error)
 	GetStation(ctx context.Context, stationId string) (station *model.Station, err error)
 	UpdateStation(ctx context.Context, station *model.Station, stationId string) (err error)
 	RemoveStation(ctx context.Context, stationId string) (err error)
 	DeleteSocket(ctx context.Context, socketId string) (err error)
+	// ResetChargePointKey issues a new OCPP key to the charge point of the station. The key is not kept, only its hash.
+	ResetChargePointKey(ctx context.Context, stationId string) (key string, err error)
 	SearchStation(ctx context.Context, brandName string) (stations []*model.Station, err error)
 	ListBrands(ctx context.Context) (brands []string, err error)
-	ListSockets(ctx context.Context) (sockets []*model.Socket, err error)
-	FilterStation(ctx context.Context, brandName []string, socketType []string, currentType int) (stations []*model.Station, err error)
+	ListSockets(ctx context.Context, page repository.Page) (sockets []*model.Socket, nextCursor string, err error)
+	FilterStation(ctx context.Context, filter StationFilter, page repository.Page) (stations []*model.Station, nextCursor string, err error)
+	NearbyStations(ctx context.Context, point model.Coordinate, radiusKm float64, limit int) (stations []*model.Station, err error)
+	StreamStations(ctx context.Context, filter StreamFilter) (events <-chan *model.StationEvent, err error)
+	// IssueStreamTicket and RedeemStreamTicket authenticate the station stream with a short-lived single-use ticket.
+	IssueStreamTicket(ctx context.Context) (ticket string, err error)
+	RedeemStreamTicket(ctx context.Context, ticket string) (claims *auth.Claims, err error)
+	ReserveSocket(ctx context.Context, socketId string, startsAt, endsAt time.Time) (reservation *model.Reservation, err error)
+	CancelReservation(ctx context.Context, reservationId string) (err error)
+	MyReservations(ctx context.Context) (reservations []*model.Reservation, err error)
+	StartChargingSession(ctx context.Context, socketId string) (session *model.ChargingSession, err error)
+	StopChargingSession(ctx context.Context, sessionId string, energyKWh *float64) (session *model.ChargingSession, err error)
+	MySessions(ctx context.Context) (sessions []*model.ChargingSession, monthly []model.MonthlyChargingTotal, err error)
+	CreateTariff(ctx context.Context, tariff *model.Tariff) (insertedTariff *model.Tariff, err error)
+	ListTariffs(ctx context.Context) (tariffs []*model.Tariff, err error)
+	UpdateTariff(ctx context.Context, tariff *model.Tariff, tariffId string) (err error)
+	DeleteTariff(ctx context.Context, tariffId string) (err error)
+	QuoteSocket(ctx context.Context, socketId string, energyKWh float64, startsAt time.Time) (quote *model.PriceQuote, err error)
+	AddReview(ctx context.Context, stationId string, review *model.Review) (insertedReview *model.Review, err error)
+	StationReviews(ctx context.Context, stationId string) (reviews []*model.Review, err error)
+	DeleteReview(ctx context.Context, reviewId string) (err error)
+	ListReviews(ctx context.Context, status model.ReviewStatus) (reviews []*model.Review, err error)
+	ModerateReview(ctx context.Context, reviewId string, status model.ReviewStatus, note string) (err error)
+	ReportIssue(ctx context.Context, socketId string, report *model.IssueReport) (insertedReport *model.IssueReport, err error)
+	ListIssueReports(ctx context.Context, status model.IssueStatus, stationId string) (reports []*model.IssueReport, err error)
+	CloseIssueReport(ctx context.Context, reportId string, status model.IssueStatus) (err error)
+	ImportStations(ctx context.Context, format model.ImportFormat, file io.Reader, dryRun bool) (job *model.ImportJob, err error)
+	ListImportJobs(ctx context.Context) (jobs []*model.ImportJob, err error)
+	GetImportJob(ctx context.Context, jobId string) (job *model.ImportJob, err error)
+}
+
+const (
+	defaultNearbyRadiusKm = 5
+	maxNearbyRadiusKm     = 100
+	defaultNearbyLimit    = 20
+	maxNearbyLimit        = 100
+)
+
+var (
+	ErrInvalidLocation     = repository.ErrInvalidLocation
+	ErrStreamUnavailable   = errors.New("station stream is not available")
+	ErrInvalidBoundingBox  = errors.New("invalid bounding box")
+	ErrInvalidOpeningHours = errors.New("invalid opening hours")
+	ErrInvalidAmenity      = errors.New("invalid amenity")
+	ErrInvalidAccess       = errors.New("invalid access restrictions")
+	ErrNoChargePoint       = errors.New("station has no charge point")
+)
+
+// BoundingBox is the area between two corners, the south west one and the north east one.
+type BoundingBox struct {
+	MinLat  float64
+	MinLong float64
+	MaxLat  float64
+	MaxLong float64
+}
+
+func (b BoundingBox) Contains(lat, long float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && long >= b.MinLong && long <= b.MaxLong
+}
+
+// StreamFilter limits the station events to the stations inside the bounding box
+// and of the given brands. Empty fields do not filter anything.
+type StreamFilter struct {
+	BoundingBox *BoundingBox
+	Brands      []string
+}
+
+func (f StreamFilter) matches(event *model.StationEvent) bool {
+	station := event.Station
+	if station == nil {
+		return f.BoundingBox == nil && len(f.Brands) == 0
+	}
+	if f.BoundingBox != nil && !f.BoundingBox.Contains(station.Latitude, station.Longitude) {
+		return false
+	}
+	if len(f.Brands) == 0 {
+		return true
+	}
+	for _, brand := range f.Brands {
+		if strings.EqualFold(brand, station.Brand) {
+			return true
+		}
+	}
+	return false
 }
 
 type chargeStationService struct {
@@ -39,6 +129,9 @@ func (s *chargeStationService) DeleteSocket(ctx context.Context, socketId string
 }
 
 func (s *chargeStationService) StationRegister(ctx context.Context, station *model.Station) (*model.Station, error) {
+	if err := validateDetails(station); err != nil {
+		return nil, err
+	}
 	lat := station.Latitude
 	long := station.Longitude
 
@@ -53,6 +146,8 @@ func (s *chargeStationService) StationRegister(ctx context.Context, station *mod
 		}
 
 		station.ID = primitive.NewObjectID()
+		station.SetLocation()
+		station.Rating = nil
 		insertedStation, err := s.store.InsertStation(ctx, station)
 		if err != nil {
 			return nil, err
@@ -84,6 +179,11 @@ func (s *chargeStationService) StationRegister(ctx context.Context, station *mod
 }
 
 func (s *chargeStationService) InsertStations(ctx context.Context, stations []*model.Station) (err error) {
+	for _, station := range stations {
+		if err = validateDetails(station); err != nil {
+			return err
+		}
+	}
 	for _, station := range stations {
 		lat := station.Latitude
 		long := station.Longitude
@@ -99,6 +199,8 @@ func (s *chargeStationService) InsertStations(ctx context.Context, stations []*m
 			}
 
 			station.ID = primitive.NewObjectID()
+			station.SetLocation()
+			station.Rating = nil
 			_, err := s.store.InsertStation(ctx, station)
 			if err != nil {
 				return err
@@ -128,12 +230,33 @@ func (s *chargeStationService) InsertStations(ctx context.Context, stations []*m
 	return nil
 }
 
-func (s *chargeStationService) GetStations(ctx context.Context) ([]*model.Station, error) {
-	stations, err := s.store.GetAllStations(ctx)
+// validateDetails checks the opening hours, the amenities and the access restrictions of the station.
+func validateDetails(station *model.Station) error {
+	if station.OpeningHours != nil && !station.OpeningHours.Valid() {
+		return ErrInvalidOpeningHours
+	}
+	for _, amenity := range station.Amenities {
+		if !amenity.Valid() {
+			return ErrInvalidAmenity
+		}
+	}
+	if access := station.Access; access != nil {
+		if access.ParkingFeePerHour < 0 || access.ParkingCurrency != "" && !pricing.ValidCurrency(access.ParkingCurrency) {
+			return ErrInvalidAccess
+		}
+	}
+	return nil
+}
+
+func (s *chargeStationService) GetStations(ctx context.Context, page repository.Page) ([]*model.Station, string, error) {
+	stations, nextCursor, err := s.store.FindStationsPage(ctx, bson.M{}, page)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return stations, nil
+	if err = s.markReserved(ctx, stations...); err != nil {
+		return nil, "", err
+	}
+	return stations, nextCursor, nil
 }
 
 func (s *chargeStationService) GetStation(ctx context.Context, stationId string) (station *model.Station, err error) {
@@ -141,10 +264,17 @@ func (s *chargeStationService) GetStation(ctx context.Context, stationId string)
 	if err != nil {
 		return nil, err
 	}
+	if err = s.markReserved(ctx, station); err != nil {
+		return nil, err
+	}
 	return station, nil
 }
 
 func (s *chargeStationService) UpdateStation(ctx context.Context, station *model.Station, stationId string) (err error) {
+	if err = validateDetails(station); err != nil {
+		return err
+	}
+	station.SetLocation()
 	err = s.store.UpdateStationInfo(ctx, station, stationId)
 	if err != nil {
 		return err
@@ -160,12 +290,35 @@ func (s *chargeStationService) RemoveStation(ctx context.Context, stationId stri
 	return nil
 }
 
+func (s *chargeStationService) ResetChargePointKey(ctx context.Context, stationId string) (key string, err error) {
+	station, err := s.store.GetStationById(ctx, stationId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrStationNotFound
+	} else if err != nil {
+		return "", err
+	}
+	if station.ChargePointID == "" {
+		return "", ErrNoChargePoint
+	}
+	key, err = ocpp.NewAuthorizationKey()
+	if err != nil {
+		return "", err
+	}
+	if err = s.store.SetChargePointKey(ctx, station.ID, ocpp.HashAuthorizationKey(key)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
 func (s *chargeStationService) SearchStation(ctx context.Context, brandName string) (stations []*model.Station, err error) {
 	filter := bson.M{"Brand": bson.M{"$regex": primitive.Regex{Pattern: brandName, Options: "i"}}}
 	stations, err = s.store.FindStationByFilter(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
+	if err = s.markReserved(ctx, stations...); err != nil {
+		return nil, err
+	}
 	return stations, nil
 }
 
@@ -185,70 +338,70 @@ func (s *chargeStationService) ListBrands(ctx context.Context) (brands []string,
 	return brands, nil
 }
 
-func (s *chargeStationService) ListSockets(ctx context.Context) (sockets []*model.Socket, err error) {
-	sockets, err = s.store.ListSockets(ctx)
+func (s *chargeStationService) ListSockets(ctx context.Context, page repository.Page) (sockets []*model.Socket, nextCursor string, err error) {
+	sockets, nextCursor, err = s.store.FindSocketsPage(ctx, bson.M{}, page)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return sockets, nil
+	return sockets, nextCursor, nil
 }
 
-func (s *chargeStationService) FilterStation(ctx context.Context, brandNames []string, socketNames []string, currentType int) (stations []*model.Station, err error) {
-
-	filter := bson.M{}
-
-	// Brand name'e göre filtreleme
-	if len(brandNames) > 0 {
-		filter["Brand"] = bson.M{
-			"$in": brandNames,
-		}
+func (s *chargeStationService) NearbyStations(ctx context.Context, point model.Coordinate, radiusKm float64, limit int) (stations []*model.Station, err error) {
+	if point.Lat < -90 || point.Lat > 90 || point.Long < -180 || point.Long > 180 {
+		return nil, ErrInvalidLocation
 	}
 
-	// socketNames'e göre filtreleme
-	if len(socketNames) > 0 {
-		filter["Sockets"] = bson.M{
-			"$elemMatch": bson.M{
-				"Name": bson.M{
-					"$in": socketNames,
-				},
-			},
-		}
+	if radiusKm <= 0 {
+		radiusKm = defaultNearbyRadiusKm
+	} else if radiusKm > maxNearbyRadiusKm {
+		radiusKm = maxNearbyRadiusKm
 	}
 
-	// currentType'a göre filtreleme
-	if currentType == 1 || currentType == 2 {
-		if elemMatch, ok := filter["Sockets"].(bson.M); ok {
-			elemMatch["$elemMatch"].(bson.M)["CurrentType"] = currentType
-		} else {
-			filter["Sockets"] = bson.M{
-				"$elemMatch": bson.M{
-					"CurrentType": currentType,
-				},
-			}
-		}
+	if limit <= 0 {
+		limit = defaultNearbyLimit
+	} else if limit > maxNearbyLimit {
+		limit = maxNearbyLimit
 	}
 
-	//filter := bson.M{
-	//	"Brand": bson.M{
-	//		"$in": brandNames, // birden fazla brandName kabul et
-	//	},
-	//	"Sockets": bson.M{
-	//		"$elemMatch": bson.M{
-	//			"Name": bson.M{
-	//				"$in": socketNames, // birden fazla socketName kabul et
-	//			},
-	//			"CurrentType": currentType,
-	//		},
-	//	},
-	//}
-	stations, err = s.store.FindStationByFilter(ctx, filter)
+	stations, err = s.store.FindStationsNear(ctx, point, radiusKm, limit)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("filter: ", filter)
+	if err = s.markReserved(ctx, stations...); err != nil {
+		return nil, err
+	}
 	return stations, nil
 }
 
+// StreamStations returns the changes made to the stations matching the filter until the context is done.
+func (s *chargeStationService) StreamStations(ctx context.Context, filter StreamFilter) (<-chan *model.StationEvent, error) {
+	notifier, ok := s.store.(repository.StationNotifier)
+	if !ok {
+		return nil, ErrStreamUnavailable
+	}
+	if b := filter.BoundingBox; b != nil && (b.MinLat > b.MaxLat || b.MinLong > b.MaxLong ||
+		b.MinLat < -90 || b.MaxLat > 90 || b.MinLong < -180 || b.MaxLong > 180) {
+		return nil, ErrInvalidBoundingBox
+	}
+
+	events := notifier.SubscribeStations(ctx)
+	filtered := make(chan *model.StationEvent)
+	go func() {
+		defer close(filtered)
+		for event := range events {
+			if !filter.matches(event) {
+				continue
+			}
+			select {
+			case filtered <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return filtered, nil
+}
+
 func removeDuplicates(duplicates []string) []string {
 	keys := make(map[string]bool)
 	list := []string{}
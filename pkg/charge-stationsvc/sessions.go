@@ -0,0 +1,253 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"california/pkg/auth"
+	"california/pkg/model"
+	"california/pkg/pricing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrSocketOccupied       = errors.New("socket is occupied")
+	ErrSocketReserved       = errors.New("socket is reserved by another user")
+	ErrSessionNotFound      = errors.New("charging session not found")
+	ErrSessionNotInProgress = errors.New("charging session is not in progress")
+	ErrInvalidEnergy        = errors.New("invalid energy")
+)
+
+// StartChargingSession starts a charge of the current user on the socket. A reservation of the user
+// due on the socket is fulfilled by it, while a reservation of someone else keeps the socket for them.
+func (s *chargeStationService) StartChargingSession(ctx context.Context, socketId string) (*model.ChargingSession, error) {
+	userId := auth.UserID(ctx)
+	if userId == "" {
+		return nil, auth.ErrInvalidToken
+	}
+
+	station, socket, err := s.findSocket(ctx, socketId)
+	if err != nil {
+		return nil, err
+	}
+	switch socket.Status {
+	case model.UnAvailable, model.Faulted:
+		return nil, ErrSocketUnavailable
+	case model.Occupied:
+		return nil, ErrSocketOccupied
+	}
+
+	if err = s.expireNoShows(ctx, bson.M{"SocketID": socket.ID}); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	reservations, err := s.store.FindReservationsByFilter(ctx, bson.M{
+		"SocketID": socket.ID,
+		"Status":   model.ReservationActive,
+		"StartsAt": bson.M{"$lte": now.Add(model.ReservationLeadTime)},
+		"EndsAt":   bson.M{"$gt": now},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var own *model.Reservation
+	for _, reservation := range reservations {
+		if reservation.UserID != userId {
+			return nil, ErrSocketReserved
+		}
+		own = reservation
+	}
+
+	tariffs, err := pricing.LoadTariffs(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+	tariff := tariffs.For(station, socket)
+
+	session := &model.ChargingSession{
+		ID:          primitive.NewObjectID(),
+		UserID:      userId,
+		StationID:   station.ID,
+		SocketID:    socket.ID,
+		Status:      model.ChargingInProgress,
+		StartedAt:   now.UTC(),
+		PricePerKWh: tariff.PricePerKWh,
+		Tariff:      tariff,
+		Currency:    tariff.Currency,
+	}
+	// The socket is claimed before the session is stored, so that only one of the users starting on it
+	// at the same time gets it.
+	claimed, err := s.store.ClaimSocket(ctx, socket.ID, []model.SocketStatus{model.Available, model.Reserved}, model.Occupied)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrSocketOccupied
+	}
+	if err = s.store.InsertChargingSession(ctx, session); err != nil {
+		if e := s.store.UpdateSocketStatus(ctx, socket.ID.Hex(), socket.Status); e != nil {
+			return nil, e
+		}
+		return nil, err
+	}
+	if own != nil {
+		if _, err = s.store.UpdateReservationStatus(ctx, own.ID, model.ReservationActive, model.ReservationFulfilled); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// StopChargingSession stops a session of the current user. The energy delivered is read from the meter of
+// the charge point when an OCPP transaction runs on the socket. Otherwise only admins may give it, e.g. to
+// correct a session, and it is estimated from the power of the socket for everyone else.
+func (s *chargeStationService) StopChargingSession(ctx context.Context, sessionId string, energyKWh *float64) (*model.ChargingSession, error) {
+	session, err := s.store.GetChargingSessionById(ctx, sessionId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	userId := auth.UserID(ctx)
+	if session.UserID != userId {
+		if err = auth.Authorize(ctx, model.Admin); err != nil {
+			return nil, ErrSessionNotFound
+		}
+	}
+	if session.Status != model.ChargingInProgress {
+		return nil, ErrSessionNotInProgress
+	}
+	if energyKWh != nil {
+		if err = auth.Authorize(ctx, model.Admin); err != nil {
+			return nil, err
+		}
+		if *energyKWh < 0 || math.IsNaN(*energyKWh) {
+			return nil, ErrInvalidEnergy
+		}
+	}
+
+	now := time.Now().UTC()
+	maxEnergy, err := s.maxEnergy(ctx, session, now)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.store.GetTransactionBySocket(ctx, session.SocketID)
+	switch {
+	case err == nil:
+		// The transaction outlives the session, so its final reading settles the session once the charge point stops it.
+		session.TransactionID = tx.ID
+		session.Complete(now, tx.EnergyKWh(), pricing.SessionCost(session, now, tx.EnergyKWh()), false)
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return nil, err
+	case energyKWh != nil:
+		if *energyKWh > maxEnergy {
+			return nil, ErrInvalidEnergy
+		}
+		session.Complete(now, *energyKWh, pricing.SessionCost(session, now, *energyKWh), false)
+	default:
+		estimate := maxEnergy
+		if math.IsInf(estimate, 1) {
+			estimate = 0
+		}
+		session.Complete(now, estimate, pricing.SessionCost(session, now, estimate), true)
+	}
+
+	completed, err := s.store.CompleteChargingSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if !completed {
+		return nil, ErrSessionNotInProgress
+	}
+	if err = s.store.UpdateSocketStatus(ctx, session.SocketID.Hex(), model.Available); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// MySessions lists the sessions of the current user, the most recent first, with the totals of each month.
+func (s *chargeStationService) MySessions(ctx context.Context) ([]*model.ChargingSession, []model.MonthlyChargingTotal, error) {
+	userId := auth.UserID(ctx)
+	if userId == "" {
+		return nil, nil, auth.ErrInvalidToken
+	}
+	sessions, err := s.store.FindChargingSessionsByFilter(ctx, bson.M{"UserID": userId})
+	if err != nil {
+		return nil, nil, err
+	}
+	return sessions, monthlyTotals(sessions), nil
+}
+
+// maxEnergy returns the most energy the socket could have delivered to the session until stoppedAt, without
+// exceeding the battery of the vehicle of the session's user. It is unbounded when the socket no longer exists
+// and the battery is unknown.
+func (s *chargeStationService) maxEnergy(ctx context.Context, session *model.ChargingSession, stoppedAt time.Time) (float64, error) {
+	_, socket, err := s.findSocket(ctx, session.SocketID.Hex())
+	if err != nil && !errors.Is(err, ErrSocketNotFound) {
+		return 0, err
+	}
+	energy := math.Inf(1)
+	if socket != nil {
+		energy = socket.KW * stoppedAt.Sub(session.StartedAt).Hours()
+	}
+
+	// The session may be stopped by an admin, whose own vehicle has nothing to do with it.
+	if user, err := s.store.GetUserById(ctx, session.UserID); err == nil {
+		if vehicle := user.DefaultVehicle(); vehicle != nil && vehicle.BatteryCapacity > 0 {
+			energy = math.Min(energy, vehicle.BatteryCapacity)
+		}
+	}
+	return energy, nil
+}
+
+// findSocket returns the socket together with the station it belongs to.
+func (s *chargeStationService) findSocket(ctx context.Context, socketId string) (*model.Station, *model.Socket, error) {
+	oid, err := primitive.ObjectIDFromHex(socketId)
+	if err != nil {
+		return nil, nil, ErrSocketNotFound
+	}
+	stations, err := s.store.FindStationByFilter(ctx, bson.M{"Sockets._id": oid})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, station := range stations {
+		for i := range station.Sockets {
+			if station.Sockets[i].ID == oid {
+				return station, &station.Sockets[i], nil
+			}
+		}
+	}
+	return nil, nil, ErrSocketNotFound
+}
+
+// monthlyTotals sums up the completed sessions by the month they started in and by their currency, following
+// the order of the sessions. Sessions started before tariffs were paid in the default currency.
+func monthlyTotals(sessions []*model.ChargingSession) []model.MonthlyChargingTotal {
+	type key struct{ month, currency string }
+	totals := []model.MonthlyChargingTotal{}
+	index := make(map[key]int)
+	for _, session := range sessions {
+		if session.Status != model.ChargingCompleted {
+			continue
+		}
+		k := key{month: session.StartedAt.UTC().Format("2006-01"), currency: session.Currency}
+		if k.currency == "" {
+			k.currency = pricing.DefaultCurrency
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(totals)
+			index[k] = i
+			totals = append(totals, model.MonthlyChargingTotal{Month: k.month, Currency: k.currency})
+		}
+		totals[i].Sessions++
+		totals[i].EnergyKWh = math.Round((totals[i].EnergyKWh+session.EnergyKWh)*1000) / 1000
+		totals[i].Cost = math.Round((totals[i].Cost+session.Cost)*100) / 100
+	}
+	return totals
+}
@@ -0,0 +1,241 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"california/pkg/auth"
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newSocket returns a service over a memory store with a station of one available 50 kW socket at 10 TRY/kWh.
+func newSocket(t *testing.T) (*chargeStationService, repository.Store, *model.Socket) {
+	t.Helper()
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	socket := model.Socket{ID: primitive.NewObjectID(), Name: "CCS", KW: 50, CurrentType: model.DC, Price: 10, Status: model.Available}
+	station := &model.Station{ID: primitive.NewObjectID(), Brand: "ZES", Latitude: 41, Longitude: 29, Sockets: []model.Socket{socket}}
+	station.SetLocation()
+	if _, err := store.InsertStation(ctx, station); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InsertSocket(ctx, &socket); err != nil {
+		t.Fatal(err)
+	}
+	return NewStationService(store).(*chargeStationService), store, &socket
+}
+
+func userContext(userId string, userType model.UserType) context.Context {
+	return auth.WithClaims(context.Background(), &auth.Claims{UserID: userId, UserType: userType})
+}
+
+func socketStatus(t *testing.T, s *chargeStationService, socketId primitive.ObjectID) model.SocketStatus {
+	t.Helper()
+	_, socket, err := s.findSocket(context.Background(), socketId.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return socket.Status
+}
+
+func TestStartChargingSession(t *testing.T) {
+	s, _, socket := newSocket(t)
+
+	session, err := s.StartChargingSession(userContext("alice", model.Normal), socket.ID.Hex())
+	if err != nil {
+		t.Fatalf("StartChargingSession: %v", err)
+	}
+	if session.UserID != "alice" || session.Status != model.ChargingInProgress || session.Currency != "TRY" || session.Tariff.PricePerKWh != 10 {
+		t.Errorf("session = %+v", session)
+	}
+	if status := socketStatus(t, s, socket.ID); status != model.Occupied {
+		t.Errorf("socket status = %v, want occupied", status)
+	}
+	if _, err = s.StartChargingSession(userContext("bob", model.Normal), socket.ID.Hex()); !errors.Is(err, ErrSocketOccupied) {
+		t.Errorf("StartChargingSession on an occupied socket: got %v, want %v", err, ErrSocketOccupied)
+	}
+	if _, err = s.StartChargingSession(userContext("bob", model.Normal), primitive.NewObjectID().Hex()); !errors.Is(err, ErrSocketNotFound) {
+		t.Errorf("StartChargingSession on a missing socket: got %v, want %v", err, ErrSocketNotFound)
+	}
+}
+
+func TestStartChargingSessionRace(t *testing.T) {
+	s, _, socket := newSocket(t)
+
+	const users = 8
+	var wg sync.WaitGroup
+	errs := make([]error, users)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.StartChargingSession(userContext(primitive.NewObjectID().Hex(), model.Normal), socket.ID.Hex())
+		}(i)
+	}
+	wg.Wait()
+
+	started := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			started++
+		case !errors.Is(err, ErrSocketOccupied):
+			t.Errorf("StartChargingSession: %v", err)
+		}
+	}
+	if started != 1 {
+		t.Errorf("%d sessions started on the same socket, want 1", started)
+	}
+}
+
+func TestStartChargingSessionReleasesSocketOnConflict(t *testing.T) {
+	s, store, socket := newSocket(t)
+	// Alice is already charging somewhere else, so her session on this socket cannot be stored.
+	busy := &model.ChargingSession{ID: primitive.NewObjectID(), UserID: "alice", SocketID: primitive.NewObjectID(), Status: model.ChargingInProgress, StartedAt: time.Now()}
+	if err := store.InsertChargingSession(context.Background(), busy); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.StartChargingSession(userContext("alice", model.Normal), socket.ID.Hex()); !errors.Is(err, repository.ErrChargingSessionConflict) {
+		t.Fatalf("StartChargingSession: got %v, want %v", err, repository.ErrChargingSessionConflict)
+	}
+	if status := socketStatus(t, s, socket.ID); status != model.Available {
+		t.Errorf("socket status after the failed start = %v, want available", status)
+	}
+}
+
+func TestStopChargingSession(t *testing.T) {
+	energy := func(kWh float64) *float64 { return &kWh }
+	tests := []struct {
+		name          string
+		userId        string
+		userType      model.UserType
+		energyKWh     *float64
+		wantErr       error
+		wantEnergyKWh float64
+		wantCost      float64
+		wantEstimated bool
+	}{
+		{name: "estimated from the socket", userId: "alice", userType: model.Normal, wantEnergyKWh: 50, wantCost: 500, wantEstimated: true},
+		{name: "energy given by the user", userId: "alice", userType: model.Normal, energyKWh: energy(10), wantErr: auth.ErrForbidden},
+		{name: "energy given by an admin", userId: "admin", userType: model.Admin, energyKWh: energy(10), wantEnergyKWh: 10, wantCost: 100},
+		{name: "more energy than the socket delivers", userId: "admin", userType: model.Admin, energyKWh: energy(60), wantErr: ErrInvalidEnergy},
+		{name: "negative energy", userId: "admin", userType: model.Admin, energyKWh: energy(-1), wantErr: ErrInvalidEnergy},
+		{name: "session of someone else", userId: "bob", userType: model.Normal, wantErr: ErrSessionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store, socket := newSocket(t)
+			ctx := context.Background()
+			// The session started an hour ago, so the 50 kW socket could deliver 50 kWh.
+			session := &model.ChargingSession{
+				ID:        primitive.NewObjectID(),
+				UserID:    "alice",
+				SocketID:  socket.ID,
+				Status:    model.ChargingInProgress,
+				StartedAt: time.Now().Add(-time.Hour),
+				Tariff:    &model.Tariff{Currency: "TRY", PricePerKWh: 10},
+				Currency:  "TRY",
+			}
+			if err := store.InsertChargingSession(ctx, session); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UpdateSocketStatus(ctx, socket.ID.Hex(), model.Occupied); err != nil {
+				t.Fatal(err)
+			}
+
+			stopped, err := s.StopChargingSession(userContext(tt.userId, tt.userType), session.ID.Hex(), tt.energyKWh)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StopChargingSession: got %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if status := socketStatus(t, s, socket.ID); status != model.Occupied {
+					t.Errorf("socket status after a refused stop = %v, want occupied", status)
+				}
+				return
+			}
+			if stopped.Status != model.ChargingCompleted || stopped.Estimated != tt.wantEstimated {
+				t.Errorf("session = %+v", stopped)
+			}
+			if diff := stopped.EnergyKWh - tt.wantEnergyKWh; diff < -0.01 || diff > 0.01 {
+				t.Errorf("energy = %v kWh, want %v", stopped.EnergyKWh, tt.wantEnergyKWh)
+			}
+			if diff := stopped.Cost - tt.wantCost; diff < -0.1 || diff > 0.1 {
+				t.Errorf("cost = %v, want %v", stopped.Cost, tt.wantCost)
+			}
+			if status := socketStatus(t, s, socket.ID); status != model.Available {
+				t.Errorf("socket status after the stop = %v, want available", status)
+			}
+			if _, err = s.StopChargingSession(userContext(tt.userId, tt.userType), session.ID.Hex(), nil); !errors.Is(err, ErrSessionNotInProgress) {
+				t.Errorf("StopChargingSession twice: got %v, want %v", err, ErrSessionNotInProgress)
+			}
+		})
+	}
+}
+
+func TestMySessions(t *testing.T) {
+	s, store, _ := newSocket(t)
+	ctx := context.Background()
+	january := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	february := time.Date(2024, 2, 10, 12, 0, 0, 0, time.UTC)
+	for _, session := range []struct {
+		userId    string
+		startedAt time.Time
+		currency  string
+		energyKWh float64
+		cost      float64
+		completed bool
+	}{
+		{"alice", january, "TRY", 10, 100, true},
+		{"alice", january.Add(24 * time.Hour), "", 5.5, 55.5, true}, // Started before tariffs, paid in TRY.
+		{"alice", january.Add(48 * time.Hour), "EUR", 20, 8, true},
+		{"alice", february, "TRY", 30, 300, true},
+		{"alice", february.Add(24 * time.Hour), "TRY", 0, 0, false},
+		{"bob", january, "TRY", 40, 400, true},
+	} {
+		stored := &model.ChargingSession{
+			ID:        primitive.NewObjectID(),
+			UserID:    session.userId,
+			SocketID:  primitive.NewObjectID(),
+			Status:    model.ChargingInProgress,
+			StartedAt: session.startedAt,
+			Currency:  session.currency,
+		}
+		if session.completed {
+			stored.Complete(session.startedAt.Add(time.Hour), session.energyKWh, session.cost, false)
+		}
+		if err := store.InsertChargingSession(ctx, stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, totals, err := s.MySessions(userContext("alice", model.Normal))
+	if err != nil {
+		t.Fatalf("MySessions: %v", err)
+	}
+	if len(sessions) != 5 {
+		t.Fatalf("MySessions returned %d sessions, want the 5 of alice", len(sessions))
+	}
+	want := map[model.MonthlyChargingTotal]bool{
+		{Month: "2024-01", Currency: "TRY", Sessions: 2, EnergyKWh: 15.5, Cost: 155.5}: true,
+		{Month: "2024-01", Currency: "EUR", Sessions: 1, EnergyKWh: 20, Cost: 8}:       true,
+		{Month: "2024-02", Currency: "TRY", Sessions: 1, EnergyKWh: 30, Cost: 300}:     true,
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("totals = %+v, want %d of them", totals, len(want))
+	}
+	for _, total := range totals {
+		if !want[total] {
+			t.Errorf("unexpected total %+v", total)
+		}
+	}
+
+	if _, _, err = s.MySessions(context.Background()); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("MySessions without a user: got %v, want %v", err, auth.ErrInvalidToken)
+	}
+}
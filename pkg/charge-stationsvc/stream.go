@@ -0,0 +1,76 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/auth"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// streamTicketTTL is how long a stream ticket can be used for. The client asks for a ticket right before
+// it opens the stream, so the ticket does not need to live longer.
+const streamTicketTTL = 30 * time.Second
+
+// IssueStreamTicket returns a single-use ticket opening the station stream as the authenticated user.
+// EventSource cannot set an Authorization header, so the ticket goes in the URL of the stream instead of
+// the access token. Only the hash of the ticket is kept.
+func (s *chargeStationService) IssueStreamTicket(ctx context.Context) (ticket string, err error) {
+	userId, err := primitive.ObjectIDFromHex(auth.UserID(ctx))
+	if err != nil {
+		return "", auth.ErrInvalidToken
+	}
+	ticket, err = helpers.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = s.store.InsertUserToken(ctx, &model.UserToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userId,
+		Purpose:   model.StreamTicket,
+		TokenHash: helpers.HashToken(ticket),
+		Email:     auth.Email(ctx),
+		CreatedAt: now,
+		ExpiresAt: now.Add(streamTicketTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemStreamTicket uses up the ticket and returns the claims of its user as they are now.
+func (s *chargeStationService) RedeemStreamTicket(ctx context.Context, ticket string) (claims *auth.Claims, err error) {
+	stored, err := s.store.GetUserTokenByHash(ctx, helpers.HashToken(ticket))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, auth.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	if stored.Purpose != model.StreamTicket || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	user, err := s.store.GetUserById(ctx, stored.UserID.Hex())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, auth.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	if user.Email != stored.Email {
+		return nil, auth.ErrInvalidToken
+	}
+	used, err := s.store.UseUserToken(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, auth.ErrInvalidToken
+	}
+	return &auth.Claims{Email: user.Email, UserID: user.ID.Hex(), UserType: user.UserType}, nil
+}
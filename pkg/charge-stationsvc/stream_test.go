@@ -0,0 +1,110 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/auth"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestStreamTicket(t *testing.T) {
+	s, store, _ := newSocket(t)
+	ctx := context.Background()
+	user := &model.User{ID: primitive.NewObjectID(), Email: "driver@example.com", UserType: model.Premium}
+	if _, err := store.InsertUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	userCtx := auth.WithClaims(ctx, &auth.Claims{Email: user.Email, UserID: user.ID.Hex(), UserType: user.UserType})
+
+	ticket, err := s.IssueStreamTicket(userCtx)
+	if err != nil {
+		t.Fatalf("IssueStreamTicket: %v", err)
+	}
+	claims, err := s.RedeemStreamTicket(ctx, ticket)
+	if err != nil {
+		t.Fatalf("RedeemStreamTicket: %v", err)
+	}
+	if claims.Email != user.Email || claims.UserID != user.ID.Hex() || claims.UserType != model.Premium {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err = s.RedeemStreamTicket(ctx, ticket); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("RedeemStreamTicket twice: got %v, want %v", err, auth.ErrInvalidToken)
+	}
+	if _, err = s.RedeemStreamTicket(ctx, "unknown"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("RedeemStreamTicket of an unknown ticket: got %v, want %v", err, auth.ErrInvalidToken)
+	}
+
+	expired := &model.UserToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Purpose:   model.StreamTicket,
+		TokenHash: helpers.HashToken("expired"),
+		Email:     user.Email,
+		CreatedAt: time.Now().Add(-time.Minute),
+		ExpiresAt: time.Now().Add(-time.Minute + streamTicketTTL),
+	}
+	if err = store.InsertUserToken(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.RedeemStreamTicket(ctx, "expired"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("RedeemStreamTicket of an expired ticket: got %v, want %v", err, auth.ErrInvalidToken)
+	}
+
+	reset := &model.UserToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Purpose:   model.ResetPassword,
+		TokenHash: helpers.HashToken("reset"),
+		Email:     user.Email,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err = store.InsertUserToken(ctx, reset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.RedeemStreamTicket(ctx, "reset"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("RedeemStreamTicket of a password reset token: got %v, want %v", err, auth.ErrInvalidToken)
+	}
+
+	if _, err = s.IssueStreamTicket(ctx); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("IssueStreamTicket without a user: got %v, want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+func TestStreamAuthenticatedWithTicket(t *testing.T) {
+	s, store, _ := newSocket(t)
+	ctx := context.Background()
+	user := &model.User{ID: primitive.NewObjectID(), Email: "driver@example.com", UserType: model.Normal}
+	if _, err := store.InsertUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := s.IssueStreamTicket(auth.WithClaims(ctx, &auth.Claims{Email: user.Email, UserID: user.ID.Hex()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var userId string
+	next := func(ctx context.Context, request interface{}) (interface{}, error) {
+		userId = auth.UserID(ctx)
+		return nil, nil
+	}
+	// The access token is not looked at when the stream has a ticket.
+	stream := streamAuthenticated(s, auth.NewParser(nil))(next)
+	if _, err = stream(ctx, streamStationsRequest{Ticket: ticket}); err != nil {
+		t.Fatalf("stream with a ticket: %v", err)
+	}
+	if userId != user.ID.Hex() {
+		t.Errorf("stream opened as %q, want %q", userId, user.ID.Hex())
+	}
+	if _, err = stream(ctx, streamStationsRequest{Ticket: ticket}); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("stream with a used ticket: got %v, want %v", err, auth.ErrInvalidToken)
+	}
+	if _, err = stream(ctx, streamStationsRequest{}); err == nil {
+		t.Error("stream without a ticket or an access token was opened")
+	}
+}
@@ -0,0 +1,92 @@
+package charge_stationsvc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/pricing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultQuoteEnergyKWh = 20
+	maxQuoteEnergyKWh     = 500
+)
+
+var (
+	ErrTariffNotFound = errors.New("tariff not found")
+	ErrInvalidQuote   = errors.New("invalid quote request")
+)
+
+// CreateTariff adds a tariff. Sockets use it once they refer to it or, for a brand tariff,
+// as soon as it is added.
+func (s *chargeStationService) CreateTariff(ctx context.Context, tariff *model.Tariff) (*model.Tariff, error) {
+	if err := pricing.Validate(tariff); err != nil {
+		return nil, err
+	}
+	tariff.ID = primitive.NewObjectID()
+	tariff.UpdatedAt = time.Now().UTC()
+	if err := s.store.InsertTariff(ctx, tariff); err != nil {
+		return nil, err
+	}
+	return tariff, nil
+}
+
+func (s *chargeStationService) ListTariffs(ctx context.Context) ([]*model.Tariff, error) {
+	return s.store.FindTariffsByFilter(ctx, bson.M{})
+}
+
+// UpdateTariff replaces the tariff. Charging sessions keep the tariff they started with.
+func (s *chargeStationService) UpdateTariff(ctx context.Context, tariff *model.Tariff, tariffId string) error {
+	oid, err := primitive.ObjectIDFromHex(tariffId)
+	if err != nil {
+		return ErrTariffNotFound
+	}
+	if err = pricing.Validate(tariff); err != nil {
+		return err
+	}
+	tariff.ID = oid
+	tariff.UpdatedAt = time.Now().UTC()
+	err = s.store.UpdateTariff(ctx, tariff)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrTariffNotFound
+	}
+	return err
+}
+
+// DeleteTariff removes the tariff. The sockets referring to it fall back to the tariff of their brand.
+func (s *chargeStationService) DeleteTariff(ctx context.Context, tariffId string) error {
+	err := s.store.DeleteTariff(ctx, tariffId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrTariffNotFound
+	}
+	return err
+}
+
+// QuoteSocket prices charging energyKWh on the socket from startsAt, with the tariff the socket would be charged with.
+func (s *chargeStationService) QuoteSocket(ctx context.Context, socketId string, energyKWh float64, startsAt time.Time) (*model.PriceQuote, error) {
+	if energyKWh == 0 {
+		energyKWh = defaultQuoteEnergyKWh
+	}
+	if energyKWh < 0 || energyKWh > maxQuoteEnergyKWh || math.IsNaN(energyKWh) {
+		return nil, ErrInvalidQuote
+	}
+	if startsAt.IsZero() {
+		startsAt = time.Now()
+	}
+
+	station, socket, err := s.findSocket(ctx, socketId)
+	if err != nil {
+		return nil, err
+	}
+	tariffs, err := pricing.LoadTariffs(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+	return pricing.QuoteFor(tariffs.For(station, socket), socket, startsAt.UTC(), energyKWh, 0), nil
+}
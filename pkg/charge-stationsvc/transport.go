@@ -1,24 +1,38 @@
 package charge_stationsvc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"california/pkg/auth"
+	"california/pkg/model"
+	"california/pkg/pricing"
+	"california/pkg/repository"
+	"california/pkg/stationimport"
 	"california/pkg/usersvc"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 )
 
-func MakeStationHTTPHandlers(c context.Context, s StationService, log log.Logger) http.Handler {
+const streamKeepAlive = 30 * time.Second
+
+// MakeStationHTTPHandlers serves the endpoints of the service. The tokens of the requests are verified with the keys.
+func MakeStationHTTPHandlers(s StationService, keys jwt.Keyfunc, log log.Logger) http.Handler {
 	r := mux.NewRouter()
-	e := MakeServerEndpoints(c, s)
+	e := MakeServerEndpoints(s, keys)
 	options := []httptransport.ServerOption{
+		httptransport.ServerBefore(auth.HTTPToContext),
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(log)),
 		httptransport.ServerErrorEncoder(encodeError),
 	}
@@ -32,8 +46,43 @@ func MakeStationHTTPHandlers(c context.Context, s StationService, log log.Logger
 	// GEt /station/search?brand=<brandName> searches for a station by brand name.
 	// GET /station/brands lists all the brands.
 	// GET /sockets lists all the sockets.
-	// GET /station/filter?brand=<brandName>&socket=<socketName>&current=<currentType> filters the stations by brand, socket and current type.
+	// GET /station/filter?brand=<brandName>&socket=<socketName>&socket_type=<socketType>&current=<currentType>&min_kw=<kW>&max_kw=<kW>
+	// &max_price=<price/kWh>&available=true&open_now=true&amenity=<amenity>&lat=<lat>&long=<long>&radius=<km> filters the stations;
+	// all the given criteria must match.
 	// DEL /socket?id=<socketId> deletes the socket.
+	// GET /stations/nearby?lat=<lat>&long=<long>&radius=<km>&limit=<n> lists the stations around a point, closest first.
+	// POST /reservations reserves a socket for a time window.
+	// GET /reservations/me lists the reservations of the current user.
+	// DELETE /reservations?id=<reservationId> cancels a reservation.
+	// POST /sessions/start starts a charging session on a socket.
+	// POST /sessions/{id}/stop stops a charging session.
+	// GET /sessions/me lists the charging sessions of the current user with their monthly totals.
+	// POST /tariffs adds a tariff.
+	// GET /tariffs lists the tariffs.
+	// PUT /tariffs?id=<tariffId> replaces a tariff.
+	// DELETE /tariffs?id=<tariffId> deletes a tariff.
+	// GET /socket/{id}/quote?kwh=<kWh>&start=<RFC3339 time> prices a charge on the socket.
+	// POST /station/{id}/charge-point-key issues a new OCPP key to the charge point of the station; the previous key stops working.
+	// POST /station/{id}/reviews rates and reviews a station, replacing the previous review of the user.
+	// GET /station/{id}/reviews lists the published reviews of a station.
+	// DELETE /reviews/{id} deletes a review.
+	// GET /reviews?status=<status> lists the reviews to moderate.
+	// PUT /reviews/{id}/moderation hides a review or publishes it again.
+	// POST /socket/{id}/issues reports an issue with a socket.
+	// GET /issues?status=<status>&station=<stationId> lists the issue reports.
+	// PUT /issues/{id} resolves or rejects an issue report.
+	// POST /station/import?format=<csv|ocpi>&dry_run=true imports the stations of the file in the body; the format defaults
+	// to csv for a text/csv body and to ocpi for a JSON one.
+	// GET /imports lists the import jobs.
+	// GET /imports/{id} gets an import job with the outcome of each of its stations.
+	// POST /stations/stream/ticket issues a single-use ticket opening the station stream within 30 seconds.
+	// GET /stations/stream?bbox=<minLat>,<minLong>,<maxLat>,<maxLong>&brand=<brandName>&ticket=<ticket> streams the station
+	// changes as server-sent events. EventSource cannot set the Authorization header, so browsers give a ticket instead
+	// of putting the access token in the URL.
+	//
+	// GET /stations, /sockets and /station/filter return a page of at most limit=<n> items, 50 by default.
+	// The next page is asked for with after=<next_cursor of the response>, which is empty on the last page.
+	// sort=<brand|distance|price|power> orders the stations, and sort=<price|power> the sockets; distance needs lat=<lat>&long=<long>.
 
 	r.Methods("POST").Path("/station").Handler(httptransport.NewServer(
 		e.StationRegisterEndpoint,
@@ -101,6 +150,162 @@ func MakeStationHTTPHandlers(c context.Context, s StationService, log log.Logger
 		encodeResponse,
 		options...,
 	))
+	r.Methods("GET").Path("/stations/nearby").Handler(httptransport.NewServer(
+		e.NearbyStationsEndpoint,
+		decodeNearbyStationsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/stations/stream").Handler(httptransport.NewServer(
+		e.StreamStationsEndpoint,
+		decodeStreamStationsRequest,
+		encodeStreamStationsResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/stations/stream/ticket").Handler(httptransport.NewServer(
+		e.StreamTicketEndpoint,
+		decodeStreamTicketRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/reservations").Handler(httptransport.NewServer(
+		e.ReserveSocketEndpoint,
+		decodeReserveSocketRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/reservations/me").Handler(httptransport.NewServer(
+		e.MyReservationsEndpoint,
+		decodeMyReservationsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/reservations").Handler(httptransport.NewServer(
+		e.CancelReservationEndpoint,
+		decodeCancelReservationRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/sessions/start").Handler(httptransport.NewServer(
+		e.StartSessionEndpoint,
+		decodeStartSessionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/sessions/{id}/stop").Handler(httptransport.NewServer(
+		e.StopSessionEndpoint,
+		decodeStopSessionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/sessions/me").Handler(httptransport.NewServer(
+		e.MySessionsEndpoint,
+		decodeMySessionsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/tariffs").Handler(httptransport.NewServer(
+		e.CreateTariffEndpoint,
+		decodeTariffRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/tariffs").Handler(httptransport.NewServer(
+		e.ListTariffsEndpoint,
+		decodeListTariffsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/tariffs").Handler(httptransport.NewServer(
+		e.UpdateTariffEndpoint,
+		decodeTariffRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/tariffs").Handler(httptransport.NewServer(
+		e.DeleteTariffEndpoint,
+		decodeDeleteTariffRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/socket/{id}/quote").Handler(httptransport.NewServer(
+		e.QuoteSocketEndpoint,
+		decodeQuoteSocketRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/station/{id}/charge-point-key").Handler(httptransport.NewServer(
+		e.ChargePointKeyEndpoint,
+		decodeChargePointKeyRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/station/{id}/reviews").Handler(httptransport.NewServer(
+		e.AddReviewEndpoint,
+		decodeAddReviewRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/station/{id}/reviews").Handler(httptransport.NewServer(
+		e.StationReviewsEndpoint,
+		decodeListReviewsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/reviews/{id}").Handler(httptransport.NewServer(
+		e.DeleteReviewEndpoint,
+		decodeDeleteReviewRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/reviews").Handler(httptransport.NewServer(
+		e.ListReviewsEndpoint,
+		decodeListReviewsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/reviews/{id}/moderation").Handler(httptransport.NewServer(
+		e.ModerateReviewEndpoint,
+		decodeModerateReviewRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/socket/{id}/issues").Handler(httptransport.NewServer(
+		e.ReportIssueEndpoint,
+		decodeReportIssueRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/issues").Handler(httptransport.NewServer(
+		e.ListIssueReportsEndpoint,
+		decodeListIssueReportsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/issues/{id}").Handler(httptransport.NewServer(
+		e.CloseIssueReportEndpoint,
+		decodeCloseIssueReportRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/station/import").Handler(httptransport.NewServer(
+		e.ImportStationsEndpoint,
+		decodeImportStationsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/imports").Handler(httptransport.NewServer(
+		e.ListImportJobsEndpoint,
+		decodeImportJobRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/imports/{id}").Handler(httptransport.NewServer(
+		e.GetImportJobEndpoint,
+		decodeImportJobRequest,
+		encodeResponse,
+		options...,
+	))
 	return r
 }
 
@@ -109,18 +314,7 @@ type errorer interface {
 }
 
 func decodeInsertStationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-
 	var req insertStationsRequest
-	req.Context = c
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return nil, err
 	}
@@ -128,131 +322,90 @@ func decodeInsertStationsRequest(ctx context.Context, r *http.Request) (interfac
 }
 
 func decodeDeleteSocketRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
 	socketId := r.URL.Query().Get("id")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
 
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-	c = context.WithValue(c, "socketId", socketId)
 	var req deleteSocketRequest
-	req.Context = c
 	req.SocketID = socketId
 	return req, nil
 }
 
 func decodeGetStationRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-	stationId := r.URL.Query().Get("id")
-
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-	c = context.WithValue(c, "stationId", stationId)
 	var req getStationRequest
-	req.Context = c
+	req.StationID = r.URL.Query().Get("id")
 	return req, nil
 }
 
 func decodeFilterStationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-
+	query := r.URL.Query()
 	var req filterStationsRequest
-	req.Context = c
-	req.BrandNames = r.URL.Query()["brand"]
-	req.SocketNames = r.URL.Query()["socket"]
-	req.CurrentType, _ = strconv.Atoi(r.URL.Query().Get("current"))
+	req.Filter.Brands = query["brand"]
+	req.Filter.SocketNames = query["socket"]
+	req.Filter.SocketTypes = query["socket_type"]
+	req.Filter.CurrentType, _ = strconv.Atoi(query.Get("current"))
+	req.Filter.AvailableOnly, _ = strconv.ParseBool(query.Get("available"))
+	req.Filter.OpenNow, _ = strconv.ParseBool(query.Get("open_now"))
+	for _, amenity := range query["amenity"] {
+		req.Filter.Amenities = append(req.Filter.Amenities, model.Amenity(amenity))
+	}
+	for param, value := range map[string]*float64{
+		"min_kw":    &req.Filter.MinKW,
+		"max_kw":    &req.Filter.MaxKW,
+		"max_price": &req.Filter.MaxPrice,
+		"radius":    &req.Filter.RadiusKm,
+	} {
+		if query.Get(param) == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(query.Get(param), 64)
+		if err != nil {
+			return nil, ErrInvalidFilter
+		}
+		*value = v
+	}
+	page, err := repository.ParsePage(query)
+	if err != nil {
+		return nil, err
+	}
+	req.Page = page
+	req.Filter.Near = page.Near
 	return req, nil
 }
 
 func decodeListSocketsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req listSocketsRequest
-	req.Context = c
+	page, err := repository.ParsePage(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	req.Page = page
 	return req, nil
 }
 
 func decodeListBrandsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req listBrandsRequest
-	req.Context = c
 	return req, nil
 }
 
 func decodeSearchStationRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
 	brand := r.URL.Query().Get("brand")
 
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req searchStationRequest
-	req.Context = c
 	req.Brand = brand
 	return req, nil
 }
 
 func decodeRemoveStationRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
 	stationId := r.URL.Query().Get("id")
 
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req removeStationRequest
-	req.Context = c
 	req.StationID = stationId
 	return req, nil
 }
 
 func decodeUpdateStationInfoRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
 	stationId := r.URL.Query().Get("id")
 
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req updateStationInfoRequest
-	req.Context = c
 	req.StationID = stationId
 	if err := json.NewDecoder(r.Body).Decode(&req.Station); err != nil {
 		return nil, err
@@ -261,16 +414,7 @@ func decodeUpdateStationInfoRequest(ctx context.Context, r *http.Request) (inter
 }
 
 func decodeStationRegisterRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req registerStationRequest
-	req.Context = c
 	if err := json.NewDecoder(r.Body).Decode(&req.Station); err != nil {
 		return nil, err
 	}
@@ -278,18 +422,307 @@ func decodeStationRegisterRequest(ctx context.Context, r *http.Request) (interfa
 }
 
 func decodeGetAllStationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
+	var req getAllStationsRequest
+	page, err := repository.ParsePage(r.URL.Query())
+	if err != nil {
+		return nil, err
 	}
+	req.Page = page
+	return req, nil
 
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-	var req getAllStationsRequest
-	req.Context = c
+}
+
+func decodeNearbyStationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil {
+		return nil, ErrInvalidLocation
+	}
+	long, err := strconv.ParseFloat(query.Get("long"), 64)
+	if err != nil {
+		return nil, ErrInvalidLocation
+	}
+
+	var req nearbyStationsRequest
+	req.Point = model.Coordinate{Lat: lat, Long: long}
+	req.RadiusKm, _ = strconv.ParseFloat(query.Get("radius"), 64)
+	req.Limit, _ = strconv.Atoi(query.Get("limit"))
+	return req, nil
+}
+
+func decodeReserveSocketRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req reserveSocketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeMyReservationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req myReservationsRequest
+	return req, nil
+}
+
+func decodeCancelReservationRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req cancelReservationRequest
+	req.ReservationID = r.URL.Query().Get("id")
+	return req, nil
+}
+
+func decodeStartSessionRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req startSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeStopSessionRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req stopSessionRequest
+	// The body is optional, a stop without the delivered energy has it estimated.
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	req.SessionID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeMySessionsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req mySessionsRequest
+	return req, nil
+}
+
+func decodeTariffRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req tariffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Tariff); err != nil {
+		return nil, err
+	}
+	req.TariffID = r.URL.Query().Get("id")
+	return req, nil
+}
+
+func decodeListTariffsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req listTariffsRequest
 	return req, nil
+}
 
+func decodeAddReviewRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req reviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Review); err != nil {
+		return nil, err
+	}
+	if req.Review == nil {
+		return nil, ErrInvalidReview
+	}
+	req.StationID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+// decodeListReviewsRequest decodes both the reviews of a station and the reviews to moderate.
+func decodeListReviewsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req listReviewsRequest
+	req.StationID = mux.Vars(r)["id"]
+	if status := r.URL.Query().Get("status"); status != "" {
+		n, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, ErrInvalidReview
+		}
+		req.Status = model.ReviewStatus(n)
+	}
+	return req, nil
+}
+
+func decodeDeleteReviewRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req reviewRequest
+	req.ReviewID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeModerateReviewRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req reviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	req.ReviewID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeReportIssueRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req issueReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Report); err != nil {
+		return nil, err
+	}
+	if req.Report == nil {
+		return nil, ErrInvalidIssueReport
+	}
+	req.SocketID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeListIssueReportsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req listIssueReportsRequest
+	req.StationID = r.URL.Query().Get("station")
+	if status := r.URL.Query().Get("status"); status != "" {
+		n, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, ErrInvalidIssueReport
+		}
+		req.Status = model.IssueStatus(n)
+	}
+	return req, nil
+}
+
+func decodeCloseIssueReportRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req issueReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	req.ReportID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+// maxImportFileSize is the size in bytes of the largest import file.
+const maxImportFileSize = 10 << 20
+
+func decodeImportStationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req importStationsRequest
+	query := r.URL.Query()
+	req.Format = model.ImportFormat(strings.ToLower(query.Get("format")))
+	if req.Format == "" {
+		req.Format = model.ImportCSV
+		if strings.Contains(r.Header.Get("Content-Type"), "json") {
+			req.Format = model.ImportOCPI
+		}
+	}
+	if !req.Format.Valid() {
+		return nil, fmt.Errorf("%w: unknown format %q", stationimport.ErrInvalidFile, req.Format)
+	}
+	if dryRun := query.Get("dry_run"); dryRun != "" {
+		var err error
+		if req.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return nil, fmt.Errorf("%w: dry_run %q is not a boolean", stationimport.ErrInvalidFile, dryRun)
+		}
+	}
+	// The file is read whole before anything is imported, so a file which is too large is refused rather than cut.
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxImportFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxImportFileSize {
+		return nil, ErrImportFileTooLarge
+	}
+	req.File = bytes.NewReader(body)
+	return req, nil
+}
+
+func decodeChargePointKeyRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req chargePointKeyRequest
+	req.StationID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeImportJobRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req importJobRequest
+	req.JobID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeDeleteTariffRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req tariffRequest
+	req.TariffID = r.URL.Query().Get("id")
+	return req, nil
+}
+
+func decodeQuoteSocketRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req quoteSocketRequest
+	req.SocketID = mux.Vars(r)["id"]
+
+	query := r.URL.Query()
+	if kwh := query.Get("kwh"); kwh != "" {
+		energy, err := strconv.ParseFloat(kwh, 64)
+		if err != nil {
+			return nil, ErrInvalidQuote
+		}
+		req.EnergyKWh = energy
+	}
+	if start := query.Get("start"); start != "" {
+		startsAt, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return nil, ErrInvalidQuote
+		}
+		req.StartsAt = startsAt
+	}
+	return req, nil
+}
+
+func decodeStreamStationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req streamStationsRequest
+	req.Ticket = r.URL.Query().Get("ticket")
+	req.Filter.Brands = r.URL.Query()["brand"]
+	if bbox := r.URL.Query().Get("bbox"); bbox != "" {
+		corners := strings.Split(bbox, ",")
+		if len(corners) != 4 {
+			return nil, ErrInvalidBoundingBox
+		}
+		var values [4]float64
+		for i, corner := range corners {
+			value, err := strconv.ParseFloat(strings.TrimSpace(corner), 64)
+			if err != nil {
+				return nil, ErrInvalidBoundingBox
+			}
+			values[i] = value
+		}
+		req.Filter.BoundingBox = &BoundingBox{MinLat: values[0], MinLong: values[1], MaxLat: values[2], MaxLong: values[3]}
+	}
+	return req, nil
+}
+
+func decodeStreamTicketRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req streamTicketRequest
+	return req, nil
+}
+
+// encodeStreamStationsResponse writes the station events as server-sent events until the client
+// disconnects. A comment is sent every streamKeepAlive so proxies do not close an idle stream.
+func encodeStreamStationsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(streamStationsResponse)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		encodeError(ctx, ErrStreamUnavailable, w)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-res.Events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return err
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+		flusher.Flush()
+	}
 }
 
 func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
@@ -323,12 +756,48 @@ func codeFrom(err error) int {
 		return http.StatusNotFound // 404
 	case errors.Is(err, usersvc.ErrAlreadyExists), errors.Is(err, usersvc.ErrInconsistentIDs):
 		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrInvalidLocation), errors.Is(err, ErrInvalidBoundingBox), errors.Is(err, ErrInvalidFilter):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrInvalidOpeningHours), errors.Is(err, ErrInvalidAmenity), errors.Is(err, ErrInvalidAccess):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrNoChargePoint):
+		return http.StatusConflict // 409
+	case errors.Is(err, ErrInvalidReview), errors.Is(err, ErrInvalidIssueReport):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrStationNotFound), errors.Is(err, ErrReviewNotFound), errors.Is(err, ErrIssueReportNotFound):
+		return http.StatusNotFound // 404
+	case errors.Is(err, ErrImportFileTooLarge):
+		return http.StatusRequestEntityTooLarge // 413
+	case errors.Is(err, stationimport.ErrInvalidFile):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrImportJobNotFound):
+		return http.StatusNotFound // 404
+	case errors.Is(err, ErrIssueReportClosed):
+		return http.StatusConflict // 409
+	case errors.Is(err, repository.ErrInvalidCursor), errors.Is(err, repository.ErrInvalidSort), errors.Is(err, repository.ErrInvalidLimit):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrInvalidReservation), errors.Is(err, ErrBeyondBookingHorizon), errors.Is(err, ErrInvalidEnergy):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, pricing.ErrInvalidTariff), errors.Is(err, ErrInvalidQuote):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrSocketNotFound), errors.Is(err, ErrReservationNotFound), errors.Is(err, ErrSessionNotFound),
+		errors.Is(err, ErrTariffNotFound):
+		return http.StatusNotFound // 404
+	case errors.Is(err, repository.ErrReservationConflict), errors.Is(err, ErrSocketUnavailable), errors.Is(err, ErrReservationNotActive):
+		return http.StatusConflict // 409
+	case errors.Is(err, repository.ErrChargingSessionConflict), errors.Is(err, ErrSocketOccupied), errors.Is(err, ErrSocketReserved),
+		errors.Is(err, ErrSessionNotInProgress):
+		return http.StatusConflict // 409
+	case errors.Is(err, ErrStreamUnavailable):
+		return http.StatusServiceUnavailable // 503
 	case errors.Is(err, usersvc.ErrAuthentication):
 		return http.StatusUnauthorized // 401
 	case errors.Is(err, usersvc.ErrPasswordEmailDoesNotMatch):
 		return http.StatusUnauthorized // 401
-	case errors.Is(err, usersvc.ErrNoAuthTokenHeader):
+	case errors.Is(err, auth.ErrNoAuthTokenHeader), errors.Is(err, auth.ErrInvalidToken):
 		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden // 403
 	default:
 		return http.StatusInternalServerError // 500
 	}
@@ -0,0 +1,96 @@
+// Package mailer sends the emails of the services, through an SMTP server or,
+// in tests and local development, by writing them to a file or a log.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"california/internal/config"
+)
+
+var (
+	ErrInvalidMessage = errors.New("invalid email message")
+	ErrNoMailer       = errors.New("no mailer configured: set SMTP_HOST, MAIL_FILE or MAIL_LOG=1")
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// validate keeps the header fields on a single line, so they cannot add headers of their own.
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+	return nil
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by the configuration: SMTP when a host is set, then the mail file,
+// then the standard error when the log mailer is asked for. Without any of them it returns ErrNoMailer,
+// so a service missing its SMTP settings does not start and quietly drop its emails.
+func New(cfg *config.Config) (Mailer, error) {
+	switch {
+	case cfg.SMTPHost != "":
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case cfg.MailFile != "":
+		return NewFileMailer(cfg.MailFile, cfg.MailFrom)
+	case cfg.MailLog:
+		return NewLogMailer(os.Stderr, cfg.MailFrom), nil
+	default:
+		return nil, ErrNoMailer
+	}
+}
+
+// LogMailer writes the emails to a writer instead of sending them.
+type LogMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewLogMailer(w io.Writer, from string) *LogMailer {
+	return &LogMailer{w: w, from: from}
+}
+
+// NewFileMailer returns a LogMailer appending the emails to the file, which is created when it does not exist.
+func NewFileMailer(path, from string) (*LogMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewLogMailer(f, from), nil
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "%s\n\n%s\n\n", header(m.from, msg), msg.Body)
+	return err
+}
+
+func header(from string, msg Message) string {
+	return strings.Join([]string{
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"From: " + from,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+	}, "\r\n")
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"california/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mail.log")
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    string
+		wantErr error
+	}{
+		{name: "smtp", cfg: config.Config{SMTPHost: "smtp.example.com", MailFile: file, MailLog: true}, want: "smtp"},
+		{name: "file", cfg: config.Config{MailFile: file, MailLog: true}, want: "log"},
+		{name: "log", cfg: config.Config{MailLog: true}, want: "log"},
+		{name: "none", wantErr: ErrNoMailer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(&tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("New: got %v, want %v", err, tt.wantErr)
+			}
+			var got string
+			switch m.(type) {
+			case *SMTPMailer:
+				got = "smtp"
+			case *LogMailer:
+				got = "log"
+			}
+			if got != tt.want {
+				t.Errorf("New returned a %T, want a %s mailer", m, tt.want)
+			}
+		})
+	}
+}
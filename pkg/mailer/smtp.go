@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer sends the emails through an SMTP server. The connection is upgraded with STARTTLS
+// when the server supports it, which net/smtp requires to authenticate to a remote server.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer returns a mailer for the server at host:port, 587 by default.
+// It does not authenticate when no username is given.
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	if port == "" {
+		port = "587"
+	}
+	m := &SMTPMailer{addr: net.JoinHostPort(host, port), from: from}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(_ context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	data := header(m.from, msg) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body + "\r\n"
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(data))
+}
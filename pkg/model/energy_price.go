@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type EnergyType int
+
+const (
+	EnergyPetrol EnergyType = iota + 1
+	EnergyDiesel
+	EnergyLPG
+	EnergyElectricityAC
+	EnergyElectricityDC
+	EnergyElectricityHome // Residential electricity, for vehicles charged at home.
+)
+
+var EnergyTypes = []EnergyType{EnergyPetrol, EnergyDiesel, EnergyLPG, EnergyElectricityAC, EnergyElectricityDC, EnergyElectricityHome}
+
+func (e EnergyType) Valid() bool {
+	return e >= EnergyPetrol && e <= EnergyElectricityHome
+}
+
+// Unit is what the price of the energy is given for.
+func (e EnergyType) Unit() string {
+	if e == EnergyElectricityAC || e == EnergyElectricityDC || e == EnergyElectricityHome {
+		return "kWh"
+	}
+	return "L"
+}
+
+// EnergyPrice is a version of the price of an energy type in a region. Prices are never changed
+// in place: a new version is added with the date it takes effect from, so past trips keep their prices.
+type EnergyPrice struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	Energy        EnergyType         `bson:"Energy" json:"energy"`
+	Region        string             `bson:"Region" json:"region,omitempty"` // The whole country when empty.
+	Currency      string             `bson:"Currency" json:"currency"`
+	Price         float64            `bson:"Price" json:"price"` // Per Unit.
+	Unit          string             `bson:"Unit" json:"unit"`
+	EffectiveFrom time.Time          `bson:"EffectiveFrom" json:"effective_from"`
+	CreatedAt     time.Time          `bson:"CreatedAt" json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type StationEventType string
+
+const (
+	StationCreated      StationEventType = "station.created"
+	StationUpdated      StationEventType = "station.updated"
+	StationDeleted      StationEventType = "station.deleted"
+	SocketStatusChanged StationEventType = "socket.status"
+)
+
+// StationEvent describes a change made to a station or one of its sockets.
+// Station holds the station as it is after the change, or as it was before it for deletions.
+type StationEvent struct {
+	ID        uint64             `json:"id"`
+	Type      StationEventType   `json:"type"`
+	StationID primitive.ObjectID `json:"station_id"`
+	SocketID  string             `json:"socket_id,omitempty"`
+	Status    *SocketStatus      `json:"status,omitempty"`
+	Station   *Station           `json:"station,omitempty"`
+	Time      time.Time          `json:"time"`
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportFormat is the format of a station import file.
+type ImportFormat string
+
+const (
+	// ImportCSV is a CSV file with a header row and a socket per row. The rows of a station are grouped by its id,
+	// or by its coordinates when it has none.
+	ImportCSV ImportFormat = "csv"
+	// ImportOCPI is a JSON file of OCPI 2.2 Locations: a single one, an array of them, or an OCPI response holding them.
+	ImportOCPI ImportFormat = "ocpi"
+)
+
+func (f ImportFormat) Valid() bool {
+	return f == ImportCSV || f == ImportOCPI
+}
+
+// ImportAction is what an import did, or would do on a dry run, with a station of the file.
+type ImportAction string
+
+const (
+	ImportCreated ImportAction = "create"
+	ImportUpdated ImportAction = "update"
+	// ImportSkipped is a station which is unchanged, or which is given more than once in the file.
+	ImportSkipped ImportAction = "skip"
+	// ImportFailed is a station which is invalid or which could not be stored.
+	ImportFailed ImportAction = "error"
+)
+
+// ImportJob is the record of a station import, with what happened to each station of the file.
+type ImportJob struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Format     ImportFormat       `bson:"Format" json:"format"`
+	DryRun     bool               `bson:"DryRun" json:"dry_run"` // Nothing was stored but the job itself.
+	UserID     string             `bson:"UserID" json:"user_id"`
+	CreatedAt  time.Time          `bson:"CreatedAt" json:"created_at"`
+	FinishedAt time.Time          `bson:"FinishedAt" json:"finished_at"`
+
+	Total   int `bson:"Total" json:"total"`
+	Created int `bson:"Created" json:"created"`
+	Updated int `bson:"Updated" json:"updated"`
+	Skipped int `bson:"Skipped" json:"skipped"`
+	Failed  int `bson:"Failed" json:"failed"`
+
+	Rows []ImportRow `bson:"Rows,omitempty" json:"rows,omitempty"`
+}
+
+// ImportRow is what happened to a station of an import file.
+type ImportRow struct {
+	// Lines are the lines of the station in a CSV file, or its position in an OCPI file, starting from 1.
+	Lines      []int              `bson:"Lines" json:"lines"`
+	ExternalID string             `bson:"ExternalID,omitempty" json:"external_id,omitempty"`
+	Brand      string             `bson:"Brand,omitempty" json:"brand,omitempty"`
+	Action     ImportAction       `bson:"Action" json:"action"`
+	StationID  primitive.ObjectID `bson:"StationID,omitempty" json:"station_id,omitempty"` // The station created or updated.
+	Errors     []string           `bson:"Errors,omitempty" json:"errors,omitempty"`
+}
+
+// Count adds the row to the counts of the job.
+func (j *ImportJob) Count(row ImportRow) {
+	j.Total++
+	switch row.Action {
+	case ImportCreated:
+		j.Created++
+	case ImportUpdated:
+		j.Updated++
+	case ImportSkipped:
+		j.Skipped++
+	case ImportFailed:
+		j.Failed++
+	}
+	j.Rows = append(j.Rows, row)
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// LoginAttempts counts the failed logins of an account or of an IP address, which Key tells apart.
+// The failures are counted again from zero when the last one is old enough, or when the account is locked.
+type LoginAttempts struct {
+	Key           string     `bson:"_id" json:"key"`
+	Failures      int        `bson:"Failures" json:"failures"`
+	LastFailureAt time.Time  `bson:"LastFailureAt" json:"last_failure_at"`
+	LockedUntil   *time.Time `bson:"LockedUntil,omitempty" json:"locked_until,omitempty"`
+}
+
+// Locked reports whether logins are refused until the lock is lifted.
+func (a *LoginAttempts) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
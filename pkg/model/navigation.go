@@ -1,14 +1,24 @@
 package model
 
 import (
-	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type TripInfo struct {
 	Speed              float64 `json:"speed"`
-	AverageConsumption float64 `json:"average_consumption"` // 4.5/100km
+	AverageConsumption float64 `json:"average_consumption"` // 4.5/100km, in kWh for electric vehicles
 	Distance           float64 `json:"distance"`
-	TotalPrice         float64 `json:"total_price"` // 432.54 TL
+	TotalPrice         float64 `json:"total_price"` // 432.54
+	UnitPrice          float64 `json:"unit_price"`  // Per litre or kWh, in effect at the trip date.
+	Currency           string  `json:"currency"`
+
+	// These fields are only used by electric vehicles.
+	EnergyKWh     float64 `json:"energy_kwh,omitempty"`
+	ChargingStops int     `json:"charging_stops,omitempty"`
+	HomeCost      float64 `json:"home_cost,omitempty"`   // Charged at home before leaving, then at DC chargers. Same as TotalPrice.
+	PublicCost    float64 `json:"public_cost,omitempty"` // Charged at DC chargers only.
 }
 
 type Advice struct {
@@ -17,11 +27,63 @@ type Advice struct {
 }
 
 type RecommendRequest struct {
-	Context      context.Context
 	Distance     int        `json:"distance"`
 	StartPoint   Coordinate `json:"start_point"`
 	ArrivalPoint Coordinate `json:"arrival_point"`
 	Stops        []Stop     `json:"stops"`
+	// VehicleID is the vehicle of the user's garage making the trip; the default vehicle when empty.
+	// The stops of an electric vehicle are no farther apart than it can drive between charges.
+	VehicleID string `json:"vehicle_id,omitempty"`
+	// OpenNow leaves out the stops at stations which are closed at the time of the request.
+	OpenNow bool `json:"open_now,omitempty"`
+}
+
+type RoutePlanRequest struct {
+	StartPoint    Coordinate `json:"start_point"`
+	ArrivalPoint  Coordinate `json:"arrival_point"`
+	Vehicle       *Vehicle   `json:"vehicle,omitempty"`      // When empty the vehicle of the user's garage is used.
+	VehicleID     string     `json:"vehicle_id,omitempty"`   // The vehicle of the garage to use; the default vehicle when empty.
+	StateOfCharge float64    `json:"state_of_charge"`        // Battery level at the start, in percent.
+	ReserveSoC    float64    `json:"reserve_soc,omitempty"`  // Battery level never to go below, in percent.
+	TargetSoC     float64    `json:"target_soc,omitempty"`   // Battery level to charge up to at the stops reached below it, in percent.
+	DepartureAt   time.Time  `json:"departure_at,omitempty"` // Prices the stops at the time of use rates; now when empty.
+}
+
+type RoutePlan struct {
+	Distance        float64 `json:"distance"`          // km
+	ArrivalSoC      float64 `json:"arrival_soc"`       // %
+	TotalChargeTime float64 `json:"total_charge_time"` // minutes
+	// TotalCost is the cost of the stops in Currency when they are all priced in the same currency.
+	// When they are not, both are empty and the cost is only given per currency by Costs.
+	TotalCost float64        `json:"total_cost"`
+	Currency  string         `json:"currency"`
+	Costs     []CurrencyCost `json:"costs"`
+	Stops     []ChargingStop `json:"stops"`
+}
+
+// CurrencyCost is the cost of the stops priced in one currency.
+type CurrencyCost struct {
+	Currency string  `json:"currency"`
+	Cost     float64 `json:"cost"`
+}
+
+type ChargingStop struct {
+	StationID         primitive.ObjectID `json:"station_id"`
+	SocketID          primitive.ObjectID `json:"socket_id"`
+	Brand             string             `json:"brand"`
+	Address           string             `json:"address"`
+	Lat               float64            `json:"lat"`
+	Long              float64            `json:"long"`
+	SocketType        string             `json:"socket_type"`
+	KW                float64            `json:"kw"`
+	DistanceFromStart float64            `json:"distance_from_start"` // km
+	ArrivalSoC        float64            `json:"arrival_soc"`         // %
+	DepartureSoC      float64            `json:"departure_soc"`       // %
+	EnergyKWh         float64            `json:"energy_kwh"`
+	ArrivalAt         time.Time          `json:"arrival_at"`
+	ChargeTime        float64            `json:"charge_time"` // minutes
+	Cost              float64            `json:"cost"`
+	Currency          string             `json:"currency"`
 }
 
 type Coordinate struct {
@@ -30,10 +92,12 @@ type Coordinate struct {
 }
 
 type Stop struct {
-	Name  string  `json:"name"`
-	Lat   float64 `json:"lat"`
-	Long  float64 `json:"long"`
-	Color string  `json:"color"`
+	Name      string  `json:"name"`
+	Lat       float64 `json:"lat"`
+	Long      float64 `json:"long"`
+	Color     string  `json:"color"`
+	StationID string  `json:"station_id,omitempty"` // The charging station at the stop, if any.
+	Favorite  bool    `json:"favorite,omitempty"`   // The user bookmarked the station.
 }
 
 func (s *Stop) DetermineColor(increment int) {
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"time"
+
+	// Opening hours are in local time; the zone database is embedded for images which do not ship it.
+	_ "time/tzdata"
+)
+
+// DefaultStationTimeZone is the time zone of the opening hours which do not have one.
+const DefaultStationTimeZone = "Europe/Istanbul"
+
+// OpeningHours is when a station can be accessed, in its local time. A station open around the clock
+// only needs TwentyFourSeven; otherwise it is open during the weekly periods, except on the holidays.
+type OpeningHours struct {
+	TwentyFourSeven bool            `bson:"TwentyFourSeven" json:"twenty_four_seven"`
+	TimeZone        string          `bson:"TimeZone,omitempty" json:"time_zone,omitempty"` // e.g. Europe/Istanbul
+	Periods         []OpeningPeriod `bson:"Periods,omitempty" json:"periods,omitempty"`
+	Holidays        []Holiday       `bson:"Holidays,omitempty" json:"holidays,omitempty"`
+}
+
+// OpeningPeriod is an opening window, from Start to End in "15:04" format. A window ending
+// before it starts runs over midnight. It applies every day when Days is empty.
+type OpeningPeriod struct {
+	Days  []time.Weekday `bson:"Days,omitempty" json:"days,omitempty"`
+	Start string         `bson:"Start" json:"start"`
+	End   string         `bson:"End" json:"end"`
+}
+
+// Holiday is a day, in "2006-01-02" format, on which the weekly periods do not apply.
+// The station is closed all day unless the holiday has periods of its own; their days are ignored.
+type Holiday struct {
+	Date    string          `bson:"Date" json:"date"`
+	Name    string          `bson:"Name,omitempty" json:"name,omitempty"`
+	Periods []OpeningPeriod `bson:"Periods,omitempty" json:"periods,omitempty"`
+}
+
+// Valid reports whether the time zone, the periods and the holidays can be read.
+func (h *OpeningHours) Valid() bool {
+	if h.TimeZone != "" {
+		if _, err := time.LoadLocation(h.TimeZone); err != nil {
+			return false
+		}
+	}
+	if !h.TwentyFourSeven && len(h.Periods) == 0 {
+		return false
+	}
+	for _, holiday := range h.Holidays {
+		if _, err := time.Parse(time.DateOnly, holiday.Date); err != nil || !validPeriods(holiday.Periods) {
+			return false
+		}
+	}
+	return validPeriods(h.Periods)
+}
+
+// OpenAt reports whether the station is open at the given time.
+func (h *OpeningHours) OpenAt(at time.Time) bool {
+	at = at.In(h.location())
+	// A window running over midnight belongs to the day it starts on, so the periods of the day before count too.
+	return openDuring(h.periodsOn(at), at, false) || openDuring(h.periodsOn(at.AddDate(0, 0, -1)), at, true)
+}
+
+// periodsOn returns the opening periods of the day of the given local time: those of its holiday, or the weekly ones.
+func (h *OpeningHours) periodsOn(day time.Time) []OpeningPeriod {
+	date := day.Format(time.DateOnly)
+	for _, holiday := range h.Holidays {
+		if holiday.Date != date {
+			continue
+		}
+		periods := make([]OpeningPeriod, len(holiday.Periods))
+		for i, period := range holiday.Periods {
+			periods[i] = OpeningPeriod{Days: []time.Weekday{day.Weekday()}, Start: period.Start, End: period.End}
+		}
+		return periods
+	}
+	if h.TwentyFourSeven {
+		return []OpeningPeriod{{Start: "00:00", End: "24:00"}}
+	}
+	return h.Periods
+}
+
+func (h *OpeningHours) location() *time.Location {
+	name := h.TimeZone
+	if name == "" {
+		name = DefaultStationTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// openDuring reports whether the local time is inside one of the periods of its day or, when overnight is set,
+// inside one of the periods of the day before which run over midnight.
+func openDuring(periods []OpeningPeriod, at time.Time, overnight bool) bool {
+	minute := at.Hour()*60 + at.Minute()
+	for _, period := range periods {
+		start, _ := minuteOfDay(period.Start)
+		end, _ := minuteOfDay(period.End)
+		if overnight {
+			if end < start && minute < end && onDay(period.Days, (at.Weekday()+6)%7) {
+				return true
+			}
+			continue
+		}
+		inWindow := minute >= start && minute < end
+		if end < start {
+			inWindow = minute >= start
+		}
+		if inWindow && onDay(period.Days, at.Weekday()) {
+			return true
+		}
+	}
+	return false
+}
+
+func validPeriods(periods []OpeningPeriod) bool {
+	for _, period := range periods {
+		start, okStart := minuteOfDay(period.Start)
+		end, okEnd := minuteOfDay(period.End)
+		if !okStart || !okEnd || start == end {
+			return false
+		}
+		for _, day := range period.Days {
+			if day < time.Sunday || day > time.Saturday {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func onDay(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// minuteOfDay parses a "15:04" time. "24:00" is accepted as the end of the day.
+func minuteOfDay(s string) (int, bool) {
+	if s == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// Amenity is a facility at or next to a station.
+type Amenity string
+
+const (
+	Toilet         Amenity = "toilet"
+	Cafe           Amenity = "cafe"
+	Restaurant     Amenity = "restaurant"
+	WiFi           Amenity = "wifi"
+	CoveredParking Amenity = "covered_parking"
+)
+
+func (a Amenity) Valid() bool {
+	switch a {
+	case Toilet, Cafe, Restaurant, WiFi, CoveredParking:
+		return true
+	}
+	return false
+}
+
+// StationAccess is who can use a station and what it costs to park there.
+type StationAccess struct {
+	CustomersOnly     bool    `bson:"CustomersOnly" json:"customers_only"` // e.g. hotel guests or shoppers of a mall.
+	ParkingFeePerHour float64 `bson:"ParkingFeePerHour" json:"parking_fee_per_hour"`
+	ParkingCurrency   string  `bson:"ParkingCurrency,omitempty" json:"parking_currency,omitempty"`
+	Note              string  `bson:"Note,omitempty" json:"note,omitempty"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReservationStatus int
+
+const (
+	// ReservationActive is a booking waiting for its driver.
+	ReservationActive ReservationStatus = iota + 1
+	// ReservationFulfilled is a booking whose driver started charging on the socket.
+	ReservationFulfilled
+	ReservationCancelled
+	// ReservationExpired is a booking whose driver did not show up in time.
+	ReservationExpired
+)
+
+type Reservation struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    string             `bson:"UserID" json:"user_id"`
+	StationID primitive.ObjectID `bson:"StationID" json:"station_id"`
+	SocketID  primitive.ObjectID `bson:"SocketID" json:"socket_id"`
+	StartsAt  time.Time          `bson:"StartsAt" json:"starts_at"`
+	EndsAt    time.Time          `bson:"EndsAt" json:"ends_at"`
+	Status    ReservationStatus  `bson:"Status" json:"status"`
+	CreatedAt time.Time          `bson:"CreatedAt" json:"created_at"`
+}
+
+// Overlaps reports whether the reservation shares some time with the [startsAt, endsAt) window.
+func (r *Reservation) Overlaps(startsAt, endsAt time.Time) bool {
+	return r.StartsAt.Before(endsAt) && startsAt.Before(r.EndsAt)
+}
+
+// Holding reports whether the reservation still keeps the socket for its driver.
+func (r *Reservation) Holding() bool {
+	return r.Status == ReservationActive || r.Status == ReservationFulfilled
+}
+
+const (
+	// ReservationNoShowGrace is how long a socket is kept after the start of a reservation for a driver who has not arrived.
+	ReservationNoShowGrace = 15 * time.Minute
+	// ReservationLeadTime is how long before the start of a reservation the socket stops being offered to others.
+	ReservationLeadTime = 15 * time.Minute
+)
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReviewStatus int
+
+const (
+	ReviewPublished ReviewStatus = iota + 1
+	// ReviewHidden is a review taken down by an admin. It does not count in the rating of the station.
+	ReviewHidden
+)
+
+// Review is the rating and the opinion of a user about a station. A user has at most one review per station.
+type Review struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	StationID primitive.ObjectID `bson:"StationID" json:"station_id"`
+	UserID    string             `bson:"UserID" json:"user_id"`
+	Rating    int                `bson:"Rating" json:"rating"` // 1 to 5
+	Text      string             `bson:"Text,omitempty" json:"text,omitempty"`
+	Status    ReviewStatus       `bson:"Status" json:"status"`
+	// ModerationNote is why an admin hid the review.
+	ModerationNote string    `bson:"ModerationNote,omitempty" json:"moderation_note,omitempty"`
+	CreatedAt      time.Time `bson:"CreatedAt" json:"created_at"`
+	UpdatedAt      time.Time `bson:"UpdatedAt" json:"updated_at"`
+}
+
+// StationRating sums up the published reviews of a station.
+type StationRating struct {
+	Average float64 `bson:"Average" json:"average"`
+	Count   int     `bson:"Count" json:"count"`
+}
+
+// IssueKind is what is wrong with a socket.
+type IssueKind string
+
+const (
+	IssueBroken IssueKind = "broken"
+	// IssueICEd is a socket blocked by a vehicle which is not charging, usually a combustion engine car.
+	IssueICEd         IssueKind = "iced"
+	IssueCableDamaged IssueKind = "cable_damaged"
+	IssueOther        IssueKind = "other"
+)
+
+func (k IssueKind) Valid() bool {
+	switch k {
+	case IssueBroken, IssueICEd, IssueCableDamaged, IssueOther:
+		return true
+	}
+	return false
+}
+
+// OutOfOrder reports whether the issue keeps the socket from charging until it is repaired.
+func (k IssueKind) OutOfOrder() bool {
+	return k == IssueBroken || k == IssueCableDamaged
+}
+
+type IssueStatus int
+
+const (
+	IssueOpen IssueStatus = iota + 1
+	// IssueResolved is an issue an admin confirmed and had fixed.
+	IssueResolved
+	// IssueRejected is a report an admin found to be wrong.
+	IssueRejected
+)
+
+// IssueReport is a problem with a socket reported by a user.
+type IssueReport struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	StationID   primitive.ObjectID `bson:"StationID" json:"station_id"`
+	SocketID    primitive.ObjectID `bson:"SocketID" json:"socket_id"`
+	UserID      string             `bson:"UserID" json:"user_id"`
+	Kind        IssueKind          `bson:"Kind" json:"kind"`
+	Description string             `bson:"Description,omitempty" json:"description,omitempty"`
+	Photos      []Photo            `bson:"Photos,omitempty" json:"photos,omitempty"`
+	Status      IssueStatus        `bson:"Status" json:"status"`
+	CreatedAt   time.Time          `bson:"CreatedAt" json:"created_at"`
+	ClosedAt    *time.Time         `bson:"ClosedAt,omitempty" json:"closed_at,omitempty"`
+}
+
+// Photo is the metadata of a picture attached to a report. The picture itself is uploaded
+// to the object storage by the client, only its URL is kept.
+type Photo struct {
+	URL         string     `bson:"URL" json:"url"`
+	ContentType string     `bson:"ContentType" json:"content_type"` // e.g. image/jpeg
+	Size        int64      `bson:"Size" json:"size"`                // bytes
+	Width       int        `bson:"Width,omitempty" json:"width,omitempty"`
+	Height      int        `bson:"Height,omitempty" json:"height,omitempty"`
+	TakenAt     *time.Time `bson:"TakenAt,omitempty" json:"taken_at,omitempty"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChargingSessionStatus int
+
+const (
+	ChargingInProgress ChargingSessionStatus = iota + 1
+	ChargingCompleted
+)
+
+// ChargingSession is a charge made by a user on a socket. The tariff of the socket is
+// copied when the session starts, so later price changes do not affect it.
+type ChargingSession struct {
+	ID          primitive.ObjectID    `bson:"_id" json:"id"`
+	UserID      string                `bson:"UserID" json:"user_id"`
+	StationID   primitive.ObjectID    `bson:"StationID" json:"station_id"`
+	SocketID    primitive.ObjectID    `bson:"SocketID" json:"socket_id"`
+	Status      ChargingSessionStatus `bson:"Status" json:"status"`
+	StartedAt   time.Time             `bson:"StartedAt" json:"started_at"`
+	StoppedAt   *time.Time            `bson:"StoppedAt,omitempty" json:"stopped_at,omitempty"`
+	EnergyKWh   float64               `bson:"EnergyKWh" json:"energy_kwh"`
+	PricePerKWh float64               `bson:"PricePerKWh" json:"price_per_kwh"` // Flat price of sessions started before tariffs.
+	Tariff      *Tariff               `bson:"Tariff,omitempty" json:"tariff,omitempty"`
+	Currency    string                `bson:"Currency,omitempty" json:"currency,omitempty"`
+	Cost        float64               `bson:"Cost" json:"cost"`
+	// Estimated is set when the delivered energy was not measured but derived from the power of the socket.
+	Estimated bool `bson:"Estimated" json:"estimated"`
+	// TransactionID is the OCPP transaction which metered the session. When the session is stopped before
+	// its transaction, the final reading of the transaction replaces the one it was stopped with.
+	TransactionID int `bson:"TransactionID,omitempty" json:"transaction_id,omitempty"`
+}
+
+// Complete stops the session with the energy delivered and its cost.
+func (s *ChargingSession) Complete(stoppedAt time.Time, energyKWh, cost float64, estimated bool) {
+	s.Status = ChargingCompleted
+	s.StoppedAt = &stoppedAt
+	s.EnergyKWh = math.Round(energyKWh*1000) / 1000
+	s.Cost = math.Round(cost*100) / 100
+	s.Estimated = estimated
+}
+
+// MonthlyChargingTotal sums up the completed sessions of a month, e.g. "2024-01", paid in the same currency.
+type MonthlyChargingTotal struct {
+	Month     string  `json:"month"`
+	Currency  string  `json:"currency"`
+	Sessions  int     `json:"sessions"`
+	EnergyKWh float64 `json:"energy_kwh"`
+	Cost      float64 `json:"cost"`
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -14,16 +16,64 @@ type Station struct {
 	Distance    float64            `bson:"Distance" json:"distance"`
 	Address     string             `bson:"Address" json:"address"`
 	Sockets     []Socket           `bson:"Sockets" json:"sockets"`
+	Location    *GeoPoint          `bson:"Location,omitempty" json:"location,omitempty"`
+
+	// ChargePointID is the identity the charger uses when it connects over OCPP.
+	ChargePointID string `bson:"ChargePointID,omitempty" json:"charge_point_id,omitempty"`
+	// ChargePointKeyHash is the hash of the key the charger authenticates with over OCPP. It is never sent out.
+	ChargePointKeyHash string `bson:"ChargePointKeyHash,omitempty" json:"-"`
+	// ExternalID is the id of the station in the file it was imported from, e.g. its OCPI location id.
+	ExternalID string `bson:"ExternalID,omitempty" json:"external_id,omitempty"`
+
+	OpeningHours *OpeningHours  `bson:"OpeningHours,omitempty" json:"opening_hours,omitempty"` // Always open when empty.
+	Amenities    []Amenity      `bson:"Amenities,omitempty" json:"amenities,omitempty"`
+	Access       *StationAccess `bson:"Access,omitempty" json:"access,omitempty"` // Open to everyone for free when empty.
+
+	// Rating is kept up to date with the published reviews of the station; empty when there are none.
+	Rating *StationRating `bson:"Rating,omitempty" json:"rating,omitempty"`
+
+	// MatchedSockets are the sockets matching the filter the station was found with.
+	MatchedSockets []primitive.ObjectID `bson:"-" json:"matched_sockets,omitempty"`
+}
+
+// SetLocation fills the GeoJSON location of the station from its Latitude and Longitude.
+func (s *Station) SetLocation() {
+	s.Location = NewGeoPoint(s.Latitude, s.Longitude)
+}
+
+// OpenAt reports whether the station can be accessed at the given time.
+func (s *Station) OpenAt(at time.Time) bool {
+	return s.OpeningHours == nil || s.OpeningHours.OpenAt(at)
+}
+
+// GeoPoint is a GeoJSON point. Coordinates are kept as [longitude, latitude],
+// which is the order MongoDB expects for 2dsphere indexes.
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+func NewGeoPoint(lat, long float64) *GeoPoint {
+	return &GeoPoint{
+		Type:        "Point",
+		Coordinates: []float64{long, lat},
+	}
 }
 
 type Socket struct {
-	ID          primitive.ObjectID `bson:"_id" json:"id"`
-	Name        string             `bson:"Name" json:"name"` // Bu field bağlı olduğu istasyonun Brand'ine eşit.
-	KW          float64            `bson:"KW" json:"kw"`
-	CurrentType CurrentType        `bson:"CurrentType" json:"current_type"`
-	Price       float64            `bson:"Price" json:"price"`
-	SocketType  string             `bson:"SocketType" json:"socket_type"`
-	Status      SocketStatus       `bson:"Status" json:"status"`
+	ID          primitive.ObjectID  `bson:"_id" json:"id"`
+	Name        string              `bson:"Name" json:"name"` // Bu field bağlı olduğu istasyonun Brand'ine eşit.
+	KW          float64             `bson:"KW" json:"kw"`
+	CurrentType CurrentType         `bson:"CurrentType" json:"current_type"`
+	Price       float64             `bson:"Price" json:"price"` // Per kWh, in TRY. Only used when the socket has no tariff.
+	SocketType  string              `bson:"SocketType" json:"socket_type"`
+	Status      SocketStatus        `bson:"Status" json:"status"`
+	ConnectorID int                 `bson:"ConnectorID,omitempty" json:"connector_id,omitempty"` // OCPP connector of the socket on its charge point.
+	TariffID    *primitive.ObjectID `bson:"TariffID,omitempty" json:"tariff_id,omitempty"`
+	ExternalID  string              `bson:"ExternalID,omitempty" json:"external_id,omitempty"` // e.g. the OCPI EVSE uid and connector id.
+	// SuspectedBroken is set when several recent issue reports agree the socket is out of order,
+	// until an admin resolves or rejects them.
+	SuspectedBroken bool `bson:"SuspectedBroken,omitempty" json:"suspected_broken,omitempty"`
 }
 
 type CurrentType int
@@ -38,4 +88,7 @@ type SocketStatus int
 const (
 	UnAvailable SocketStatus = iota
 	Available
+	Occupied
+	Reserved
+	Faulted
 )
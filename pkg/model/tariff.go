@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tariff is the price list of a socket. It applies to the sockets referring to it with TariffID or,
+// when Brand is set, to the sockets of the brand's stations which have no tariff of their own.
+// Prices are in Currency; the time of use periods override the base prices at the given local times.
+type Tariff struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Name        string             `bson:"Name" json:"name"`
+	Currency    string             `bson:"Currency" json:"currency"`
+	Brand       string             `bson:"Brand,omitempty" json:"brand,omitempty"`
+	CurrentType CurrentType        `bson:"CurrentType,omitempty" json:"current_type,omitempty"` // Both AC and DC when empty.
+	TimeZone    string             `bson:"TimeZone,omitempty" json:"time_zone,omitempty"`       // e.g. Europe/Istanbul
+
+	PricePerKWh      float64 `bson:"PricePerKWh" json:"price_per_kwh"`
+	PricePerMinute   float64 `bson:"PricePerMinute" json:"price_per_minute"`
+	SessionFee       float64 `bson:"SessionFee" json:"session_fee"`
+	IdleFeePerMinute float64 `bson:"IdleFeePerMinute" json:"idle_fee_per_minute"`
+	// IdleGraceMinutes is how long a vehicle can stay plugged in after charging before the idle fee applies.
+	IdleGraceMinutes int `bson:"IdleGraceMinutes" json:"idle_grace_minutes"`
+
+	Periods   []TariffPeriod `bson:"Periods,omitempty" json:"periods,omitempty"`
+	UpdatedAt time.Time      `bson:"UpdatedAt" json:"updated_at"`
+}
+
+// TariffPeriod is a time of use window, from Start to End in "15:04" format. A window ending
+// before it starts runs over midnight. It applies every day when Days is empty.
+type TariffPeriod struct {
+	Days           []time.Weekday `bson:"Days,omitempty" json:"days,omitempty"`
+	Start          string         `bson:"Start" json:"start"`
+	End            string         `bson:"End" json:"end"`
+	PricePerKWh    float64        `bson:"PricePerKWh" json:"price_per_kwh"`
+	PricePerMinute float64        `bson:"PricePerMinute" json:"price_per_minute"`
+}
+
+// PriceQuote is the price of a charge, split by the periods of the tariff it goes through.
+type PriceQuote struct {
+	SocketID        primitive.ObjectID  `json:"socket_id"`
+	TariffID        *primitive.ObjectID `json:"tariff_id,omitempty"` // Empty when the legacy socket price is used.
+	Currency        string              `json:"currency"`
+	StartsAt        time.Time           `json:"starts_at"`
+	EnergyKWh       float64             `json:"energy_kwh"`
+	DurationMinutes float64             `json:"duration_minutes"`
+	EnergyCost      float64             `json:"energy_cost"`
+	TimeCost        float64             `json:"time_cost"`
+	SessionFee      float64             `json:"session_fee"`
+	IdleFee         float64             `json:"idle_fee"`
+	Total           float64             `json:"total"`
+	Lines           []PriceQuoteLine    `json:"lines"`
+}
+
+type PriceQuoteLine struct {
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	EnergyKWh      float64   `json:"energy_kwh"`
+	PricePerKWh    float64   `json:"price_per_kwh"`
+	PricePerMinute float64   `json:"price_per_minute"`
+	Cost           float64   `json:"cost"`
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is the stored side of an opaque refresh token. Only the hash of the token is kept.
+// Every rotation revokes the presented token and issues a new one in the same family,
+// so a family stands for one login on one device.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    primitive.ObjectID `bson:"UserID" json:"user_id"`
+	FamilyID  primitive.ObjectID `bson:"FamilyID" json:"family_id"`
+	TokenHash string             `bson:"TokenHash" json:"-"`
+	DeviceID  string             `bson:"DeviceID" json:"device_id"`
+	CreatedAt time.Time          `bson:"CreatedAt" json:"created_at"`
+	ExpiresAt time.Time          `bson:"ExpiresAt" json:"expires_at"`
+	RevokedAt *time.Time         `bson:"RevokedAt,omitempty" json:"revoked_at,omitempty"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Lifetime of the access token in seconds.
+}
+
+type TokenPurpose string
+
+const (
+	VerifyEmail   TokenPurpose = "verify_email"
+	ResetPassword TokenPurpose = "reset_password"
+	// A login challenge finishes a login with a second factor, and an enrolment challenge
+	// the login of a user who must enrol first.
+	LoginChallenge      TokenPurpose = "login_challenge"
+	EnrollmentChallenge TokenPurpose = "enrollment_challenge"
+	UnlockAccount       TokenPurpose = "unlock_account"
+	// A stream ticket opens the station stream, whose client cannot send the access token in a header.
+	StreamTicket TokenPurpose = "stream_ticket"
+)
+
+// UserToken is the stored side of a single-use token given to a user: mailed to verify the email address of the user,
+// to reset the password or to unlock the account, returned by a login which needs a second factor, or given
+// to open the station stream.
+// Like refresh tokens, only the hash of the token is kept.
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    primitive.ObjectID `bson:"UserID" json:"user_id"`
+	Purpose   TokenPurpose       `bson:"Purpose" json:"purpose"`
+	TokenHash string             `bson:"TokenHash" json:"-"`
+	// Email is the address the token was sent to. The token is only good while the user keeps it.
+	Email     string     `bson:"Email" json:"email"`
+	CreatedAt time.Time  `bson:"CreatedAt" json:"created_at"`
+	ExpiresAt time.Time  `bson:"ExpiresAt" json:"expires_at"`
+	UsedAt    *time.Time `bson:"UsedAt,omitempty" json:"used_at,omitempty"`
+	// Attempts counts the wrong codes given with a challenge.
+	Attempts int `bson:"Attempts,omitempty" json:"-"`
+}
+
+// Challenge is returned instead of the tokens by a login which needs a second factor. The login is finished by
+// giving its token back with a TOTP or recovery code; when enrolment is required, the user enrols with it first.
+type Challenge struct {
+	Token              string `json:"challenge_token"`
+	ExpiresIn          int64  `json:"expires_in"` // Lifetime of the challenge in seconds.
+	EnrollmentRequired bool   `json:"enrollment_required,omitempty"`
+}
+
+// TOTPEnrollment is what an authenticator app needs to generate the codes of a user.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth URI of the secret, to show as a QR code.
+	URI string `json:"uri"`
+}
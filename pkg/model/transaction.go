@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Transaction is a charge reported by an OCPP charge point, from its StartTransaction until its
+// StopTransaction, after which it is deleted. Its ID is the transaction id handed to the charge point.
+type Transaction struct {
+	ID            int                `bson:"_id" json:"id"`
+	ChargePointID string             `bson:"ChargePointID" json:"charge_point_id"`
+	ConnectorID   int                `bson:"ConnectorID" json:"connector_id"`
+	SocketID      primitive.ObjectID `bson:"SocketID" json:"socket_id"`
+	IdTag         string             `bson:"IdTag" json:"id_tag"`
+	MeterStart    int                `bson:"MeterStart" json:"meter_start"` // Wh
+	MeterValue    int                `bson:"MeterValue" json:"meter_value"` // Wh, latest reading.
+	StartedAt     time.Time          `bson:"StartedAt" json:"started_at"`
+}
+
+// EnergyKWh returns the energy delivered so far according to the meter of the charge point.
+func (t *Transaction) EnergyKWh() float64 {
+	return float64(t.MeterValue-t.MeterStart) / 1000
+}
@@ -13,11 +13,116 @@ const (
 )
 
 type User struct {
-	ID           primitive.ObjectID `bson:"id,omitempty" json:"id,omitempty"` // This id is created by mongo and stored as 'id'
-	Name         string             `bson:"Name" json:"name"`
-	Email        string             `bson:"Email" json:"email"`
-	Password     string             `bson:"Password" json:"password"` // Store the password as a hash
-	UserType     UserType           `bson:"UserType" json:"user_type"`
-	Vehicle      Vehicle            `bson:"Vehicle" json:"vehicle"`
-	RefreshToken string             `bson:"RefreshToken" json:"refresh_token",omitempty`
+	ID       primitive.ObjectID `bson:"id,omitempty" json:"id,omitempty"` // This id is created by mongo and stored as 'id'
+	Name     string             `bson:"Name" json:"name"`
+	Email    string             `bson:"Email" json:"email"`
+	Password string             `bson:"Password" json:"password"` // Store the password as a hash
+	UserType UserType           `bson:"UserType" json:"user_type"`
+	// EmailVerified tells whether the user proved to own the email address, with a verification or a password reset token.
+	EmailVerified bool `bson:"EmailVerified,omitempty" json:"email_verified"`
+	// TwoFactor is the two-factor authentication of the user, nil until the user starts enrolling.
+	TwoFactor *TwoFactor `bson:"TwoFactor,omitempty" json:"-"`
+
+	Vehicles []Vehicle `bson:"Vehicles,omitempty" json:"vehicles"`
+	// DefaultVehicleID is the vehicle used when a request names none. The first vehicle is used when it is empty.
+	DefaultVehicleID primitive.ObjectID `bson:"DefaultVehicleID,omitempty" json:"default_vehicle_id,omitempty"`
+	// Vehicle is the only vehicle of the users registered before they could have several.
+	// It is moved into Vehicles when their garage is first changed.
+	Vehicle *Vehicle `bson:"Vehicle,omitempty" json:"-"`
+
+	// FavoriteStations are the stations bookmarked by the user, in the order the user sorted them.
+	FavoriteStations []primitive.ObjectID `bson:"FavoriteStations,omitempty" json:"favorite_stations,omitempty"`
+	// Places are the locations saved by the user, in the order the user sorted them.
+	Places []Place `bson:"Places,omitempty" json:"places,omitempty"`
+}
+
+// TwoFactor is the TOTP (RFC 6238) enrolment of a user. It is pending until the user confirms it with a code.
+type TwoFactor struct {
+	Secret  string `bson:"Secret"` // base32
+	Enabled bool   `bson:"Enabled"`
+	// LastStep is the time step of the last code accepted, so that a code cannot be used twice.
+	LastStep int64 `bson:"LastStep"`
+	// RecoveryCodes are the hashes of the recovery codes which are not used yet.
+	RecoveryCodes []string `bson:"RecoveryCodes,omitempty"`
+}
+
+// Place is a named location saved by a user, e.g. home or work.
+type Place struct {
+	ID      primitive.ObjectID `bson:"ID" json:"id"`
+	Name    string             `bson:"Name" json:"name"`
+	Lat     float64            `bson:"Lat" json:"lat"`
+	Long    float64            `bson:"Long" json:"long"`
+	Address string             `bson:"Address,omitempty" json:"address,omitempty"`
+}
+
+// Garage returns the vehicles of the user, including a vehicle registered before the garage.
+func (u *User) Garage() []Vehicle {
+	if legacy := u.LegacyVehicle(); len(u.Vehicles) == 0 && legacy != nil {
+		return []Vehicle{*legacy}
+	}
+	return u.Vehicles
+}
+
+// LegacyVehicle returns the vehicle registered before the garage, or nil when there is none. The users who
+// registered none have an empty one stored, which is not a vehicle either.
+func (u *User) LegacyVehicle() *Vehicle {
+	if u.Vehicle == nil || u.Vehicle.IsEmpty() {
+		return nil
+	}
+	return u.Vehicle
+}
+
+// VehicleById returns the vehicle of the user with the given id, or nil when there is none.
+func (u *User) VehicleById(vehicleId primitive.ObjectID) *Vehicle {
+	for i := range u.Vehicles {
+		if u.Vehicles[i].ID == vehicleId {
+			return &u.Vehicles[i]
+		}
+	}
+	return nil
+}
+
+// DefaultVehicle returns the default vehicle of the user, or nil when the user has no vehicle.
+func (u *User) DefaultVehicle() *Vehicle {
+	vehicles := u.Garage()
+	for i := range vehicles {
+		if vehicles[i].ID == u.DefaultVehicleID {
+			return &vehicles[i]
+		}
+	}
+	if len(vehicles) == 0 {
+		return nil
+	}
+	return &vehicles[0]
+}
+
+// IsFavorite reports whether the user bookmarked the station.
+func (u *User) IsFavorite(stationId primitive.ObjectID) bool {
+	for _, id := range u.FavoriteStations {
+		if id == stationId {
+			return true
+		}
+	}
+	return false
+}
+
+// PlaceById returns the place of the user with the given id, or nil when there is none.
+func (u *User) PlaceById(placeId primitive.ObjectID) *Place {
+	for i := range u.Places {
+		if u.Places[i].ID == placeId {
+			return &u.Places[i]
+		}
+	}
+	return nil
+}
+
+// TwoFactorEnabled reports whether the user logs in with a second factor.
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+// RequiresTwoFactor reports whether the user may only log in with a second factor. Admins can change
+// and delete any station, so they must enrol.
+func (u *User) RequiresTwoFactor() bool {
+	return u.UserType == Admin
 }
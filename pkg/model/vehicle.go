@@ -1,11 +1,35 @@
 package model
 
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 type Vehicle struct {
-	Brand              string     `bson:"Brand" json:"brand"`
-	Model              string     `bson:"Model" json:"model"`
-	EngineType         EngineType `bson:"EngineType" json:"engine_type"`                 // Diesel, Petrol, Hybrid
-	EngineSize         float64    `bson:"EngineSize" json:"engine_size"`                 // 1.6L/2.0L
-	AverageConsumption float64    `bson:"AverageConsumption" json:"average_consumption"` // 4.5/100km
+	ID                 primitive.ObjectID `bson:"ID,omitempty" json:"id,omitempty"`
+	Name               string             `bson:"Name,omitempty" json:"name,omitempty"` // e.g. "Family car"
+	Default            bool               `bson:"-" json:"default"`
+	CatalogID          string             `bson:"CatalogID,omitempty" json:"catalog_id,omitempty"` // The specs are copied from this catalog entry when set.
+	Brand              string             `bson:"Brand" json:"brand"`
+	Model              string             `bson:"Model" json:"model"`
+	EngineType         EngineType         `bson:"EngineType" json:"engine_type"`                 // Diesel, Petrol, Hybrid, Electric, LPG
+	EngineSize         float64            `bson:"EngineSize" json:"engine_size"`                 // 1.6L/2.0L
+	AverageConsumption float64            `bson:"AverageConsumption" json:"average_consumption"` // 4.5/100km
+
+	// These fields are only used by electric vehicles.
+	BatteryCapacity   float64  `bson:"BatteryCapacity,omitempty" json:"battery_capacity,omitempty"`     // 77 kWh
+	EnergyConsumption float64  `bson:"EnergyConsumption,omitempty" json:"energy_consumption,omitempty"` // 18 kWh/100km
+	SocketTypes       []string `bson:"SocketTypes,omitempty" json:"socket_types,omitempty"`             // CCS, Type 2
+	MaxACPowerKW      float64  `bson:"MaxACPowerKW,omitempty" json:"max_ac_power_kw,omitempty"`
+	MaxDCPowerKW      float64  `bson:"MaxDCPowerKW,omitempty" json:"max_dc_power_kw,omitempty"`
+}
+
+// IsEmpty reports whether no field of the vehicle is set.
+func (v *Vehicle) IsEmpty() bool {
+	return v.ID.IsZero() && v.Name == "" && v.CatalogID == "" && v.Brand == "" && v.Model == "" &&
+		v.EngineType == 0 && v.EngineSize == 0 && v.AverageConsumption == 0 && v.BatteryCapacity == 0 &&
+		v.EnergyConsumption == 0 && len(v.SocketTypes) == 0 && v.MaxACPowerKW == 0 && v.MaxDCPowerKW == 0
 }
 
 type EngineType int
@@ -15,4 +39,54 @@ const (
 	Diesel
 	Hybrid
 	Electric
+	LPG
 )
+
+var engineTypeNames = map[string]EngineType{
+	"petrol":   Petrol,
+	"diesel":   Diesel,
+	"hybrid":   Hybrid,
+	"electric": Electric,
+	"lpg":      LPG,
+}
+
+// ParseEngineType returns the engine type of the given name, e.g. "diesel", regardless of case.
+func ParseEngineType(name string) (EngineType, bool) {
+	t, ok := engineTypeNames[strings.ToLower(strings.TrimSpace(name))]
+	return t, ok
+}
+
+// CatalogVehicle is a trim of a make and model with its official specs.
+type CatalogVehicle struct {
+	ID                 string     `json:"id"` // e.g. tesla-model-y-long-range-awd-2023
+	Make               string     `json:"make"`
+	Model              string     `json:"model"`
+	Trim               string     `json:"trim"`
+	Year               int        `json:"year,omitempty"`
+	EngineType         EngineType `json:"engine_type"`
+	EngineSize         float64    `json:"engine_size,omitempty"`         // L
+	AverageConsumption float64    `json:"average_consumption,omitempty"` // L/100km
+
+	BatteryCapacity   float64  `json:"battery_capacity,omitempty"`   // Usable kWh
+	EnergyConsumption float64  `json:"energy_consumption,omitempty"` // kWh/100km
+	SocketTypes       []string `json:"socket_types,omitempty"`
+	MaxACPowerKW      float64  `json:"max_ac_power_kw,omitempty"`
+	MaxDCPowerKW      float64  `json:"max_dc_power_kw,omitempty"`
+}
+
+// Vehicle returns a vehicle with the specs of the catalog entry.
+func (c *CatalogVehicle) Vehicle() Vehicle {
+	return Vehicle{
+		CatalogID:          c.ID,
+		Brand:              c.Make,
+		Model:              strings.TrimSpace(c.Model + " " + c.Trim),
+		EngineType:         c.EngineType,
+		EngineSize:         c.EngineSize,
+		AverageConsumption: c.AverageConsumption,
+		BatteryCapacity:    c.BatteryCapacity,
+		EnergyConsumption:  c.EnergyConsumption,
+		SocketTypes:        append([]string(nil), c.SocketTypes...),
+		MaxACPowerKW:       c.MaxACPowerKW,
+		MaxDCPowerKW:       c.MaxDCPowerKW,
+	}
+}
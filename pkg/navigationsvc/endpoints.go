@@ -2,20 +2,34 @@ package navigationsvc
 
 import (
 	"context"
+	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
 	"github.com/go-kit/kit/endpoint"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type Endpoints struct {
 	CalculateTripEndpoint endpoint.Endpoint
 	RecommendEndpoint     endpoint.Endpoint
+	PlanRouteEndpoint     endpoint.Endpoint
+	AddPriceEndpoint      endpoint.Endpoint
+	ListPricesEndpoint    endpoint.Endpoint
+	PriceHistoryEndpoint  endpoint.Endpoint
+	DeletePriceEndpoint   endpoint.Endpoint
 }
 
-func MakeServerEndpoints(c context.Context, s NavigationService) Endpoints {
+func MakeServerEndpoints(s NavigationService, keys jwt.Keyfunc) Endpoints {
+	authenticated := auth.NewParser(keys)
 	return Endpoints{
-		CalculateTripEndpoint: MakeCalculateTripEndpoint(c, s),
-		RecommendEndpoint:     MakeRecommendEndpoint(c, s),
+		CalculateTripEndpoint: authenticated(MakeCalculateTripEndpoint(s)),
+		RecommendEndpoint:     authenticated(MakeRecommendEndpoint(s)),
+		PlanRouteEndpoint:     authenticated(MakePlanRouteEndpoint(s)),
+		AddPriceEndpoint:      authenticated(MakeAddPriceEndpoint(s)),
+		ListPricesEndpoint:    authenticated(MakeListPricesEndpoint(s)),
+		PriceHistoryEndpoint:  authenticated(MakePriceHistoryEndpoint(s)),
+		DeletePriceEndpoint:   authenticated(MakeDeletePriceEndpoint(s)),
 	}
 }
 
@@ -24,12 +38,10 @@ type BaseResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-func MakeRecommendEndpoint(c context.Context, s NavigationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeRecommendEndpoint(s NavigationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(model.RecommendRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		advice, e := s.Recommend(c, &req)
+		advice, e := s.Recommend(ctx, &req)
 		if e != nil {
 			return BaseResponse{
 				Message: "failed",
@@ -47,8 +59,7 @@ func MakeRecommendEndpoint(c context.Context, s NavigationService) endpoint.Endp
 }
 
 type recommendRequest struct {
-	Context context.Context
-	Stops   []model.Stop
+	Stops []model.Stop
 }
 
 type recommendResponse struct {
@@ -59,12 +70,10 @@ type recommendResponse struct {
 
 func (r recommendResponse) Failed() error { return r.Err }
 
-func MakeCalculateTripEndpoint(c context.Context, s NavigationService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeCalculateTripEndpoint(s NavigationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(calculateTripRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		tripInfo, e := s.CalculateTrip(c, req)
+		tripInfo, e := s.CalculateTrip(ctx, req)
 		if e != nil {
 			return calculateTripResponse{
 				Err: e,
@@ -81,8 +90,15 @@ func MakeCalculateTripEndpoint(c context.Context, s NavigationService) endpoint.
 }
 
 type calculateTripRequest struct {
-	Context  context.Context
 	Distance float64
+	Date     time.Time // The prices in effect at this date are used; now when empty.
+	Region   string
+	// VehicleID is the vehicle of the user's garage making the trip; the default vehicle when empty.
+	VehicleID string
+
+	// These fields are only used by electric vehicles.
+	Temperature   *float64 // Outside temperature in °C.
+	StateOfCharge float64  // Battery level at the start, in percent.
 }
 
 type calculateTripResponse struct {
@@ -92,3 +108,126 @@ type calculateTripResponse struct {
 }
 
 func (r calculateTripResponse) Failed() error { return r.Err }
+
+func MakePlanRouteEndpoint(s NavigationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(model.RoutePlanRequest)
+		plan, e := s.PlanRoute(ctx, &req)
+		if e != nil {
+			return planRouteResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: planRouteResponse{
+				Plan: plan,
+				Err:  e,
+			},
+		}, nil
+	}
+}
+
+type planRouteResponse struct {
+	*BaseResponse
+	Plan *model.RoutePlan `json:"plan,omitempty"`
+	Err  error            `json:"err,omitempty"`
+}
+
+func (r planRouteResponse) Failed() error { return r.Err }
+
+func MakeAddPriceEndpoint(s NavigationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(addPriceRequest)
+		price, e := s.AddEnergyPrice(ctx, req.Price)
+		if e != nil {
+			return pricesResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: pricesResponse{
+				Prices: []*model.EnergyPrice{price},
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+type addPriceRequest struct {
+	Price *model.EnergyPrice
+}
+
+type pricesResponse struct {
+	*BaseResponse
+	Prices []*model.EnergyPrice `json:"prices,omitempty"`
+	Err    error                `json:"err,omitempty"`
+}
+
+func (r pricesResponse) Failed() error { return r.Err }
+
+func MakeListPricesEndpoint(s NavigationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listPricesRequest)
+		prices, e := s.EnergyPrices(ctx, req.Region, req.At)
+		if e != nil {
+			return pricesResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: pricesResponse{
+				Prices: prices,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+type listPricesRequest struct {
+	Region string
+	At     time.Time
+}
+
+func MakePriceHistoryEndpoint(s NavigationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listPricesRequest)
+		prices, e := s.EnergyPriceHistory(ctx, req.Region)
+		if e != nil {
+			return pricesResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: pricesResponse{
+				Prices: prices,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+func MakeDeletePriceEndpoint(s NavigationService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deletePriceRequest)
+		e := s.DeleteEnergyPrice(ctx, req.PriceID)
+		if e != nil {
+			return pricesResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: pricesResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type deletePriceRequest struct {
+	PriceID string
+}
@@ -0,0 +1,135 @@
+package navigationsvc
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"california/pkg/model"
+)
+
+const (
+	// The rated consumption of a vehicle is taken as its consumption at this speed, in km/h, in mild weather.
+	ratedSpeed = 90
+	// Share of the rated consumption spent against the air drag, which grows with the square of the speed.
+	aeroShareAtRatedSpeed = 0.45
+	// Share of the energy the regenerative brakes give back in slow traffic. It fades away at motorway speeds.
+	maxRegenShare  = 0.15
+	regenFadeSpeed = 150
+
+	// Cabin heating below and air conditioning above the comfort band, in kW per degree up to a maximum draw.
+	heatingBelow         = 18
+	heatingKWPerDegree   = 0.2
+	maxHeatingKW         = 5
+	coolingAbove         = 24
+	coolingKWPerDegree   = 0.15
+	maxCoolingKW         = 3
+	defaultTemperature   = 20
+	minTemperature       = -50
+	maxTemperature       = 60
+	coldBatteryBelow     = 10
+	coldBatteryPerDegree = 0.01 // Extra consumption of a cold battery, per degree.
+
+	// Share of the energy drawn from the grid which reaches the battery.
+	homeChargingEfficiency = 0.9
+	dcChargingEfficiency   = 0.95
+
+	defaultTripStartSoC = 100
+)
+
+// evConsumption returns the kWh/100km used at the given speed and outside temperature by a vehicle
+// rated at ratedConsumption kWh/100km.
+func evConsumption(ratedConsumption, speed, temperature float64) float64 {
+	speedFactor := (1 - aeroShareAtRatedSpeed) + aeroShareAtRatedSpeed*math.Pow(speed/ratedSpeed, 2)
+	// The rated consumption already has the regeneration of the rated speed in it.
+	regenFactor := (1 - regenShare(speed)) / (1 - regenShare(ratedSpeed))
+	consumption := ratedConsumption * speedFactor * regenFactor
+
+	if temperature < coldBatteryBelow {
+		consumption *= 1 + coldBatteryPerDegree*(coldBatteryBelow-temperature)
+	}
+	// The climate control draws a constant power, so it costs more per km the slower the vehicle goes.
+	return consumption + hvacPower(temperature)*100/speed
+}
+
+func regenShare(speed float64) float64 {
+	return maxRegenShare * math.Max(0, 1-speed/regenFadeSpeed)
+}
+
+func hvacPower(temperature float64) float64 {
+	switch {
+	case temperature < heatingBelow:
+		return math.Min(heatingKWPerDegree*(heatingBelow-temperature), maxHeatingKW)
+	case temperature > coolingAbove:
+		return math.Min(coolingKWPerDegree*(temperature-coolingAbove), maxCoolingKW)
+	default:
+		return 0
+	}
+}
+
+// chargingStops returns how many times the vehicle has to charge on the way, leaving with startSoC and
+// charging from the reserve up to the target at each stop, like the route planner does.
+func chargingStops(energyKWh, batteryCapacity, startSoC float64) int {
+	firstLeg := (startSoC - defaultReserveSoC) / 100 * batteryCapacity
+	if energyKWh <= firstLeg {
+		return 0
+	}
+	perStop := float64(defaultTargetSoC-defaultReserveSoC) / 100 * batteryCapacity
+	return int(math.Ceil((energyKWh - firstLeg) / perStop))
+}
+
+// calculateElectricTrip prices the trip of an electric vehicle. The home cost assumes the battery is charged
+// at home before leaving and at public DC chargers on the way, the public cost that all of it is charged at DC chargers.
+func (s *navigationService) calculateElectricTrip(ctx context.Context, vehicle *model.Vehicle, req calculateTripRequest, date time.Time, speeds []float64) ([]*model.TripInfo, error) {
+	if vehicle.BatteryCapacity <= 0 || vehicle.EnergyConsumption <= 0 {
+		return nil, ErrNotElectricVehicle
+	}
+	temperature := float64(defaultTemperature)
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+	if temperature < minTemperature || temperature > maxTemperature || math.IsNaN(temperature) {
+		return nil, ErrInvalidTemperature
+	}
+	startSoC := req.StateOfCharge
+	if startSoC == 0 {
+		startSoC = defaultTripStartSoC
+	}
+	if startSoC <= defaultReserveSoC || startSoC > 100 {
+		return nil, ErrInvalidStateOfCharge
+	}
+
+	homePrice, err := s.energyPrice(ctx, model.EnergyElectricityHome, req.Region, date)
+	if err != nil {
+		return nil, err
+	}
+	dcPrice, err := s.energyPrice(ctx, model.EnergyElectricityDC, req.Region, date)
+	if err != nil {
+		return nil, err
+	}
+
+	kilometers := req.Distance / 1000
+	var tripInfo []*model.TripInfo
+	for _, speed := range speeds {
+		consumption := evConsumption(vehicle.EnergyConsumption, speed, temperature)
+		energy := consumption * kilometers / 100
+
+		fromHome := math.Min(energy, (startSoC-defaultReserveSoC)/100*vehicle.BatteryCapacity)
+		homeCost := fromHome/homeChargingEfficiency*homePrice.Price + (energy-fromHome)/dcChargingEfficiency*dcPrice.Price
+		publicCost := energy / dcChargingEfficiency * dcPrice.Price
+
+		tripInfo = append(tripInfo, &model.TripInfo{
+			Speed:              speed,
+			AverageConsumption: roundResult(consumption),
+			Distance:           req.Distance,
+			TotalPrice:         roundResult(homeCost),
+			UnitPrice:          homePrice.Price,
+			Currency:           homePrice.Currency,
+			EnergyKWh:          roundResult(energy),
+			ChargingStops:      chargingStops(energy, vehicle.BatteryCapacity, startSoC),
+			HomeCost:           roundResult(homeCost),
+			PublicCost:         roundResult(publicCost),
+		})
+	}
+	return tripInfo, nil
+}
@@ -0,0 +1,142 @@
+package navigationsvc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEVConsumption(t *testing.T) {
+	const rated = 18.0
+	if got := evConsumption(rated, ratedSpeed, defaultTemperature); math.Abs(got-rated) > 1e-9 {
+		t.Errorf("consumption at the rated speed in mild weather = %v, want the rated %v", got, rated)
+	}
+
+	tests := []struct {
+		name               string
+		speed, temperature float64
+		slower, faster     bool // Compared with the rated consumption.
+	}{
+		{name: "motorway", speed: 130, temperature: defaultTemperature, faster: true},
+		{name: "town", speed: 50, temperature: defaultTemperature, slower: true},
+		{name: "winter", speed: ratedSpeed, temperature: -10, faster: true},
+		{name: "summer", speed: ratedSpeed, temperature: 35, faster: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evConsumption(rated, tt.speed, tt.temperature)
+			if tt.faster && got <= rated || tt.slower && got >= rated {
+				t.Errorf("consumption = %v, rated %v", got, rated)
+			}
+		})
+	}
+
+	// The climate control draws the same power at any speed, so it weighs more on the slow trips.
+	winterTown := evConsumption(rated, 30, -10) - evConsumption(rated, 30, defaultTemperature)
+	winterMotorway := evConsumption(rated, 120, -10) - evConsumption(rated, 120, defaultTemperature)
+	if winterTown <= winterMotorway {
+		t.Errorf("heating costs %v kWh/100km in town and %v on the motorway, want more in town", winterTown, winterMotorway)
+	}
+}
+
+func TestHVACPower(t *testing.T) {
+	tests := []struct {
+		temperature float64
+		want        float64
+	}{
+		{temperature: 20, want: 0},
+		{temperature: heatingBelow, want: 0},
+		{temperature: coolingAbove, want: 0},
+		{temperature: 8, want: 2},
+		{temperature: -40, want: maxHeatingKW},
+		{temperature: 34, want: 1.5},
+		{temperature: 60, want: maxCoolingKW},
+	}
+	for _, tt := range tests {
+		if got := hvacPower(tt.temperature); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("hvacPower(%v) = %v, want %v", tt.temperature, got, tt.want)
+		}
+	}
+}
+
+func TestChargingStops(t *testing.T) {
+	// A 60 kWh battery leaving full has 54 kWh above the reserve, and gets 42 kWh at each stop.
+	tests := []struct {
+		energyKWh, startSoC float64
+		want                int
+	}{
+		{energyKWh: 30, startSoC: 100, want: 0},
+		{energyKWh: 54, startSoC: 100, want: 0},
+		{energyKWh: 55, startSoC: 100, want: 1},
+		{energyKWh: 96, startSoC: 100, want: 1},
+		{energyKWh: 97, startSoC: 100, want: 2},
+		{energyKWh: 30, startSoC: 50, want: 1},
+	}
+	for _, tt := range tests {
+		if got := chargingStops(tt.energyKWh, 60, tt.startSoC); got != tt.want {
+			t.Errorf("chargingStops(%v kWh from %v%%) = %d, want %d", tt.energyKWh, tt.startSoC, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateElectricTrip(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	for energy, price := range map[model.EnergyType]float64{model.EnergyElectricityHome: 3, model.EnergyElectricityDC: 10} {
+		err := store.InsertEnergyPrice(ctx, &model.EnergyPrice{
+			ID:            primitive.NewObjectID(),
+			Energy:        energy,
+			Currency:      "TRY",
+			Price:         price,
+			Unit:          "kWh",
+			EffectiveFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewNavigationService(store).(*navigationService)
+	vehicle := &model.Vehicle{BatteryCapacity: 60, EnergyConsumption: 20}
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// 400 km at the rated speed in mild weather use 80 kWh: 54 kWh from home, the other 26 at a DC charger.
+	trips, err := s.calculateElectricTrip(ctx, vehicle, calculateTripRequest{Distance: 400_000}, date, []float64{ratedSpeed})
+	if err != nil {
+		t.Fatalf("calculateElectricTrip: %v", err)
+	}
+	trip := trips[0]
+	wantHome := roundResult(54/homeChargingEfficiency*3 + 26/dcChargingEfficiency*10)
+	wantPublic := roundResult(80 / dcChargingEfficiency * 10)
+	if trip.EnergyKWh != 80 || trip.ChargingStops != 1 || trip.HomeCost != wantHome || trip.TotalPrice != wantHome ||
+		trip.PublicCost != wantPublic || trip.UnitPrice != 3 || trip.Currency != "TRY" {
+		t.Errorf("trip = %+v, want 80 kWh, 1 stop, %v at home and %v in public", trip, wantHome, wantPublic)
+	}
+
+	invalid := []struct {
+		name    string
+		vehicle *model.Vehicle
+		req     calculateTripRequest
+		wantErr error
+	}{
+		{name: "no battery", vehicle: &model.Vehicle{EnergyConsumption: 20}, wantErr: ErrNotElectricVehicle},
+		{name: "too cold", vehicle: vehicle, req: calculateTripRequest{Temperature: ptr(-60.0)}, wantErr: ErrInvalidTemperature},
+		{name: "not a temperature", vehicle: vehicle, req: calculateTripRequest{Temperature: ptr(math.NaN())}, wantErr: ErrInvalidTemperature},
+		{name: "below the reserve", vehicle: vehicle, req: calculateTripRequest{StateOfCharge: 5}, wantErr: ErrInvalidStateOfCharge},
+		{name: "above full", vehicle: vehicle, req: calculateTripRequest{StateOfCharge: 110}, wantErr: ErrInvalidStateOfCharge},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.calculateElectricTrip(ctx, tt.vehicle, tt.req, date, []float64{ratedSpeed}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("calculateElectricTrip: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -2,13 +2,11 @@ package navigationsvc
 
 import (
 	"context"
-	"strings"
 	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
-	"california/pkg/usersvc"
 	"github.com/go-kit/kit/log"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type Middleware func(NavigationService) NavigationService
@@ -47,60 +45,108 @@ func (mw loggingMiddleware) Recommend(c context.Context, req *model.RecommendReq
 	return mw.next.Recommend(c, req)
 }
 
-type authMiddleware struct {
-	next       NavigationService
-	signingKey string
-	c          context.Context
+func (mw loggingMiddleware) PlanRoute(c context.Context, req *model.RoutePlanRequest) (plan *model.RoutePlan, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "PlanRoute",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.PlanRoute(c, req)
 }
 
-func (aw authMiddleware) CalculateTrip(ctx context.Context, req calculateTripRequest) (tripInfo []*model.TripInfo, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (mw loggingMiddleware) AddEnergyPrice(c context.Context, price *model.EnergyPrice) (insertedPrice *model.EnergyPrice, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "AddEnergyPrice",
+			"energy", price.Energy,
+			"region", price.Region,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.AddEnergyPrice(c, price)
+}
+
+func (mw loggingMiddleware) EnergyPrices(c context.Context, region string, at time.Time) (prices []*model.EnergyPrice, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "EnergyPrices",
+			"region", region,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.EnergyPrices(c, region, at)
+}
+
+func (mw loggingMiddleware) EnergyPriceHistory(c context.Context, region string) (prices []*model.EnergyPrice, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "EnergyPriceHistory",
+			"region", region,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.EnergyPriceHistory(c, region)
+}
+
+func (mw loggingMiddleware) DeleteEnergyPrice(c context.Context, priceId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "DeleteEnergyPrice",
+			"price_id", priceId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.DeleteEnergyPrice(c, priceId)
+}
+
+type authorizationMiddleware struct {
+	next NavigationService
+}
+
+func (am authorizationMiddleware) CalculateTrip(ctx context.Context, req calculateTripRequest) (tripInfo []*model.TripInfo, err error) {
+	return am.next.CalculateTrip(ctx, req)
+}
+
+func (am authorizationMiddleware) Recommend(ctx context.Context, req *model.RecommendRequest) (advices []*model.Advice, err error) {
+	return am.next.Recommend(ctx, req)
+}
+
+func (am authorizationMiddleware) PlanRoute(ctx context.Context, req *model.RoutePlanRequest) (plan *model.RoutePlan, err error) {
+	return am.next.PlanRoute(ctx, req)
+}
+
+func (am authorizationMiddleware) AddEnergyPrice(ctx context.Context, price *model.EnergyPrice) (insertedPrice *model.EnergyPrice, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return nil, e
 	}
-	return aw.next.CalculateTrip(ctx, req)
+	return am.next.AddEnergyPrice(ctx, price)
+}
+
+func (am authorizationMiddleware) EnergyPrices(ctx context.Context, region string, at time.Time) (prices []*model.EnergyPrice, err error) {
+	return am.next.EnergyPrices(ctx, region, at)
 }
 
-func (aw authMiddleware) Recommend(ctx context.Context, req *model.RecommendRequest) (advices []*model.Advice, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) EnergyPriceHistory(ctx context.Context, region string) (prices []*model.EnergyPrice, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return nil, e
 	}
-	return aw.next.Recommend(ctx, req)
+	return am.next.EnergyPriceHistory(ctx, region)
 }
 
-func AuthMiddleware(signingKey string) Middleware {
-	return func(next NavigationService) NavigationService {
-		return &authMiddleware{
-			next:       next,
-			signingKey: signingKey,
-		}
+func (am authorizationMiddleware) DeleteEnergyPrice(ctx context.Context, priceId string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return e
 	}
+	return am.next.DeleteEnergyPrice(ctx, priceId)
 }
 
-func isAuthenticated(ctx context.Context, signingKey string) (context.Context, error) {
-	// Extract the JWT token from the request header and validate it.
-	tokenString := ctx.Value("Authorization").(string)
-	if tokenString == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the token algorithm is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, usersvc.ErrUnexpectedSigningMethod
+// AuthorizationMiddleware restricts the energy price table writes to admins.
+// It relies on the claims put into the context by the auth.NewParser endpoint middleware.
+func AuthorizationMiddleware() Middleware {
+	return func(next NavigationService) NavigationService {
+		return &authorizationMiddleware{
+			next: next,
 		}
-		return []byte(signingKey), nil
-	})
-	if err != nil {
-		return nil, usersvc.ErrInvalidToken
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		ctx = context.WithValue(ctx, "email", claims["Email"])
-		ctx = context.WithValue(ctx, "userId", claims["userId"])
-		return ctx, nil
 	}
-	return nil, usersvc.ErrInvalidToken
 }
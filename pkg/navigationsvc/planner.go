@@ -0,0 +1,254 @@
+package navigationsvc
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/pricing"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultReserveSoC = 10
+	defaultTargetSoC  = 80
+
+	// Stations are only searched inside the bounding box of the trip, widened by this many degrees (~50 km).
+	corridorMargin = 0.5
+	// Road distances are estimated from the straight line distance.
+	roadDistanceFactor = 1.2
+	// Arrival times at the stops, which the time of use prices depend on, assume this average speed in km/h.
+	averageDrivingSpeed = 80
+	maxChargingStops    = 20
+	// socTolerance absorbs the floating point error of the battery levels, so a vehicle charged
+	// just enough to reach a point is not found to fall short of it.
+	socTolerance = 1e-9
+)
+
+// PlanRoute picks charging stops from the station database so the vehicle can drive
+// from the start point to the arrival point without its battery dropping below the reserve.
+func (s *navigationService) PlanRoute(ctx context.Context, req *model.RoutePlanRequest) (*model.RoutePlan, error) {
+	vehicle := req.Vehicle
+	if vehicle == nil {
+		var err error
+		if vehicle, err = s.userVehicle(ctx, req.VehicleID); err != nil {
+			return nil, err
+		}
+	}
+	if vehicle.BatteryCapacity <= 0 || vehicle.EnergyConsumption <= 0 {
+		return nil, ErrNotElectricVehicle
+	}
+
+	reserve := req.ReserveSoC
+	if reserve <= 0 {
+		reserve = defaultReserveSoC
+	}
+	target := req.TargetSoC
+	if target <= 0 {
+		target = defaultTargetSoC
+	}
+	if req.StateOfCharge <= 0 || req.StateOfCharge > 100 || reserve >= target || target > 100 {
+		return nil, ErrInvalidStateOfCharge
+	}
+
+	candidates, err := s.corridorCandidates(ctx, req.StartPoint, req.ArrivalPoint, vehicle.SocketTypes)
+	if err != nil {
+		return nil, err
+	}
+	tariffs, err := pricing.LoadTariffs(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		plan     = &model.RoutePlan{Currency: pricing.DefaultCurrency, Costs: []model.CurrencyCost{}}
+		position = req.StartPoint
+		soc      = req.StateOfCharge
+		traveled = 0.0
+		visited  = make(map[int]bool)
+		clock    = req.DepartureAt
+	)
+	if clock.IsZero() {
+		clock = time.Now().UTC()
+	}
+
+	// socUsedFor returns the battery percentage needed to drive the given distance.
+	socUsedFor := func(distance float64) float64 {
+		return distance * vehicle.EnergyConsumption / 100 / vehicle.BatteryCapacity * 100
+	}
+
+	for len(plan.Stops) <= maxChargingStops {
+		remaining := roadDistance(position, req.ArrivalPoint)
+		if soc-socUsedFor(remaining) >= reserve-socTolerance {
+			plan.Distance = roundResult(traveled + remaining)
+			plan.ArrivalSoC = roundResult(soc - socUsedFor(remaining))
+			plan.TotalChargeTime = roundResult(plan.TotalChargeTime)
+			totalCosts(plan)
+			return plan, nil
+		}
+
+		// Among the stations reachable without going below the reserve and open when the vehicle
+		// gets there, pick the one that leaves the shortest distance to the arrival point.
+		next := -1
+		for i, c := range candidates {
+			if visited[i] {
+				continue
+			}
+			point := model.Coordinate{Lat: c.station.Latitude, Long: c.station.Longitude}
+			leg := roadDistance(position, point)
+			if soc-socUsedFor(leg) < reserve-socTolerance {
+				continue
+			}
+			if !c.station.OpenAt(clock.Add(time.Duration(leg / averageDrivingSpeed * float64(time.Hour)))) {
+				continue
+			}
+			left := roadDistance(point, req.ArrivalPoint)
+			if left >= remaining {
+				continue
+			}
+			if next == -1 || left < candidates[next].left(req.ArrivalPoint) ||
+				(left == candidates[next].left(req.ArrivalPoint) && c.socket.KW > candidates[next].socket.KW) {
+				next = i
+			}
+		}
+		if next == -1 {
+			return nil, ErrNoReachableStation
+		}
+		visited[next] = true
+
+		c := candidates[next]
+		point := model.Coordinate{Lat: c.station.Latitude, Long: c.station.Longitude}
+		leg := roadDistance(position, point)
+		traveled += leg
+		soc -= socUsedFor(leg)
+		clock = clock.Add(time.Duration(leg / averageDrivingSpeed * float64(time.Hour)))
+
+		position = point
+
+		// Charge up to the target, or just enough to reach the arrival point when that is less.
+		// A vehicle arriving above the target would not get any further by charging to it, so it charges
+		// as much as the rest of the trip needs instead; a full one drives past the station.
+		needed := reserve + socUsedFor(c.left(req.ArrivalPoint))
+		chargeTo := math.Min(target, needed)
+		if chargeTo <= soc {
+			chargeTo = math.Min(100, needed)
+		}
+		if chargeTo <= soc {
+			continue
+		}
+		energy := (chargeTo - soc) / 100 * vehicle.BatteryCapacity
+		quote := pricing.Quote(tariffs.For(c.station, &c.socket), clock, energy, c.socket.KW, 0)
+		chargeTime := quote.DurationMinutes
+
+		plan.Stops = append(plan.Stops, model.ChargingStop{
+			StationID:         c.station.ID,
+			SocketID:          c.socket.ID,
+			Brand:             c.station.Brand,
+			Address:           c.station.Address,
+			Lat:               c.station.Latitude,
+			Long:              c.station.Longitude,
+			SocketType:        c.socket.SocketType,
+			KW:                c.socket.KW,
+			DistanceFromStart: roundResult(traveled),
+			ArrivalSoC:        roundResult(soc),
+			DepartureSoC:      roundResult(chargeTo),
+			EnergyKWh:         roundResult(energy),
+			ArrivalAt:         clock,
+			ChargeTime:        roundResult(chargeTime),
+			Cost:              quote.Total,
+			Currency:          quote.Currency,
+		})
+		plan.TotalChargeTime += chargeTime
+		clock = clock.Add(time.Duration(chargeTime * float64(time.Minute)))
+		soc = chargeTo
+	}
+	return nil, ErrNoReachableStation
+}
+
+// totalCosts adds up the costs of the stops of the plan in each of their currencies.
+func totalCosts(plan *model.RoutePlan) {
+	for _, stop := range plan.Stops {
+		i := slices.IndexFunc(plan.Costs, func(c model.CurrencyCost) bool { return c.Currency == stop.Currency })
+		if i == -1 {
+			plan.Costs = append(plan.Costs, model.CurrencyCost{Currency: stop.Currency})
+			i = len(plan.Costs) - 1
+		}
+		plan.Costs[i].Cost += stop.Cost
+	}
+	for i := range plan.Costs {
+		plan.Costs[i].Cost = roundResult(plan.Costs[i].Cost)
+	}
+
+	switch len(plan.Costs) {
+	case 0:
+	case 1:
+		plan.TotalCost, plan.Currency = plan.Costs[0].Cost, plan.Costs[0].Currency
+	default:
+		plan.TotalCost, plan.Currency = 0, ""
+	}
+}
+
+// chargingCandidate is a station together with its most powerful socket usable by the vehicle.
+type chargingCandidate struct {
+	station *model.Station
+	socket  model.Socket
+}
+
+func (c chargingCandidate) left(arrival model.Coordinate) float64 {
+	return roadDistance(model.Coordinate{Lat: c.station.Latitude, Long: c.station.Longitude}, arrival)
+}
+
+// corridorCandidates returns the stations around the trip which have an available socket
+// compatible with the given socket types. Every socket is accepted when socketTypes is empty.
+func (s *navigationService) corridorCandidates(ctx context.Context, start, arrival model.Coordinate, socketTypes []string) ([]chargingCandidate, error) {
+	filter := bson.M{
+		"Latitude": bson.M{
+			"$gte": math.Min(start.Lat, arrival.Lat) - corridorMargin,
+			"$lte": math.Max(start.Lat, arrival.Lat) + corridorMargin,
+		},
+		"Longitude": bson.M{
+			"$gte": math.Min(start.Long, arrival.Long) - corridorMargin,
+			"$lte": math.Max(start.Long, arrival.Long) + corridorMargin,
+		},
+	}
+	stations, err := s.store.FindStationByFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []chargingCandidate
+	for _, station := range stations {
+		best := -1
+		for i, socket := range station.Sockets {
+			if socket.Status != model.Available || !supportsSocket(socketTypes, socket.SocketType) {
+				continue
+			}
+			if best == -1 || socket.KW > station.Sockets[best].KW {
+				best = i
+			}
+		}
+		if best != -1 {
+			candidates = append(candidates, chargingCandidate{station: station, socket: station.Sockets[best]})
+		}
+	}
+	return candidates, nil
+}
+
+func supportsSocket(socketTypes []string, socketType string) bool {
+	if len(socketTypes) == 0 {
+		return true
+	}
+	for _, t := range socketTypes {
+		if strings.EqualFold(t, socketType) {
+			return true
+		}
+	}
+	return false
+}
+
+func roadDistance(from, to model.Coordinate) float64 {
+	return haversineDistance(from.Lat, from.Long, to.Lat, to.Long) * roadDistanceFactor
+}
@@ -0,0 +1,196 @@
+package navigationsvc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The test vehicle drives 3 km on each percent of its battery.
+var testVehicle = &model.Vehicle{BatteryCapacity: 60, EnergyConsumption: 20}
+
+// along returns the point on the equator the given road distance east of 0, 0.
+func along(km float64) model.Coordinate {
+	return model.Coordinate{Long: km / roadDistanceFactor / (earthRadius * math.Pi / 180)}
+}
+
+// newPlanner returns a service over a memory store with a station of one available 50 kW socket at each of the points.
+func newPlanner(t *testing.T, brands map[string]model.Coordinate) *navigationService {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	for brand, point := range brands {
+		station := &model.Station{
+			ID:        primitive.NewObjectID(),
+			Brand:     brand,
+			Latitude:  point.Lat,
+			Longitude: point.Long,
+			Sockets:   []model.Socket{{ID: primitive.NewObjectID(), SocketType: "CCS", KW: 50, CurrentType: model.DC, Price: 10, Status: model.Available}},
+		}
+		station.SetLocation()
+		if _, err := store.InsertStation(context.Background(), station); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewNavigationService(store).(*navigationService)
+}
+
+func TestPlanRoute(t *testing.T) {
+	s := newPlanner(t, map[string]model.Coordinate{"A": along(90), "B": along(270)})
+	plan, err := s.PlanRoute(context.Background(), &model.RoutePlanRequest{
+		StartPoint:    along(0),
+		ArrivalPoint:  along(360),
+		Vehicle:       testVehicle,
+		StateOfCharge: 50,
+		DepartureAt:   time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("PlanRoute: %v", err)
+	}
+
+	// A is reached at 20%, charged to the 80% target and left for B, reached at 20% again,
+	// where the 30 km left only need 20%.
+	want := []struct {
+		brand        string
+		arrivalSoC   float64
+		departureSoC float64
+	}{
+		{"A", 20, 80},
+		{"B", 20, 40},
+	}
+	if len(plan.Stops) != len(want) {
+		t.Fatalf("plan has %d stops, want %d: %+v", len(plan.Stops), len(want), plan.Stops)
+	}
+	for i, w := range want {
+		stop := plan.Stops[i]
+		if stop.Brand != w.brand || stop.ArrivalSoC != w.arrivalSoC || stop.DepartureSoC != w.departureSoC {
+			t.Errorf("stop %d = %s from %v%% to %v%%, want %s from %v%% to %v%%",
+				i, stop.Brand, stop.ArrivalSoC, stop.DepartureSoC, w.brand, w.arrivalSoC, w.departureSoC)
+		}
+	}
+	if plan.Distance != 360 || plan.ArrivalSoC != 10 {
+		t.Errorf("plan drives %v km and arrives at %v%%, want 360 km and 10%%", plan.Distance, plan.ArrivalSoC)
+	}
+}
+
+func TestPlanRouteChargesAboveTheTarget(t *testing.T) {
+	// B is too far from the start, and from A at the 80% target, so the vehicle reaching A above the
+	// target charges more there instead of stopping for nothing.
+	s := newPlanner(t, map[string]model.Coordinate{"A": along(15), "B": along(15 + 261)})
+	plan, err := s.PlanRoute(context.Background(), &model.RoutePlanRequest{
+		StartPoint:    along(0),
+		ArrivalPoint:  along(15 + 261 + 30),
+		Vehicle:       testVehicle,
+		StateOfCharge: 100,
+		TargetSoC:     80,
+	})
+	if err != nil {
+		t.Fatalf("PlanRoute: %v", err)
+	}
+	if len(plan.Stops) != 2 {
+		t.Fatalf("plan has %d stops, want 2: %+v", len(plan.Stops), plan.Stops)
+	}
+	if stop := plan.Stops[0]; stop.ArrivalSoC != 95 || stop.DepartureSoC != 100 {
+		t.Errorf("stop at A from %v%% to %v%%, want from 95%% to 100%%", stop.ArrivalSoC, stop.DepartureSoC)
+	}
+	for _, stop := range plan.Stops {
+		if stop.EnergyKWh <= 0 {
+			t.Errorf("stop at %s charges %v kWh", stop.Brand, stop.EnergyKWh)
+		}
+	}
+}
+
+func TestPlanRouteWithoutStops(t *testing.T) {
+	s := newPlanner(t, map[string]model.Coordinate{"A": along(90)})
+	plan, err := s.PlanRoute(context.Background(), &model.RoutePlanRequest{
+		StartPoint:    along(0),
+		ArrivalPoint:  along(150),
+		Vehicle:       testVehicle,
+		StateOfCharge: 90,
+	})
+	if err != nil {
+		t.Fatalf("PlanRoute: %v", err)
+	}
+	if len(plan.Stops) != 0 || plan.ArrivalSoC != 40 || plan.TotalCost != 0 || len(plan.Costs) != 0 {
+		t.Errorf("plan = %+v, want no stops and an arrival at 40%%", plan)
+	}
+}
+
+func TestPlanRouteUnreachable(t *testing.T) {
+	s := newPlanner(t, map[string]model.Coordinate{"A": along(90), "B": along(400)})
+	_, err := s.PlanRoute(context.Background(), &model.RoutePlanRequest{
+		StartPoint:    along(0),
+		ArrivalPoint:  along(600),
+		Vehicle:       testVehicle,
+		StateOfCharge: 50,
+	})
+	if !errors.Is(err, ErrNoReachableStation) {
+		t.Errorf("PlanRoute: got %v, want %v", err, ErrNoReachableStation)
+	}
+}
+
+func TestPlanRouteInvalid(t *testing.T) {
+	s := newPlanner(t, nil)
+	tests := []struct {
+		name    string
+		req     model.RoutePlanRequest
+		wantErr error
+	}{
+		{name: "no battery", req: model.RoutePlanRequest{Vehicle: &model.Vehicle{EnergyConsumption: 20}, StateOfCharge: 50}, wantErr: ErrNotElectricVehicle},
+		{name: "empty battery", req: model.RoutePlanRequest{Vehicle: testVehicle}, wantErr: ErrInvalidStateOfCharge},
+		{name: "reserve above the target", req: model.RoutePlanRequest{Vehicle: testVehicle, StateOfCharge: 50, ReserveSoC: 50, TargetSoC: 40}, wantErr: ErrInvalidStateOfCharge},
+		{name: "target above full", req: model.RoutePlanRequest{Vehicle: testVehicle, StateOfCharge: 50, TargetSoC: 120}, wantErr: ErrInvalidStateOfCharge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.PlanRoute(context.Background(), &tt.req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("PlanRoute: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTotalCosts(t *testing.T) {
+	tests := []struct {
+		name         string
+		stops        []model.ChargingStop
+		wantCost     float64
+		wantCurrency string
+		wantCosts    []model.CurrencyCost
+	}{
+		{
+			name:         "one currency",
+			stops:        []model.ChargingStop{{Cost: 360, Currency: "TRY"}, {Cost: 180.5, Currency: "TRY"}},
+			wantCost:     540.5,
+			wantCurrency: "TRY",
+			wantCosts:    []model.CurrencyCost{{Currency: "TRY", Cost: 540.5}},
+		},
+		{
+			name:      "two currencies",
+			stops:     []model.ChargingStop{{Cost: 360, Currency: "TRY"}, {Cost: 9, Currency: "EUR"}, {Cost: 40, Currency: "TRY"}},
+			wantCosts: []model.CurrencyCost{{Currency: "TRY", Cost: 400}, {Currency: "EUR", Cost: 9}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &model.RoutePlan{Currency: "TRY", Costs: []model.CurrencyCost{}, Stops: tt.stops}
+			totalCosts(plan)
+			if plan.TotalCost != tt.wantCost || plan.Currency != tt.wantCurrency {
+				t.Errorf("total = %v %q, want %v %q", plan.TotalCost, plan.Currency, tt.wantCost, tt.wantCurrency)
+			}
+			if len(plan.Costs) != len(tt.wantCosts) {
+				t.Fatalf("costs = %+v, want %+v", plan.Costs, tt.wantCosts)
+			}
+			for i := range tt.wantCosts {
+				if plan.Costs[i] != tt.wantCosts[i] {
+					t.Errorf("costs = %+v, want %+v", plan.Costs, tt.wantCosts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package navigationsvc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/pricing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidEnergyPrice  = errors.New("invalid energy price")
+	ErrEnergyPriceNotFound = errors.New("energy price not found")
+	ErrEnergyPriceInEffect = errors.New("energy price is already in effect")
+	ErrInvalidDate         = errors.New("invalid date")
+	ErrNoEnergyPrice       = errors.New("no energy price in effect")
+)
+
+// AddEnergyPrice adds a version of the price of an energy type. It takes effect from its EffectiveFrom
+// date, now when empty, until a later version does.
+func (s *navigationService) AddEnergyPrice(ctx context.Context, price *model.EnergyPrice) (*model.EnergyPrice, error) {
+	price.Region = strings.TrimSpace(price.Region)
+	if !price.Energy.Valid() || !pricing.ValidCurrency(price.Currency) || price.Price <= 0 || math.IsInf(price.Price, 0) {
+		return nil, ErrInvalidEnergyPrice
+	}
+	now := time.Now().UTC()
+	if price.EffectiveFrom.IsZero() {
+		price.EffectiveFrom = now
+	}
+	price.ID = primitive.NewObjectID()
+	price.Unit = price.Energy.Unit()
+	price.EffectiveFrom = price.EffectiveFrom.UTC()
+	price.CreatedAt = now
+	if err := s.store.InsertEnergyPrice(ctx, price); err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+// EnergyPrices returns the price of every energy type in effect in the region at the given time.
+// The energy types without a price in effect are left out.
+func (s *navigationService) EnergyPrices(ctx context.Context, region string, at time.Time) ([]*model.EnergyPrice, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	prices := make([]*model.EnergyPrice, 0, len(model.EnergyTypes))
+	for _, energy := range model.EnergyTypes {
+		price, err := s.energyPrice(ctx, energy, region, at)
+		if errors.Is(err, ErrNoEnergyPrice) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+// EnergyPriceHistory lists all the versions of the prices of the region, including the national ones, latest first.
+func (s *navigationService) EnergyPriceHistory(ctx context.Context, region string) ([]*model.EnergyPrice, error) {
+	return s.store.FindEnergyPricesByFilter(ctx, bson.M{"Region": bson.M{"$in": regionsOf(region)}})
+}
+
+// DeleteEnergyPrice removes a version which has not taken effect yet. Versions in effect
+// are kept so the trips calculated with them can be explained.
+func (s *navigationService) DeleteEnergyPrice(ctx context.Context, priceId string) error {
+	oid, err := primitive.ObjectIDFromHex(priceId)
+	if err != nil {
+		return ErrEnergyPriceNotFound
+	}
+	prices, err := s.store.FindEnergyPricesByFilter(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if len(prices) == 0 {
+		return ErrEnergyPriceNotFound
+	}
+	if !prices[0].EffectiveFrom.After(time.Now()) {
+		return ErrEnergyPriceInEffect
+	}
+	err = s.store.DeleteEnergyPrice(ctx, priceId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrEnergyPriceNotFound
+	}
+	return err
+}
+
+// energyPrice returns the version in effect at the given time: the one which took effect last, whether it is
+// national or of the region, so a newer national price replaces an older regional one. The price of the region
+// wins over a national one taking effect at the same time. ErrNoEnergyPrice is returned when no version is in effect.
+func (s *navigationService) energyPrice(ctx context.Context, energy model.EnergyType, region string, at time.Time) (*model.EnergyPrice, error) {
+	prices, err := s.store.FindEnergyPricesByFilter(ctx, bson.M{
+		"Energy":        energy,
+		"Region":        bson.M{"$in": regionsOf(region)},
+		"EffectiveFrom": bson.M{"$lte": at},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var inEffect *model.EnergyPrice
+	for _, price := range prices {
+		if inEffect == nil || price.EffectiveFrom.After(inEffect.EffectiveFrom) ||
+			price.EffectiveFrom.Equal(inEffect.EffectiveFrom) && price.Region != "" && inEffect.Region == "" {
+			inEffect = price
+		}
+	}
+	if inEffect == nil {
+		return nil, ErrNoEnergyPrice
+	}
+	return inEffect, nil
+}
+
+func regionsOf(region string) []string {
+	region = strings.TrimSpace(region)
+	if region == "" {
+		return []string{""}
+	}
+	return []string{"", region}
+}
+
+// energyOf returns what the engine runs on. Hybrids are refuelled with petrol.
+func energyOf(engineType model.EngineType) model.EnergyType {
+	switch engineType {
+	case model.Diesel:
+		return model.EnergyDiesel
+	case model.LPG:
+		return model.EnergyLPG
+	case model.Electric:
+		return model.EnergyElectricityHome
+	default:
+		return model.EnergyPetrol
+	}
+}
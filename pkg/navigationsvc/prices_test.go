@@ -0,0 +1,84 @@
+package navigationsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEnergyPriceInEffect(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+	for _, price := range []struct {
+		region string
+		price  float64
+		from   time.Time
+	}{
+		{"", 40, day(1, 1)},
+		{"Istanbul", 42, day(2, 1)},
+		{"", 45, day(3, 1)},
+		{"Istanbul", 47, day(3, 1)},
+		{"Ankara", 41, day(4, 1)},
+	} {
+		err := store.InsertEnergyPrice(ctx, &model.EnergyPrice{
+			ID:            primitive.NewObjectID(),
+			Energy:        model.EnergyPetrol,
+			Region:        price.region,
+			Currency:      "TRY",
+			Price:         price.price,
+			Unit:          "L",
+			EffectiveFrom: price.from,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewNavigationService(store).(*navigationService)
+
+	tests := []struct {
+		name   string
+		region string
+		at     time.Time
+		want   float64
+	}{
+		{name: "national", at: day(1, 15), want: 40},
+		{name: "regional", region: "Istanbul", at: day(2, 15), want: 42},
+		{name: "regional of another region", region: "Ankara", at: day(2, 15), want: 40},
+		{name: "newer national over older regional", region: "Istanbul", at: day(3, 1).Add(-time.Hour), want: 42},
+		{name: "regional on the same day as national", region: "Istanbul", at: day(3, 15), want: 47},
+		{name: "national after the change", at: day(3, 15), want: 45},
+		{name: "newer regional over national", region: "Ankara", at: day(5, 1), want: 41},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := s.energyPrice(ctx, model.EnergyPetrol, tt.region, tt.at)
+			if err != nil {
+				t.Fatalf("energyPrice: %v", err)
+			}
+			if price.Price != tt.want {
+				t.Errorf("price = %v, want %v", price.Price, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.energyPrice(ctx, model.EnergyPetrol, "", day(1, 1).Add(-time.Hour)); !errors.Is(err, ErrNoEnergyPrice) {
+		t.Errorf("energyPrice before the first version: got %v, want %v", err, ErrNoEnergyPrice)
+	}
+	if _, err := s.energyPrice(ctx, model.EnergyDiesel, "", day(5, 1)); !errors.Is(err, ErrNoEnergyPrice) {
+		t.Errorf("energyPrice of an energy without a price: got %v, want %v", err, ErrNoEnergyPrice)
+	}
+
+	prices, err := s.EnergyPrices(ctx, "Istanbul", day(5, 1))
+	if err != nil {
+		t.Fatalf("EnergyPrices: %v", err)
+	}
+	if len(prices) != 1 || prices[0].Energy != model.EnergyPetrol || prices[0].Price != 47 {
+		t.Errorf("EnergyPrices = %+v, want only the petrol price of Istanbul", prices)
+	}
+}
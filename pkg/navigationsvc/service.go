@@ -2,24 +2,44 @@ package navigationsvc
 
 import (
 	"context"
+	"errors"
 	"math"
+	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
 	"california/pkg/repository"
+	"california/pkg/usersvc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
-	patrolPrice = 33.02
-	dieselPrice = 35.47
-
 	earthRadius = 6371
+
+	// defaultStopInterval is how far apart the recommended stops are, in km, unless the vehicle's range is shorter.
+	defaultStopInterval = 300
+	minStopInterval     = 50
 )
 
 type NavigationService interface {
 	CalculateTrip(ctx context.Context, req calculateTripRequest) (tripInfo []*model.TripInfo, err error)
 	Recommend(ctx context.Context, req *model.RecommendRequest) (advices []*model.Advice, err error)
+	PlanRoute(ctx context.Context, req *model.RoutePlanRequest) (plan *model.RoutePlan, err error)
+	AddEnergyPrice(ctx context.Context, price *model.EnergyPrice) (insertedPrice *model.EnergyPrice, err error)
+	EnergyPrices(ctx context.Context, region string, at time.Time) (prices []*model.EnergyPrice, err error)
+	EnergyPriceHistory(ctx context.Context, region string) (prices []*model.EnergyPrice, err error)
+	DeleteEnergyPrice(ctx context.Context, priceId string) (err error)
 }
 
+var (
+	ErrNotElectricVehicle   = errors.New("vehicle has no battery capacity or energy consumption")
+	ErrInvalidStateOfCharge = errors.New("invalid state of charge")
+	ErrNoReachableStation   = errors.New("no reachable charging station")
+	ErrInvalidTemperature   = errors.New("invalid temperature")
+	ErrNoVehicle            = errors.New("user has no vehicle")
+)
+
 type navigationService struct {
 	store repository.Store
 }
@@ -31,8 +51,33 @@ func NewNavigationService(store repository.Store) NavigationService {
 }
 
 func (s *navigationService) Recommend(ctx context.Context, rec *model.RecommendRequest) (advices []*model.Advice, err error) {
+	vehicle, err := s.userVehicle(ctx, rec.VehicleID)
+	if err != nil && !errors.Is(err, ErrNoVehicle) {
+		return nil, err
+	}
+	interval := stopInterval(vehicle)
+
+	stops := rec.Stops
+	if rec.OpenNow {
+		if stops, err = s.openStops(ctx, stops, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	favorites, err := s.favoriteStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	favoriteStops := make(map[string]bool)
+	for i := range stops {
+		stops[i].Favorite = favorites[stops[i].StationID]
+		if stops[i].Favorite {
+			favoriteStops[stops[i].Name] = true
+		}
+	}
+
 	var (
-		allStops         = rec.Stops
+		allStops         = stops
 		startPoint       = rec.StartPoint
 		arrivalPoint     = rec.ArrivalPoint
 		_                = haversineDistance(startPoint.Lat, startPoint.Long, arrivalPoint.Lat, arrivalPoint.Long)
@@ -53,8 +98,8 @@ func (s *navigationService) Recommend(ctx context.Context, rec *model.RecommendR
 	// Eğer toplam yol 600 km ise 1 durak önerilecek. Ve 300 300 artarak gidecek
 	// İlk kontrol edilecek durak 200 km'den uzak olmalı. 200 km'den uzak olan duraklar arasından en yakın olanı seçilecek.
 	// Eğer toplam yol 0-600 km arasındaysa 1 durak önerilecek. Ve bu durak yaklaşık %50lik kısımda olacak. +- 5km.
-	totalStopCount := int(float64(realDistance / 300))
-	if realDistance <= 650 {
+	totalStopCount := int(float64(realDistance / interval))
+	if realDistance <= 2*interval+50 {
 		totalStopCount = 1
 	}
 
@@ -81,34 +126,27 @@ func (s *navigationService) Recommend(ctx context.Context, rec *model.RecommendR
 			//	}
 			//}
 			for {
-				found := false
-				for stopName, dist := range startStopDistMap {
-					if dist > float64(stopPoint-increment) && dist < float64(stopPoint+increment) {
-						for _, stop := range allStops {
-							if stop.Name == stopName {
-								dStop := model.Stop{
-									Name: stopName,
-									Lat:  stop.Lat,
-									Long: stop.Long,
-								}
-								dStop.DetermineColor(increment)
-								advice.Stops = append(advice.Stops, dStop)
-								found = true
-								break
+				stopName, found := pickStop(startStopDistMap, favoriteStops, stopPoint, increment)
+				if found {
+					for _, stop := range allStops {
+						if stop.Name == stopName {
+							dStop := model.Stop{
+								Name:      stopName,
+								Lat:       stop.Lat,
+								Long:      stop.Long,
+								StationID: stop.StationID,
+								Favorite:  stop.Favorite,
 							}
+							dStop.DetermineColor(increment)
+							advice.Stops = append(advice.Stops, dStop)
+							break
 						}
 					}
-					if found {
-						break
-					}
+					break
 				}
-				if found {
+				increment += 10
+				if increment > 50 {
 					break
-				} else {
-					increment += 10
-					if increment > 50 {
-						break
-					}
 				}
 			}
 			advices = append(advices, &advice)
@@ -117,7 +155,7 @@ func (s *navigationService) Recommend(ctx context.Context, rec *model.RecommendR
 	}
 
 	for i := 1; i <= totalStopCount; i++ {
-		stopPoint := 300 * i
+		stopPoint := interval * i
 		for j := 1; j <= totalAdviceCount; j++ {
 			var advice model.Advice
 			advice.Number = j
@@ -140,35 +178,27 @@ func (s *navigationService) Recommend(ctx context.Context, rec *model.RecommendR
 			//	}
 			//}
 			for {
-				found := false
-				for stopName, dist := range startStopDistMap {
-					if dist > float64(stopPoint-increment) && dist < float64(stopPoint+increment) {
-						for _, stop := range allStops {
-							if stop.Name == stopName {
-								dStop := model.Stop{
-									Name: stopName,
-									Lat:  stop.Lat,
-									Long: stop.Long,
-								}
-								dStop.DetermineColor(increment)
-								advice.Stops = append(advice.Stops, dStop)
-								found = true
-								break
+				stopName, found := pickStop(startStopDistMap, favoriteStops, stopPoint, increment)
+				if found {
+					for _, stop := range allStops {
+						if stop.Name == stopName {
+							dStop := model.Stop{
+								Name:      stopName,
+								Lat:       stop.Lat,
+								Long:      stop.Long,
+								StationID: stop.StationID,
+								Favorite:  stop.Favorite,
 							}
+							dStop.DetermineColor(increment)
+							advice.Stops = append(advice.Stops, dStop)
+							break
 						}
 					}
-					if found {
-						break
-					}
+					break
 				}
-
-				if found {
+				increment += 10
+				if increment > 50 {
 					break
-				} else {
-					increment += 10
-					if increment > 50 {
-						break
-					}
 				}
 			}
 			advices = append(advices, &advice)
@@ -178,6 +208,68 @@ func (s *navigationService) Recommend(ctx context.Context, rec *model.RecommendR
 	return advices, nil
 }
 
+// pickStop returns the name of a stop between stopPoint-increment and stopPoint+increment km from the start.
+// The stops in the range are equally good, so one at a favourite station of the user is picked when there is one.
+func pickStop(distances map[string]float64, favoriteStops map[string]bool, stopPoint, increment int) (string, bool) {
+	picked, found := "", false
+	for stopName, dist := range distances {
+		if dist <= float64(stopPoint-increment) || dist >= float64(stopPoint+increment) {
+			continue
+		}
+		if favoriteStops[stopName] {
+			return stopName, true
+		}
+		if !found {
+			picked, found = stopName, true
+		}
+	}
+	return picked, found
+}
+
+// favoriteStations returns the ids of the stations bookmarked by the user.
+func (s *navigationService) favoriteStations(ctx context.Context) (map[string]bool, error) {
+	userEmail := auth.Email(ctx)
+	user, err := s.store.GetUserByEmail(ctx, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	favorites := make(map[string]bool, len(user.FavoriteStations))
+	for _, id := range user.FavoriteStations {
+		favorites[id.Hex()] = true
+	}
+	return favorites, nil
+}
+
+// openStops returns the stops without those at a station which is closed at the given time.
+// Stops which are not at a known station are kept.
+func (s *navigationService) openStops(ctx context.Context, stops []model.Stop, at time.Time) ([]model.Stop, error) {
+	ids := bson.A{}
+	for _, stop := range stops {
+		if oid, err := primitive.ObjectIDFromHex(stop.StationID); err == nil {
+			ids = append(ids, oid)
+		}
+	}
+	if len(ids) == 0 {
+		return stops, nil
+	}
+	stations, err := s.store.FindStationByFilter(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	closed := make(map[string]bool)
+	for _, station := range stations {
+		closed[station.ID.Hex()] = !station.OpenAt(at)
+	}
+
+	var open []model.Stop
+	for _, stop := range stops {
+		if !closed[stop.StationID] {
+			open = append(open, stop)
+		}
+	}
+	return open, nil
+}
+
 func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	// convert to radians
 	lat1 = degreesToRadians(lat1)
@@ -197,41 +289,75 @@ func degreesToRadians(degrees float64) float64 {
 	return degrees * math.Pi / 180
 }
 
-func (s *navigationService) CalculateTrip(ctx context.Context, req calculateTripRequest) (tripInfo []*model.TripInfo, err error) {
-	userEmail := ctx.Value("email").(string)
+// stopInterval returns how far apart the stops of the vehicle are recommended, in km. An electric vehicle
+// is expected to charge from the reserve up to the target battery level of the route planner at each stop.
+func stopInterval(vehicle *model.Vehicle) int {
+	if vehicle == nil || vehicle.BatteryCapacity <= 0 || vehicle.EnergyConsumption <= 0 {
+		return defaultStopInterval
+	}
+	usable := vehicle.BatteryCapacity * (defaultTargetSoC - defaultReserveSoC) / 100
+	interval := int(usable / vehicle.EnergyConsumption * 100)
+	return max(minStopInterval, min(interval, defaultStopInterval))
+}
+
+// userVehicle returns the vehicle of the user's garage with the given id, or the default vehicle when the id is empty.
+func (s *navigationService) userVehicle(ctx context.Context, vehicleId string) (*model.Vehicle, error) {
+	userEmail := auth.Email(ctx)
 	user, err := s.store.GetUserByEmail(ctx, userEmail)
 	if err != nil {
 		return nil, err
 	}
+	if vehicleId == "" {
+		if vehicle := user.DefaultVehicle(); vehicle != nil {
+			return vehicle, nil
+		}
+		return nil, ErrNoVehicle
+	}
+	oid, err := primitive.ObjectIDFromHex(vehicleId)
+	if err != nil {
+		return nil, usersvc.ErrVehicleNotFound
+	}
+	vehicle := user.VehicleById(oid)
+	if vehicle == nil {
+		return nil, usersvc.ErrVehicleNotFound
+	}
+	return vehicle, nil
+}
 
-	var userVehicle *model.Vehicle
-	userVehicle = &user.Vehicle
+func (s *navigationService) CalculateTrip(ctx context.Context, req calculateTripRequest) (tripInfo []*model.TripInfo, err error) {
+	userVehicle, err := s.userVehicle(ctx, req.VehicleID)
+	if err != nil {
+		return nil, err
+	}
 
+	date := req.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
 	speeds := []float64{60, 80, 90, 100, 110, 120, 150, 200}
+	if userVehicle.EngineType == model.Electric {
+		return s.calculateElectricTrip(ctx, userVehicle, req, date, speeds)
+	}
+
+	price, err := s.energyPrice(ctx, energyOf(userVehicle.EngineType), req.Region, date)
+	if err != nil {
+		return nil, err
+	}
 	for _, speed := range speeds {
 		avgConsumption := calculateFuelConsumption(userVehicle.EngineType, userVehicle.EngineSize, userVehicle.AverageConsumption, req.Distance, speed)
-		totalPrice := calculateTotalPrice(avgConsumption, userVehicle.EngineType)
+		totalPrice := avgConsumption * price.Price
 		tripInfo = append(tripInfo, &model.TripInfo{
 			Speed:              speed,
 			AverageConsumption: roundResult(avgConsumption),
 			Distance:           req.Distance,
 			TotalPrice:         roundResult(totalPrice),
+			UnitPrice:          price.Price,
+			Currency:           price.Currency,
 		})
 	}
 	return tripInfo, nil
 }
 
-func calculateTotalPrice(consumption float64, engineType model.EngineType) float64 {
-	switch engineType {
-	case 1:
-		return consumption * patrolPrice
-	case 2:
-		return consumption * dieselPrice
-	default:
-		return consumption * patrolPrice
-	}
-}
-
 func calculateFuelConsumption(engineType model.EngineType, engineSize, averageConsumption, distance, speed float64) float64 {
 	//Base speed factor for all engines
 	speedFactor := math.Pow(speed/100, 1.2)
@@ -6,26 +6,35 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
 	"california/pkg/usersvc"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 )
 
-func MakeHTTPHandler(c context.Context, s NavigationService, log log.Logger) http.Handler {
+// MakeHTTPHandler serves the endpoints of the service. The tokens of the requests are verified with the keys.
+func MakeHTTPHandler(s NavigationService, keys jwt.Keyfunc, log log.Logger) http.Handler {
 	r := mux.NewRouter()
-	e := MakeServerEndpoints(c, s)
+	e := MakeServerEndpoints(s, keys)
 	options := []httptransport.ServerOption{
+		httptransport.ServerBefore(auth.HTTPToContext),
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(log)),
 		httptransport.ServerErrorEncoder(encodeError),
 	}
 
-	// GET /trip?distance=543 returns the trip information.
+	// GET /trip?distance=543&date=<date>&region=<region>&temp=<°C>&soc=<%> returns the trip information.
 	// POST /recommend returns the recommended stops.
+	// POST /route/plan returns the charging stops an electric vehicle needs on the way.
+	// POST /prices adds a version of an energy price.
+	// GET /prices?region=<region>&at=<date> lists the energy prices in effect.
+	// GET /prices/history?region=<region> lists all the versions of the energy prices.
+	// DELETE /prices?id=<priceId> deletes a version which is not in effect yet.
 
 	r.Methods("GET").Path("/trip").Handler(httptransport.NewServer(
 		e.CalculateTripEndpoint,
@@ -39,43 +48,129 @@ func MakeHTTPHandler(c context.Context, s NavigationService, log log.Logger) htt
 		encodeResponse,
 		options...,
 	))
+	r.Methods("POST").Path("/route/plan").Handler(httptransport.NewServer(
+		e.PlanRouteEndpoint,
+		decodePlanRouteRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/prices").Handler(httptransport.NewServer(
+		e.AddPriceEndpoint,
+		decodeAddPriceRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/prices").Handler(httptransport.NewServer(
+		e.ListPricesEndpoint,
+		decodeListPricesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/prices/history").Handler(httptransport.NewServer(
+		e.PriceHistoryEndpoint,
+		decodeListPricesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/prices").Handler(httptransport.NewServer(
+		e.DeletePriceEndpoint,
+		decodeDeletePriceRequest,
+		encodeResponse,
+		options...,
+	))
 	return r
 }
 
 func decodeRecommendRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
-	}
-
 	var req model.RecommendRequest
-	req.Context = context.WithValue(ctx, "jwt", jwtToken)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return nil, err
 	}
 	return req, nil
 }
 
-func decodeCalculateTripRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, usersvc.ErrNoAuthTokenHeader
+func decodePlanRouteRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req model.RoutePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
 	}
+	return req, nil
+}
 
+func decodeCalculateTripRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	distanceStr := r.URL.Query().Get("distance")
 	distFloat, _ := strconv.ParseFloat(distanceStr, 64)
+	date, err := parseDate(r.URL.Query().Get("date"))
+	if err != nil {
+		return nil, err
+	}
 
-	ctx = context.WithValue(ctx, "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-	c = context.WithValue(c, "distance", distanceStr)
 	var req calculateTripRequest
-	req.Context = c
 	req.Distance = distFloat
+	req.Date = date
+	req.Region = r.URL.Query().Get("region")
+	req.VehicleID = r.URL.Query().Get("vehicle")
+	if temp := r.URL.Query().Get("temp"); temp != "" {
+		temperature, err := strconv.ParseFloat(temp, 64)
+		if err != nil {
+			return nil, ErrInvalidTemperature
+		}
+		req.Temperature = &temperature
+	}
+	if soc := r.URL.Query().Get("soc"); soc != "" {
+		stateOfCharge, err := strconv.ParseFloat(soc, 64)
+		if err != nil {
+			return nil, ErrInvalidStateOfCharge
+		}
+		req.StateOfCharge = stateOfCharge
+	}
+	return req, nil
+}
+
+func decodeAddPriceRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req addPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Price); err != nil {
+		return nil, err
+	}
+	if req.Price == nil {
+		return nil, ErrInvalidEnergyPrice
+	}
+	return req, nil
+}
+
+func decodeListPricesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	at, err := parseDate(r.URL.Query().Get("at"))
+	if err != nil {
+		return nil, err
+	}
+	var req listPricesRequest
+	req.Region = r.URL.Query().Get("region")
+	req.At = at
+	return req, nil
+}
+
+func decodeDeletePriceRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req deletePriceRequest
+	req.PriceID = r.URL.Query().Get("id")
 	return req, nil
 }
 
+// parseDate accepts either a date, which stands for the end of that day, or an RFC 3339 time.
+// An empty value is the zero time.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+	return t, nil
+}
+
 type errorer interface {
 	error() error
 }
@@ -107,16 +202,28 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 
 func codeFrom(err error) int {
 	switch {
-	case errors.Is(err, usersvc.ErrNotFound):
+	case errors.Is(err, usersvc.ErrNotFound), errors.Is(err, usersvc.ErrVehicleNotFound):
 		return http.StatusNotFound // 404
 	case errors.Is(err, usersvc.ErrAlreadyExists), errors.Is(err, usersvc.ErrInconsistentIDs):
 		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrNotElectricVehicle), errors.Is(err, ErrInvalidStateOfCharge), errors.Is(err, ErrNoVehicle):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrNoReachableStation), errors.Is(err, ErrNoEnergyPrice):
+		return http.StatusUnprocessableEntity // 422
+	case errors.Is(err, ErrInvalidEnergyPrice), errors.Is(err, ErrInvalidDate), errors.Is(err, ErrInvalidTemperature):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrEnergyPriceNotFound):
+		return http.StatusNotFound // 404
+	case errors.Is(err, ErrEnergyPriceInEffect):
+		return http.StatusConflict // 409
 	case errors.Is(err, usersvc.ErrAuthentication):
 		return http.StatusUnauthorized // 401
 	case errors.Is(err, usersvc.ErrPasswordEmailDoesNotMatch):
 		return http.StatusUnauthorized // 401
-	case errors.Is(err, usersvc.ErrNoAuthTokenHeader):
+	case errors.Is(err, auth.ErrNoAuthTokenHeader), errors.Is(err, auth.ErrInvalidToken):
 		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden // 403
 	default:
 		return http.StatusInternalServerError // 500
 	}
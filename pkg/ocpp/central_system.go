@@ -0,0 +1,447 @@
+package ocpp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/model"
+	"california/pkg/pricing"
+	"california/pkg/repository"
+	"github.com/go-kit/kit/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/websocket"
+)
+
+// HeartbeatInterval is the interval, in seconds, charge points are asked to send heartbeats at.
+const HeartbeatInterval = 300
+
+// Path is the prefix charge points connect to, followed by their charge point id.
+const Path = "/ocpp/"
+
+// CentralSystem is an OCPP 1.6-J central system. Charge points connect to /ocpp/{chargePointId}
+// over WebSocket and their connector statuses are written to the matching sockets of the station
+// whose ChargePointID equals the charge point id. Their transactions are kept in the store, so they
+// outlive a restart of the service.
+type CentralSystem struct {
+	store  repository.Store
+	logger log.Logger
+}
+
+func NewCentralSystem(store repository.Store, logger log.Logger) *CentralSystem {
+	return &CentralSystem{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// ErrUnauthorized is returned when a charge point connects with an unknown id or with a wrong key.
+var ErrUnauthorized = errors.New("ocpp: unknown charge point or wrong key")
+
+// NewAuthorizationKey returns a random key for a charge point, which it sends as its AuthorizationKey.
+// Only the hash of the key is kept, see HashAuthorizationKey.
+func NewAuthorizationKey() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashAuthorizationKey returns the hash the key of a charge point is stored as in its station.
+func HashAuthorizationKey(key string) string {
+	return helpers.HashToken(key)
+}
+
+// ServeHTTP authenticates the charge point with HTTP Basic auth, as in security profile 1 of OCPP-J:
+// the user name is the charge point id and the password is the key issued for its station.
+func (cs *CentralSystem) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	chargePointID := strings.Trim(strings.TrimPrefix(r.URL.Path, Path), "/")
+	if chargePointID == "" || strings.Contains(chargePointID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if err := cs.authenticate(r.Context(), chargePointID, r); err != nil {
+		cs.logger.Log("charge_point", chargePointID, "msg", "connection refused", "err", err)
+		if !errors.Is(err, ErrUnauthorized) {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="ocpp"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			for _, protocol := range config.Protocol {
+				if protocol == Subprotocol {
+					config.Protocol = []string{Subprotocol}
+					return nil
+				}
+			}
+			return errors.New("ocpp1.6 subprotocol is required")
+		},
+		Handler: func(conn *websocket.Conn) {
+			cs.serveChargePoint(r.Context(), chargePointID, conn)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// authenticate checks the Basic auth credentials of the request against the key of the charge point's station.
+// A station without a key refuses its charge point until an admin issues one.
+func (cs *CentralSystem) authenticate(ctx context.Context, chargePointID string, r *http.Request) error {
+	user, key, ok := r.BasicAuth()
+	if !ok || user != chargePointID {
+		return ErrUnauthorized
+	}
+	station, err := cs.station(ctx, chargePointID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrUnauthorized
+	} else if err != nil {
+		return err
+	}
+	hash := HashAuthorizationKey(key)
+	if station.ChargePointKeyHash == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(station.ChargePointKeyHash)) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func (cs *CentralSystem) serveChargePoint(ctx context.Context, chargePointID string, conn *websocket.Conn) {
+	defer conn.Close()
+	logger := log.With(cs.logger, "charge_point", chargePointID)
+	logger.Log("msg", "connected")
+
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			logger.Log("msg", "disconnected", "err", err)
+			return
+		}
+
+		reply, err := cs.handleMessage(ctx, chargePointID, data)
+		if err != nil {
+			logger.Log("msg", "invalid message", "err", err)
+			continue
+		}
+		if reply == nil {
+			continue
+		}
+		if err = websocket.Message.Send(conn, string(reply)); err != nil {
+			logger.Log("msg", "disconnected", "err", err)
+			return
+		}
+	}
+}
+
+// handleMessage answers a frame received from a charge point. Results and errors of calls made by
+// the central system need no answer, so a nil reply is returned for them.
+func (cs *CentralSystem) handleMessage(ctx context.Context, chargePointID string, data []byte) ([]byte, error) {
+	msg, err := parseMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	if msg.TypeID != messageTypeCall {
+		return nil, nil
+	}
+
+	response, err := cs.handleCall(ctx, chargePointID, msg.Action, msg.Payload)
+	if err != nil {
+		var callErr *CallError
+		if !errors.As(err, &callErr) {
+			callErr = &CallError{Code: ErrorInternalError, Description: err.Error()}
+		}
+		cs.logger.Log("charge_point", chargePointID, "action", msg.Action, "err", callErr)
+		return newCallError(msg.UniqueID, callErr.Code, callErr.Description)
+	}
+	return newCallResult(msg.UniqueID, response)
+}
+
+func (cs *CentralSystem) handleCall(ctx context.Context, chargePointID, action string, payload json.RawMessage) (interface{}, error) {
+	switch action {
+	case ActionBootNotification:
+		var req BootNotificationRequest
+		if err := decodePayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return cs.bootNotification(ctx, chargePointID, &req)
+	case ActionHeartbeat:
+		return &HeartbeatResponse{CurrentTime: time.Now().UTC()}, nil
+	case ActionStatusNotification:
+		var req StatusNotificationRequest
+		if err := decodePayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return cs.statusNotification(ctx, chargePointID, &req)
+	case ActionStartTransaction:
+		var req StartTransactionRequest
+		if err := decodePayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return cs.startTransaction(ctx, chargePointID, &req)
+	case ActionStopTransaction:
+		var req StopTransactionRequest
+		if err := decodePayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return cs.stopTransaction(ctx, chargePointID, &req)
+	case ActionMeterValues:
+		var req MeterValuesRequest
+		if err := decodePayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return cs.meterValues(ctx, chargePointID, &req)
+	}
+	return nil, &CallError{Code: ErrorNotImplemented, Description: "action " + action + " is not supported"}
+}
+
+func decodePayload(payload json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return &CallError{Code: ErrorFormationViolation, Description: err.Error()}
+	}
+	return nil
+}
+
+func (cs *CentralSystem) bootNotification(ctx context.Context, chargePointID string, req *BootNotificationRequest) (*BootNotificationResponse, error) {
+	response := &BootNotificationResponse{
+		Status:      RegistrationAccepted,
+		CurrentTime: time.Now().UTC(),
+		Interval:    HeartbeatInterval,
+	}
+	if _, err := cs.station(ctx, chargePointID); err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		response.Status = RegistrationRejected
+	}
+	cs.logger.Log("charge_point", chargePointID, "vendor", req.ChargePointVendor, "model", req.ChargePointModel, "registration", response.Status)
+	return response, nil
+}
+
+func (cs *CentralSystem) statusNotification(ctx context.Context, chargePointID string, req *StatusNotificationRequest) (*StatusNotificationResponse, error) {
+	// Connector 0 reports the charge point as a whole, which has no socket of its own.
+	if req.ConnectorID == 0 {
+		return &StatusNotificationResponse{}, nil
+	}
+	socket, err := cs.socket(ctx, chargePointID, req.ConnectorID)
+	if err != nil {
+		return nil, err
+	}
+	if err = cs.store.UpdateSocketStatus(ctx, socket.ID.Hex(), SocketStatus(req.Status)); err != nil {
+		return nil, err
+	}
+	return &StatusNotificationResponse{}, nil
+}
+
+func (cs *CentralSystem) startTransaction(ctx context.Context, chargePointID string, req *StartTransactionRequest) (*StartTransactionResponse, error) {
+	socket, err := cs.socket(ctx, chargePointID, req.ConnectorID)
+	if err != nil {
+		return nil, err
+	}
+	if err = cs.store.UpdateSocketStatus(ctx, socket.ID.Hex(), model.Occupied); err != nil {
+		return nil, err
+	}
+	if err = cs.fulfilReservation(ctx, socket.ID); err != nil {
+		return nil, err
+	}
+
+	startedAt := req.Timestamp
+	if startedAt.IsZero() {
+		startedAt = time.Now().UTC()
+	}
+	tx := &model.Transaction{
+		ChargePointID: chargePointID,
+		ConnectorID:   req.ConnectorID,
+		SocketID:      socket.ID,
+		IdTag:         req.IdTag,
+		MeterStart:    req.MeterStart,
+		MeterValue:    req.MeterStart,
+		StartedAt:     startedAt,
+	}
+	if err = cs.store.InsertTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	return &StartTransactionResponse{
+		IdTagInfo:     IdTagInfo{Status: AuthorizationAccepted},
+		TransactionID: tx.ID,
+	}, nil
+}
+
+// fulfilReservation marks the reservation due on the socket as fulfilled, so it is not expired as a no-show.
+// The charge point cannot tell who is charging, so any transaction on the socket counts as the driver's arrival.
+func (cs *CentralSystem) fulfilReservation(ctx context.Context, socketID primitive.ObjectID) error {
+	now := time.Now()
+	reservations, err := cs.store.FindReservationsByFilter(ctx, bson.M{
+		"SocketID": socketID,
+		"Status":   model.ReservationActive,
+		"StartsAt": bson.M{"$lte": now.Add(model.ReservationLeadTime), "$gte": now.Add(-model.ReservationNoShowGrace)},
+		"EndsAt":   bson.M{"$gt": now},
+	})
+	if err != nil || len(reservations) == 0 {
+		return err
+	}
+	_, err = cs.store.UpdateReservationStatus(ctx, reservations[0].ID, model.ReservationActive, model.ReservationFulfilled)
+	return err
+}
+
+func (cs *CentralSystem) stopTransaction(ctx context.Context, chargePointID string, req *StopTransactionRequest) (*StopTransactionResponse, error) {
+	stoppedAt := req.Timestamp
+	if stoppedAt.IsZero() {
+		stoppedAt = time.Now().UTC()
+	}
+
+	// A stop for an unknown transaction still has to be acknowledged, otherwise the charge point keeps retrying it.
+	tx, err := cs.transaction(ctx, chargePointID, req.TransactionID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &StopTransactionResponse{IdTagInfo: &IdTagInfo{Status: AuthorizationInvalid}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	tx.MeterValue = req.MeterStop
+
+	if err = cs.store.UpdateSocketStatus(ctx, tx.SocketID.Hex(), model.Available); err != nil {
+		return nil, err
+	}
+	if err = cs.completeSession(ctx, tx, stoppedAt); err != nil {
+		return nil, err
+	}
+	if err = cs.store.DeleteTransaction(ctx, tx.ID); err != nil {
+		return nil, err
+	}
+	return &StopTransactionResponse{IdTagInfo: &IdTagInfo{Status: AuthorizationAccepted}}, nil
+}
+
+// completeSession stops the charging session in progress on the socket with the energy measured by the charge point.
+// A session its user already stopped while the transaction was running is settled with the final reading instead.
+func (cs *CentralSystem) completeSession(ctx context.Context, tx *model.Transaction, stoppedAt time.Time) error {
+	energyKWh := tx.EnergyKWh()
+	sessions, err := cs.store.FindChargingSessionsByFilter(ctx, bson.M{"SocketID": tx.SocketID, "Status": model.ChargingInProgress})
+	if err != nil {
+		return err
+	}
+	if len(sessions) > 0 {
+		session := sessions[0]
+		session.TransactionID = tx.ID
+		session.Complete(stoppedAt, energyKWh, pricing.SessionCost(session, stoppedAt, energyKWh), false)
+		_, err = cs.store.CompleteChargingSession(ctx, session)
+		return err
+	}
+
+	sessions, err = cs.store.FindChargingSessionsByFilter(ctx, bson.M{"TransactionID": tx.ID, "Status": model.ChargingCompleted})
+	if err != nil || len(sessions) == 0 {
+		return err
+	}
+	session := sessions[0]
+	session.Complete(*session.StoppedAt, energyKWh, pricing.SessionCost(session, *session.StoppedAt, energyKWh), false)
+	_, err = cs.store.SettleChargingSession(ctx, session)
+	return err
+}
+
+func (cs *CentralSystem) meterValues(ctx context.Context, chargePointID string, req *MeterValuesRequest) (*MeterValuesResponse, error) {
+	if req.TransactionID == nil {
+		return &MeterValuesResponse{}, nil
+	}
+	tx, err := cs.transaction(ctx, chargePointID, *req.TransactionID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &MeterValuesResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	meterWh, found := 0, false
+	for _, mv := range req.MeterValue {
+		for _, sv := range mv.SampledValue {
+			if wh, ok := energyRegisterWh(sv); ok {
+				meterWh, found = wh, true
+			}
+		}
+	}
+	if !found {
+		return &MeterValuesResponse{}, nil
+	}
+	if err = cs.store.UpdateTransactionMeter(ctx, tx.ID, meterWh); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return &MeterValuesResponse{}, nil
+}
+
+// transaction returns the transaction with the given id, which must have been started by the charge point.
+func (cs *CentralSystem) transaction(ctx context.Context, chargePointID string, transactionID int) (*model.Transaction, error) {
+	tx, err := cs.store.GetTransactionById(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if tx.ChargePointID != chargePointID {
+		return nil, mongo.ErrNoDocuments
+	}
+	return tx, nil
+}
+
+// energyRegisterWh returns the reading of the energy register in Wh, which is the default measurand of a sampled value.
+func energyRegisterWh(sv SampledValue) (int, bool) {
+	if sv.Measurand != "" && sv.Measurand != "Energy.Active.Import.Register" {
+		return 0, false
+	}
+	var value float64
+	if err := json.Unmarshal([]byte(sv.Value), &value); err != nil {
+		return 0, false
+	}
+	if sv.Unit == "kWh" {
+		value *= 1000
+	}
+	return int(value), true
+}
+
+func (cs *CentralSystem) station(ctx context.Context, chargePointID string) (*model.Station, error) {
+	stations, err := cs.store.FindStationByFilter(ctx, bson.M{"ChargePointID": chargePointID})
+	if err != nil {
+		return nil, err
+	}
+	if len(stations) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return stations[0], nil
+}
+
+// socket returns the socket of the charge point's station which is wired to the given connector.
+func (cs *CentralSystem) socket(ctx context.Context, chargePointID string, connectorID int) (*model.Socket, error) {
+	station, err := cs.station(ctx, chargePointID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, &CallError{Code: ErrorPropertyConstraintViol, Description: "unknown charge point " + chargePointID}
+		}
+		return nil, err
+	}
+	for i := range station.Sockets {
+		if station.Sockets[i].ConnectorID == connectorID {
+			return &station.Sockets[i], nil
+		}
+	}
+	return nil, &CallError{Code: ErrorPropertyConstraintViol, Description: "unknown connector"}
+}
+
+// SocketStatus maps an OCPP connector status to the status of a socket.
+func SocketStatus(status string) model.SocketStatus {
+	switch status {
+	case ChargePointAvailable:
+		return model.Available
+	case ChargePointPreparing, ChargePointCharging, ChargePointSuspendedEV, ChargePointSuspendedEVSE, ChargePointFinishing:
+		return model.Occupied
+	case ChargePointReserved:
+		return model.Reserved
+	case ChargePointFaulted:
+		return model.Faulted
+	}
+	return model.UnAvailable
+}
@@ -0,0 +1,248 @@
+package ocpp
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"github.com/go-kit/kit/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newTestCentralSystem serves a central system over the store and returns the URL charge points dial.
+func newTestCentralSystem(t *testing.T, store repository.Store) string {
+	server := httptest.NewServer(NewCentralSystem(store, log.NewNopLogger()))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + Path
+}
+
+func TestChargePointTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	socket := model.Socket{ID: primitive.NewObjectID(), KW: 22, Price: 10, Status: model.Available, ConnectorID: 1}
+	station, key := insertStation(t, store, "CP-1", socket)
+	session := &model.ChargingSession{
+		ID:          primitive.NewObjectID(),
+		UserID:      primitive.NewObjectID().Hex(),
+		StationID:   station.ID,
+		SocketID:    socket.ID,
+		Status:      model.ChargingInProgress,
+		StartedAt:   time.Now().Add(-time.Hour).UTC(),
+		PricePerKWh: 10,
+	}
+	if err := store.InsertChargingSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	url := newTestCentralSystem(t, store)
+	cp, err := DialChargePoint(url, "CP-1", key)
+	if err != nil {
+		t.Fatalf("DialChargePoint: %v", err)
+	}
+	defer cp.Close()
+
+	boot, err := cp.BootNotification("Acme", "AC22")
+	if err != nil || boot.Status != RegistrationAccepted || boot.Interval != HeartbeatInterval {
+		t.Fatalf("BootNotification = %+v, %v", boot, err)
+	}
+	txID, err := cp.StartTransaction(1, "TAG-1", 1000)
+	if err != nil {
+		t.Fatalf("StartTransaction: %v", err)
+	}
+	if got := socketStatus(t, store, station.ID, socket.ID); got != model.Occupied {
+		t.Fatalf("socket status after StartTransaction = %v, want occupied", got)
+	}
+	if err = cp.MeterValues(1, txID, 6000); err != nil {
+		t.Fatalf("MeterValues: %v", err)
+	}
+	tx, err := store.GetTransactionById(ctx, txID)
+	if err != nil || tx.MeterValue != 6000 || tx.SocketID != socket.ID {
+		t.Fatalf("transaction after MeterValues = %+v, %v", tx, err)
+	}
+	if err = cp.StopTransaction(txID, 13500); err != nil {
+		t.Fatalf("StopTransaction: %v", err)
+	}
+
+	if _, err = store.GetTransactionById(ctx, txID); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("transaction after StopTransaction: got %v, want it deleted", err)
+	}
+	if got := socketStatus(t, store, station.ID, socket.ID); got != model.Available {
+		t.Fatalf("socket status after StopTransaction = %v, want available", got)
+	}
+	stopped, err := store.GetChargingSessionById(ctx, session.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Status != model.ChargingCompleted || stopped.EnergyKWh != 12.5 || stopped.Cost != 125 || stopped.Estimated {
+		t.Fatalf("session after StopTransaction = %+v, want 12.5 kWh metered for 125", stopped)
+	}
+
+	// A restarted central system keeps counting the transaction ids from the store.
+	cp2, err := DialChargePoint(newTestCentralSystem(t, store), "CP-1", key)
+	if err != nil {
+		t.Fatalf("DialChargePoint after restart: %v", err)
+	}
+	defer cp2.Close()
+	next, err := cp2.StartTransaction(1, "TAG-1", 13500)
+	if err != nil || next <= txID {
+		t.Fatalf("StartTransaction after restart = %d, %v; want an id after %d", next, err, txID)
+	}
+}
+
+func TestStopTransactionSettlesStoppedSession(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	socket := model.Socket{ID: primitive.NewObjectID(), KW: 22, Status: model.Available, ConnectorID: 1}
+	station, key := insertStation(t, store, "CP-1", socket)
+	session := &model.ChargingSession{
+		ID:          primitive.NewObjectID(),
+		UserID:      primitive.NewObjectID().Hex(),
+		StationID:   station.ID,
+		SocketID:    socket.ID,
+		Status:      model.ChargingInProgress,
+		StartedAt:   time.Now().Add(-time.Hour).UTC(),
+		PricePerKWh: 10,
+	}
+	if err := store.InsertChargingSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := DialChargePoint(newTestCentralSystem(t, store), "CP-1", key)
+	if err != nil {
+		t.Fatalf("DialChargePoint: %v", err)
+	}
+	defer cp.Close()
+	txID, err := cp.StartTransaction(1, "TAG-1", 0)
+	if err != nil {
+		t.Fatalf("StartTransaction: %v", err)
+	}
+	if err = cp.MeterValues(1, txID, 4000); err != nil {
+		t.Fatalf("MeterValues: %v", err)
+	}
+
+	// The user stops the session first, with the latest reading of the transaction.
+	session.TransactionID = txID
+	session.Complete(time.Now().UTC(), 4, 40, false)
+	if completed, err := store.CompleteChargingSession(ctx, session); err != nil || !completed {
+		t.Fatalf("CompleteChargingSession = %v, %v", completed, err)
+	}
+	if err = cp.StopTransaction(txID, 4600); err != nil {
+		t.Fatalf("StopTransaction: %v", err)
+	}
+	settled, err := store.GetChargingSessionById(ctx, session.ID.Hex())
+	if err != nil || settled.EnergyKWh != 4.6 || settled.Cost != 46 || settled.StoppedAt.Sub(*session.StoppedAt).Abs() >= time.Millisecond {
+		t.Fatalf("session after StopTransaction = %+v, %v; want 4.6 kWh for 46, stopped when the user stopped it", settled, err)
+	}
+}
+
+func TestChargePointRejections(t *testing.T) {
+	store := repository.NewMemoryStore()
+	socket := model.Socket{ID: primitive.NewObjectID(), Status: model.Available, ConnectorID: 1}
+	_, key := insertStation(t, store, "CP-1", socket)
+	url := newTestCentralSystem(t, store)
+
+	cp, err := DialChargePoint(url, "CP-1", key)
+	if err != nil {
+		t.Fatalf("DialChargePoint: %v", err)
+	}
+	defer cp.Close()
+	var callErr *CallError
+	if _, err = cp.StartTransaction(2, "TAG-1", 0); !errors.As(err, &callErr) {
+		t.Fatalf("StartTransaction on an unknown connector: got %v, want a CallError", err)
+	}
+	txID, err := cp.StartTransaction(1, "TAG-1", 0)
+	if err != nil {
+		t.Fatalf("StartTransaction: %v", err)
+	}
+
+	// Another charge point can neither meter nor stop the transaction.
+	_, otherKey := insertStation(t, store, "CP-2")
+	cp2, err := DialChargePoint(url, "CP-2", otherKey)
+	if err != nil {
+		t.Fatalf("DialChargePoint: %v", err)
+	}
+	defer cp2.Close()
+	var stop StopTransactionResponse
+	err = cp2.Call(ActionStopTransaction, &StopTransactionRequest{TransactionID: txID, MeterStop: 500, Timestamp: time.Now().UTC()}, &stop)
+	if err != nil || stop.IdTagInfo == nil || stop.IdTagInfo.Status != AuthorizationInvalid {
+		t.Fatalf("StopTransaction of another charge point = %+v, %v; want it refused", stop.IdTagInfo, err)
+	}
+	if _, err = store.GetTransactionById(context.Background(), txID); err != nil {
+		t.Fatalf("transaction after a foreign StopTransaction: %v", err)
+	}
+}
+
+func TestChargePointAuthentication(t *testing.T) {
+	store := repository.NewMemoryStore()
+	_, key := insertStation(t, store, "CP-1")
+	withoutKey := &model.Station{ID: primitive.NewObjectID(), ChargePointID: "CP-2"}
+	if _, err := store.InsertStation(context.Background(), withoutKey); err != nil {
+		t.Fatal(err)
+	}
+	url := newTestCentralSystem(t, store)
+
+	tests := []struct {
+		name          string
+		chargePointID string
+		key           string
+	}{
+		{"wrong key", "CP-1", "0123456789abcdef0123"},
+		{"no key", "CP-1", ""},
+		{"key of another charge point", "CP-3", key},
+		{"station without a key", "CP-2", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cp, err := DialChargePoint(url, tt.chargePointID, tt.key); err == nil {
+				cp.Close()
+				t.Fatal("DialChargePoint succeeded, want the connection refused")
+			}
+		})
+	}
+
+	cp, err := DialChargePoint(url, "CP-1", key)
+	if err != nil {
+		t.Fatalf("DialChargePoint with the key of the station: %v", err)
+	}
+	cp.Close()
+}
+
+// insertStation stores a station for the charge point and returns it with the key issued for it.
+func insertStation(t *testing.T, store repository.Store, chargePointID string, sockets ...model.Socket) (*model.Station, string) {
+	t.Helper()
+	key, err := NewAuthorizationKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	station := &model.Station{
+		ID:                 primitive.NewObjectID(),
+		ChargePointID:      chargePointID,
+		ChargePointKeyHash: HashAuthorizationKey(key),
+		Sockets:            sockets,
+	}
+	if _, err = store.InsertStation(context.Background(), station); err != nil {
+		t.Fatal(err)
+	}
+	return station, key
+}
+
+func socketStatus(t *testing.T, store repository.Store, stationID, socketID primitive.ObjectID) model.SocketStatus {
+	t.Helper()
+	station, err := store.GetStationById(context.Background(), stationID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, socket := range station.Sockets {
+		if socket.ID == socketID {
+			return socket.Status
+		}
+	}
+	t.Fatalf("socket %s not found", socketID.Hex())
+	return 0
+}
@@ -0,0 +1,142 @@
+package ocpp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// ChargePoint is a simulated OCPP 1.6-J charge point. It is used to exercise the central
+// system without hardware; calls are made one at a time and wait for their answer.
+type ChargePoint struct {
+	ID string
+
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	nextID int
+}
+
+// DialChargePoint connects the charge point with the given id to the central system at serverURL,
+// e.g. ws://localhost:8080/ocpp/, authenticating with the key issued for its station.
+func DialChargePoint(serverURL, id, key string) (*ChargePoint, error) {
+	url := strings.TrimSuffix(serverURL, "/") + "/" + id
+	origin := strings.Replace(strings.Replace(serverURL, "wss://", "https://", 1), "ws://", "http://", 1)
+
+	config, err := websocket.NewConfig(url, origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = []string{Subprotocol}
+	config.Header = http.Header{}
+	config.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(id+":"+key)))
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ChargePoint{ID: id, conn: conn}, nil
+}
+
+func (cp *ChargePoint) Close() error {
+	return cp.conn.Close()
+}
+
+// Call sends a CALL with the given action and decodes the CALLRESULT into response.
+// A CALLERROR is returned as *CallError.
+func (cp *ChargePoint) Call(action string, request, response interface{}) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.nextID++
+	uniqueID := strconv.Itoa(cp.nextID)
+	data, err := newCall(uniqueID, action, request)
+	if err != nil {
+		return err
+	}
+	if err = websocket.Message.Send(cp.conn, string(data)); err != nil {
+		return err
+	}
+
+	for {
+		var reply []byte
+		if err = websocket.Message.Receive(cp.conn, &reply); err != nil {
+			return err
+		}
+		msg, err := parseMessage(reply)
+		if err != nil {
+			return err
+		}
+		if msg.UniqueID != uniqueID {
+			continue
+		}
+		switch msg.TypeID {
+		case messageTypeCallResult:
+			if response == nil {
+				return nil
+			}
+			return json.Unmarshal(msg.Payload, response)
+		case messageTypeCallError:
+			return &CallError{Code: msg.ErrorCode, Description: msg.ErrorDescription}
+		}
+		return fmt.Errorf("unexpected message type %d", msg.TypeID)
+	}
+}
+
+func (cp *ChargePoint) BootNotification(vendor, model string) (*BootNotificationResponse, error) {
+	var response BootNotificationResponse
+	err := cp.Call(ActionBootNotification, &BootNotificationRequest{ChargePointVendor: vendor, ChargePointModel: model}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (cp *ChargePoint) Heartbeat() (*HeartbeatResponse, error) {
+	var response HeartbeatResponse
+	if err := cp.Call(ActionHeartbeat, &HeartbeatRequest{}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (cp *ChargePoint) StatusNotification(connectorID int, status string) error {
+	now := time.Now().UTC()
+	req := &StatusNotificationRequest{ConnectorID: connectorID, ErrorCode: "NoError", Status: status, Timestamp: &now}
+	return cp.Call(ActionStatusNotification, req, &StatusNotificationResponse{})
+}
+
+func (cp *ChargePoint) StartTransaction(connectorID int, idTag string, meterStart int) (int, error) {
+	var response StartTransactionResponse
+	req := &StartTransactionRequest{ConnectorID: connectorID, IdTag: idTag, MeterStart: meterStart, Timestamp: time.Now().UTC()}
+	if err := cp.Call(ActionStartTransaction, req, &response); err != nil {
+		return 0, err
+	}
+	if response.IdTagInfo.Status != AuthorizationAccepted {
+		return 0, errors.New("ocpp: transaction was not accepted")
+	}
+	return response.TransactionID, nil
+}
+
+func (cp *ChargePoint) MeterValues(connectorID, transactionID, meterWh int) error {
+	req := &MeterValuesRequest{
+		ConnectorID:   connectorID,
+		TransactionID: &transactionID,
+		MeterValue: []MeterValue{{
+			Timestamp:    time.Now().UTC(),
+			SampledValue: []SampledValue{{Value: strconv.Itoa(meterWh)}},
+		}},
+	}
+	return cp.Call(ActionMeterValues, req, &MeterValuesResponse{})
+}
+
+func (cp *ChargePoint) StopTransaction(transactionID, meterStop int) error {
+	req := &StopTransactionRequest{TransactionID: transactionID, MeterStop: meterStop, Timestamp: time.Now().UTC()}
+	return cp.Call(ActionStopTransaction, req, &StopTransactionResponse{})
+}
@@ -0,0 +1,213 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Subprotocol is the WebSocket subprotocol negotiated by OCPP 1.6-J charge points.
+const Subprotocol = "ocpp1.6"
+
+// Message type ids of the OCPP-J RPC framework.
+const (
+	messageTypeCall       = 2
+	messageTypeCallResult = 3
+	messageTypeCallError  = 4
+)
+
+// Error codes of a CALLERROR.
+const (
+	ErrorNotImplemented         = "NotImplemented"
+	ErrorFormationViolation     = "FormationViolation"
+	ErrorProtocolError          = "ProtocolError"
+	ErrorInternalError          = "InternalError"
+	ErrorPropertyConstraintViol = "PropertyConstraintViolation"
+)
+
+// Actions handled by the central system.
+const (
+	ActionBootNotification   = "BootNotification"
+	ActionHeartbeat          = "Heartbeat"
+	ActionStatusNotification = "StatusNotification"
+	ActionStartTransaction   = "StartTransaction"
+	ActionStopTransaction    = "StopTransaction"
+	ActionMeterValues        = "MeterValues"
+)
+
+// message is a decoded OCPP-J frame: [2, id, action, payload], [3, id, payload]
+// or [4, id, errorCode, errorDescription, errorDetails].
+type message struct {
+	TypeID           int
+	UniqueID         string
+	Action           string
+	Payload          json.RawMessage
+	ErrorCode        string
+	ErrorDescription string
+}
+
+func parseMessage(data []byte) (*message, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if len(fields) < 3 {
+		return nil, errors.New("message has too few elements")
+	}
+
+	var msg message
+	if err := json.Unmarshal(fields[0], &msg.TypeID); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(fields[1], &msg.UniqueID); err != nil {
+		return nil, err
+	}
+	switch msg.TypeID {
+	case messageTypeCall:
+		if len(fields) != 4 {
+			return nil, errors.New("CALL must have 4 elements")
+		}
+		if err := json.Unmarshal(fields[2], &msg.Action); err != nil {
+			return nil, err
+		}
+		msg.Payload = fields[3]
+	case messageTypeCallResult:
+		msg.Payload = fields[2]
+	case messageTypeCallError:
+		if len(fields) < 4 {
+			return nil, errors.New("CALLERROR must have 5 elements")
+		}
+		_ = json.Unmarshal(fields[2], &msg.ErrorCode)
+		_ = json.Unmarshal(fields[3], &msg.ErrorDescription)
+	default:
+		return nil, fmt.Errorf("unknown message type %d", msg.TypeID)
+	}
+	return &msg, nil
+}
+
+func newCall(uniqueID, action string, payload interface{}) ([]byte, error) {
+	return json.Marshal([]interface{}{messageTypeCall, uniqueID, action, payload})
+}
+
+func newCallResult(uniqueID string, payload interface{}) ([]byte, error) {
+	return json.Marshal([]interface{}{messageTypeCallResult, uniqueID, payload})
+}
+
+func newCallError(uniqueID, code, description string) ([]byte, error) {
+	return json.Marshal([]interface{}{messageTypeCallError, uniqueID, code, description, struct{}{}})
+}
+
+// CallError is returned by the charge point simulator when the central system answers with a CALLERROR.
+type CallError struct {
+	Code        string
+	Description string
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("ocpp: %s: %s", e.Code, e.Description)
+}
+
+// Registration statuses of a BootNotification.
+const (
+	RegistrationAccepted = "Accepted"
+	RegistrationRejected = "Rejected"
+)
+
+// Statuses of an IdTagInfo.
+const (
+	AuthorizationAccepted = "Accepted"
+	AuthorizationInvalid  = "Invalid"
+)
+
+// Connector statuses of a StatusNotification.
+const (
+	ChargePointAvailable     = "Available"
+	ChargePointPreparing     = "Preparing"
+	ChargePointCharging      = "Charging"
+	ChargePointSuspendedEVSE = "SuspendedEVSE"
+	ChargePointSuspendedEV   = "SuspendedEV"
+	ChargePointFinishing     = "Finishing"
+	ChargePointReserved      = "Reserved"
+	ChargePointUnavailable   = "Unavailable"
+	ChargePointFaulted       = "Faulted"
+)
+
+type BootNotificationRequest struct {
+	ChargePointVendor       string `json:"chargePointVendor"`
+	ChargePointModel        string `json:"chargePointModel"`
+	ChargePointSerialNumber string `json:"chargePointSerialNumber,omitempty"`
+	FirmwareVersion         string `json:"firmwareVersion,omitempty"`
+}
+
+type BootNotificationResponse struct {
+	Status      string    `json:"status"`
+	CurrentTime time.Time `json:"currentTime"`
+	Interval    int       `json:"interval"` // Heartbeat interval in seconds.
+}
+
+type HeartbeatRequest struct{}
+
+type HeartbeatResponse struct {
+	CurrentTime time.Time `json:"currentTime"`
+}
+
+type StatusNotificationRequest struct {
+	ConnectorID int        `json:"connectorId"`
+	ErrorCode   string     `json:"errorCode"`
+	Status      string     `json:"status"`
+	Info        string     `json:"info,omitempty"`
+	Timestamp   *time.Time `json:"timestamp,omitempty"`
+}
+
+type StatusNotificationResponse struct{}
+
+type IdTagInfo struct {
+	Status     string     `json:"status"`
+	ExpiryDate *time.Time `json:"expiryDate,omitempty"`
+}
+
+type StartTransactionRequest struct {
+	ConnectorID   int       `json:"connectorId"`
+	IdTag         string    `json:"idTag"`
+	MeterStart    int       `json:"meterStart"` // Wh
+	ReservationID *int      `json:"reservationId,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+type StartTransactionResponse struct {
+	IdTagInfo     IdTagInfo `json:"idTagInfo"`
+	TransactionID int       `json:"transactionId"`
+}
+
+type StopTransactionRequest struct {
+	TransactionID int       `json:"transactionId"`
+	IdTag         string    `json:"idTag,omitempty"`
+	MeterStop     int       `json:"meterStop"` // Wh
+	Timestamp     time.Time `json:"timestamp"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+type StopTransactionResponse struct {
+	IdTagInfo *IdTagInfo `json:"idTagInfo,omitempty"`
+}
+
+type SampledValue struct {
+	Value     string `json:"value"`
+	Context   string `json:"context,omitempty"`
+	Measurand string `json:"measurand,omitempty"` // Energy.Active.Import.Register when empty.
+	Unit      string `json:"unit,omitempty"`      // Wh when empty.
+}
+
+type MeterValue struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	SampledValue []SampledValue `json:"sampledValue"`
+}
+
+type MeterValuesRequest struct {
+	ConnectorID   int          `json:"connectorId"`
+	TransactionID *int         `json:"transactionId,omitempty"`
+	MeterValue    []MeterValue `json:"meterValue"`
+}
+
+type MeterValuesResponse struct{}
@@ -0,0 +1,235 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"time"
+
+	"california/pkg/model"
+
+	// Tariffs are in local time; the zone database is embedded for images which do not ship it.
+	_ "time/tzdata"
+)
+
+const (
+	DefaultCurrency = "TRY"
+	DefaultTimeZone = "Europe/Istanbul"
+
+	// Chargers rarely hold their peak power for the whole session because of the charging curve.
+	AverageChargePowerFactor = 0.8
+
+	// Charges are priced minute by minute up to this length; the rest is priced at the rates of that minute.
+	maxPricedMinutes = 7 * 24 * 60
+)
+
+var (
+	ErrInvalidTariff = errors.New("invalid tariff")
+
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// LegacyTariff is the tariff of a socket which only has the flat Price of its own.
+func LegacyTariff(socket *model.Socket) *model.Tariff {
+	return &model.Tariff{
+		Name:        "Flat rate",
+		Currency:    DefaultCurrency,
+		PricePerKWh: socket.Price,
+	}
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code, e.g. TRY.
+func ValidCurrency(code string) bool {
+	return currencyPattern.MatchString(code)
+}
+
+// Validate checks the prices, the currency, the time zone and the periods of the tariff.
+func Validate(t *model.Tariff) error {
+	if t.Name == "" || !ValidCurrency(t.Currency) {
+		return ErrInvalidTariff
+	}
+	if t.TimeZone != "" {
+		if _, err := time.LoadLocation(t.TimeZone); err != nil {
+			return ErrInvalidTariff
+		}
+	}
+	if t.CurrentType != 0 && t.CurrentType != model.DC && t.CurrentType != model.AC {
+		return ErrInvalidTariff
+	}
+	if t.PricePerKWh < 0 || t.PricePerMinute < 0 || t.SessionFee < 0 || t.IdleFeePerMinute < 0 || t.IdleGraceMinutes < 0 {
+		return ErrInvalidTariff
+	}
+	for _, period := range t.Periods {
+		start, okStart := minuteOfDay(period.Start)
+		end, okEnd := minuteOfDay(period.End)
+		if !okStart || !okEnd || start == end || period.PricePerKWh < 0 || period.PricePerMinute < 0 {
+			return ErrInvalidTariff
+		}
+		for _, day := range period.Days {
+			if day < time.Sunday || day > time.Saturday {
+				return ErrInvalidTariff
+			}
+		}
+	}
+	return nil
+}
+
+// Quote prices charging energyKWh from startsAt on a socket of the given power. The length of the charge
+// is estimated from the power, which is what the time based prices and time of use periods apply to.
+func Quote(t *model.Tariff, startsAt time.Time, energyKWh, powerKW float64, idleMinutes float64) *model.PriceQuote {
+	duration := time.Duration(0)
+	if powerKW > 0 {
+		duration = time.Duration(energyKWh / (powerKW * AverageChargePowerFactor) * float64(time.Hour))
+	}
+	return Price(t, startsAt, duration, energyKWh, idleMinutes)
+}
+
+// Price prices a charge of the given length delivering energyKWh at a constant rate.
+func Price(t *model.Tariff, startsAt time.Time, duration time.Duration, energyKWh float64, idleMinutes float64) *model.PriceQuote {
+	quote := &model.PriceQuote{
+		Currency:        t.Currency,
+		StartsAt:        startsAt,
+		EnergyKWh:       round(energyKWh, 3),
+		DurationMinutes: round(duration.Minutes(), 2),
+		SessionFee:      t.SessionFee,
+	}
+	if !t.ID.IsZero() {
+		id := t.ID
+		quote.TariffID = &id
+	}
+
+	loc := location(t)
+	minutes := duration.Minutes()
+	if minutes <= 0 {
+		// Without a length, the whole energy is priced at the rate of the start.
+		pricePerKWh, pricePerMinute := pricesAt(t, startsAt.In(loc))
+		cost := energyKWh * pricePerKWh
+		quote.EnergyCost = cost
+		quote.Lines = []model.PriceQuoteLine{{
+			From: startsAt, To: startsAt, EnergyKWh: quote.EnergyKWh,
+			PricePerKWh: pricePerKWh, PricePerMinute: pricePerMinute, Cost: round(cost, 2),
+		}}
+	} else {
+		energyPerMinute := energyKWh / minutes
+		var line *model.PriceQuoteLine
+		for elapsed, step := 0.0, 1.0; elapsed < minutes; elapsed += step {
+			step = math.Min(1, minutes-elapsed)
+			if elapsed >= maxPricedMinutes {
+				step = minutes - elapsed
+			}
+			at := startsAt.Add(time.Duration(elapsed * float64(time.Minute)))
+
+			pricePerKWh, pricePerMinute := pricesAt(t, at.In(loc))
+			if line == nil || line.PricePerKWh != pricePerKWh || line.PricePerMinute != pricePerMinute {
+				quote.Lines = append(quote.Lines, model.PriceQuoteLine{From: at, PricePerKWh: pricePerKWh, PricePerMinute: pricePerMinute})
+				line = &quote.Lines[len(quote.Lines)-1]
+			}
+			energyCost := energyPerMinute * step * pricePerKWh
+			timeCost := step * pricePerMinute
+			quote.EnergyCost += energyCost
+			quote.TimeCost += timeCost
+			line.EnergyKWh += energyPerMinute * step
+			line.Cost += energyCost + timeCost
+			line.To = at.Add(time.Duration(step * float64(time.Minute)))
+		}
+		for i := range quote.Lines {
+			quote.Lines[i].EnergyKWh = round(quote.Lines[i].EnergyKWh, 3)
+			quote.Lines[i].Cost = round(quote.Lines[i].Cost, 2)
+		}
+	}
+
+	if billable := idleMinutes - float64(t.IdleGraceMinutes); billable > 0 {
+		quote.IdleFee = billable * t.IdleFeePerMinute
+	}
+	quote.EnergyCost = round(quote.EnergyCost, 2)
+	quote.TimeCost = round(quote.TimeCost, 2)
+	quote.IdleFee = round(quote.IdleFee, 2)
+	quote.Total = round(quote.EnergyCost+quote.TimeCost+quote.SessionFee+quote.IdleFee, 2)
+	return quote
+}
+
+// SessionCost prices a completed charging session with the tariff it started with.
+func SessionCost(session *model.ChargingSession, stoppedAt time.Time, energyKWh float64) float64 {
+	tariff := session.Tariff
+	if tariff == nil {
+		tariff = &model.Tariff{Currency: DefaultCurrency, PricePerKWh: session.PricePerKWh}
+	}
+	return Price(tariff, session.StartedAt, stoppedAt.Sub(session.StartedAt), energyKWh, 0).Total
+}
+
+// PriceAt returns the price per kWh of the tariff at the given time, in the currency of the tariff.
+func PriceAt(t *model.Tariff, at time.Time) float64 {
+	pricePerKWh, _ := pricesAt(t, at.In(location(t)))
+	return pricePerKWh
+}
+
+// pricesAt returns the prices of the period in effect at the given local time, or the base prices.
+func pricesAt(t *model.Tariff, at time.Time) (pricePerKWh, pricePerMinute float64) {
+	minute := at.Hour()*60 + at.Minute()
+	for _, period := range t.Periods {
+		start, _ := minuteOfDay(period.Start)
+		end, _ := minuteOfDay(period.End)
+
+		// A window running over midnight belongs to the day it starts on.
+		day := at.Weekday()
+		inWindow := minute >= start && minute < end
+		if end < start {
+			inWindow = minute >= start || minute < end
+			if minute < end {
+				day = (day + 6) % 7
+			}
+		}
+		if inWindow && appliesOn(period.Days, day) {
+			return period.PricePerKWh, period.PricePerMinute
+		}
+	}
+	return t.PricePerKWh, t.PricePerMinute
+}
+
+func appliesOn(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// minuteOfDay parses a "15:04" time. "24:00" is accepted as the end of the day.
+func minuteOfDay(s string) (int, bool) {
+	if s == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func location(t *model.Tariff) *time.Location {
+	name := t.TimeZone
+	if name == "" {
+		name = DefaultTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// QuoteFor is Quote for a given socket.
+func QuoteFor(t *model.Tariff, socket *model.Socket, startsAt time.Time, energyKWh, idleMinutes float64) *model.PriceQuote {
+	quote := Quote(t, startsAt, energyKWh, socket.KW, idleMinutes)
+	quote.SocketID = socket.ID
+	return quote
+}
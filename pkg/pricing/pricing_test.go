@@ -0,0 +1,143 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"california/pkg/model"
+)
+
+func TestPrice(t *testing.T) {
+	peak := []model.TariffPeriod{{Start: "18:00", End: "22:00", PricePerKWh: 20}}
+	friday := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	type line struct {
+		from, to  string
+		energyKWh float64
+		cost      float64
+	}
+	tests := []struct {
+		name        string
+		tariff      model.Tariff
+		startsAt    time.Time
+		duration    time.Duration
+		energyKWh   float64
+		idleMinutes float64
+		wantLines   []line
+		wantTotal   float64
+	}{
+		{
+			name:      "crosses into a period",
+			tariff:    model.Tariff{TimeZone: "UTC", PricePerKWh: 10, Periods: peak},
+			startsAt:  friday.Add(17*time.Hour + 30*time.Minute),
+			duration:  time.Hour,
+			energyKWh: 10,
+			wantLines: []line{{"17:30", "18:00", 5, 50}, {"18:00", "18:30", 5, 100}},
+			wantTotal: 150,
+		},
+		{
+			name:   "period over midnight belongs to the day it starts on",
+			tariff: model.Tariff{TimeZone: "UTC", PricePerKWh: 10, Periods: []model.TariffPeriod{{Days: []time.Weekday{time.Friday}, Start: "22:00", End: "06:00", PricePerKWh: 5}}},
+			// Saturday morning, still in Friday's night.
+			startsAt:  friday.Add(29*time.Hour + 30*time.Minute),
+			duration:  time.Hour,
+			energyKWh: 6,
+			wantLines: []line{{"05:30", "06:00", 3, 15}, {"06:00", "06:30", 3, 30}},
+			wantTotal: 45,
+		},
+		{
+			name:      "period of another day",
+			tariff:    model.Tariff{TimeZone: "UTC", PricePerKWh: 10, Periods: []model.TariffPeriod{{Days: []time.Weekday{time.Friday}, Start: "22:00", End: "06:00", PricePerKWh: 5}}},
+			startsAt:  friday.Add(53*time.Hour + 30*time.Minute),
+			duration:  time.Hour,
+			energyKWh: 6,
+			wantLines: []line{{"05:30", "06:30", 6, 60}},
+			wantTotal: 60,
+		},
+		{
+			name:      "periods are in the time zone of the tariff",
+			tariff:    model.Tariff{TimeZone: "Europe/Istanbul", PricePerKWh: 10, Periods: peak},
+			startsAt:  friday.Add(15*time.Hour + 30*time.Minute),
+			duration:  30 * time.Minute,
+			energyKWh: 5,
+			wantLines: []line{{"15:30", "16:00", 5, 100}},
+			wantTotal: 100,
+		},
+		{
+			name:        "time price, session fee and idle fee after the grace",
+			tariff:      model.Tariff{TimeZone: "UTC", PricePerKWh: 10, PricePerMinute: 0.5, SessionFee: 5, IdleFeePerMinute: 1, IdleGraceMinutes: 10},
+			startsAt:    friday.Add(12 * time.Hour),
+			duration:    40 * time.Minute,
+			energyKWh:   4,
+			idleMinutes: 25,
+			wantLines:   []line{{"12:00", "12:40", 4, 60}},
+			wantTotal:   80,
+		},
+		{
+			name:      "without a length at the rate of the start",
+			tariff:    model.Tariff{TimeZone: "UTC", PricePerKWh: 10, Periods: peak},
+			startsAt:  friday.Add(19 * time.Hour),
+			energyKWh: 5,
+			wantLines: []line{{"19:00", "19:00", 5, 100}},
+			wantTotal: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote := Price(&tt.tariff, tt.startsAt, tt.duration, tt.energyKWh, tt.idleMinutes)
+			if quote.Total != tt.wantTotal {
+				t.Errorf("Total = %v, want %v", quote.Total, tt.wantTotal)
+			}
+			if len(quote.Lines) != len(tt.wantLines) {
+				t.Fatalf("Lines = %+v, want %d lines", quote.Lines, len(tt.wantLines))
+			}
+			for i, want := range tt.wantLines {
+				got := quote.Lines[i]
+				from, to := got.From.UTC().Format("15:04"), got.To.UTC().Format("15:04")
+				if from != want.from || to != want.to || got.EnergyKWh != want.energyKWh || got.Cost != want.cost {
+					t.Errorf("line %d = %s-%s %v kWh for %v, want %s-%s %v kWh for %v",
+						i, from, to, got.EnergyKWh, got.Cost, want.from, want.to, want.energyKWh, want.cost)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionCost(t *testing.T) {
+	startedAt := time.Date(2024, 1, 5, 17, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		session   model.ChargingSession
+		duration  time.Duration
+		energyKWh float64
+		want      float64
+	}{
+		{
+			name:      "flat price of a session started before tariffs",
+			session:   model.ChargingSession{StartedAt: startedAt, PricePerKWh: 7.777},
+			duration:  time.Hour,
+			energyKWh: 3.3333,
+			want:      25.92, // 25.9230741
+		},
+		{
+			name:      "tariff split by its periods",
+			session:   model.ChargingSession{StartedAt: startedAt, Tariff: &model.Tariff{TimeZone: "UTC", PricePerKWh: 10, Periods: []model.TariffPeriod{{Start: "18:00", End: "22:00", PricePerKWh: 20}}}},
+			duration:  time.Hour,
+			energyKWh: 10,
+			want:      150,
+		},
+		{
+			name:      "rounded to cents",
+			session:   model.ChargingSession{StartedAt: startedAt, Tariff: &model.Tariff{TimeZone: "UTC", PricePerKWh: 0.333, PricePerMinute: 0.0015}},
+			duration:  7 * time.Minute,
+			energyKWh: 7,
+			want:      2.34, // 2.331 for the energy and 0.0105 for the time, each rounded to cents
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SessionCost(&tt.session, tt.session.StartedAt.Add(tt.duration), tt.energyKWh); got != tt.want {
+				t.Errorf("SessionCost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package pricing
+
+import (
+	"context"
+	"strings"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Tariffs resolves the tariff of sockets from a snapshot of the tariffs stored.
+type Tariffs struct {
+	byID    map[string]*model.Tariff
+	byBrand []*model.Tariff
+}
+
+// LoadTariffs reads all the tariffs. There are only a few per operator, so they are loaded
+// at once rather than looked up socket by socket.
+func LoadTariffs(ctx context.Context, store repository.Store) (*Tariffs, error) {
+	tariffs, err := store.FindTariffsByFilter(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	set := &Tariffs{byID: make(map[string]*model.Tariff, len(tariffs))}
+	for _, tariff := range tariffs {
+		set.byID[tariff.ID.Hex()] = tariff
+		if tariff.Brand != "" {
+			set.byBrand = append(set.byBrand, tariff)
+		}
+	}
+	return set, nil
+}
+
+// For returns the tariff of the socket: its own tariff, else the tariff of the station's brand
+// for the socket's current type, else the legacy flat price of the socket.
+func (ts *Tariffs) For(station *model.Station, socket *model.Socket) *model.Tariff {
+	if socket.TariffID != nil {
+		if tariff, ok := ts.byID[socket.TariffID.Hex()]; ok {
+			return tariff
+		}
+	}
+
+	var brandTariff *model.Tariff
+	for _, tariff := range ts.byBrand {
+		if !strings.EqualFold(tariff.Brand, station.Brand) {
+			continue
+		}
+		if tariff.CurrentType == socket.CurrentType {
+			return tariff
+		}
+		if tariff.CurrentType == 0 && brandTariff == nil {
+			brandTariff = tariff
+		}
+	}
+	if brandTariff != nil {
+		return brandTariff
+	}
+	return LegacyTariff(socket)
+}
@@ -0,0 +1,50 @@
+package pricing
+
+import (
+	"context"
+	"testing"
+
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTariffsFor(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	own := &model.Tariff{ID: primitive.NewObjectID(), Name: "Own", Currency: "EUR"}
+	anyCurrent := &model.Tariff{ID: primitive.NewObjectID(), Name: "Brand", Currency: "TRY", Brand: "ZES"}
+	dc := &model.Tariff{ID: primitive.NewObjectID(), Name: "Brand DC", Currency: "TRY", Brand: "ZES", CurrentType: model.DC}
+	for _, tariff := range []*model.Tariff{own, anyCurrent, dc} {
+		if err := store.InsertTariff(ctx, tariff); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tariffs, err := LoadTariffs(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := primitive.NewObjectID()
+	tests := []struct {
+		name    string
+		brand   string
+		socket  model.Socket
+		want    string
+		wantKWh float64
+	}{
+		{"own tariff of the socket", "ZES", model.Socket{TariffID: &own.ID, CurrentType: model.DC}, "Own", 0},
+		{"brand tariff when the own tariff is gone", "ZES", model.Socket{TariffID: &missing, CurrentType: model.DC}, "Brand DC", 0},
+		{"brand tariff of the current type", "zes", model.Socket{CurrentType: model.DC}, "Brand DC", 0},
+		{"brand tariff of any current type", "ZES", model.Socket{CurrentType: model.AC}, "Brand", 0},
+		{"flat price of the socket for another brand", "Eşarj", model.Socket{CurrentType: model.AC, Price: 8.5}, "Flat rate", 8.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tariffs.For(&model.Station{Brand: tt.brand}, &tt.socket)
+			if got.Name != tt.want || got.PricePerKWh != tt.wantKWh {
+				t.Errorf("For = %s at %v/kWh, want %s at %v/kWh", got.Name, got.PricePerKWh, tt.want, tt.wantKWh)
+			}
+		})
+	}
+}
@@ -0,0 +1,469 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// toDocument converts a value to the document MongoDB would store for it,
+// so filters can be evaluated against the same field names and types.
+func toDocument(v interface{}) (bson.M, error) {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// matchFilter reports whether the document matches the filter. It supports the subset of the
+// MongoDB query language used by the services: implicit equality, dotted paths into embedded
+// documents and arrays, $and, $or, $nor, $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists,
+// $regex with $options, $not, $elemMatch and $geoWithin with $centerSphere.
+func matchFilter(doc bson.M, filter bson.M) (bool, error) {
+	for key, cond := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			subFilters, err := asFilterList(cond)
+			if err != nil {
+				return false, err
+			}
+			matched := 0
+			for _, sub := range subFilters {
+				ok, err := matchFilter(doc, sub)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					matched++
+				}
+			}
+			if key == "$and" && matched != len(subFilters) ||
+				key == "$or" && matched == 0 ||
+				key == "$nor" && matched != 0 {
+				return false, nil
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("unsupported top level operator %s", key)
+			}
+			ok, err := matchField(lookup(doc, strings.Split(key, ".")), cond)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// lookup returns the values found at the path. Arrays met on the way are traversed,
+// so a path can yield several values, or none when the field is missing.
+func lookup(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	if doc, ok := asDocument(value); ok {
+		field, ok := doc[path[0]]
+		if !ok {
+			return nil
+		}
+		return lookup(field, path[1:])
+	}
+	if arr, ok := asArray(value); ok {
+		var values []interface{}
+		for _, elem := range arr {
+			if _, isDoc := asDocument(elem); isDoc {
+				values = append(values, lookup(elem, path)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// candidates returns the values a condition is compared with: the values themselves
+// and, for arrays, their elements.
+func candidates(values []interface{}) []interface{} {
+	var result []interface{}
+	for _, v := range values {
+		result = append(result, v)
+		if arr, ok := asArray(v); ok {
+			result = append(result, arr...)
+		}
+	}
+	return result
+}
+
+func matchField(values []interface{}, cond interface{}) (bool, error) {
+	if ops, ok := asDocument(cond); ok && isOperatorDocument(ops) {
+		return matchOperators(values, ops)
+	}
+	if re, ok := cond.(primitive.Regex); ok {
+		return matchRegex(values, re.Pattern, re.Options)
+	}
+	return anyEqual(candidates(values), cond), nil
+}
+
+func matchOperators(values []interface{}, ops bson.M) (bool, error) {
+	for op, arg := range ops {
+		var (
+			ok  bool
+			err error
+		)
+		switch op {
+		case "$eq":
+			ok = anyEqual(candidates(values), arg)
+		case "$ne":
+			ok = !anyEqual(candidates(values), arg)
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = anyCompare(candidates(values), arg, op)
+		case "$in", "$nin":
+			list, isArr := asArray(arg)
+			if !isArr {
+				return false, fmt.Errorf("%s needs an array", op)
+			}
+			ok = false
+			for _, item := range list {
+				if re, isRe := item.(primitive.Regex); isRe {
+					if matched, _ := matchRegex(values, re.Pattern, re.Options); matched {
+						ok = true
+					}
+				} else if anyEqual(candidates(values), item) {
+					ok = true
+				}
+			}
+			if op == "$nin" {
+				ok = !ok
+			}
+		case "$exists":
+			ok = (len(values) > 0) == truthy(arg)
+		case "$regex":
+			pattern, options := "", ""
+			switch re := arg.(type) {
+			case primitive.Regex:
+				pattern, options = re.Pattern, re.Options
+			case string:
+				pattern = re
+			}
+			if o, hasOptions := ops["$options"].(string); hasOptions {
+				options = o
+			}
+			ok, err = matchRegex(values, pattern, options)
+		case "$options":
+			continue
+		case "$not":
+			ok, err = matchField(values, arg)
+			ok = !ok
+		case "$elemMatch":
+			ok, err = matchElem(values, arg)
+		case "$geoWithin":
+			ok, err = matchGeoWithin(values, arg)
+		default:
+			return false, fmt.Errorf("unsupported operator %s", op)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchElem(values []interface{}, cond interface{}) (bool, error) {
+	filter, ok := asDocument(cond)
+	if !ok {
+		return false, fmt.Errorf("$elemMatch needs a document")
+	}
+	for _, v := range values {
+		arr, isArr := asArray(v)
+		if !isArr {
+			continue
+		}
+		for _, elem := range arr {
+			var (
+				matched bool
+				err     error
+			)
+			if doc, isDoc := asDocument(elem); isDoc && !isOperatorDocument(filter) {
+				matched, err = matchFilter(doc, filter)
+			} else {
+				matched, err = matchOperators([]interface{}{elem}, filter)
+			}
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// matchGeoWithin supports {$centerSphere: [[long, lat], radiusInRadians]} on GeoJSON points.
+func matchGeoWithin(values []interface{}, cond interface{}) (bool, error) {
+	shape, ok := asDocument(cond)
+	if !ok {
+		return false, fmt.Errorf("$geoWithin needs a document")
+	}
+	sphere, ok := asArray(shape["$centerSphere"])
+	if !ok || len(sphere) != 2 {
+		return false, fmt.Errorf("$geoWithin only supports $centerSphere")
+	}
+	center, ok := asArray(sphere[0])
+	if !ok || len(center) != 2 {
+		return false, fmt.Errorf("invalid $centerSphere center")
+	}
+	centerLong, _ := toFloat(center[0])
+	centerLat, _ := toFloat(center[1])
+	radius, _ := toFloat(sphere[1])
+
+	for _, v := range values {
+		long, lat, isPoint := asGeoPoint(v)
+		if isPoint && distanceKm(centerLat, centerLong, lat, long) <= radius*earthRadiusKm {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchRegex(values []interface{}, pattern, options string) (bool, error) {
+	flags := ""
+	for _, o := range options {
+		if strings.ContainsRune("ims", o) {
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range candidates(values) {
+		if s, ok := v.(string); ok && re.MatchString(s) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func anyEqual(values []interface{}, target interface{}) bool {
+	if target == nil {
+		// {field: nil} matches documents where the field is null or missing.
+		if len(values) == 0 {
+			return true
+		}
+	}
+	for _, v := range values {
+		if equal(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyCompare(values []interface{}, target interface{}, op string) bool {
+	for _, v := range values {
+		c, ok := compare(v, target)
+		if !ok {
+			continue
+		}
+		switch {
+		case op == "$gt" && c > 0, op == "$gte" && c >= 0, op == "$lt" && c < 0, op == "$lte" && c <= 0:
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two values of the same kind. Numbers of any Go or BSON type are compared
+// as floats and times at millisecond precision, as MongoDB stores them.
+func compare(a, b interface{}) (int, bool) {
+	// Times come first, as primitive.DateTime would otherwise be taken for a number.
+	if ta, ok := toTime(a); ok {
+		if tb, ok := toTime(b); ok {
+			return compareInt64(ta, tb), true
+		}
+		return 0, false
+	}
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+		return 0, false
+	}
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case primitive.ObjectID:
+		if bv, ok := b.(primitive.ObjectID); ok {
+			return strings.Compare(av.Hex(), bv.Hex()), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0, true
+			}
+			if !av {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func toTime(v interface{}) (int64, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UnixMilli(), true
+	case *time.Time:
+		if t == nil {
+			return 0, false
+		}
+		return t.UnixMilli(), true
+	case primitive.DateTime:
+		return int64(t), true
+	}
+	return 0, false
+}
+
+func truthy(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	f, ok := toFloat(v)
+	return ok && f != 0
+}
+
+func asGeoPoint(v interface{}) (long, lat float64, ok bool) {
+	doc, isDoc := asDocument(v)
+	if !isDoc || doc["type"] != "Point" {
+		return 0, 0, false
+	}
+	coords, isArr := asArray(doc["coordinates"])
+	if !isArr || len(coords) != 2 {
+		return 0, 0, false
+	}
+	long, okLong := toFloat(coords[0])
+	lat, okLat := toFloat(coords[1])
+	return long, lat, okLong && okLat
+}
+
+func isOperatorDocument(doc bson.M) bool {
+	if len(doc) == 0 {
+		return false
+	}
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+func asDocument(v interface{}) (bson.M, bool) {
+	switch d := v.(type) {
+	case bson.M:
+		return d, true
+	case map[string]interface{}:
+		return d, true
+	case bson.D:
+		doc := make(bson.M, len(d))
+		for _, e := range d {
+			doc[e.Key] = e.Value
+		}
+		return doc, true
+	}
+	return nil, false
+}
+
+func asArray(v interface{}) ([]interface{}, bool) {
+	switch a := v.(type) {
+	case bson.A:
+		return a, true
+	case []interface{}:
+		return a, true
+	case nil:
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	arr := make([]interface{}, rv.Len())
+	for i := range arr {
+		arr[i] = rv.Index(i).Interface()
+	}
+	return arr, true
+}
+
+func asFilterList(v interface{}) ([]bson.M, error) {
+	arr, ok := asArray(v)
+	if !ok {
+		return nil, fmt.Errorf("logical operators need an array of filters")
+	}
+	filters := make([]bson.M, 0, len(arr))
+	for _, item := range arr {
+		doc, ok := asDocument(item)
+		if !ok {
+			return nil, fmt.Errorf("logical operators need an array of filters")
+		}
+		filters = append(filters, doc)
+	}
+	return filters, nil
+}
@@ -0,0 +1,1436 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/auth"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const earthRadiusKm = 6371
+
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// MemoryStore is a Store keeping everything in memory. It is meant for tests and local development
+// and follows the behaviour of MongoStore, including the bson.M filters accepted by the Find methods.
+// Values are copied on the way in and out, so callers never share memory with the store.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	users         []*model.User
+	stations      []*model.Station
+	sockets       []*model.Socket
+	refreshTokens []*model.RefreshToken
+	reservations  []*model.Reservation
+	sessions      []*model.ChargingSession
+	tariffs       []*model.Tariff
+	energyPrices  []*model.EnergyPrice
+	reviews       []*model.Review
+	issueReports  []*model.IssueReport
+	importJobs    []*model.ImportJob
+	userTokens    []*model.UserToken
+	loginAttempts map[string]*model.LoginAttempts
+	transactions  []*model.Transaction
+	lastTxID      int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// clone copies a value through its BSON representation, the same round trip a value makes through MongoDB.
+func clone[T any](v *T) (*T, error) {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c T
+	if err = bson.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// filterDocs returns copies of the values matching the filter, in insertion order.
+func filterDocs[T any](values []*T, filter bson.M) ([]*T, error) {
+	var result []*T
+	for _, v := range values {
+		doc, err := toDocument(v)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := matchFilter(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		c, err := clone(v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) InsertUser(_ context.Context, user *model.User) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := clone(user)
+	if err != nil {
+		return nil, err
+	}
+	s.users = append(s.users, stored)
+	return clone(stored)
+}
+
+func (s *MemoryStore) UserExists(_ context.Context, email string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.userByEmail(email) != nil, nil
+}
+
+func (s *MemoryStore) GetUserByEmail(_ context.Context, email string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user := s.userByEmail(email)
+	if user == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	return clone(user)
+}
+
+func (s *MemoryStore) GetUserById(_ context.Context, userId string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	oid, _ := primitive.ObjectIDFromHex(userId)
+	user := s.userById(oid)
+	if user == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	return clone(user)
+}
+
+func (s *MemoryStore) InsertVehicleToUser(_ context.Context, user *model.User, vehicle *model.Vehicle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored := s.userByEmail(user.Email); stored != nil {
+		v, err := clone(vehicle)
+		if err != nil {
+			return err
+		}
+		stored.Vehicles = append(stored.Vehicles, *v)
+		stored.Vehicle = nil
+	}
+	return nil
+}
+
+func (s *MemoryStore) UnsetLegacyVehicle(_ context.Context, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored := s.userByEmail(email); stored != nil {
+		stored.Vehicle = nil
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindUsersByFilter(_ context.Context, filter bson.M) ([]*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return filterDocs(s.users, filter)
+}
+
+func (s *MemoryStore) UpdateUser(ctx context.Context, reqUser *model.User) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	var newHashedPass string
+	if reqUser.Password != "" {
+		var err error
+		newHashedPass, err = helpers.HashRegisterPassword(reqUser.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored := s.userById(oid); stored != nil {
+		stored.Name = reqUser.Name
+		if newHashedPass != "" {
+			stored.Password = newHashedPass
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateVehicle(ctx context.Context, reqVehicle *model.Vehicle) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(oid)
+	if stored == nil {
+		return mongo.ErrNoDocuments
+	}
+	vehicle := stored.VehicleById(reqVehicle.ID)
+	if vehicle == nil {
+		return mongo.ErrNoDocuments
+	}
+	v, err := clone(reqVehicle)
+	if err != nil {
+		return err
+	}
+	*vehicle = *v
+	return nil
+}
+
+func (s *MemoryStore) DeleteVehicle(ctx context.Context, vehicleId primitive.ObjectID) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(oid)
+	if stored == nil || stored.VehicleById(vehicleId) == nil {
+		return mongo.ErrNoDocuments
+	}
+	vehicles := stored.Vehicles[:0]
+	for _, vehicle := range stored.Vehicles {
+		if vehicle.ID != vehicleId {
+			vehicles = append(vehicles, vehicle)
+		}
+	}
+	stored.Vehicles = vehicles
+	if stored.DefaultVehicleID == vehicleId {
+		stored.DefaultVehicleID = primitive.NilObjectID
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetDefaultVehicle(ctx context.Context, vehicleId primitive.ObjectID) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(oid)
+	if stored == nil || stored.VehicleById(vehicleId) == nil {
+		return mongo.ErrNoDocuments
+	}
+	stored.DefaultVehicleID = vehicleId
+	return nil
+}
+
+func (s *MemoryStore) SetFavoriteStations(ctx context.Context, stationIds []primitive.ObjectID) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(oid)
+	if stored == nil {
+		return mongo.ErrNoDocuments
+	}
+	stored.FavoriteStations = append([]primitive.ObjectID(nil), stationIds...)
+	return nil
+}
+
+func (s *MemoryStore) SetPlaces(ctx context.Context, places []model.Place) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(oid)
+	if stored == nil {
+		return mongo.ErrNoDocuments
+	}
+	stored.Places = append([]model.Place(nil), places...)
+	return nil
+}
+
+func (s *MemoryStore) GetAllUsers(_ context.Context) ([]*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return filterDocs(s.users, bson.M{})
+}
+
+func (s *MemoryStore) DeleteUser(_ context.Context, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, user := range s.users {
+		if user.Email == email {
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) InsertStation(_ context.Context, station *model.Station) (*model.Station, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stationById(station.ID) != nil {
+		return nil, ErrDuplicateKey
+	}
+	stored, err := clone(station)
+	if err != nil {
+		return nil, err
+	}
+	s.stations = append(s.stations, stored)
+	return clone(stored)
+}
+
+func (s *MemoryStore) GetStationById(_ context.Context, stationId string) (*model.Station, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	oid, _ := primitive.ObjectIDFromHex(stationId)
+	station := s.stationById(oid)
+	if station == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	return clone(station)
+}
+
+func (s *MemoryStore) GetAllStations(_ context.Context) ([]*model.Station, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return filterDocs(s.stations, bson.M{})
+}
+
+func (s *MemoryStore) UpdateStationInfo(_ context.Context, station *model.Station, stationId string) error {
+	oid, _ := primitive.ObjectIDFromHex(stationId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.stationById(oid)
+	if stored == nil {
+		return nil
+	}
+	update, err := clone(station)
+	if err != nil {
+		return err
+	}
+	stored.Brand = update.Brand
+	stored.Latitude = update.Latitude
+	stored.Longitude = update.Longitude
+	stored.Status = update.Status
+	stored.CurrentType = update.CurrentType
+	stored.Distance = update.Distance
+	stored.Address = update.Address
+	stored.Sockets = update.Sockets
+	stored.Location = update.Location
+	stored.ChargePointID = update.ChargePointID
+	stored.ExternalID = update.ExternalID
+	stored.OpeningHours = update.OpeningHours
+	stored.Amenities = update.Amenities
+	stored.Access = update.Access
+	return nil
+}
+
+func (s *MemoryStore) SetChargePointKey(_ context.Context, stationId primitive.ObjectID, keyHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	station := s.stationById(stationId)
+	if station == nil {
+		return mongo.ErrNoDocuments
+	}
+	station.ChargePointKeyHash = keyHash
+	return nil
+}
+
+func (s *MemoryStore) DeleteStation(_ context.Context, stationId string) error {
+	oid, _ := primitive.ObjectIDFromHex(stationId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, station := range s.stations {
+		if station.ID == oid {
+			s.stations = append(s.stations[:i], s.stations[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindStationByFilter(_ context.Context, filter bson.M) ([]*model.Station, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return filterDocs(s.stations, filter)
+}
+
+func (s *MemoryStore) FilterStations(ctx context.Context, filter bson.M) ([]*model.Station, error) {
+	return s.FindStationByFilter(ctx, filter)
+}
+
+func (s *MemoryStore) PushSocketToStation(_ context.Context, station *model.Station, socket model.Socket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored := s.stationById(station.ID); stored != nil {
+		c, err := clone(&socket)
+		if err != nil {
+			return err
+		}
+		stored.Sockets = append(stored.Sockets, *c)
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteSocket(_ context.Context, socketId string) error {
+	oid, _ := primitive.ObjectIDFromHex(socketId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, socket := range s.sockets {
+		if socket.ID == oid {
+			s.sockets = append(s.sockets[:i], s.sockets[i+1:]...)
+			break
+		}
+	}
+	for _, station := range s.stations {
+		sockets := station.Sockets[:0]
+		for _, socket := range station.Sockets {
+			if socket.ID != oid {
+				sockets = append(sockets, socket)
+			}
+		}
+		station.Sockets = sockets
+	}
+	return nil
+}
+
+func (s *MemoryStore) InsertSocket(_ context.Context, socket *model.Socket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.sockets {
+		if stored.ID == socket.ID {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(socket)
+	if err != nil {
+		return err
+	}
+	s.sockets = append(s.sockets, stored)
+	return nil
+}
+
+func (s *MemoryStore) ListSockets(_ context.Context) ([]*model.Socket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return filterDocs(s.sockets, bson.M{})
+}
+
+func (s *MemoryStore) UpdateSocketStatus(_ context.Context, socketId string, status model.SocketStatus) error {
+	oid, _ := primitive.ObjectIDFromHex(socketId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, socket := range s.sockets {
+		if socket.ID == oid {
+			socket.Status = status
+		}
+	}
+	for _, station := range s.stations {
+		for i := range station.Sockets {
+			if station.Sockets[i].ID == oid {
+				station.Sockets[i].Status = status
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ClaimSocket(_ context.Context, socketId primitive.ObjectID, from []model.SocketStatus, to model.SocketStatus) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := false
+	for _, station := range s.stations {
+		for i := range station.Sockets {
+			if station.Sockets[i].ID == socketId && slices.Contains(from, station.Sockets[i].Status) {
+				station.Sockets[i].Status = to
+				claimed = true
+			}
+		}
+	}
+	if !claimed {
+		return false, nil
+	}
+	for _, socket := range s.sockets {
+		if socket.ID == socketId {
+			socket.Status = to
+		}
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) SetSocketSuspected(_ context.Context, socketId primitive.ObjectID, suspected bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, socket := range s.sockets {
+		if socket.ID == socketId {
+			socket.SuspectedBroken = suspected
+		}
+	}
+	for _, station := range s.stations {
+		for i := range station.Sockets {
+			if station.Sockets[i].ID == socketId {
+				station.Sockets[i].SuspectedBroken = suspected
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindStationsNear(_ context.Context, point model.Coordinate, maxDistanceKm float64, limit int) ([]*model.Station, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stations []*model.Station
+	for _, station := range s.stations {
+		if station.Location == nil || len(station.Location.Coordinates) != 2 {
+			continue
+		}
+		long, lat := station.Location.Coordinates[0], station.Location.Coordinates[1]
+		distance := distanceKm(point.Lat, point.Long, lat, long)
+		if distance > maxDistanceKm {
+			continue
+		}
+		c, err := clone(station)
+		if err != nil {
+			return nil, err
+		}
+		c.Distance = distance
+		stations = append(stations, c)
+	}
+
+	sort.SliceStable(stations, func(i, j int) bool {
+		return stations[i].Distance < stations[j].Distance
+	})
+	if limit > 0 && len(stations) > limit {
+		stations = stations[:limit]
+	}
+	return stations, nil
+}
+
+func (s *MemoryStore) FindStationsPage(_ context.Context, filter bson.M, page Page) ([]*model.Station, string, error) {
+	order, err := stationOrder(page)
+	if err != nil {
+		return nil, "", err
+	}
+	s.mu.RLock()
+	stations, err := filterDocs(s.stations, filter)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if page.Sort == SortDistance {
+		// Like $geoNear, only the stations with a location are ordered by distance.
+		located := stations[:0]
+		for _, station := range stations {
+			if station.Location == nil || len(station.Location.Coordinates) != 2 {
+				continue
+			}
+			long, lat := station.Location.Coordinates[0], station.Location.Coordinates[1]
+			station.Distance = distanceKm(page.Near.Lat, page.Near.Long, lat, long)
+			located = append(located, station)
+		}
+		stations = located
+	}
+	key := func(station *model.Station) interface{} { return stationSortKey(station, page) }
+	return pageOf(stations, page, order, stationId, key)
+}
+
+func (s *MemoryStore) FindSocketsPage(_ context.Context, filter bson.M, page Page) ([]*model.Socket, string, error) {
+	order, err := socketOrder(page)
+	if err != nil {
+		return nil, "", err
+	}
+	s.mu.RLock()
+	sockets, err := filterDocs(s.sockets, filter)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, "", err
+	}
+	key := func(socket *model.Socket) interface{} { return socketSortKey(socket, page) }
+	return pageOf(sockets, page, order, socketId, key)
+}
+
+func (s *MemoryStore) FindUsersPage(_ context.Context, filter bson.M, page Page) ([]*model.User, string, error) {
+	order, err := userOrder(page)
+	if err != nil {
+		return nil, "", err
+	}
+	s.mu.RLock()
+	users, err := filterDocs(s.users, filter)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, "", err
+	}
+	return pageOf(users, page, order, userId, noSortKey[model.User])
+}
+
+func (s *MemoryStore) InsertRefreshToken(_ context.Context, token *model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.refreshTokens {
+		if stored.ID == token.ID || stored.TokenHash == token.TokenHash {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(token)
+	if err != nil {
+		return err
+	}
+	s.refreshTokens = append(s.refreshTokens, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetRefreshTokenByHash(_ context.Context, tokenHash string) (*model.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return clone(token)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) RevokeRefreshToken(_ context.Context, tokenId primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.ID == tokenId && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) RevokeRefreshTokenFamily(_ context.Context, familyId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range s.refreshTokens {
+		if token.FamilyID == familyId && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeUserRefreshTokens(_ context.Context, userId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range s.refreshTokens {
+		if token.UserID == userId && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) InsertUserToken(_ context.Context, token *model.UserToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.userTokens {
+		if stored.ID == token.ID || stored.TokenHash == token.TokenHash {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(token)
+	if err != nil {
+		return err
+	}
+	s.userTokens = append(s.userTokens, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetUserTokenByHash(_ context.Context, tokenHash string) (*model.UserToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.userTokens {
+		if token.TokenHash == tokenHash {
+			return clone(token)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) UseUserToken(_ context.Context, tokenId primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.userTokens {
+		if token.ID == tokenId && token.UsedAt == nil {
+			now := time.Now()
+			token.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) SetUserPassword(_ context.Context, userId primitive.ObjectID, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(userId)
+	if stored == nil {
+		return mongo.ErrNoDocuments
+	}
+	stored.Password = passwordHash
+	return nil
+}
+
+func (s *MemoryStore) FailUserToken(_ context.Context, tokenId primitive.ObjectID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.userTokens {
+		if token.ID == tokenId {
+			token.Attempts++
+			return token.Attempts, nil
+		}
+	}
+	return 0, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) GetLoginAttempts(_ context.Context, key string) (*model.LoginAttempts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attempts, ok := s.loginAttempts[key]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return clone(attempts)
+}
+
+func (s *MemoryStore) RecordLoginFailure(_ context.Context, key string, at time.Time, window time.Duration) (*model.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.loginAttemptsOf(key)
+	if attempts.LastFailureAt.Before(at.Add(-window)) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = at
+	return clone(attempts)
+}
+
+func (s *MemoryStore) LockLogin(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.loginAttemptsOf(key)
+	attempts.LockedUntil = &until
+	attempts.Failures = 0
+	return nil
+}
+
+func (s *MemoryStore) ResetLoginAttempts(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginAttempts, key)
+	return nil
+}
+
+// loginAttemptsOf returns the stored failed logins of the key, which are created when there are none.
+func (s *MemoryStore) loginAttemptsOf(key string) *model.LoginAttempts {
+	if s.loginAttempts == nil {
+		s.loginAttempts = make(map[string]*model.LoginAttempts)
+	}
+	attempts, ok := s.loginAttempts[key]
+	if !ok {
+		attempts = &model.LoginAttempts{Key: key}
+		s.loginAttempts[key] = attempts
+	}
+	return attempts
+}
+
+func (s *MemoryStore) SetTwoFactor(_ context.Context, userId primitive.ObjectID, twoFactor *model.TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(userId)
+	if stored == nil {
+		return mongo.ErrNoDocuments
+	}
+	if twoFactor == nil {
+		stored.TwoFactor = nil
+		return nil
+	}
+	copied := *twoFactor
+	copied.RecoveryCodes = append([]string(nil), twoFactor.RecoveryCodes...)
+	stored.TwoFactor = &copied
+	return nil
+}
+
+func (s *MemoryStore) UseTwoFactorStep(_ context.Context, userId primitive.ObjectID, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(userId)
+	if stored == nil || stored.TwoFactor == nil || stored.TwoFactor.LastStep >= step {
+		return false, nil
+	}
+	stored.TwoFactor.LastStep = step
+	return true, nil
+}
+
+func (s *MemoryStore) UseRecoveryCode(_ context.Context, userId primitive.ObjectID, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(userId)
+	if stored == nil || stored.TwoFactor == nil {
+		return false, nil
+	}
+	codes := stored.TwoFactor.RecoveryCodes
+	for i, code := range codes {
+		if code == codeHash {
+			stored.TwoFactor.RecoveryCodes = append(codes[:i:i], codes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) SetUserEmailVerified(_ context.Context, userId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.userById(userId)
+	if stored == nil {
+		return mongo.ErrNoDocuments
+	}
+	stored.EmailVerified = true
+	return nil
+}
+
+func (s *MemoryStore) InsertReservation(_ context.Context, reservation *model.Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.reservations {
+		if stored.ID == reservation.ID {
+			return ErrDuplicateKey
+		}
+		if stored.SocketID == reservation.SocketID && stored.Holding() && stored.Overlaps(reservation.StartsAt, reservation.EndsAt) {
+			return ErrReservationConflict
+		}
+	}
+	stored, err := clone(reservation)
+	if err != nil {
+		return err
+	}
+	s.reservations = append(s.reservations, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetReservationById(_ context.Context, reservationId string) (*model.Reservation, error) {
+	oid, _ := primitive.ObjectIDFromHex(reservationId)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, reservation := range s.reservations {
+		if reservation.ID == oid {
+			return clone(reservation)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) FindReservationsByFilter(_ context.Context, filter bson.M) ([]*model.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reservations, err := filterDocs(s.reservations, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(reservations, func(i, j int) bool {
+		return reservations[i].StartsAt.Before(reservations[j].StartsAt)
+	})
+	return reservations, nil
+}
+
+func (s *MemoryStore) UpdateReservationStatus(_ context.Context, reservationId primitive.ObjectID, from, to model.ReservationStatus) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, reservation := range s.reservations {
+		if reservation.ID == reservationId && reservation.Status == from {
+			reservation.Status = to
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) InsertChargingSession(_ context.Context, session *model.ChargingSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.sessions {
+		if stored.ID == session.ID {
+			return ErrDuplicateKey
+		}
+		if session.Status == model.ChargingInProgress && stored.Status == model.ChargingInProgress &&
+			(stored.SocketID == session.SocketID || stored.UserID == session.UserID) {
+			return ErrChargingSessionConflict
+		}
+	}
+	stored, err := clone(session)
+	if err != nil {
+		return err
+	}
+	s.sessions = append(s.sessions, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetChargingSessionById(_ context.Context, sessionId string) (*model.ChargingSession, error) {
+	oid, _ := primitive.ObjectIDFromHex(sessionId)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, session := range s.sessions {
+		if session.ID == oid {
+			return clone(session)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) FindChargingSessionsByFilter(_ context.Context, filter bson.M) ([]*model.ChargingSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions, err := filterDocs(s.sessions, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartedAt.After(sessions[j].StartedAt)
+	})
+	return sessions, nil
+}
+
+func (s *MemoryStore) CompleteChargingSession(_ context.Context, session *model.ChargingSession) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, stored := range s.sessions {
+		if stored.ID == session.ID && stored.Status == model.ChargingInProgress {
+			update := *stored
+			update.Status = session.Status
+			update.StoppedAt = session.StoppedAt
+			update.EnergyKWh = session.EnergyKWh
+			update.Cost = session.Cost
+			update.Estimated = session.Estimated
+			update.TransactionID = session.TransactionID
+			completed, err := clone(&update)
+			if err != nil {
+				return false, err
+			}
+			s.sessions[i] = completed
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) SettleChargingSession(_ context.Context, session *model.ChargingSession) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session.TransactionID == 0 {
+		return false, nil
+	}
+	for _, stored := range s.sessions {
+		if stored.ID == session.ID && stored.Status == model.ChargingCompleted && stored.TransactionID == session.TransactionID {
+			stored.EnergyKWh = session.EnergyKWh
+			stored.Cost = session.Cost
+			stored.Estimated = session.Estimated
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) InsertTransaction(_ context.Context, tx *model.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastTxID++
+	tx.ID = s.lastTxID
+	c, err := clone(tx)
+	if err != nil {
+		return err
+	}
+	s.transactions = append(s.transactions, c)
+	return nil
+}
+
+func (s *MemoryStore) GetTransactionById(_ context.Context, transactionId int) (*model.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, tx := range s.transactions {
+		if tx.ID == transactionId {
+			return clone(tx)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) GetTransactionBySocket(_ context.Context, socketId primitive.ObjectID) (*model.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The transactions are kept in the order of their ids, so the latest one is the last match.
+	for i := len(s.transactions) - 1; i >= 0; i-- {
+		if s.transactions[i].SocketID == socketId {
+			return clone(s.transactions[i])
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) UpdateTransactionMeter(_ context.Context, transactionId int, meterWh int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tx := range s.transactions {
+		if tx.ID == transactionId {
+			tx.MeterValue = meterWh
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) DeleteTransaction(_ context.Context, transactionId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, tx := range s.transactions {
+		if tx.ID == transactionId {
+			s.transactions = append(s.transactions[:i], s.transactions[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) InsertTariff(_ context.Context, tariff *model.Tariff) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.tariffs {
+		if stored.ID == tariff.ID {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(tariff)
+	if err != nil {
+		return err
+	}
+	s.tariffs = append(s.tariffs, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetTariffById(_ context.Context, tariffId string) (*model.Tariff, error) {
+	oid, _ := primitive.ObjectIDFromHex(tariffId)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, tariff := range s.tariffs {
+		if tariff.ID == oid {
+			return clone(tariff)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) FindTariffsByFilter(_ context.Context, filter bson.M) ([]*model.Tariff, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return filterDocs(s.tariffs, filter)
+}
+
+func (s *MemoryStore) UpdateTariff(_ context.Context, tariff *model.Tariff) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, stored := range s.tariffs {
+		if stored.ID == tariff.ID {
+			updated, err := clone(tariff)
+			if err != nil {
+				return err
+			}
+			s.tariffs[i] = updated
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) DeleteTariff(_ context.Context, tariffId string) error {
+	oid, _ := primitive.ObjectIDFromHex(tariffId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, tariff := range s.tariffs {
+		if tariff.ID == oid {
+			s.tariffs = append(s.tariffs[:i], s.tariffs[i+1:]...)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) InsertEnergyPrice(_ context.Context, price *model.EnergyPrice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.energyPrices {
+		if stored.ID == price.ID {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(price)
+	if err != nil {
+		return err
+	}
+	s.energyPrices = append(s.energyPrices, stored)
+	return nil
+}
+
+func (s *MemoryStore) FindEnergyPricesByFilter(_ context.Context, filter bson.M) ([]*model.EnergyPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prices, err := filterDocs(s.energyPrices, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].EffectiveFrom.After(prices[j].EffectiveFrom)
+	})
+	return prices, nil
+}
+
+func (s *MemoryStore) DeleteEnergyPrice(_ context.Context, priceId string) error {
+	oid, _ := primitive.ObjectIDFromHex(priceId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, price := range s.energyPrices {
+		if price.ID == oid {
+			s.energyPrices = append(s.energyPrices[:i], s.energyPrices[i+1:]...)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) userByEmail(email string) *model.User {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) userById(id primitive.ObjectID) *model.User {
+	for _, user := range s.users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) stationById(id primitive.ObjectID) *model.Station {
+	for _, station := range s.stations {
+		if station.ID == id {
+			return station
+		}
+	}
+	return nil
+}
+
+// distanceKm returns the great-circle distance between two points.
+func distanceKm(lat1, long1, lat2, long2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLong := toRadians(long2 - long1)
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Pow(math.Sin(dLong/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func (s *MemoryStore) InsertReview(_ context.Context, review *model.Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.reviews {
+		if stored.ID == review.ID || stored.StationID == review.StationID && stored.UserID == review.UserID {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(review)
+	if err != nil {
+		return err
+	}
+	s.reviews = append(s.reviews, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetReviewById(_ context.Context, reviewId string) (*model.Review, error) {
+	oid, _ := primitive.ObjectIDFromHex(reviewId)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, review := range s.reviews {
+		if review.ID == oid {
+			return clone(review)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) FindReviewsByFilter(_ context.Context, filter bson.M) ([]*model.Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews, err := filterDocs(s.reviews, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
+
+func (s *MemoryStore) UpdateReview(_ context.Context, review *model.Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, stored := range s.reviews {
+		if stored.ID == review.ID {
+			updated, err := clone(review)
+			if err != nil {
+				return err
+			}
+			s.reviews[i] = updated
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) DeleteReview(_ context.Context, reviewId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, review := range s.reviews {
+		if review.ID == reviewId {
+			s.reviews = append(s.reviews[:i], s.reviews[i+1:]...)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) SetStationRating(_ context.Context, stationId primitive.ObjectID, rating *model.StationRating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	station := s.stationById(stationId)
+	if station == nil {
+		return nil
+	}
+	if rating == nil {
+		station.Rating = nil
+		return nil
+	}
+	stored, err := clone(rating)
+	if err != nil {
+		return err
+	}
+	station.Rating = stored
+	return nil
+}
+
+func (s *MemoryStore) InsertIssueReport(_ context.Context, report *model.IssueReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.issueReports {
+		if stored.ID == report.ID {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(report)
+	if err != nil {
+		return err
+	}
+	s.issueReports = append(s.issueReports, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetIssueReportById(_ context.Context, reportId string) (*model.IssueReport, error) {
+	oid, _ := primitive.ObjectIDFromHex(reportId)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, report := range s.issueReports {
+		if report.ID == oid {
+			return clone(report)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) FindIssueReportsByFilter(_ context.Context, filter bson.M) ([]*model.IssueReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reports, err := filterDocs(s.issueReports, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].CreatedAt.After(reports[j].CreatedAt)
+	})
+	return reports, nil
+}
+
+func (s *MemoryStore) CloseIssueReport(_ context.Context, reportId primitive.ObjectID, status model.IssueStatus, closedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, report := range s.issueReports {
+		if report.ID == reportId && report.Status == model.IssueOpen {
+			closedAt := closedAt.UTC()
+			report.Status = status
+			report.ClosedAt = &closedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) InsertImportJob(_ context.Context, job *model.ImportJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.importJobs {
+		if stored.ID == job.ID {
+			return ErrDuplicateKey
+		}
+	}
+	stored, err := clone(job)
+	if err != nil {
+		return err
+	}
+	s.importJobs = append(s.importJobs, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetImportJobById(_ context.Context, jobId string) (*model.ImportJob, error) {
+	oid, _ := primitive.ObjectIDFromHex(jobId)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, job := range s.importJobs {
+		if job.ID == oid {
+			return clone(job)
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *MemoryStore) FindImportJobsByFilter(_ context.Context, filter bson.M) ([]*model.ImportJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs, err := filterDocs(s.importJobs, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"testing"
+
+	"california/pkg/repository"
+	"california/pkg/repository/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) repository.Store {
+		return repository.NewMemoryStore()
+	})
+}
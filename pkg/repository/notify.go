@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// subscriptionBuffer is the number of events kept for a subscriber which is not reading fast enough.
+// Further events are dropped for that subscriber rather than slowing the writes down.
+const subscriptionBuffer = 64
+
+// StationNotifier is implemented by stores which publish the changes made to stations.
+type StationNotifier interface {
+	// SubscribeStations returns the events of the changes made from now on. The channel is
+	// closed once the context is done.
+	SubscribeStations(ctx context.Context) <-chan *model.StationEvent
+}
+
+var _ StationNotifier = (*NotifyingStore)(nil)
+
+// NotifyingStore wraps a Store and publishes an event for every station and socket change made through it.
+// Events only cover the writes of this process; writes made directly to the database are not seen.
+type NotifyingStore struct {
+	Store
+
+	mu          sync.Mutex
+	lastID      uint64
+	subscribers map[chan *model.StationEvent]struct{}
+}
+
+func NewNotifyingStore(store Store) *NotifyingStore {
+	return &NotifyingStore{
+		Store:       store,
+		subscribers: make(map[chan *model.StationEvent]struct{}),
+	}
+}
+
+func (s *NotifyingStore) SubscribeStations(ctx context.Context) <-chan *model.StationEvent {
+	ch := make(chan *model.StationEvent, subscriptionBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		close(ch)
+		s.mu.Unlock()
+	}()
+	return ch
+}
+
+func (s *NotifyingStore) publish(event *model.StationEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	event.ID = s.lastID
+	event.Time = time.Now().UTC()
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// publishStation reloads the station and publishes its current state.
+func (s *NotifyingStore) publishStation(ctx context.Context, eventType model.StationEventType, stationId string) {
+	station, err := s.Store.GetStationById(ctx, stationId)
+	if err != nil {
+		return
+	}
+	s.publish(&model.StationEvent{Type: eventType, StationID: station.ID, Station: station})
+}
+
+// stationsWithSocket returns the stations the socket is attached to.
+func (s *NotifyingStore) stationsWithSocket(ctx context.Context, socketId string) []*model.Station {
+	oid, _ := primitive.ObjectIDFromHex(socketId)
+	stations, err := s.Store.FindStationByFilter(ctx, bson.M{"Sockets._id": oid})
+	if err != nil {
+		return nil
+	}
+	return stations
+}
+
+func (s *NotifyingStore) InsertStation(ctx context.Context, station *model.Station) (*model.Station, error) {
+	inserted, err := s.Store.InsertStation(ctx, station)
+	if err != nil {
+		return nil, err
+	}
+	s.publishStation(ctx, model.StationCreated, inserted.ID.Hex())
+	return inserted, nil
+}
+
+func (s *NotifyingStore) UpdateStationInfo(ctx context.Context, station *model.Station, stationId string) error {
+	if err := s.Store.UpdateStationInfo(ctx, station, stationId); err != nil {
+		return err
+	}
+	s.publishStation(ctx, model.StationUpdated, stationId)
+	return nil
+}
+
+func (s *NotifyingStore) DeleteStation(ctx context.Context, stationId string) error {
+	station, err := s.Store.GetStationById(ctx, stationId)
+	if err != nil {
+		station = nil
+	}
+	if err = s.Store.DeleteStation(ctx, stationId); err != nil {
+		return err
+	}
+	if station != nil {
+		s.publish(&model.StationEvent{Type: model.StationDeleted, StationID: station.ID, Station: station})
+	}
+	return nil
+}
+
+func (s *NotifyingStore) PushSocketToStation(ctx context.Context, station *model.Station, socket model.Socket) error {
+	if err := s.Store.PushSocketToStation(ctx, station, socket); err != nil {
+		return err
+	}
+	s.publishStation(ctx, model.StationUpdated, station.ID.Hex())
+	return nil
+}
+
+func (s *NotifyingStore) DeleteSocket(ctx context.Context, socketId string) error {
+	stations := s.stationsWithSocket(ctx, socketId)
+	if err := s.Store.DeleteSocket(ctx, socketId); err != nil {
+		return err
+	}
+	for _, station := range stations {
+		s.publishStation(ctx, model.StationUpdated, station.ID.Hex())
+	}
+	return nil
+}
+
+func (s *NotifyingStore) UpdateSocketStatus(ctx context.Context, socketId string, status model.SocketStatus) error {
+	if err := s.Store.UpdateSocketStatus(ctx, socketId, status); err != nil {
+		return err
+	}
+	for _, station := range s.stationsWithSocket(ctx, socketId) {
+		s.publish(&model.StationEvent{
+			Type:      model.SocketStatusChanged,
+			StationID: station.ID,
+			SocketID:  socketId,
+			Status:    &status,
+			Station:   station,
+		})
+	}
+	return nil
+}
+
+func (s *NotifyingStore) SetSocketSuspected(ctx context.Context, socketId primitive.ObjectID, suspected bool) error {
+	if err := s.Store.SetSocketSuspected(ctx, socketId, suspected); err != nil {
+		return err
+	}
+	for _, station := range s.stationsWithSocket(ctx, socketId.Hex()) {
+		s.publish(&model.StationEvent{Type: model.StationUpdated, StationID: station.ID, Station: station})
+	}
+	return nil
+}
+
+func (s *NotifyingStore) SetStationRating(ctx context.Context, stationId primitive.ObjectID, rating *model.StationRating) error {
+	if err := s.Store.SetStationRating(ctx, stationId, rating); err != nil {
+		return err
+	}
+	s.publishStation(ctx, model.StationUpdated, stationId.Hex())
+	return nil
+}
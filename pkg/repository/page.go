@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+
+	// sortKeyField holds the value a page is ordered by while the page is read from MongoDB.
+	sortKeyField = "_sortKey"
+	// maxSortValue is the price of the items without one, which come last.
+	maxSortValue = 1e18
+)
+
+// SortKey is the order of the items of a page. Every order is stable: items with the same value are ordered by id.
+type SortKey string
+
+const (
+	SortDefault  SortKey = ""         // The insertion order.
+	SortBrand    SortKey = "brand"    // Station brand, A to Z.
+	SortDistance SortKey = "distance" // Distance to the Near point of the page, closest first.
+	SortPrice    SortKey = "price"    // Price per kWh, cheapest first. The cheapest socket is used for stations.
+	SortPower    SortKey = "power"    // Charging power, most powerful first. The most powerful socket is used for stations.
+)
+
+var (
+	ErrInvalidCursor   = errors.New("invalid page cursor")
+	ErrInvalidSort     = errors.New("invalid sort order")
+	ErrInvalidLimit    = errors.New("invalid page limit")
+	ErrInvalidLocation = errors.New("invalid location")
+)
+
+// Page asks for at most Limit items following the item the After cursor points to.
+// After is the NextCursor of the previous page; the first page is returned when it is empty.
+type Page struct {
+	Limit int
+	After string
+	Sort  SortKey
+	Near  *model.Coordinate // Required by SortDistance.
+}
+
+// ParsePage reads a page from the limit, after, sort, lat and long query parameters.
+func ParsePage(query url.Values) (Page, error) {
+	var page Page
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return Page{}, ErrInvalidLimit
+		}
+		page.Limit = n
+	}
+	page.After = query.Get("after")
+	page.Sort = SortKey(strings.ToLower(query.Get("sort")))
+	if query.Get("lat") != "" || query.Get("long") != "" {
+		lat, errLat := strconv.ParseFloat(query.Get("lat"), 64)
+		long, errLong := strconv.ParseFloat(query.Get("long"), 64)
+		if errLat != nil || errLong != nil || lat < -90 || lat > 90 || long < -180 || long > 180 {
+			return Page{}, ErrInvalidLocation
+		}
+		page.Near = &model.Coordinate{Lat: lat, Long: long}
+	}
+	return page, nil
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	return min(p.Limit, MaxPageLimit)
+}
+
+// pageCursor is the position of the last item of a page. Key is a string or a float64, depending on the order.
+type pageCursor struct {
+	Key interface{}        `json:"k"`
+	ID  primitive.ObjectID `json:"id"`
+}
+
+func encodeCursor(key interface{}, id primitive.ObjectID) string {
+	raw, _ := json.Marshal(pageCursor{Key: key, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err = json.Unmarshal(raw, &c); err != nil || c.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	switch c.Key.(type) {
+	case nil, string, float64:
+		return &c, nil
+	}
+	return nil, ErrInvalidCursor
+}
+
+// pageOrder is how a page is ordered: by the value of expr, then by the id field.
+// Items missing the value are ordered as if they had the value of missing.
+type pageOrder struct {
+	idField string
+	expr    interface{}
+	missing interface{}
+	desc    bool
+}
+
+// pageStages returns the pipeline stages which sort the documents and skip those up to the cursor.
+// One more document than the limit is asked for, to know whether there is a next page.
+func pageStages(order pageOrder, after *pageCursor, limit int) (mongo.Pipeline, error) {
+	if order.expr == nil {
+		stages := mongo.Pipeline{}
+		if after != nil {
+			stages = append(stages, bson.D{{Key: "$match", Value: bson.M{order.idField: bson.M{"$gt": after.ID}}}})
+		}
+		return append(stages,
+			bson.D{{Key: "$sort", Value: bson.D{{Key: order.idField, Value: 1}}}},
+			bson.D{{Key: "$limit", Value: limit + 1}},
+		), nil
+	}
+
+	stages := mongo.Pipeline{
+		{{Key: "$addFields", Value: bson.M{sortKeyField: bson.M{"$ifNull": bson.A{order.expr, order.missing}}}}},
+	}
+	if after != nil {
+		if after.Key == nil {
+			return nil, ErrInvalidCursor
+		}
+		beyond := "$gt"
+		if order.desc {
+			beyond = "$lt"
+		}
+		stages = append(stages, bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{sortKeyField: bson.M{beyond: after.Key}},
+			bson.M{sortKeyField: after.Key, order.idField: bson.M{"$gt": after.ID}},
+		}}}})
+	}
+	direction := 1
+	if order.desc {
+		direction = -1
+	}
+	return append(stages,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: sortKeyField, Value: direction}, {Key: order.idField, Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit + 1}},
+		bson.D{{Key: "$project", Value: bson.M{sortKeyField: 0}}},
+	), nil
+}
+
+// stationOrder returns the order of a page of stations.
+func stationOrder(page Page) (pageOrder, error) {
+	order := pageOrder{idField: "_id"}
+	switch page.Sort {
+	case SortDefault:
+	case SortBrand:
+		order.expr, order.missing = "$Brand", ""
+	case SortDistance:
+		if page.Near == nil {
+			return pageOrder{}, ErrInvalidSort
+		}
+		order.expr, order.missing = "$Distance", 0.0
+	case SortPrice:
+		// Stations without sockets come last.
+		order.expr, order.missing = bson.M{"$min": "$Sockets.Price"}, maxSortValue
+	case SortPower:
+		order.expr, order.missing, order.desc = bson.M{"$max": "$Sockets.KW"}, 0.0, true
+	default:
+		return pageOrder{}, ErrInvalidSort
+	}
+	return order, nil
+}
+
+// socketOrder returns the order of a page of sockets.
+func socketOrder(page Page) (pageOrder, error) {
+	order := pageOrder{idField: "_id"}
+	switch page.Sort {
+	case SortDefault:
+	case SortPrice:
+		order.expr, order.missing = "$Price", maxSortValue
+	case SortPower:
+		order.expr, order.missing, order.desc = "$KW", 0.0, true
+	default:
+		return pageOrder{}, ErrInvalidSort
+	}
+	return order, nil
+}
+
+// userOrder returns the order of a page of users, which are only listed in their insertion order.
+func userOrder(page Page) (pageOrder, error) {
+	if page.Sort != SortDefault {
+		return pageOrder{}, ErrInvalidSort
+	}
+	return pageOrder{idField: "id"}, nil
+}
+
+// stationSortKey returns the value of a station the page is ordered by, as MongoDB computes it.
+func stationSortKey(station *model.Station, page Page) interface{} {
+	switch page.Sort {
+	case SortBrand:
+		return station.Brand
+	case SortDistance:
+		return station.Distance
+	case SortPrice:
+		if len(station.Sockets) == 0 {
+			return maxSortValue
+		}
+		price := station.Sockets[0].Price
+		for _, socket := range station.Sockets[1:] {
+			price = min(price, socket.Price)
+		}
+		return price
+	case SortPower:
+		power := 0.0
+		for _, socket := range station.Sockets {
+			power = max(power, socket.KW)
+		}
+		return power
+	}
+	return nil
+}
+
+func socketSortKey(socket *model.Socket, page Page) interface{} {
+	switch page.Sort {
+	case SortPrice:
+		return socket.Price
+	case SortPower:
+		return socket.KW
+	}
+	return nil
+}
+
+// pageOf orders the values the way pageStages does and returns the page following the cursor, with the cursor of the next one.
+func pageOf[T any](values []*T, page Page, order pageOrder, id func(*T) primitive.ObjectID, key func(*T) interface{}) ([]*T, string, error) {
+	after, err := decodeCursor(page.After)
+	if err != nil {
+		return nil, "", err
+	}
+	if after != nil && order.expr != nil && after.Key == nil {
+		return nil, "", ErrInvalidCursor
+	}
+
+	// less reports whether the position (ka, ia) comes before (kb, ib).
+	less := func(ka interface{}, ia primitive.ObjectID, kb interface{}, ib primitive.ObjectID) bool {
+		if order.expr != nil {
+			if c := compareSortKeys(ka, kb); c != 0 {
+				return (c < 0) != order.desc
+			}
+		}
+		return ia.Hex() < ib.Hex()
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return less(key(values[i]), id(values[i]), key(values[j]), id(values[j]))
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(values), func(i int) bool {
+			return less(after.Key, after.ID, key(values[i]), id(values[i]))
+		})
+	}
+	return nextPage(values[start:], page.limit(), id, key)
+}
+
+// nextPage cuts the values to the limit and returns the cursor of the next page, if there are more values.
+func nextPage[T any](values []*T, limit int, id func(*T) primitive.ObjectID, key func(*T) interface{}) ([]*T, string, error) {
+	if len(values) <= limit {
+		return values, "", nil
+	}
+	values = values[:limit]
+	last := values[limit-1]
+	return values, encodeCursor(key(last), id(last)), nil
+}
+
+// findPage reads a page of documents through the given first stages of an aggregation, which select them.
+func findPage[T any](ctx context.Context, coll *mongo.Collection, first mongo.Pipeline, page Page, order pageOrder, id func(*T) primitive.ObjectID, key func(*T) interface{}) ([]*T, string, error) {
+	after, err := decodeCursor(page.After)
+	if err != nil {
+		return nil, "", err
+	}
+	stages, err := pageStages(order, after, page.limit())
+	if err != nil {
+		return nil, "", err
+	}
+
+	cursor, err := coll.Aggregate(ctx, append(first, stages...))
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+	var values []*T
+	for cursor.Next(ctx) {
+		var v T
+		if err := cursor.Decode(&v); err != nil {
+			return nil, "", err
+		}
+		values = append(values, &v)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, "", err
+	}
+	return nextPage(values, page.limit(), id, key)
+}
+
+// compareSortKeys compares two strings or two numbers.
+func compareSortKeys(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	case float64:
+		b, _ := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+func stationId(station *model.Station) primitive.ObjectID { return station.ID }
+func socketId(socket *model.Socket) primitive.ObjectID    { return socket.ID }
+func userId(user *model.User) primitive.ObjectID          { return user.ID }
+
+func noSortKey[T any](*T) interface{} { return nil }
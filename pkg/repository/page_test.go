@@ -0,0 +1,47 @@
+package repository_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"california/pkg/repository"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    repository.Page
+		wantErr error
+	}{
+		{query: "", want: repository.Page{}},
+		{query: "limit=10&after=abc&sort=Price", want: repository.Page{Limit: 10, After: "abc", Sort: repository.SortPrice}},
+		{query: "limit=0", wantErr: repository.ErrInvalidLimit},
+		{query: "limit=ten", wantErr: repository.ErrInvalidLimit},
+		{query: "sort=distance&lat=41&long=29", want: repository.Page{Sort: repository.SortDistance}},
+		{query: "sort=distance&lat=91&long=29", wantErr: repository.ErrInvalidLocation},
+		{query: "sort=distance&lat=41&long=-181", wantErr: repository.ErrInvalidLocation},
+		{query: "sort=distance&lat=41", wantErr: repository.ErrInvalidLocation},
+		{query: "sort=distance&lat=north&long=29", wantErr: repository.ErrInvalidLocation},
+	}
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := repository.ParsePage(query)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParsePage(%q): got %v, want %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if page.Limit != tt.want.Limit || page.After != tt.want.After || page.Sort != tt.want.Sort {
+			t.Errorf("ParsePage(%q) = %+v, want %+v", tt.query, page, tt.want)
+		}
+		if tt.want.Sort == repository.SortDistance && (page.Near == nil || page.Near.Lat != 41 || page.Near.Long != 29) {
+			t.Errorf("ParsePage(%q) near %+v, want 41, 29", tt.query, page.Near)
+		}
+	}
+}
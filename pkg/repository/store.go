@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"california/internal/config"
 	"california/internal/helpers"
+	"california/pkg/auth"
 	"california/pkg/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,10 +22,21 @@ type Store interface {
 	InsertUser(ctx context.Context, user *model.User) (*model.User, error)
 	UserExists(ctx context.Context, email string) (bool, error)
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	GetUserById(ctx context.Context, userId string) (*model.User, error)
+	// InsertVehicleToUser adds the vehicle to the garage of the user, where it replaces a vehicle registered before the garage.
 	InsertVehicleToUser(ctx context.Context, user *model.User, vehicle *model.Vehicle) error
+	// UnsetLegacyVehicle removes the vehicle registered before the garage, like the empty one of the users who had none.
+	UnsetLegacyVehicle(ctx context.Context, email string) error
 	FindUsersByFilter(ctx context.Context, filter bson.M) ([]*model.User, error)
 	UpdateUser(ctx context.Context, reqUser *model.User) error
+	// UpdateVehicle, DeleteVehicle and SetDefaultVehicle change the garage of the user in the context.
+	// They return mongo.ErrNoDocuments when the user has no vehicle with the given id.
 	UpdateVehicle(ctx context.Context, reqVehicle *model.Vehicle) error
+	DeleteVehicle(ctx context.Context, vehicleId primitive.ObjectID) error
+	SetDefaultVehicle(ctx context.Context, vehicleId primitive.ObjectID) error
+	// SetFavoriteStations and SetPlaces replace the favourite stations and the places of the user in the context.
+	SetFavoriteStations(ctx context.Context, stationIds []primitive.ObjectID) error
+	SetPlaces(ctx context.Context, places []model.Place) error
 	GetAllUsers(ctx context.Context) ([]*model.User, error)
 	DeleteUser(ctx context.Context, email string) error
 
@@ -36,18 +49,191 @@ type Store interface {
 	FindStationByFilter(ctx context.Context, filter bson.M) ([]*model.Station, error)
 	PushSocketToStation(ctx context.Context, station *model.Station, socket model.Socket) error
 	DeleteSocket(ctx context.Context, socketId string) error
+	// SetChargePointKey replaces the hash of the OCPP key of the station's charge point.
+	// It returns mongo.ErrNoDocuments when there is no such station.
+	SetChargePointKey(ctx context.Context, stationId primitive.ObjectID, keyHash string) error
 
 	// These are the socket related methods.
 	InsertSocket(ctx context.Context, socket *model.Socket) error
 	ListSockets(ctx context.Context) ([]*model.Socket, error)
+	// UpdateSocketStatus sets the status of the socket, both in the sockets collection and inside its station.
+	UpdateSocketStatus(ctx context.Context, socketId string, status model.SocketStatus) error
+	// ClaimSocket only changes the status of a socket which is in one of the from statuses inside its station,
+	// and reports whether it did, so that a single caller wins the socket.
+	ClaimSocket(ctx context.Context, socketId primitive.ObjectID, from []model.SocketStatus, to model.SocketStatus) (bool, error)
+	// SetSocketSuspected marks the socket as suspected broken or clears the mark, both in the sockets collection and inside its station.
+	SetSocketSuspected(ctx context.Context, socketId primitive.ObjectID, suspected bool) error
 	FilterStations(ctx context.Context, filter bson.M) ([]*model.Station, error)
+
+	// FindStationsPage, FindSocketsPage and FindUsersPage return a page of the documents matching the filter
+	// and the cursor of the next page, which is empty on the last page.
+	FindStationsPage(ctx context.Context, filter bson.M, page Page) ([]*model.Station, string, error)
+	FindSocketsPage(ctx context.Context, filter bson.M, page Page) ([]*model.Socket, string, error)
+	FindUsersPage(ctx context.Context, filter bson.M, page Page) ([]*model.User, string, error)
+
+	// FindStationsNear returns the stations within maxDistanceKm of the given point,
+	// closest first, with their Distance field set in kilometers.
+	FindStationsNear(ctx context.Context, point model.Coordinate, maxDistanceKm float64, limit int) ([]*model.Station, error)
+
+	// These are the refresh token related methods.
+	InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	// RevokeRefreshToken reports false when the token had already been revoked.
+	RevokeRefreshToken(ctx context.Context, tokenId primitive.ObjectID) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyId primitive.ObjectID) error
+	// RevokeUserRefreshTokens ends all the sessions of the user.
+	RevokeUserRefreshTokens(ctx context.Context, userId primitive.ObjectID) error
+
+	// These are the methods of the tokens mailed to the users.
+	InsertUserToken(ctx context.Context, token *model.UserToken) error
+	GetUserTokenByHash(ctx context.Context, tokenHash string) (*model.UserToken, error)
+	// UseUserToken reports false when the token had already been used.
+	UseUserToken(ctx context.Context, tokenId primitive.ObjectID) (bool, error)
+	// SetUserPassword and SetUserEmailVerified return mongo.ErrNoDocuments when there is no such user.
+	SetUserPassword(ctx context.Context, userId primitive.ObjectID, passwordHash string) error
+	SetUserEmailVerified(ctx context.Context, userId primitive.ObjectID) error
+	// FailUserToken counts a wrong code given with the token and returns the number of them.
+	FailUserToken(ctx context.Context, tokenId primitive.ObjectID) (int, error)
+
+	// These are the failed login related methods.
+	GetLoginAttempts(ctx context.Context, key string) (*model.LoginAttempts, error)
+	// RecordLoginFailure counts a failed login at the given time and returns the updated counts.
+	// The failures are counted from zero again when the last one is older than window.
+	RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*model.LoginAttempts, error)
+	// LockLogin refuses the logins until the given time and counts the failures from zero again.
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	// ResetLoginAttempts forgets the failures and lifts the lock.
+	ResetLoginAttempts(ctx context.Context, key string) error
+
+	// These are the two-factor authentication related methods.
+	// SetTwoFactor replaces the two-factor authentication of the user, or removes it when it is nil.
+	// It returns mongo.ErrNoDocuments when there is no such user.
+	SetTwoFactor(ctx context.Context, userId primitive.ObjectID, twoFactor *model.TwoFactor) error
+	// UseTwoFactorStep records the time step of an accepted code. It reports false when the step is not
+	// after the last one, i.e. when the code was already used.
+	UseTwoFactorStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error)
+	// UseRecoveryCode removes the recovery code and reports false when the user did not have it.
+	UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) (bool, error)
+
+	// These are the reservation related methods.
+	// InsertReservation returns ErrReservationConflict when the socket is already held for an overlapping window.
+	InsertReservation(ctx context.Context, reservation *model.Reservation) error
+	GetReservationById(ctx context.Context, reservationId string) (*model.Reservation, error)
+	FindReservationsByFilter(ctx context.Context, filter bson.M) ([]*model.Reservation, error)
+	// UpdateReservationStatus only changes a reservation which is still in the from status and reports whether it did.
+	UpdateReservationStatus(ctx context.Context, reservationId primitive.ObjectID, from, to model.ReservationStatus) (bool, error)
+
+	// These are the charging session related methods.
+	// InsertChargingSession returns ErrChargingSessionConflict when the socket or the user already has a session in progress.
+	InsertChargingSession(ctx context.Context, session *model.ChargingSession) error
+	GetChargingSessionById(ctx context.Context, sessionId string) (*model.ChargingSession, error)
+	// FindChargingSessionsByFilter returns the matching sessions, the most recent first.
+	FindChargingSessionsByFilter(ctx context.Context, filter bson.M) ([]*model.ChargingSession, error)
+	// CompleteChargingSession stores the result of a session which is still in progress and reports whether it was.
+	CompleteChargingSession(ctx context.Context, session *model.ChargingSession) (bool, error)
+	// SettleChargingSession replaces the energy and the cost of a session completed while its transaction was
+	// still metering it, and reports whether the session was completed with that transaction.
+	SettleChargingSession(ctx context.Context, session *model.ChargingSession) (bool, error)
+
+	// These are the OCPP transaction related methods.
+	// InsertTransaction stores the transaction under the next transaction id, which is never handed out twice,
+	// even after the transactions holding the previous ids were deleted.
+	InsertTransaction(ctx context.Context, tx *model.Transaction) error
+	// GetTransactionById and GetTransactionBySocket return mongo.ErrNoDocuments when there is no such transaction.
+	// GetTransactionBySocket returns the latest transaction of the socket.
+	GetTransactionById(ctx context.Context, transactionId int) (*model.Transaction, error)
+	GetTransactionBySocket(ctx context.Context, socketId primitive.ObjectID) (*model.Transaction, error)
+	// UpdateTransactionMeter stores the latest meter reading of the transaction, in Wh.
+	UpdateTransactionMeter(ctx context.Context, transactionId int, meterWh int) error
+	DeleteTransaction(ctx context.Context, transactionId int) error
+
+	// These are the tariff related methods.
+	InsertTariff(ctx context.Context, tariff *model.Tariff) error
+	GetTariffById(ctx context.Context, tariffId string) (*model.Tariff, error)
+	FindTariffsByFilter(ctx context.Context, filter bson.M) ([]*model.Tariff, error)
+	// UpdateTariff and DeleteTariff return mongo.ErrNoDocuments when there is no such tariff.
+	UpdateTariff(ctx context.Context, tariff *model.Tariff) error
+	DeleteTariff(ctx context.Context, tariffId string) error
+
+	// These are the energy price related methods.
+	InsertEnergyPrice(ctx context.Context, price *model.EnergyPrice) error
+	// FindEnergyPricesByFilter returns the latest versions first.
+	FindEnergyPricesByFilter(ctx context.Context, filter bson.M) ([]*model.EnergyPrice, error)
+	DeleteEnergyPrice(ctx context.Context, priceId string) error
+
+	// These are the review related methods.
+	// InsertReview returns ErrDuplicateKey when the user has already reviewed the station.
+	InsertReview(ctx context.Context, review *model.Review) error
+	GetReviewById(ctx context.Context, reviewId string) (*model.Review, error)
+	// FindReviewsByFilter returns the matching reviews, the most recent first.
+	FindReviewsByFilter(ctx context.Context, filter bson.M) ([]*model.Review, error)
+	// UpdateReview and DeleteReview return mongo.ErrNoDocuments when there is no such review.
+	UpdateReview(ctx context.Context, review *model.Review) error
+	DeleteReview(ctx context.Context, reviewId primitive.ObjectID) error
+	// SetStationRating stores the rating of the station, or removes it when rating is nil.
+	SetStationRating(ctx context.Context, stationId primitive.ObjectID, rating *model.StationRating) error
+
+	// These are the issue report related methods.
+	InsertIssueReport(ctx context.Context, report *model.IssueReport) error
+	GetIssueReportById(ctx context.Context, reportId string) (*model.IssueReport, error)
+	// FindIssueReportsByFilter returns the matching reports, the most recent first.
+	FindIssueReportsByFilter(ctx context.Context, filter bson.M) ([]*model.IssueReport, error)
+	// CloseIssueReport only changes a report which is still open and reports whether it did.
+	CloseIssueReport(ctx context.Context, reportId primitive.ObjectID, status model.IssueStatus, closedAt time.Time) (bool, error)
+
+	// These are the station import related methods.
+	InsertImportJob(ctx context.Context, job *model.ImportJob) error
+	GetImportJobById(ctx context.Context, jobId string) (*model.ImportJob, error)
+	// FindImportJobsByFilter returns the matching jobs, the most recent first.
+	FindImportJobsByFilter(ctx context.Context, filter bson.M) ([]*model.ImportJob, error)
+}
+
+var (
+	ErrReservationConflict     = errors.New("socket is already reserved for this time")
+	ErrChargingSessionConflict = errors.New("a charging session is already in progress")
+)
+
+// overlappingReservations matches the reservations holding the socket for some of the [startsAt, endsAt) window.
+func overlappingReservations(socketId primitive.ObjectID, startsAt, endsAt time.Time) bson.M {
+	return bson.M{
+		"SocketID": socketId,
+		"Status":   bson.M{"$in": bson.A{model.ReservationActive, model.ReservationFulfilled}},
+		"StartsAt": bson.M{"$lt": endsAt},
+		"EndsAt":   bson.M{"$gt": startsAt},
+	}
+}
+
+var (
+	_ Store = (*MongoStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*NotifyingStore)(nil)
+)
+
+// NewStore returns the store selected by the configuration. MongoDB is used unless
+// the memory driver is asked for, in which case nothing is persisted.
+func NewStore(cfg *config.Config) Store {
+	if cfg.StoreDriver == "memory" {
+		return NewMemoryStore()
+	}
+	return NewMongoStore(cfg)
 }
 
 type MongoStore struct {
-	Client       *mongo.Client
-	UsersColl    *mongo.Collection
-	StationsColl *mongo.Collection
-	SocketsColl  *mongo.Collection
+	Client            *mongo.Client
+	UsersColl         *mongo.Collection
+	StationsColl      *mongo.Collection
+	SocketsColl       *mongo.Collection
+	RefreshTokensColl *mongo.Collection
+	ReservationsColl  *mongo.Collection
+	SessionsColl      *mongo.Collection
+	TariffsColl       *mongo.Collection
+	EnergyPricesColl  *mongo.Collection
+	ReviewsColl       *mongo.Collection
+	IssueReportsColl  *mongo.Collection
+	ImportJobsColl    *mongo.Collection
+	UserTokensColl    *mongo.Collection
+	LoginAttemptsColl *mongo.Collection
+	TransactionsColl  *mongo.Collection
 }
 
 func NewMongoStore(cfg *config.Config) *MongoStore {
@@ -55,12 +241,207 @@ func NewMongoStore(cfg *config.Config) *MongoStore {
 	userColl := GetCollection(client, cfg.DatabaseName, cfg.UsersCollectionName)
 	stationsColl := GetCollection(client, cfg.DatabaseName, cfg.StationsCollectionName)
 	socketsColl := GetCollection(client, cfg.DatabaseName, cfg.SocketsCollectionName)
-	return &MongoStore{
-		Client:       client,
-		UsersColl:    userColl,
-		StationsColl: stationsColl,
-		SocketsColl:  socketsColl,
+	refreshTokensColl := GetCollection(client, cfg.DatabaseName, cfg.RefreshTokensCollectionName)
+	reservationsColl := GetCollection(client, cfg.DatabaseName, cfg.ReservationsCollectionName)
+	sessionsColl := GetCollection(client, cfg.DatabaseName, cfg.SessionsCollectionName)
+	tariffsColl := GetCollection(client, cfg.DatabaseName, cfg.TariffsCollectionName)
+	energyPricesColl := GetCollection(client, cfg.DatabaseName, cfg.EnergyPricesCollectionName)
+	reviewsColl := GetCollection(client, cfg.DatabaseName, cfg.ReviewsCollectionName)
+	issueReportsColl := GetCollection(client, cfg.DatabaseName, cfg.IssueReportsCollectionName)
+	importJobsColl := GetCollection(client, cfg.DatabaseName, cfg.ImportJobsCollectionName)
+	userTokensColl := GetCollection(client, cfg.DatabaseName, cfg.UserTokensCollectionName)
+	loginAttemptsColl := GetCollection(client, cfg.DatabaseName, cfg.LoginAttemptsCollectionName)
+	transactionsColl := GetCollection(client, cfg.DatabaseName, cfg.TransactionsCollectionName)
+	store := &MongoStore{
+		Client:            client,
+		UsersColl:         userColl,
+		StationsColl:      stationsColl,
+		SocketsColl:       socketsColl,
+		RefreshTokensColl: refreshTokensColl,
+		ReservationsColl:  reservationsColl,
+		SessionsColl:      sessionsColl,
+		TariffsColl:       tariffsColl,
+		EnergyPricesColl:  energyPricesColl,
+		ReviewsColl:       reviewsColl,
+		IssueReportsColl:  issueReportsColl,
+		ImportJobsColl:    importJobsColl,
+		UserTokensColl:    userTokensColl,
+		LoginAttemptsColl: loginAttemptsColl,
+		TransactionsColl:  transactionsColl,
+	}
+	if err := store.ensureStationLocations(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureRefreshTokenIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureUserTokenIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureLoginAttemptIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureReservationIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureSessionIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureTransactionIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureFeedbackIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.ensureImportIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	return store
+}
+
+// ensureRefreshTokenIndexes makes token lookups unique and lets Mongo remove the expired tokens.
+func (s *MongoStore) ensureRefreshTokenIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "TokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "FamilyID", Value: 1}}},
+		{Keys: bson.D{{Key: "UserID", Value: 1}}},
+		{Keys: bson.D{{Key: "ExpiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := s.RefreshTokensColl.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureUserTokenIndexes makes the lookups of the tokens mailed to the users unique and lets Mongo remove the expired tokens.
+func (s *MongoStore) ensureUserTokenIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "TokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "ExpiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := s.UserTokensColl.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureLoginAttemptIndexes lets Mongo forget the failed logins a day after the last one, which outlasts any lock.
+func (s *MongoStore) ensureLoginAttemptIndexes(ctx context.Context) error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "LastFailureAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32((24 * time.Hour).Seconds())),
+	}
+	if _, err := s.LoginAttemptsColl.Indexes().CreateOne(ctx, index); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureReservationIndexes supports the overlap checks of a socket and the listing of a user's reservations.
+func (s *MongoStore) ensureReservationIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "SocketID", Value: 1}, {Key: "StartsAt", Value: 1}}},
+		{Keys: bson.D{{Key: "UserID", Value: 1}, {Key: "StartsAt", Value: -1}}},
+	}
+	if _, err := s.ReservationsColl.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureSessionIndexes allows a single session in progress per socket and per user, and supports finding the
+// session an OCPP transaction metered.
+func (s *MongoStore) ensureSessionIndexes(ctx context.Context) error {
+	inProgress := bson.M{"Status": model.ChargingInProgress}
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "SocketID", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(inProgress).SetName("SocketID_in_progress")},
+		{Keys: bson.D{{Key: "UserID", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(inProgress).SetName("UserID_in_progress")},
+		{Keys: bson.D{{Key: "UserID", Value: 1}, {Key: "StartedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "TransactionID", Value: 1}}, Options: options.Index().SetSparse(true)},
+	}
+	if _, err := s.SessionsColl.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureTransactionIndexes supports finding the transaction in progress on a socket.
+func (s *MongoStore) ensureTransactionIndexes(ctx context.Context) error {
+	index := mongo.IndexModel{Keys: bson.D{{Key: "SocketID", Value: 1}}}
+	if _, err := s.TransactionsColl.Indexes().CreateOne(ctx, index); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureFeedbackIndexes allows a single review per user and station, and supports listing the recent reports of a socket.
+func (s *MongoStore) ensureFeedbackIndexes(ctx context.Context) error {
+	reviewIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "StationID", Value: 1}, {Key: "UserID", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "Status", Value: 1}, {Key: "CreatedAt", Value: -1}}},
+	}
+	if _, err := s.ReviewsColl.Indexes().CreateMany(ctx, reviewIndexes); err != nil {
+		return err
+	}
+	reportIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "SocketID", Value: 1}, {Key: "CreatedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "Status", Value: 1}, {Key: "CreatedAt", Value: -1}}},
+	}
+	if _, err := s.IssueReportsColl.Indexes().CreateMany(ctx, reportIndexes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureImportIndexes supports finding the imported stations by their id in the file and listing the recent imports.
+func (s *MongoStore) ensureImportIndexes(ctx context.Context) error {
+	stationIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ExternalID", Value: 1}}, Options: options.Index().SetSparse(true)},
+	}
+	if _, err := s.StationsColl.Indexes().CreateMany(ctx, stationIndexes); err != nil {
+		return err
+	}
+	jobIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "CreatedAt", Value: -1}}},
+	}
+	if _, err := s.ImportJobsColl.Indexes().CreateMany(ctx, jobIndexes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureStationLocations backfills the GeoJSON location of the stations inserted
+// before it was introduced and creates the 2dsphere index used by FindStationsNear.
+// Stations without valid coordinates are left without a location, which the index skips,
+// so that they cannot keep the services from starting.
+func (s *MongoStore) ensureStationLocations(ctx context.Context) error {
+	filter := bson.M{
+		"Location":  bson.M{"$exists": false},
+		"Latitude":  bson.M{"$type": "number", "$gte": -90, "$lte": 90},
+		"Longitude": bson.M{"$type": "number", "$gte": -180, "$lte": 180},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"Location": bson.M{
+			"type":        "Point",
+			"coordinates": bson.A{"$Longitude", "$Latitude"},
+		}}}},
+	}
+	if _, err := s.StationsColl.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+	skipped, err := s.StationsColl.CountDocuments(ctx, bson.M{"Location": bson.M{"$exists": false}})
+	if err != nil {
+		return err
 	}
+	if skipped > 0 {
+		log.Printf("%d stations have no valid coordinates, so they are left out of the nearby search", skipped)
+	}
+
+	index := mongo.IndexModel{Keys: bson.D{{Key: "Location", Value: "2dsphere"}}}
+	if _, err := s.StationsColl.Indexes().CreateOne(ctx, index); err != nil {
+		return err
+	}
+	return nil
 }
 
 func (s *MongoStore) InsertUser(_ context.Context, user *model.User) (*model.User, error) {
@@ -95,9 +476,22 @@ func (s *MongoStore) GetUserByEmail(_ context.Context, email string) (*model.Use
 	return &user, nil
 }
 
+func (s *MongoStore) GetUserById(ctx context.Context, userId string) (*model.User, error) {
+	var user model.User
+	oid, _ := primitive.ObjectIDFromHex(userId)
+	filter := bson.M{"id": oid}
+	err := s.UsersColl.FindOne(ctx, filter).Decode(&user)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (s *MongoStore) InsertVehicleToUser(_ context.Context, user *model.User, vehicle *model.Vehicle) error {
 	filter := bson.M{"Email": user.Email}
-	update := bson.M{"$set": bson.M{"Vehicle": vehicle}}
+	update := bson.M{"$push": bson.M{"Vehicles": vehicle}, "$unset": bson.M{"Vehicle": ""}}
 	_, err := s.UsersColl.UpdateOne(context.Background(), filter, update)
 	if err != nil {
 		return err
@@ -105,11 +499,16 @@ func (s *MongoStore) InsertVehicleToUser(_ context.Context, user *model.User, ve
 	return nil
 }
 
+func (s *MongoStore) UnsetLegacyVehicle(ctx context.Context, email string) error {
+	filter := bson.M{"Email": email}
+	update := bson.M{"$unset": bson.M{"Vehicle": ""}}
+	_, err := s.UsersColl.UpdateOne(ctx, filter, update)
+	return err
+}
+
 func (s *MongoStore) UpdateUser(ctx context.Context, reqUser *model.User) error {
-	userId := ctx.Value("userId").(string)
+	userId := auth.UserID(ctx)
 	oid, _ := primitive.ObjectIDFromHex(userId)
-	fmt.Println(oid)
-	fmt.Println(userId)
 
 	filter := bson.M{"id": oid}
 	update := bson.M{"$set": bson.M{"Name": reqUser.Name}}
@@ -129,15 +528,79 @@ func (s *MongoStore) UpdateUser(ctx context.Context, reqUser *model.User) error
 }
 
 func (s *MongoStore) UpdateVehicle(ctx context.Context, reqVehicle *model.Vehicle) error {
-	userId := ctx.Value("userId").(string)
+	userId := auth.UserID(ctx)
 	oid, _ := primitive.ObjectIDFromHex(userId)
 
-	filter := bson.M{"id": oid}
-	update := bson.M{"$set": bson.M{"Vehicle": reqVehicle}}
-	_, err := s.UsersColl.UpdateOne(context.Background(), filter, update)
+	filter := bson.M{"id": oid, "Vehicles.ID": reqVehicle.ID}
+	update := bson.M{"$set": bson.M{"Vehicles.$": reqVehicle}}
+	res, err := s.UsersColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) DeleteVehicle(ctx context.Context, vehicleId primitive.ObjectID) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	filter := bson.M{"id": oid, "Vehicles.ID": vehicleId}
+	update := bson.M{"$pull": bson.M{"Vehicles": bson.M{"ID": vehicleId}}}
+	res, err := s.UsersColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	// The first remaining vehicle becomes the default one.
+	filter = bson.M{"id": oid, "DefaultVehicleID": vehicleId}
+	update = bson.M{"$unset": bson.M{"DefaultVehicleID": ""}}
+	_, err = s.UsersColl.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (s *MongoStore) SetDefaultVehicle(ctx context.Context, vehicleId primitive.ObjectID) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	filter := bson.M{"id": oid, "Vehicles.ID": vehicleId}
+	update := bson.M{"$set": bson.M{"DefaultVehicleID": vehicleId}}
+	res, err := s.UsersColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) SetFavoriteStations(ctx context.Context, stationIds []primitive.ObjectID) error {
+	return s.setUserField(ctx, "FavoriteStations", stationIds)
+}
+
+func (s *MongoStore) SetPlaces(ctx context.Context, places []model.Place) error {
+	return s.setUserField(ctx, "Places", places)
+}
+
+// setUserField sets the field of the user in the context.
+func (s *MongoStore) setUserField(ctx context.Context, field string, value interface{}) error {
+	userId := auth.UserID(ctx)
+	oid, _ := primitive.ObjectIDFromHex(userId)
+
+	update := bson.M{"$set": bson.M{field: value}}
+	res, err := s.UsersColl.UpdateOne(ctx, bson.M{"id": oid}, update)
 	if err != nil {
 		return err
 	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
 	return nil
 }
 
@@ -228,6 +691,13 @@ func (s *MongoStore) UpdateStationInfo(ctx context.Context, station *model.Stati
 		"Distance":    station.Distance,
 		"Address":     station.Address,
 		"Sockets":     station.Sockets,
+		"Location":    station.Location,
+
+		"ChargePointID": station.ChargePointID,
+		"ExternalID":    station.ExternalID,
+		"OpeningHours":  station.OpeningHours,
+		"Amenities":     station.Amenities,
+		"Access":        station.Access,
 	}}
 	_, err := s.StationsColl.UpdateOne(context.Background(), filter, update)
 	if err != nil {
@@ -236,6 +706,17 @@ func (s *MongoStore) UpdateStationInfo(ctx context.Context, station *model.Stati
 	return nil
 }
 
+func (s *MongoStore) SetChargePointKey(ctx context.Context, stationId primitive.ObjectID, keyHash string) error {
+	res, err := s.StationsColl.UpdateOne(ctx, bson.M{"_id": stationId}, bson.M{"$set": bson.M{"ChargePointKeyHash": keyHash}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
 func (s *MongoStore) DeleteStation(ctx context.Context, stationId string) error {
 	oid, _ := primitive.ObjectIDFromHex(stationId)
 
@@ -289,6 +770,56 @@ func (s *MongoStore) ListSockets(ctx context.Context) ([]*model.Socket, error) {
 	return sockets, nil
 }
 
+func (s *MongoStore) UpdateSocketStatus(ctx context.Context, socketId string, status model.SocketStatus) error {
+	oid, _ := primitive.ObjectIDFromHex(socketId)
+	_, err := s.SocketsColl.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"Status": status}})
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"Sockets._id": oid}
+	update := bson.M{"$set": bson.M{"Sockets.$.Status": status}}
+	_, err = s.StationsColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) ClaimSocket(ctx context.Context, socketId primitive.ObjectID, from []model.SocketStatus, to model.SocketStatus) (bool, error) {
+	// The station holds the status the services read, so it is the one the claim is decided on.
+	filter := bson.M{"Sockets": bson.M{"$elemMatch": bson.M{"_id": socketId, "Status": bson.M{"$in": from}}}}
+	update := bson.M{"$set": bson.M{"Sockets.$.Status": to}}
+	res, err := s.StationsColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if res.ModifiedCount == 0 {
+		return false, nil
+	}
+
+	_, err = s.SocketsColl.UpdateOne(ctx, bson.M{"_id": socketId}, bson.M{"$set": bson.M{"Status": to}})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *MongoStore) SetSocketSuspected(ctx context.Context, socketId primitive.ObjectID, suspected bool) error {
+	_, err := s.SocketsColl.UpdateOne(ctx, bson.M{"_id": socketId}, bson.M{"$set": bson.M{"SuspectedBroken": suspected}})
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"Sockets._id": socketId}
+	update := bson.M{"$set": bson.M{"Sockets.$.SuspectedBroken": suspected}}
+	_, err = s.StationsColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (s *MongoStore) GetStationById(ctx context.Context, stationId string) (*model.Station, error) {
 	var station model.Station
 	oid, _ := primitive.ObjectIDFromHex(stationId)
@@ -319,6 +850,72 @@ func (s *MongoStore) FilterStations(ctx context.Context, filter bson.M) ([]*mode
 	return stations, nil
 }
 
+func (s *MongoStore) FindStationsPage(ctx context.Context, filter bson.M, page Page) ([]*model.Station, string, error) {
+	order, err := stationOrder(page)
+	if err != nil {
+		return nil, "", err
+	}
+	first := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	if page.Sort == SortDistance {
+		first = mongo.Pipeline{{{Key: "$geoNear", Value: bson.M{
+			"near":               model.NewGeoPoint(page.Near.Lat, page.Near.Long),
+			"distanceField":      "Distance",
+			"distanceMultiplier": 0.001,
+			"spherical":          true,
+			"query":              filter,
+		}}}}
+	}
+	key := func(station *model.Station) interface{} { return stationSortKey(station, page) }
+	return findPage(ctx, s.StationsColl, first, page, order, stationId, key)
+}
+
+func (s *MongoStore) FindSocketsPage(ctx context.Context, filter bson.M, page Page) ([]*model.Socket, string, error) {
+	order, err := socketOrder(page)
+	if err != nil {
+		return nil, "", err
+	}
+	first := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	key := func(socket *model.Socket) interface{} { return socketSortKey(socket, page) }
+	return findPage(ctx, s.SocketsColl, first, page, order, socketId, key)
+}
+
+func (s *MongoStore) FindUsersPage(ctx context.Context, filter bson.M, page Page) ([]*model.User, string, error) {
+	order, err := userOrder(page)
+	if err != nil {
+		return nil, "", err
+	}
+	first := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	return findPage(ctx, s.UsersColl, first, page, order, userId, noSortKey[model.User])
+}
+
+func (s *MongoStore) FindStationsNear(ctx context.Context, point model.Coordinate, maxDistanceKm float64, limit int) ([]*model.Station, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":               model.NewGeoPoint(point.Lat, point.Long),
+			"distanceField":      "Distance",
+			"maxDistance":        maxDistanceKm * 1000,
+			"distanceMultiplier": 0.001,
+			"spherical":          true,
+		}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	var stations []*model.Station
+	cursor, err := s.StationsColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var station model.Station
+		if err := cursor.Decode(&station); err != nil {
+			return nil, err
+		}
+		stations = append(stations, &station)
+	}
+	return stations, nil
+}
+
 func (s *MongoStore) PushSocketToStation(ctx context.Context, station *model.Station, socket model.Socket) error {
 	filter := bson.M{"_id": station.ID}
 	update := bson.M{"$push": bson.M{"Sockets": socket}}
@@ -345,17 +942,698 @@ func (s *MongoStore) DeleteSocket(ctx context.Context, socketId string) error {
 	return nil
 }
 
-func ConnectDB(dbUri string) *mongo.Client {
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(dbUri))
+func (s *MongoStore) InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	_, err := s.RefreshTokensColl.InsertOne(ctx, token)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return err
 	}
-	fmt.Println("Connected to Database!")
-	return client
+	return nil
 }
 
-func GetCollection(client *mongo.Client, dbName, collectionName string) *mongo.Collection {
-	coll := client.Database(dbName).Collection(collectionName)
-	return coll
+func (s *MongoStore) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	filter := bson.M{"TokenHash": tokenHash}
+	err := s.RefreshTokensColl.FindOne(ctx, filter).Decode(&token)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *MongoStore) RevokeRefreshToken(ctx context.Context, tokenId primitive.ObjectID) (bool, error) {
+	// Only a token which is not revoked yet matches, so two concurrent rotations cannot both succeed.
+	filter := bson.M{"_id": tokenId, "RevokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"RevokedAt": time.Now()}}
+	res, err := s.RefreshTokensColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) RevokeRefreshTokenFamily(ctx context.Context, familyId primitive.ObjectID) error {
+	filter := bson.M{"FamilyID": familyId, "RevokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"RevokedAt": time.Now()}}
+	_, err := s.RefreshTokensColl.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) RevokeUserRefreshTokens(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.M{"UserID": userId, "RevokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"RevokedAt": time.Now()}}
+	_, err := s.RefreshTokensColl.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertUserToken(ctx context.Context, token *model.UserToken) error {
+	_, err := s.UserTokensColl.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) GetUserTokenByHash(ctx context.Context, tokenHash string) (*model.UserToken, error) {
+	var token model.UserToken
+	filter := bson.M{"TokenHash": tokenHash}
+	err := s.UserTokensColl.FindOne(ctx, filter).Decode(&token)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *MongoStore) UseUserToken(ctx context.Context, tokenId primitive.ObjectID) (bool, error) {
+	// Only a token which is not used yet matches, so a token cannot be used twice even concurrently.
+	filter := bson.M{"_id": tokenId, "UsedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"UsedAt": time.Now()}}
+	res, err := s.UserTokensColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) SetUserPassword(ctx context.Context, userId primitive.ObjectID, passwordHash string) error {
+	res, err := s.UsersColl.UpdateOne(ctx, bson.M{"id": userId}, bson.M{"$set": bson.M{"Password": passwordHash}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) FailUserToken(ctx context.Context, tokenId primitive.ObjectID) (int, error) {
+	var token model.UserToken
+	update := bson.M{"$inc": bson.M{"Attempts": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.UserTokensColl.FindOneAndUpdate(ctx, bson.M{"_id": tokenId}, update, opts).Decode(&token); err != nil {
+		return 0, err
+	}
+	return token.Attempts, nil
+}
+
+func (s *MongoStore) GetLoginAttempts(ctx context.Context, key string) (*model.LoginAttempts, error) {
+	var attempts model.LoginAttempts
+	err := s.LoginAttemptsColl.FindOne(ctx, bson.M{"_id": key}).Decode(&attempts)
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (s *MongoStore) RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*model.LoginAttempts, error) {
+	// The update is a pipeline so that the count is reset and incremented in a single atomic step,
+	// which keeps the replicas of the user service in agreement.
+	failures := bson.M{"$cond": bson.A{
+		bson.M{"$lt": bson.A{"$LastFailureAt", at.Add(-window)}},
+		1,
+		bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$Failures", 0}}, 1}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"Failures": failures, "LastFailureAt": at}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var attempts model.LoginAttempts
+	if err := s.LoginAttemptsColl.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempts); err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (s *MongoStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	update := bson.M{"$set": bson.M{"LockedUntil": until, "Failures": 0}}
+	_, err := s.LoginAttemptsColl.UpdateOne(ctx, bson.M{"_id": key}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := s.LoginAttemptsColl.DeleteOne(ctx, bson.M{"_id": key})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) SetTwoFactor(ctx context.Context, userId primitive.ObjectID, twoFactor *model.TwoFactor) error {
+	update := bson.M{"$set": bson.M{"TwoFactor": twoFactor}}
+	if twoFactor == nil {
+		update = bson.M{"$unset": bson.M{"TwoFactor": ""}}
+	}
+	res, err := s.UsersColl.UpdateOne(ctx, bson.M{"id": userId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) UseTwoFactorStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error) {
+	// The step only moves forward, so two concurrent logins with the same code cannot both succeed.
+	filter := bson.M{"id": userId, "TwoFactor.LastStep": bson.M{"$lt": step}}
+	update := bson.M{"$set": bson.M{"TwoFactor.LastStep": step}}
+	res, err := s.UsersColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) (bool, error) {
+	filter := bson.M{"id": userId, "TwoFactor.RecoveryCodes": codeHash}
+	update := bson.M{"$pull": bson.M{"TwoFactor.RecoveryCodes": codeHash}}
+	res, err := s.UsersColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) SetUserEmailVerified(ctx context.Context, userId primitive.ObjectID) error {
+	res, err := s.UsersColl.UpdateOne(ctx, bson.M{"id": userId}, bson.M{"$set": bson.M{"EmailVerified": true}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+const (
+	// reservationLockTTL is how long the lock on the reservations of a socket is held at most, so an insert which
+	// never releases it, because its service stopped, only holds up the socket for a moment.
+	reservationLockTTL = 10 * time.Second
+	// reservationLockRetry is how long an insert waits before trying again to take a lock held by another one.
+	reservationLockRetry = 10 * time.Millisecond
+)
+
+func (s *MongoStore) InsertReservation(ctx context.Context, reservation *model.Reservation) error {
+	unlock, err := s.lockSocketReservations(ctx, reservation.SocketID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// No other reservation of the socket is inserted while the lock is held, so none can come
+	// between the check and the insert.
+	count, err := s.ReservationsColl.CountDocuments(ctx, overlappingReservations(reservation.SocketID, reservation.StartsAt, reservation.EndsAt))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrReservationConflict
+	}
+	_, err = s.ReservationsColl.InsertOne(ctx, reservation)
+	return err
+}
+
+// lockSocketReservations takes the lock on the reservations of the socket, waiting for it while another insert
+// holds it, and returns the function releasing it. The lock is a document of the reservations collection keyed
+// by the socket and taken by a single upsert: while it is held, the filter misses the document, so the upsert
+// tries to insert it again and fails on its duplicate id.
+func (s *MongoStore) lockSocketReservations(ctx context.Context, socketId primitive.ObjectID) (func(), error) {
+	lockId := "lock:" + socketId.Hex()
+	owner := primitive.NewObjectID()
+	for {
+		now := time.Now()
+		filter := bson.M{"_id": lockId, "LockedUntil": bson.M{"$lt": now}}
+		update := bson.M{"$set": bson.M{"LockedUntil": now.Add(reservationLockTTL), "Owner": owner}}
+		_, err := s.ReservationsColl.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if err == nil {
+			return func() {
+				// The lock expires anyway when it cannot be released, so the error is not worth failing the insert for.
+				_, _ = s.ReservationsColl.DeleteOne(context.Background(), bson.M{"_id": lockId, "Owner": owner})
+			}, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(reservationLockRetry):
+		}
+	}
+}
+
+func (s *MongoStore) GetReservationById(ctx context.Context, reservationId string) (*model.Reservation, error) {
+	var reservation model.Reservation
+	oid, _ := primitive.ObjectIDFromHex(reservationId)
+	err := s.ReservationsColl.FindOne(ctx, bson.M{"_id": oid}).Decode(&reservation)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+func (s *MongoStore) FindReservationsByFilter(ctx context.Context, filter bson.M) ([]*model.Reservation, error) {
+	var reservations []*model.Reservation
+	opts := options.Find().SetSort(bson.D{{Key: "StartsAt", Value: 1}})
+	cursor, err := s.ReservationsColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var reservation model.Reservation
+		if err := cursor.Decode(&reservation); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, &reservation)
+	}
+	return reservations, nil
+}
+
+func (s *MongoStore) UpdateReservationStatus(ctx context.Context, reservationId primitive.ObjectID, from, to model.ReservationStatus) (bool, error) {
+	filter := bson.M{"_id": reservationId, "Status": from}
+	update := bson.M{"$set": bson.M{"Status": to}}
+	res, err := s.ReservationsColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) InsertChargingSession(ctx context.Context, session *model.ChargingSession) error {
+	_, err := s.SessionsColl.InsertOne(ctx, session)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrChargingSessionConflict
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) GetChargingSessionById(ctx context.Context, sessionId string) (*model.ChargingSession, error) {
+	var session model.ChargingSession
+	oid, _ := primitive.ObjectIDFromHex(sessionId)
+	err := s.SessionsColl.FindOne(ctx, bson.M{"_id": oid}).Decode(&session)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *MongoStore) FindChargingSessionsByFilter(ctx context.Context, filter bson.M) ([]*model.ChargingSession, error) {
+	var sessions []*model.ChargingSession
+	opts := options.Find().SetSort(bson.D{{Key: "StartedAt", Value: -1}})
+	cursor, err := s.SessionsColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var session model.ChargingSession
+		if err := cursor.Decode(&session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+func (s *MongoStore) CompleteChargingSession(ctx context.Context, session *model.ChargingSession) (bool, error) {
+	filter := bson.M{"_id": session.ID, "Status": model.ChargingInProgress}
+	update := bson.M{"$set": bson.M{
+		"Status":        session.Status,
+		"StoppedAt":     session.StoppedAt,
+		"EnergyKWh":     session.EnergyKWh,
+		"Cost":          session.Cost,
+		"Estimated":     session.Estimated,
+		"TransactionID": session.TransactionID,
+	}}
+	res, err := s.SessionsColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) SettleChargingSession(ctx context.Context, session *model.ChargingSession) (bool, error) {
+	if session.TransactionID == 0 {
+		return false, nil
+	}
+	filter := bson.M{"_id": session.ID, "Status": model.ChargingCompleted, "TransactionID": session.TransactionID}
+	update := bson.M{"$set": bson.M{
+		"EnergyKWh": session.EnergyKWh,
+		"Cost":      session.Cost,
+		"Estimated": session.Estimated,
+	}}
+	res, err := s.SessionsColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// transactionSequenceId is the id of the document of the transactions collection holding the last
+// transaction id. The ids cannot be derived from the transactions, which are deleted once stopped.
+const transactionSequenceId = "sequence"
+
+func (s *MongoStore) InsertTransaction(ctx context.Context, tx *model.Transaction) error {
+	var sequence struct {
+		Value int `bson:"Value"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{"$inc": bson.M{"Value": 1}}
+	if err := s.TransactionsColl.FindOneAndUpdate(ctx, bson.M{"_id": transactionSequenceId}, update, opts).Decode(&sequence); err != nil {
+		return err
+	}
+	tx.ID = sequence.Value
+	_, err := s.TransactionsColl.InsertOne(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) GetTransactionById(ctx context.Context, transactionId int) (*model.Transaction, error) {
+	var tx model.Transaction
+	err := s.TransactionsColl.FindOne(ctx, bson.M{"_id": transactionId}).Decode(&tx)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (s *MongoStore) GetTransactionBySocket(ctx context.Context, socketId primitive.ObjectID) (*model.Transaction, error) {
+	var tx model.Transaction
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})
+	err := s.TransactionsColl.FindOne(ctx, bson.M{"SocketID": socketId}, opts).Decode(&tx)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (s *MongoStore) UpdateTransactionMeter(ctx context.Context, transactionId int, meterWh int) error {
+	res, err := s.TransactionsColl.UpdateOne(ctx, bson.M{"_id": transactionId}, bson.M{"$set": bson.M{"MeterValue": meterWh}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) DeleteTransaction(ctx context.Context, transactionId int) error {
+	_, err := s.TransactionsColl.DeleteOne(ctx, bson.M{"_id": transactionId})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertTariff(ctx context.Context, tariff *model.Tariff) error {
+	_, err := s.TariffsColl.InsertOne(ctx, tariff)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) GetTariffById(ctx context.Context, tariffId string) (*model.Tariff, error) {
+	var tariff model.Tariff
+	oid, _ := primitive.ObjectIDFromHex(tariffId)
+	err := s.TariffsColl.FindOne(ctx, bson.M{"_id": oid}).Decode(&tariff)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &tariff, nil
+}
+
+func (s *MongoStore) FindTariffsByFilter(ctx context.Context, filter bson.M) ([]*model.Tariff, error) {
+	var tariffs []*model.Tariff
+	cursor, err := s.TariffsColl.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var tariff model.Tariff
+		if err := cursor.Decode(&tariff); err != nil {
+			return nil, err
+		}
+		tariffs = append(tariffs, &tariff)
+	}
+	return tariffs, nil
+}
+
+func (s *MongoStore) UpdateTariff(ctx context.Context, tariff *model.Tariff) error {
+	res, err := s.TariffsColl.ReplaceOne(ctx, bson.M{"_id": tariff.ID}, tariff)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) DeleteTariff(ctx context.Context, tariffId string) error {
+	oid, _ := primitive.ObjectIDFromHex(tariffId)
+	res, err := s.TariffsColl.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertEnergyPrice(ctx context.Context, price *model.EnergyPrice) error {
+	_, err := s.EnergyPricesColl.InsertOne(ctx, price)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) FindEnergyPricesByFilter(ctx context.Context, filter bson.M) ([]*model.EnergyPrice, error) {
+	var prices []*model.EnergyPrice
+	opts := options.Find().SetSort(bson.D{{Key: "EffectiveFrom", Value: -1}})
+	cursor, err := s.EnergyPricesColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var price model.EnergyPrice
+		if err := cursor.Decode(&price); err != nil {
+			return nil, err
+		}
+		prices = append(prices, &price)
+	}
+	return prices, nil
+}
+
+func (s *MongoStore) DeleteEnergyPrice(ctx context.Context, priceId string) error {
+	oid, _ := primitive.ObjectIDFromHex(priceId)
+	res, err := s.EnergyPricesColl.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func ConnectDB(dbUri string) *mongo.Client {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(dbUri))
+	if err != nil {
+		log.Fatal(err)
+		return nil
+	}
+	fmt.Println("Connected to Database!")
+	return client
+}
+
+func GetCollection(client *mongo.Client, dbName, collectionName string) *mongo.Collection {
+	coll := client.Database(dbName).Collection(collectionName)
+	return coll
+}
+
+func (s *MongoStore) InsertReview(ctx context.Context, review *model.Review) error {
+	_, err := s.ReviewsColl.InsertOne(ctx, review)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (s *MongoStore) GetReviewById(ctx context.Context, reviewId string) (*model.Review, error) {
+	var review model.Review
+	oid, _ := primitive.ObjectIDFromHex(reviewId)
+	err := s.ReviewsColl.FindOne(ctx, bson.M{"_id": oid}).Decode(&review)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (s *MongoStore) FindReviewsByFilter(ctx context.Context, filter bson.M) ([]*model.Review, error) {
+	var reviews []*model.Review
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}})
+	cursor, err := s.ReviewsColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var review model.Review
+		if err := cursor.Decode(&review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+	return reviews, nil
+}
+
+func (s *MongoStore) UpdateReview(ctx context.Context, review *model.Review) error {
+	res, err := s.ReviewsColl.ReplaceOne(ctx, bson.M{"_id": review.ID}, review)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) DeleteReview(ctx context.Context, reviewId primitive.ObjectID) error {
+	res, err := s.ReviewsColl.DeleteOne(ctx, bson.M{"_id": reviewId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *MongoStore) SetStationRating(ctx context.Context, stationId primitive.ObjectID, rating *model.StationRating) error {
+	update := bson.M{"$set": bson.M{"Rating": rating}}
+	if rating == nil {
+		update = bson.M{"$unset": bson.M{"Rating": ""}}
+	}
+	_, err := s.StationsColl.UpdateOne(ctx, bson.M{"_id": stationId}, update)
+	return err
+}
+
+func (s *MongoStore) InsertIssueReport(ctx context.Context, report *model.IssueReport) error {
+	_, err := s.IssueReportsColl.InsertOne(ctx, report)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) GetIssueReportById(ctx context.Context, reportId string) (*model.IssueReport, error) {
+	var report model.IssueReport
+	oid, _ := primitive.ObjectIDFromHex(reportId)
+	err := s.IssueReportsColl.FindOne(ctx, bson.M{"_id": oid}).Decode(&report)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (s *MongoStore) FindIssueReportsByFilter(ctx context.Context, filter bson.M) ([]*model.IssueReport, error) {
+	var reports []*model.IssueReport
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}})
+	cursor, err := s.IssueReportsColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var report model.IssueReport
+		if err := cursor.Decode(&report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, &report)
+	}
+	return reports, nil
+}
+
+func (s *MongoStore) CloseIssueReport(ctx context.Context, reportId primitive.ObjectID, status model.IssueStatus, closedAt time.Time) (bool, error) {
+	filter := bson.M{"_id": reportId, "Status": model.IssueOpen}
+	update := bson.M{"$set": bson.M{"Status": status, "ClosedAt": closedAt}}
+	res, err := s.IssueReportsColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) InsertImportJob(ctx context.Context, job *model.ImportJob) error {
+	_, err := s.ImportJobsColl.InsertOne(ctx, job)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) GetImportJobById(ctx context.Context, jobId string) (*model.ImportJob, error) {
+	var job model.ImportJob
+	oid, _ := primitive.ObjectIDFromHex(jobId)
+	err := s.ImportJobsColl.FindOne(ctx, bson.M{"_id": oid}).Decode(&job)
+	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mongo.ErrNoDocuments
+	} else if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *MongoStore) FindImportJobsByFilter(ctx context.Context, filter bson.M) ([]*model.ImportJob, error) {
+	var jobs []*model.ImportJob
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}})
+	cursor, err := s.ImportJobsColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var job model.ImportJob
+		if err := cursor.Decode(&job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
 }
@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"california/internal/config"
+	"california/pkg/repository"
+	"california/pkg/repository/storetest"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestMongoStore runs the conformance suite against the MongoDB server of STORE_TEST_MONGO_URI. Every subtest
+// gets its own database, which is dropped when it ends.
+func TestMongoStore(t *testing.T) {
+	uri := os.Getenv("STORE_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("STORE_TEST_MONGO_URI is not set")
+	}
+	storetest.Run(t, func(t *testing.T) repository.Store {
+		return newMongoStore(t, testConfig(uri))
+	})
+}
+
+// TestMongoStoreStationLocations checks that the stations stored before they had a location get one when
+// the store starts, and that those without valid coordinates are skipped instead of keeping it from starting.
+func TestMongoStoreStationLocations(t *testing.T) {
+	uri := os.Getenv("STORE_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("STORE_TEST_MONGO_URI is not set")
+	}
+	ctx := context.Background()
+	cfg := testConfig(uri)
+	store := newMongoStore(t, cfg)
+	legacy := []interface{}{
+		bson.M{"Brand": "valid", "Latitude": 41.0, "Longitude": 29.0},
+		bson.M{"Brand": "missing"},
+		bson.M{"Brand": "text", "Latitude": "41", "Longitude": "29"},
+		bson.M{"Brand": "out of range", "Latitude": 141.0, "Longitude": 29.0},
+	}
+	if _, err := store.StationsColl.InsertMany(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newMongoStore(t, cfg)
+	count := func(filter bson.M) int64 {
+		n, err := restarted.StationsColl.CountDocuments(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(bson.M{"Brand": "valid", "Location.coordinates": bson.A{29.0, 41.0}}); n != 1 {
+		t.Errorf("the valid station got no location")
+	}
+	if n := count(bson.M{"Location": bson.M{"$exists": true}}); n != 1 {
+		t.Errorf("%d stations got a location, want only the valid one", n)
+	}
+}
+
+func testConfig(uri string) *config.Config {
+	return &config.Config{
+		MongoDBUri:                  uri,
+		DatabaseName:                fmt.Sprintf("storetest_%d", time.Now().UnixNano()),
+		UsersCollectionName:         "users",
+		StationsCollectionName:      "stations",
+		SocketsCollectionName:       "sockets",
+		RefreshTokensCollectionName: "refresh_tokens",
+		ReservationsCollectionName:  "reservations",
+		SessionsCollectionName:      "sessions",
+		TariffsCollectionName:       "tariffs",
+		EnergyPricesCollectionName:  "energy_prices",
+		ReviewsCollectionName:       "reviews",
+		IssueReportsCollectionName:  "issue_reports",
+		ImportJobsCollectionName:    "import_jobs",
+		UserTokensCollectionName:    "user_tokens",
+		LoginAttemptsCollectionName: "login_attempts",
+		TransactionsCollectionName:  "ocpp_transactions",
+	}
+}
+
+// newMongoStore returns a store of the database of cfg, which is dropped when the test ends.
+func newMongoStore(t *testing.T, cfg *config.Config) *repository.MongoStore {
+	store := repository.NewMongoStore(cfg)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if err := store.Client.Database(cfg.DatabaseName).Drop(ctx); err != nil {
+			t.Logf("dropping %s: %v", cfg.DatabaseName, err)
+		}
+		store.Client.Disconnect(ctx)
+	})
+	return store
+}
@@ -0,0 +1,1201 @@
+// Package storetest checks that an implementation of repository.Store behaves like the others.
+//
+// A test for a store only has to call Run with a function returning an empty store:
+//
+//	func TestMemoryStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) repository.Store {
+//			return repository.NewMemoryStore()
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/auth"
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Run runs the conformance suite. newStore is called once per subtest and must return an empty store.
+func Run(t *testing.T, newStore func(t *testing.T) repository.Store) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStore(t)) })
+	t.Run("LegacyVehicles", func(t *testing.T) { testLegacyVehicles(t, newStore(t)) })
+	t.Run("UserFilters", func(t *testing.T) { testUserFilters(t, newStore(t)) })
+	t.Run("Stations", func(t *testing.T) { testStations(t, newStore(t)) })
+	t.Run("StationFilters", func(t *testing.T) { testStationFilters(t, newStore(t)) })
+	t.Run("Sockets", func(t *testing.T) { testSockets(t, newStore(t)) })
+	t.Run("StationsNear", func(t *testing.T) { testStationsNear(t, newStore(t)) })
+	t.Run("Pages", func(t *testing.T) { testPages(t, newStore(t)) })
+	t.Run("RefreshTokens", func(t *testing.T) { testRefreshTokens(t, newStore(t)) })
+	t.Run("UserTokens", func(t *testing.T) { testUserTokens(t, newStore(t)) })
+	t.Run("TwoFactor", func(t *testing.T) { testTwoFactor(t, newStore(t)) })
+	t.Run("LoginAttempts", func(t *testing.T) { testLoginAttempts(t, newStore(t)) })
+	t.Run("Reservations", func(t *testing.T) { testReservations(t, newStore(t)) })
+	t.Run("ChargingSessions", func(t *testing.T) { testChargingSessions(t, newStore(t)) })
+	t.Run("Transactions", func(t *testing.T) { testTransactions(t, newStore(t)) })
+	t.Run("Tariffs", func(t *testing.T) { testTariffs(t, newStore(t)) })
+	t.Run("EnergyPrices", func(t *testing.T) { testEnergyPrices(t, newStore(t)) })
+	t.Run("Reviews", func(t *testing.T) { testReviews(t, newStore(t)) })
+	t.Run("IssueReports", func(t *testing.T) { testIssueReports(t, newStore(t)) })
+	t.Run("ImportJobs", func(t *testing.T) { testImportJobs(t, newStore(t)) })
+}
+
+func testUsers(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := &model.User{ID: primitive.NewObjectID(), Name: "Jane Doe", Email: "jane@example.com", Password: "hash", UserType: model.Normal}
+
+	inserted, err := store.InsertUser(ctx, user)
+	if err != nil {
+		t.Fatalf("InsertUser: %v", err)
+	}
+	if inserted.ID != user.ID || inserted.Email != user.Email {
+		t.Fatalf("InsertUser returned %+v, want %+v", inserted, user)
+	}
+
+	exists, err := store.UserExists(ctx, user.Email)
+	if err != nil || !exists {
+		t.Fatalf("UserExists = %v, %v; want true", exists, err)
+	}
+	exists, err = store.UserExists(ctx, "nobody@example.com")
+	if err != nil || exists {
+		t.Fatalf("UserExists for a missing user = %v, %v; want false", exists, err)
+	}
+
+	if _, err = store.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetUserByEmail for a missing user: got %v, want mongo.ErrNoDocuments", err)
+	}
+	got, err := store.GetUserById(ctx, user.ID.Hex())
+	if err != nil || got.Email != user.Email {
+		t.Fatalf("GetUserById = %+v, %v", got, err)
+	}
+
+	vehicle := &model.Vehicle{ID: primitive.NewObjectID(), Brand: "Togg", Model: "T10X", EngineType: model.Electric, BatteryCapacity: 88.5}
+	if err = store.InsertVehicleToUser(ctx, user, vehicle); err != nil {
+		t.Fatalf("InsertVehicleToUser: %v", err)
+	}
+	second := &model.Vehicle{ID: primitive.NewObjectID(), Brand: "Fiat", Model: "Egea", EngineType: model.Diesel}
+	if err = store.InsertVehicleToUser(ctx, user, second); err != nil {
+		t.Fatalf("InsertVehicleToUser: %v", err)
+	}
+	got, _ = store.GetUserByEmail(ctx, user.Email)
+	if len(got.Vehicles) != 2 || got.Vehicles[0].Brand != "Togg" || got.Vehicles[0].BatteryCapacity != 88.5 || got.Vehicles[1].ID != second.ID {
+		t.Fatalf("vehicles after InsertVehicleToUser = %+v", got.Vehicles)
+	}
+	if got.DefaultVehicle().ID != vehicle.ID {
+		t.Fatalf("default vehicle = %+v, want the first one", got.DefaultVehicle())
+	}
+
+	userCtx := auth.WithClaims(ctx, &auth.Claims{UserID: user.ID.Hex()})
+	if err = store.UpdateUser(userCtx, &model.User{Name: "Jane Roe", Password: "new-password"}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	got, _ = store.GetUserByEmail(ctx, user.Email)
+	if got.Name != "Jane Roe" {
+		t.Fatalf("name after UpdateUser = %q, want %q", got.Name, "Jane Roe")
+	}
+	if err = helpers.CompareLoginPasswordAndHash("new-password", got.Password); err != nil {
+		t.Fatalf("UpdateUser did not store the hash of the new password: %v", err)
+	}
+
+	if err = store.UpdateVehicle(userCtx, &model.Vehicle{ID: vehicle.ID, Brand: "Renault", Model: "Zoe"}); err != nil {
+		t.Fatalf("UpdateVehicle: %v", err)
+	}
+	got, _ = store.GetUserByEmail(ctx, user.Email)
+	if v := got.VehicleById(vehicle.ID); v == nil || v.Brand != "Renault" || v.BatteryCapacity != 0 {
+		t.Fatalf("UpdateVehicle should replace the vehicle, got %+v", got.Vehicles)
+	}
+	if got.Vehicles[1].Brand != "Fiat" {
+		t.Fatalf("UpdateVehicle changed another vehicle: %+v", got.Vehicles[1])
+	}
+	if err = store.UpdateVehicle(userCtx, &model.Vehicle{ID: primitive.NewObjectID()}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("UpdateVehicle for a missing vehicle: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	if err = store.SetDefaultVehicle(userCtx, second.ID); err != nil {
+		t.Fatalf("SetDefaultVehicle: %v", err)
+	}
+	if err = store.SetDefaultVehicle(userCtx, primitive.NewObjectID()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("SetDefaultVehicle for a missing vehicle: got %v, want mongo.ErrNoDocuments", err)
+	}
+	got, _ = store.GetUserByEmail(ctx, user.Email)
+	if got.DefaultVehicle().ID != second.ID {
+		t.Fatalf("default vehicle after SetDefaultVehicle = %+v", got.DefaultVehicle())
+	}
+	if err = store.DeleteVehicle(userCtx, second.ID); err != nil {
+		t.Fatalf("DeleteVehicle: %v", err)
+	}
+	if err = store.DeleteVehicle(userCtx, second.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("DeleteVehicle twice: got %v, want mongo.ErrNoDocuments", err)
+	}
+	got, _ = store.GetUserByEmail(ctx, user.Email)
+	if len(got.Vehicles) != 1 || !got.DefaultVehicleID.IsZero() || got.DefaultVehicle().ID != vehicle.ID {
+		t.Fatalf("garage after deleting the default vehicle = %+v, default %v", got.Vehicles, got.DefaultVehicleID)
+	}
+
+	first, last := primitive.NewObjectID(), primitive.NewObjectID()
+	if err = store.SetFavoriteStations(userCtx, []primitive.ObjectID{last, first}); err != nil {
+		t.Fatalf("SetFavoriteStations: %v", err)
+	}
+	home := model.Place{ID: primitive.NewObjectID(), Name: "Home", Lat: 41.01, Long: 28.97}
+	work := model.Place{ID: primitive.NewObjectID(), Name: "Work", Lat: 39.92, Long: 32.85, Address: "Kızılay"}
+	if err = store.SetPlaces(userCtx, []model.Place{work, home}); err != nil {
+		t.Fatalf("SetPlaces: %v", err)
+	}
+	got, _ = store.GetUserByEmail(ctx, user.Email)
+	if len(got.FavoriteStations) != 2 || got.FavoriteStations[0] != last || !got.IsFavorite(first) {
+		t.Fatalf("favourite stations after SetFavoriteStations = %v", got.FavoriteStations)
+	}
+	if len(got.Places) != 2 || got.Places[0] != work || *got.PlaceById(home.ID) != home {
+		t.Fatalf("places after SetPlaces = %+v", got.Places)
+	}
+	if err = store.SetFavoriteStations(userCtx, nil); err != nil {
+		t.Fatalf("SetFavoriteStations: %v", err)
+	}
+	got, _ = store.GetUserByEmail(ctx, user.Email)
+	if len(got.FavoriteStations) != 0 || len(got.Places) != 2 {
+		t.Fatalf("clearing the favourite stations left %v, places %+v", got.FavoriteStations, got.Places)
+	}
+	missingCtx := auth.WithClaims(ctx, &auth.Claims{UserID: primitive.NewObjectID().Hex()})
+	if err = store.SetPlaces(missingCtx, []model.Place{home}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("SetPlaces for a missing user: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	// Returned values must not share memory with the store.
+	got.Name = "changed"
+	again, _ := store.GetUserByEmail(ctx, user.Email)
+	if again.Name != "Jane Roe" {
+		t.Fatalf("modifying a returned user changed the stored one")
+	}
+
+	if err = store.DeleteUser(ctx, user.Email); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	all, err := store.GetAllUsers(ctx)
+	if err != nil || len(all) != 0 {
+		t.Fatalf("GetAllUsers after DeleteUser = %d users, %v", len(all), err)
+	}
+}
+
+// testLegacyVehicles stores users the way they were before the garage: every user had a Vehicle subdocument,
+// which was empty when the user registered none.
+func testLegacyVehicles(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	empty, err := store.InsertUser(ctx, &model.User{Name: "No Car", Email: "nocar@example.com", Vehicle: &model.Vehicle{}})
+	if err != nil {
+		t.Fatalf("InsertUser: %v", err)
+	}
+	if empty.Vehicle == nil {
+		t.Fatal("the empty legacy vehicle was not stored")
+	}
+	if garage := empty.Garage(); len(garage) != 0 {
+		t.Fatalf("garage of a user with an empty legacy vehicle = %+v, want none", garage)
+	}
+	if v := empty.DefaultVehicle(); v != nil {
+		t.Fatalf("default vehicle of a user with an empty legacy vehicle = %+v, want nil", v)
+	}
+	if err = store.UnsetLegacyVehicle(ctx, empty.Email); err != nil {
+		t.Fatalf("UnsetLegacyVehicle: %v", err)
+	}
+	got, _ := store.GetUserByEmail(ctx, empty.Email)
+	if got.Vehicle != nil {
+		t.Fatalf("legacy vehicle after UnsetLegacyVehicle = %+v, want nil", got.Vehicle)
+	}
+
+	owner, err := store.InsertUser(ctx, &model.User{Name: "Old Car", Email: "oldcar@example.com", Vehicle: &model.Vehicle{Brand: "Fiat", Model: "Egea", EngineType: model.Diesel}})
+	if err != nil {
+		t.Fatalf("InsertUser: %v", err)
+	}
+	if garage := owner.Garage(); len(garage) != 1 || garage[0].Brand != "Fiat" {
+		t.Fatalf("garage of a user with a legacy vehicle = %+v, want the Fiat", garage)
+	}
+}
+
+func testUserFilters(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	for _, name := range []string{"Ayşe Yılmaz", "Mehmet Yilmaz", "John Doe"} {
+		_, err := store.InsertUser(ctx, &model.User{ID: primitive.NewObjectID(), Name: name, Email: name + "@example.com", UserType: model.Normal})
+		if err != nil {
+			t.Fatalf("InsertUser: %v", err)
+		}
+	}
+
+	// The filter used by usersvc.SearchUsers.
+	users, err := store.FindUsersByFilter(ctx, bson.M{"Name": bson.M{"$regex": primitive.Regex{Pattern: "yilmaz", Options: "i"}}})
+	if err != nil {
+		t.Fatalf("FindUsersByFilter: %v", err)
+	}
+	if len(users) != 1 || users[0].Name != "Mehmet Yilmaz" {
+		t.Fatalf("case insensitive regex matched %v", names(users))
+	}
+
+	users, err = store.FindUsersByFilter(ctx, bson.M{"$or": []bson.M{{"Name": "John Doe"}, {"Name": "Ayşe Yılmaz"}}})
+	if err != nil || len(users) != 2 {
+		t.Fatalf("$or matched %v, %v; want 2 users", names(users), err)
+	}
+
+	users, err = store.FindUsersByFilter(ctx, bson.M{"Name": "Nobody"})
+	if err != nil || len(users) != 0 {
+		t.Fatalf("filter without match returned %v, %v", names(users), err)
+	}
+}
+
+func testStations(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	station := newStation("ZES", 41.0082, 28.9784, model.Socket{ID: primitive.NewObjectID(), Name: "ZES", KW: 60, CurrentType: model.DC, SocketType: "CCS", Status: model.Available})
+
+	inserted, err := store.InsertStation(ctx, station)
+	if err != nil {
+		t.Fatalf("InsertStation: %v", err)
+	}
+	if inserted.ID != station.ID || len(inserted.Sockets) != 1 {
+		t.Fatalf("InsertStation returned %+v", inserted)
+	}
+
+	if _, err = store.GetStationById(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetStationById for a missing station: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	update := *station
+	update.Brand = "Eşarj"
+	update.Address = "Kadıköy"
+	if err = store.UpdateStationInfo(ctx, &update, station.ID.Hex()); err != nil {
+		t.Fatalf("UpdateStationInfo: %v", err)
+	}
+	got, err := store.GetStationById(ctx, station.ID.Hex())
+	if err != nil || got.Brand != "Eşarj" || got.Address != "Kadıköy" {
+		t.Fatalf("GetStationById after update = %+v, %v", got, err)
+	}
+
+	socket := model.Socket{ID: primitive.NewObjectID(), Name: "Eşarj", KW: 22, CurrentType: model.AC, SocketType: "Type 2"}
+	if err = store.PushSocketToStation(ctx, got, socket); err != nil {
+		t.Fatalf("PushSocketToStation: %v", err)
+	}
+	got, _ = store.GetStationById(ctx, station.ID.Hex())
+	if len(got.Sockets) != 2 || got.Sockets[1].ID != socket.ID {
+		t.Fatalf("sockets after PushSocketToStation = %+v", got.Sockets)
+	}
+
+	if err = store.SetChargePointKey(ctx, station.ID, "key-hash"); err != nil {
+		t.Fatalf("SetChargePointKey: %v", err)
+	}
+	// The key is not part of the station details, so updating them keeps it.
+	if err = store.UpdateStationInfo(ctx, got, station.ID.Hex()); err != nil {
+		t.Fatalf("UpdateStationInfo: %v", err)
+	}
+	got, _ = store.GetStationById(ctx, station.ID.Hex())
+	if got.ChargePointKeyHash != "key-hash" {
+		t.Fatalf("ChargePointKeyHash after UpdateStationInfo = %q, want key-hash", got.ChargePointKeyHash)
+	}
+	if err = store.SetChargePointKey(ctx, primitive.NewObjectID(), "key-hash"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("SetChargePointKey for a missing station: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	if err = store.DeleteStation(ctx, station.ID.Hex()); err != nil {
+		t.Fatalf("DeleteStation: %v", err)
+	}
+	all, err := store.GetAllStations(ctx)
+	if err != nil || len(all) != 0 {
+		t.Fatalf("GetAllStations after DeleteStation = %d stations, %v", len(all), err)
+	}
+}
+
+func testStationFilters(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	stations := []*model.Station{
+		newStation("ZES", 41.0, 29.0, model.Socket{ID: primitive.NewObjectID(), Name: "ZES", CurrentType: model.DC, KW: 120}),
+		newStation("Eşarj", 40.0, 30.0, model.Socket{ID: primitive.NewObjectID(), Name: "Eşarj", CurrentType: model.AC, KW: 22}),
+		newStation("Voltrun", 39.0, 32.0,
+			model.Socket{ID: primitive.NewObjectID(), Name: "Voltrun", CurrentType: model.AC, KW: 22},
+			model.Socket{ID: primitive.NewObjectID(), Name: "Voltrun", CurrentType: model.DC, KW: 180}),
+	}
+	for _, station := range stations {
+		if _, err := store.InsertStation(ctx, station); err != nil {
+			t.Fatalf("InsertStation: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter bson.M
+		want   []string
+	}{
+		{"location", bson.M{"Latitude": 40.0, "Longitude": 30.0}, []string{"Eşarj"}},
+		{"brand regex", bson.M{"Brand": bson.M{"$regex": primitive.Regex{Pattern: "^volt", Options: "i"}}}, []string{"Voltrun"}},
+		{"brand in", bson.M{"Brand": bson.M{"$in": []string{"ZES", "Voltrun"}}}, []string{"ZES", "Voltrun"}},
+		{"socket current type", bson.M{"Sockets": bson.M{"$elemMatch": bson.M{"CurrentType": 2}}}, []string{"Eşarj", "Voltrun"}},
+		{"socket name and current type", bson.M{"Sockets": bson.M{"$elemMatch": bson.M{
+			"Name":        bson.M{"$in": []string{"ZES", "Eşarj"}},
+			"CurrentType": model.DC,
+		}}}, []string{"ZES"}},
+		{"socket power", bson.M{"Sockets.KW": bson.M{"$gte": 100}}, []string{"ZES", "Voltrun"}},
+		{"bounding box", bson.M{
+			"Latitude":  bson.M{"$gte": 39.5, "$lte": 41.5},
+			"Longitude": bson.M{"$gte": 28.5, "$lte": 30.5},
+		}, []string{"ZES", "Eşarj"}},
+	}
+	for _, tt := range tests {
+		got, err := store.FindStationByFilter(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: FindStationByFilter: %v", tt.name, err)
+		}
+		if !sameBrands(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, brands(got), tt.want)
+		}
+	}
+}
+
+func testSockets(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	socket := model.Socket{ID: primitive.NewObjectID(), Name: "ZES", KW: 60, CurrentType: model.DC}
+	station := newStation("ZES", 41.0, 29.0, socket)
+
+	if _, err := store.InsertStation(ctx, station); err != nil {
+		t.Fatalf("InsertStation: %v", err)
+	}
+	if err := store.InsertSocket(ctx, &socket); err != nil {
+		t.Fatalf("InsertSocket: %v", err)
+	}
+	sockets, err := store.ListSockets(ctx)
+	if err != nil || len(sockets) != 1 || sockets[0].ID != socket.ID {
+		t.Fatalf("ListSockets = %+v, %v", sockets, err)
+	}
+
+	if err = store.UpdateSocketStatus(ctx, socket.ID.Hex(), model.Occupied); err != nil {
+		t.Fatalf("UpdateSocketStatus: %v", err)
+	}
+	sockets, _ = store.ListSockets(ctx)
+	got, _ := store.GetStationById(ctx, station.ID.Hex())
+	if sockets[0].Status != model.Occupied || got.Sockets[0].Status != model.Occupied {
+		t.Fatalf("UpdateSocketStatus should update the socket and its station, got %v and %v", sockets[0].Status, got.Sockets[0].Status)
+	}
+
+	free := []model.SocketStatus{model.Available, model.Reserved}
+	if claimed, err := store.ClaimSocket(ctx, socket.ID, free, model.Occupied); err != nil || claimed {
+		t.Fatalf("ClaimSocket of an occupied socket = %v, %v; want false", claimed, err)
+	}
+	if err = store.UpdateSocketStatus(ctx, socket.ID.Hex(), model.Available); err != nil {
+		t.Fatalf("UpdateSocketStatus: %v", err)
+	}
+	if claimed, err := store.ClaimSocket(ctx, socket.ID, free, model.Occupied); err != nil || !claimed {
+		t.Fatalf("ClaimSocket of an available socket = %v, %v; want true", claimed, err)
+	}
+	if claimed, err := store.ClaimSocket(ctx, socket.ID, free, model.Occupied); err != nil || claimed {
+		t.Fatalf("ClaimSocket twice = %v, %v; want false", claimed, err)
+	}
+	sockets, _ = store.ListSockets(ctx)
+	got, _ = store.GetStationById(ctx, station.ID.Hex())
+	if sockets[0].Status != model.Occupied || got.Sockets[0].Status != model.Occupied {
+		t.Fatalf("ClaimSocket should update the socket and its station, got %v and %v", sockets[0].Status, got.Sockets[0].Status)
+	}
+
+	if err = store.DeleteSocket(ctx, socket.ID.Hex()); err != nil {
+		t.Fatalf("DeleteSocket: %v", err)
+	}
+	sockets, _ = store.ListSockets(ctx)
+	if len(sockets) != 0 {
+		t.Fatalf("ListSockets after DeleteSocket = %+v", sockets)
+	}
+	got, _ = store.GetStationById(ctx, station.ID.Hex())
+	if len(got.Sockets) != 0 {
+		t.Fatalf("DeleteSocket should pull the socket from its station, got %+v", got.Sockets)
+	}
+}
+
+func testStationsNear(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	// Kadıköy, Üsküdar and Ankara, seen from Taksim.
+	for _, station := range []*model.Station{
+		newStation("Kadıköy", 40.9900, 29.0290),
+		newStation("Ankara", 39.9334, 32.8597),
+		newStation("Üsküdar", 41.0260, 29.0150),
+	} {
+		if _, err := store.InsertStation(ctx, station); err != nil {
+			t.Fatalf("InsertStation: %v", err)
+		}
+	}
+	taksim := model.Coordinate{Lat: 41.0370, Long: 28.9850}
+
+	got, err := store.FindStationsNear(ctx, taksim, 10, 10)
+	if err != nil {
+		t.Fatalf("FindStationsNear: %v", err)
+	}
+	if len(got) != 2 || got[0].Brand != "Üsküdar" || got[1].Brand != "Kadıköy" {
+		t.Fatalf("FindStationsNear = %v, want [Üsküdar Kadıköy]", brands(got))
+	}
+	if got[0].Distance <= 0 || got[0].Distance > got[1].Distance || got[1].Distance > 10 {
+		t.Fatalf("unexpected distances %v and %v", got[0].Distance, got[1].Distance)
+	}
+
+	got, err = store.FindStationsNear(ctx, taksim, 1000, 1)
+	if err != nil || len(got) != 1 || got[0].Brand != "Üsküdar" {
+		t.Fatalf("FindStationsNear with a limit = %v, %v", brands(got), err)
+	}
+}
+
+func testPages(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	socket := func(kw, price float64) model.Socket {
+		return model.Socket{ID: primitive.NewObjectID(), KW: kw, Price: price}
+	}
+	for _, station := range []*model.Station{
+		newStation("Zes", 40.9900, 29.0290, socket(180, 9.5), socket(22, 7.9)),
+		newStation("Eşarj", 39.9334, 32.8597, socket(60, 8.5)),
+		newStation("Trugo", 41.0260, 29.0150, socket(300, 10.9)),
+		newStation("Zes", 41.0000, 29.0000),
+		newStation("Voltrun", 41.1000, 29.1000, socket(120, 8.9)),
+	} {
+		if _, err := store.InsertStation(ctx, station); err != nil {
+			t.Fatalf("InsertStation: %v", err)
+		}
+	}
+	taksim := &model.Coordinate{Lat: 41.0370, Long: 28.9850}
+
+	// all reads every page of two stations.
+	all := func(page repository.Page, filter bson.M) []string {
+		var result []string
+		page.Limit = 2
+		for i := 0; ; i++ {
+			stations, next, err := store.FindStationsPage(ctx, filter, page)
+			if err != nil {
+				t.Fatalf("FindStationsPage(%+v): %v", page, err)
+			}
+			if len(stations) > 2 || (next != "" && len(stations) != 2) || i > 5 {
+				t.Fatalf("FindStationsPage(%+v) = %v stations, next %q", page, brands(stations), next)
+			}
+			result = append(result, brands(stations)...)
+			if next == "" {
+				return result
+			}
+			page.After = next
+		}
+	}
+	for _, tc := range []struct {
+		page repository.Page
+		want []string
+	}{
+		{repository.Page{}, []string{"Zes", "Eşarj", "Trugo", "Zes", "Voltrun"}},
+		{repository.Page{Sort: repository.SortBrand}, []string{"Eşarj", "Trugo", "Voltrun", "Zes", "Zes"}},
+		{repository.Page{Sort: repository.SortPrice}, []string{"Zes", "Eşarj", "Voltrun", "Trugo", "Zes"}},
+		{repository.Page{Sort: repository.SortPower}, []string{"Trugo", "Zes", "Voltrun", "Eşarj", "Zes"}},
+		{repository.Page{Sort: repository.SortDistance, Near: taksim}, []string{"Trugo", "Zes", "Zes", "Voltrun", "Eşarj"}},
+	} {
+		if got := all(tc.page, bson.M{}); !slices.Equal(got, tc.want) {
+			t.Errorf("pages sorted by %q = %v, want %v", tc.page.Sort, got, tc.want)
+		}
+	}
+	if got := all(repository.Page{Sort: repository.SortPrice}, bson.M{"Brand": "Zes"}); !slices.Equal(got, []string{"Zes", "Zes"}) {
+		t.Errorf("filtered pages = %v", got)
+	}
+
+	if _, _, err := store.FindStationsPage(ctx, bson.M{}, repository.Page{Sort: repository.SortDistance}); !errors.Is(err, repository.ErrInvalidSort) {
+		t.Errorf("distance order without a point: got %v, want ErrInvalidSort", err)
+	}
+	if _, _, err := store.FindStationsPage(ctx, bson.M{}, repository.Page{After: "not-a-cursor"}); !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("invalid cursor: got %v, want ErrInvalidCursor", err)
+	}
+	if _, _, err := store.FindUsersPage(ctx, bson.M{}, repository.Page{Sort: repository.SortPower}); !errors.Is(err, repository.ErrInvalidSort) {
+		t.Errorf("users sorted by power: got %v, want ErrInvalidSort", err)
+	}
+
+	for _, name := range []string{"Ali", "Veli", "Ayşe"} {
+		if _, err := store.InsertUser(ctx, &model.User{ID: primitive.NewObjectID(), Name: name, Email: name + "@example.com"}); err != nil {
+			t.Fatalf("InsertUser: %v", err)
+		}
+	}
+	users, next, err := store.FindUsersPage(ctx, bson.M{}, repository.Page{Limit: 2})
+	if err != nil || !slices.Equal(names(users), []string{"Ali", "Veli"}) || next == "" {
+		t.Fatalf("first page of users = %v, %q, %v", names(users), next, err)
+	}
+	users, next, err = store.FindUsersPage(ctx, bson.M{}, repository.Page{Limit: 2, After: next})
+	if err != nil || !slices.Equal(names(users), []string{"Ayşe"}) || next != "" {
+		t.Fatalf("last page of users = %v, %q, %v", names(users), next, err)
+	}
+}
+
+func testRefreshTokens(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	family := primitive.NewObjectID()
+	now := time.Now()
+	first := &model.RefreshToken{ID: primitive.NewObjectID(), UserID: primitive.NewObjectID(), FamilyID: family, TokenHash: "first", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	second := &model.RefreshToken{ID: primitive.NewObjectID(), UserID: first.UserID, FamilyID: family, TokenHash: "second", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	for _, token := range []*model.RefreshToken{first, second} {
+		if err := store.InsertRefreshToken(ctx, token); err != nil {
+			t.Fatalf("InsertRefreshToken: %v", err)
+		}
+	}
+	duplicate := *first
+	duplicate.ID = primitive.NewObjectID()
+	if err := store.InsertRefreshToken(ctx, &duplicate); err == nil {
+		t.Fatalf("InsertRefreshToken accepted a duplicate token hash")
+	}
+
+	got, err := store.GetRefreshTokenByHash(ctx, "first")
+	if err != nil || got.ID != first.ID || got.RevokedAt != nil {
+		t.Fatalf("GetRefreshTokenByHash = %+v, %v", got, err)
+	}
+	if _, err = store.GetRefreshTokenByHash(ctx, "missing"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetRefreshTokenByHash for a missing token: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	revoked, err := store.RevokeRefreshToken(ctx, first.ID)
+	if err != nil || !revoked {
+		t.Fatalf("first RevokeRefreshToken = %v, %v; want true", revoked, err)
+	}
+	revoked, err = store.RevokeRefreshToken(ctx, first.ID)
+	if err != nil || revoked {
+		t.Fatalf("second RevokeRefreshToken = %v, %v; want false", revoked, err)
+	}
+
+	if err = store.RevokeRefreshTokenFamily(ctx, family); err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+	got, _ = store.GetRefreshTokenByHash(ctx, "second")
+	if got.RevokedAt == nil {
+		t.Fatalf("RevokeRefreshTokenFamily left a token of the family active")
+	}
+
+	other := &model.RefreshToken{ID: primitive.NewObjectID(), UserID: first.UserID, FamilyID: primitive.NewObjectID(), TokenHash: "other", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err = store.InsertRefreshToken(ctx, other); err != nil {
+		t.Fatalf("InsertRefreshToken: %v", err)
+	}
+	if err = store.RevokeUserRefreshTokens(ctx, first.UserID); err != nil {
+		t.Fatalf("RevokeUserRefreshTokens: %v", err)
+	}
+	got, _ = store.GetRefreshTokenByHash(ctx, "other")
+	if got.RevokedAt == nil {
+		t.Fatalf("RevokeUserRefreshTokens left a token of the user active")
+	}
+}
+
+func testUserTokens(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := &model.User{ID: primitive.NewObjectID(), Name: "Jane Doe", Email: "jane@example.com", Password: "hash", UserType: model.Normal}
+	if _, err := store.InsertUser(ctx, user); err != nil {
+		t.Fatalf("InsertUser: %v", err)
+	}
+	now := time.Now()
+	token := &model.UserToken{ID: primitive.NewObjectID(), UserID: user.ID, Purpose: model.ResetPassword, TokenHash: "reset",
+		Email: user.Email, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := store.InsertUserToken(ctx, token); err != nil {
+		t.Fatalf("InsertUserToken: %v", err)
+	}
+	duplicate := *token
+	duplicate.ID = primitive.NewObjectID()
+	if err := store.InsertUserToken(ctx, &duplicate); err == nil {
+		t.Fatalf("InsertUserToken accepted a duplicate token hash")
+	}
+
+	got, err := store.GetUserTokenByHash(ctx, "reset")
+	if err != nil || got.ID != token.ID || got.Purpose != model.ResetPassword || got.UsedAt != nil {
+		t.Fatalf("GetUserTokenByHash = %+v, %v", got, err)
+	}
+	if _, err = store.GetUserTokenByHash(ctx, "missing"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetUserTokenByHash for a missing token: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		if attempts, err := store.FailUserToken(ctx, token.ID); err != nil || attempts != want {
+			t.Fatalf("FailUserToken = %d, %v; want %d", attempts, err, want)
+		}
+	}
+
+	used, err := store.UseUserToken(ctx, token.ID)
+	if err != nil || !used {
+		t.Fatalf("first UseUserToken = %v, %v; want true", used, err)
+	}
+	used, err = store.UseUserToken(ctx, token.ID)
+	if err != nil || used {
+		t.Fatalf("second UseUserToken = %v, %v; want false", used, err)
+	}
+
+	if err = store.SetUserPassword(ctx, user.ID, "new hash"); err != nil {
+		t.Fatalf("SetUserPassword: %v", err)
+	}
+	if err = store.SetUserEmailVerified(ctx, user.ID); err != nil {
+		t.Fatalf("SetUserEmailVerified: %v", err)
+	}
+	stored, err := store.GetUserById(ctx, user.ID.Hex())
+	if err != nil || stored.Password != "new hash" || !stored.EmailVerified {
+		t.Fatalf("GetUserById after the updates = %+v, %v", stored, err)
+	}
+	if err = store.SetUserPassword(ctx, primitive.NewObjectID(), "hash"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("SetUserPassword for a missing user: got %v, want mongo.ErrNoDocuments", err)
+	}
+}
+
+func testTwoFactor(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := &model.User{ID: primitive.NewObjectID(), Name: "Jane Doe", Email: "jane@example.com", Password: "hash", UserType: model.Admin}
+	if _, err := store.InsertUser(ctx, user); err != nil {
+		t.Fatalf("InsertUser: %v", err)
+	}
+	twoFactor := &model.TwoFactor{Secret: "SECRET", Enabled: true, RecoveryCodes: []string{"one", "two"}}
+	if err := store.SetTwoFactor(ctx, user.ID, twoFactor); err != nil {
+		t.Fatalf("SetTwoFactor: %v", err)
+	}
+	if err := store.SetTwoFactor(ctx, primitive.NewObjectID(), twoFactor); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("SetTwoFactor for a missing user: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	for _, c := range []struct {
+		step int64
+		want bool
+	}{{10, true}, {10, false}, {9, false}, {11, true}} {
+		if used, err := store.UseTwoFactorStep(ctx, user.ID, c.step); err != nil || used != c.want {
+			t.Fatalf("UseTwoFactorStep(%d) = %v, %v; want %v", c.step, used, err, c.want)
+		}
+	}
+	if used, err := store.UseRecoveryCode(ctx, user.ID, "one"); err != nil || !used {
+		t.Fatalf("first UseRecoveryCode = %v, %v; want true", used, err)
+	}
+	if used, err := store.UseRecoveryCode(ctx, user.ID, "one"); err != nil || used {
+		t.Fatalf("second UseRecoveryCode = %v, %v; want false", used, err)
+	}
+
+	stored, err := store.GetUserById(ctx, user.ID.Hex())
+	if err != nil || !stored.TwoFactorEnabled() || stored.TwoFactor.LastStep != 11 ||
+		len(stored.TwoFactor.RecoveryCodes) != 1 || stored.TwoFactor.RecoveryCodes[0] != "two" {
+		t.Fatalf("GetUserById after the codes = %+v, %v", stored.TwoFactor, err)
+	}
+
+	if err = store.SetTwoFactor(ctx, user.ID, nil); err != nil {
+		t.Fatalf("SetTwoFactor(nil): %v", err)
+	}
+	if stored, _ = store.GetUserById(ctx, user.ID.Hex()); stored.TwoFactor != nil {
+		t.Fatalf("SetTwoFactor(nil) kept %+v", stored.TwoFactor)
+	}
+}
+
+func testLoginAttempts(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	key := "email:jane@example.com"
+	if _, err := store.GetLoginAttempts(ctx, key); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetLoginAttempts before any failure: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	start := time.Now().Truncate(time.Millisecond)
+	for i, at := range []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute)} {
+		attempts, err := store.RecordLoginFailure(ctx, key, at, time.Hour)
+		if err != nil || attempts.Failures != i+1 || !attempts.LastFailureAt.Equal(at) {
+			t.Fatalf("RecordLoginFailure #%d = %+v, %v", i+1, attempts, err)
+		}
+	}
+	attempts, err := store.RecordLoginFailure(ctx, key, start.Add(3*time.Hour), time.Hour)
+	if err != nil || attempts.Failures != 1 {
+		t.Fatalf("RecordLoginFailure after the window = %+v, %v; want 1 failure", attempts, err)
+	}
+
+	until := start.Add(4 * time.Hour)
+	if err = store.LockLogin(ctx, key, until); err != nil {
+		t.Fatalf("LockLogin: %v", err)
+	}
+	attempts, err = store.GetLoginAttempts(ctx, key)
+	if err != nil || attempts.Failures != 0 || !attempts.Locked(start.Add(3*time.Hour)) || attempts.Locked(until) {
+		t.Fatalf("GetLoginAttempts after LockLogin = %+v, %v", attempts, err)
+	}
+
+	if err = store.ResetLoginAttempts(ctx, key); err != nil {
+		t.Fatalf("ResetLoginAttempts: %v", err)
+	}
+	if _, err = store.GetLoginAttempts(ctx, key); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetLoginAttempts after ResetLoginAttempts: got %v, want mongo.ErrNoDocuments", err)
+	}
+}
+
+func testReservations(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	socket := primitive.NewObjectID()
+	start := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	reserve := func(user string, from, to time.Duration) (*model.Reservation, error) {
+		reservation := &model.Reservation{
+			ID:        primitive.NewObjectID(),
+			UserID:    user,
+			SocketID:  socket,
+			StartsAt:  start.Add(from),
+			EndsAt:    start.Add(to),
+			Status:    model.ReservationActive,
+			CreatedAt: time.Now(),
+		}
+		return reservation, store.InsertReservation(ctx, reservation)
+	}
+
+	first, err := reserve("alice", 0, time.Hour)
+	if err != nil {
+		t.Fatalf("InsertReservation: %v", err)
+	}
+	if _, err = reserve("bob", 30*time.Minute, 90*time.Minute); !errors.Is(err, repository.ErrReservationConflict) {
+		t.Fatalf("InsertReservation for an overlapping window: got %v, want ErrReservationConflict", err)
+	}
+	if _, err = reserve("bob", time.Hour, 2*time.Hour); err != nil {
+		t.Fatalf("InsertReservation right after another one: %v", err)
+	}
+
+	got, err := store.GetReservationById(ctx, first.ID.Hex())
+	if err != nil || got.UserID != "alice" || !got.StartsAt.Equal(first.StartsAt) {
+		t.Fatalf("GetReservationById = %+v, %v", got, err)
+	}
+	if _, err = store.GetReservationById(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetReservationById for a missing reservation: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	updated, err := store.UpdateReservationStatus(ctx, first.ID, model.ReservationActive, model.ReservationCancelled)
+	if err != nil || !updated {
+		t.Fatalf("UpdateReservationStatus = %v, %v; want true", updated, err)
+	}
+	updated, err = store.UpdateReservationStatus(ctx, first.ID, model.ReservationActive, model.ReservationExpired)
+	if err != nil || updated {
+		t.Fatalf("UpdateReservationStatus from a stale status = %v, %v; want false", updated, err)
+	}
+	if _, err = reserve("bob", 30*time.Minute, 60*time.Minute); err != nil {
+		t.Fatalf("InsertReservation over a cancelled reservation: %v", err)
+	}
+
+	mine, err := store.FindReservationsByFilter(ctx, bson.M{"UserID": "bob"})
+	if err != nil || len(mine) != 2 || !mine[0].StartsAt.Before(mine[1].StartsAt) {
+		t.Fatalf("FindReservationsByFilter = %+v, %v; want bob's 2 reservations by start", mine, err)
+	}
+	later, err := store.FindReservationsByFilter(ctx, bson.M{"StartsAt": bson.M{"$gte": start.Add(time.Hour), "$lt": start.Add(2 * time.Hour)}})
+	if err != nil || len(later) != 1 || later[0].UserID != "bob" {
+		t.Fatalf("FindReservationsByFilter on a time range = %+v, %v; want bob's second reservation", later, err)
+	}
+
+	// Of the overlapping reservations inserted at the same time, a single one is kept.
+	const users = 8
+	var wg sync.WaitGroup
+	errs := make([]error, users)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = reserve(fmt.Sprintf("user%d", i), 3*time.Hour, 4*time.Hour)
+		}(i)
+	}
+	wg.Wait()
+	inserted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			inserted++
+		case !errors.Is(err, repository.ErrReservationConflict):
+			t.Errorf("InsertReservation at the same time: %v", err)
+		}
+	}
+	if inserted != 1 {
+		t.Errorf("%d overlapping reservations were inserted at the same time, want 1", inserted)
+	}
+}
+
+func testChargingSessions(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	socket := primitive.NewObjectID()
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	newSession := func(user string, socket primitive.ObjectID, startedAt time.Time) *model.ChargingSession {
+		return &model.ChargingSession{
+			ID:          primitive.NewObjectID(),
+			UserID:      user,
+			SocketID:    socket,
+			Status:      model.ChargingInProgress,
+			StartedAt:   startedAt,
+			PricePerKWh: 8.5,
+		}
+	}
+
+	first := newSession("alice", socket, start)
+	if err := store.InsertChargingSession(ctx, first); err != nil {
+		t.Fatalf("InsertChargingSession: %v", err)
+	}
+	if err := store.InsertChargingSession(ctx, newSession("bob", socket, start)); !errors.Is(err, repository.ErrChargingSessionConflict) {
+		t.Fatalf("InsertChargingSession on a busy socket: got %v, want ErrChargingSessionConflict", err)
+	}
+	if err := store.InsertChargingSession(ctx, newSession("alice", primitive.NewObjectID(), start)); !errors.Is(err, repository.ErrChargingSessionConflict) {
+		t.Fatalf("InsertChargingSession for a user already charging: got %v, want ErrChargingSessionConflict", err)
+	}
+
+	first.Complete(start.Add(30*time.Minute), 20, 170, false)
+	completed, err := store.CompleteChargingSession(ctx, first)
+	if err != nil || !completed {
+		t.Fatalf("CompleteChargingSession = %v, %v; want true", completed, err)
+	}
+	completed, err = store.CompleteChargingSession(ctx, first)
+	if err != nil || completed {
+		t.Fatalf("second CompleteChargingSession = %v, %v; want false", completed, err)
+	}
+	got, err := store.GetChargingSessionById(ctx, first.ID.Hex())
+	if err != nil || got.Status != model.ChargingCompleted || got.EnergyKWh != 20 || got.Cost != 170 || got.StoppedAt == nil {
+		t.Fatalf("GetChargingSessionById = %+v, %v", got, err)
+	}
+	if _, err = store.GetChargingSessionById(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetChargingSessionById for a missing session: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	second := newSession("alice", socket, start.Add(45*time.Minute))
+	if err = store.InsertChargingSession(ctx, second); err != nil {
+		t.Fatalf("InsertChargingSession once the socket is free: %v", err)
+	}
+	sessions, err := store.FindChargingSessionsByFilter(ctx, bson.M{"UserID": "alice"})
+	if err != nil || len(sessions) != 2 || sessions[0].ID != second.ID {
+		t.Fatalf("FindChargingSessionsByFilter = %+v, %v; want alice's 2 sessions, most recent first", sessions, err)
+	}
+
+	// A session stopped while its transaction was metering it is settled with the final reading of the transaction.
+	second.TransactionID = 7
+	second.Complete(start.Add(50*time.Minute), 4, 34, false)
+	if completed, err = store.CompleteChargingSession(ctx, second); err != nil || !completed {
+		t.Fatalf("CompleteChargingSession with a transaction = %v, %v; want true", completed, err)
+	}
+	settled, err := store.SettleChargingSession(ctx, first)
+	if err != nil || settled {
+		t.Fatalf("SettleChargingSession of a session without a transaction = %v, %v; want false", settled, err)
+	}
+	other := *second
+	other.TransactionID = 8
+	if settled, err = store.SettleChargingSession(ctx, &other); err != nil || settled {
+		t.Fatalf("SettleChargingSession with another transaction = %v, %v; want false", settled, err)
+	}
+	second.EnergyKWh, second.Cost = 5.5, 46.75
+	if settled, err = store.SettleChargingSession(ctx, second); err != nil || !settled {
+		t.Fatalf("SettleChargingSession = %v, %v; want true", settled, err)
+	}
+	got, err = store.GetChargingSessionById(ctx, second.ID.Hex())
+	if err != nil || got.TransactionID != 7 || got.EnergyKWh != 5.5 || got.Cost != 46.75 || got.Status != model.ChargingCompleted {
+		t.Fatalf("GetChargingSessionById after SettleChargingSession = %+v, %v", got, err)
+	}
+}
+
+func testTransactions(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	socket := primitive.NewObjectID()
+	start := time.Now().Truncate(time.Millisecond).UTC()
+
+	first := &model.Transaction{ChargePointID: "CP-1", ConnectorID: 1, SocketID: socket, MeterStart: 1000, MeterValue: 1000, StartedAt: start}
+	if err := store.InsertTransaction(ctx, first); err != nil || first.ID == 0 {
+		t.Fatalf("InsertTransaction = %d, %v", first.ID, err)
+	}
+	second := &model.Transaction{ChargePointID: "CP-1", ConnectorID: 1, SocketID: socket, MeterStart: 2000, MeterValue: 2000, StartedAt: start.Add(time.Hour)}
+	if err := store.InsertTransaction(ctx, second); err != nil || second.ID <= first.ID {
+		t.Fatalf("InsertTransaction after #%d = %d, %v; want a greater id", first.ID, second.ID, err)
+	}
+
+	if err := store.UpdateTransactionMeter(ctx, first.ID, 4500); err != nil {
+		t.Fatalf("UpdateTransactionMeter: %v", err)
+	}
+	got, err := store.GetTransactionById(ctx, first.ID)
+	if err != nil || got.MeterValue != 4500 || got.EnergyKWh() != 3.5 || !got.StartedAt.Equal(start) {
+		t.Fatalf("GetTransactionById = %+v, %v", got, err)
+	}
+	got, err = store.GetTransactionBySocket(ctx, socket)
+	if err != nil || got.ID != second.ID {
+		t.Fatalf("GetTransactionBySocket = %+v, %v; want #%d", got, err, second.ID)
+	}
+	if _, err = store.GetTransactionBySocket(ctx, primitive.NewObjectID()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetTransactionBySocket of another socket: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	if err = store.DeleteTransaction(ctx, second.ID); err != nil {
+		t.Fatalf("DeleteTransaction: %v", err)
+	}
+	if _, err = store.GetTransactionById(ctx, second.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetTransactionById after DeleteTransaction: got %v, want mongo.ErrNoDocuments", err)
+	}
+	if err = store.UpdateTransactionMeter(ctx, second.ID, 3000); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("UpdateTransactionMeter after DeleteTransaction: got %v, want mongo.ErrNoDocuments", err)
+	}
+	// The id of a deleted transaction is not handed out again.
+	third := &model.Transaction{ChargePointID: "CP-1", ConnectorID: 1, SocketID: socket, StartedAt: start.Add(2 * time.Hour)}
+	if err = store.InsertTransaction(ctx, third); err != nil || third.ID <= second.ID {
+		t.Fatalf("InsertTransaction after deleting #%d = %d, %v; want a greater id", second.ID, third.ID, err)
+	}
+}
+
+func testTariffs(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	tariff := &model.Tariff{
+		ID:          primitive.NewObjectID(),
+		Name:        "ZES DC",
+		Currency:    "TRY",
+		Brand:       "ZES",
+		CurrentType: model.DC,
+		PricePerKWh: 9.9,
+		Periods:     []model.TariffPeriod{{Days: []time.Weekday{time.Saturday, time.Sunday}, Start: "22:00", End: "06:00", PricePerKWh: 7.5}},
+	}
+	if err := store.InsertTariff(ctx, tariff); err != nil {
+		t.Fatalf("InsertTariff: %v", err)
+	}
+	if err := store.InsertTariff(ctx, &model.Tariff{ID: primitive.NewObjectID(), Name: "Flat", Currency: "TRY", PricePerKWh: 8}); err != nil {
+		t.Fatalf("InsertTariff: %v", err)
+	}
+
+	got, err := store.GetTariffById(ctx, tariff.ID.Hex())
+	if err != nil || got.Name != tariff.Name || len(got.Periods) != 1 || got.Periods[0].Days[1] != time.Sunday {
+		t.Fatalf("GetTariffById = %+v, %v", got, err)
+	}
+	branded, err := store.FindTariffsByFilter(ctx, bson.M{"Brand": "ZES"})
+	if err != nil || len(branded) != 1 || branded[0].ID != tariff.ID {
+		t.Fatalf("FindTariffsByFilter = %+v, %v; want the ZES tariff", branded, err)
+	}
+
+	tariff.PricePerKWh = 10.5
+	if err = store.UpdateTariff(ctx, tariff); err != nil {
+		t.Fatalf("UpdateTariff: %v", err)
+	}
+	if got, _ = store.GetTariffById(ctx, tariff.ID.Hex()); got.PricePerKWh != 10.5 {
+		t.Fatalf("UpdateTariff did not update the price: %+v", got)
+	}
+	if err = store.UpdateTariff(ctx, &model.Tariff{ID: primitive.NewObjectID()}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("UpdateTariff for a missing tariff: got %v, want mongo.ErrNoDocuments", err)
+	}
+
+	if err = store.DeleteTariff(ctx, tariff.ID.Hex()); err != nil {
+		t.Fatalf("DeleteTariff: %v", err)
+	}
+	if err = store.DeleteTariff(ctx, tariff.ID.Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("second DeleteTariff: got %v, want mongo.ErrNoDocuments", err)
+	}
+	if all, _ := store.FindTariffsByFilter(ctx, bson.M{}); len(all) != 1 {
+		t.Fatalf("FindTariffsByFilter after delete = %d tariffs, want 1", len(all))
+	}
+}
+
+func testEnergyPrices(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	prices := []*model.EnergyPrice{
+		{ID: primitive.NewObjectID(), Energy: model.EnergyPetrol, Currency: "TRY", Price: 42.1, EffectiveFrom: day},
+		{ID: primitive.NewObjectID(), Energy: model.EnergyPetrol, Currency: "TRY", Price: 43.5, EffectiveFrom: day.AddDate(0, 0, 10)},
+		{ID: primitive.NewObjectID(), Energy: model.EnergyPetrol, Region: "Istanbul", Currency: "TRY", Price: 42.6, EffectiveFrom: day.AddDate(0, 0, 5)},
+		{ID: primitive.NewObjectID(), Energy: model.EnergyDiesel, Currency: "TRY", Price: 44, EffectiveFrom: day},
+	}
+	for _, price := range prices {
+		if err := store.InsertEnergyPrice(ctx, price); err != nil {
+			t.Fatalf("InsertEnergyPrice: %v", err)
+		}
+	}
+
+	got, err := store.FindEnergyPricesByFilter(ctx, bson.M{
+		"Energy":        model.EnergyPetrol,
+		"Region":        bson.M{"$in": []string{"", "Istanbul"}},
+		"EffectiveFrom": bson.M{"$lte": day.AddDate(0, 0, 7)},
+	})
+	if err != nil {
+		t.Fatalf("FindEnergyPricesByFilter: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != prices[2].ID || got[1].ID != prices[0].ID {
+		t.Fatalf("FindEnergyPricesByFilter = %+v; want the Istanbul version, then the first national one", got)
+	}
+
+	if err = store.DeleteEnergyPrice(ctx, prices[1].ID.Hex()); err != nil {
+		t.Fatalf("DeleteEnergyPrice: %v", err)
+	}
+	if err = store.DeleteEnergyPrice(ctx, prices[1].ID.Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("second DeleteEnergyPrice: got %v, want mongo.ErrNoDocuments", err)
+	}
+	if all, _ := store.FindEnergyPricesByFilter(ctx, bson.M{"Energy": model.EnergyPetrol}); len(all) != 2 {
+		t.Fatalf("FindEnergyPricesByFilter after delete = %d prices, want 2", len(all))
+	}
+}
+
+func newStation(brand string, lat, long float64, sockets ...model.Socket) *model.Station {
+	station := &model.Station{
+		ID:        primitive.NewObjectID(),
+		Brand:     brand,
+		Latitude:  lat,
+		Longitude: long,
+		Sockets:   sockets,
+	}
+	station.SetLocation()
+	return station
+}
+
+func names(users []*model.User) []string {
+	var result []string
+	for _, user := range users {
+		result = append(result, user.Name)
+	}
+	return result
+}
+
+func brands(stations []*model.Station) []string {
+	var result []string
+	for _, station := range stations {
+		result = append(result, station.Brand)
+	}
+	return result
+}
+
+func sameBrands(stations []*model.Station, want []string) bool {
+	got := brands(stations)
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, b := range got {
+		seen[b]++
+	}
+	for _, b := range want {
+		if seen[b] == 0 {
+			return false
+		}
+		seen[b]--
+	}
+	return true
+}
+
+func testReviews(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	station := &model.Station{ID: primitive.NewObjectID(), Brand: "ZES"}
+	if _, err := store.InsertStation(ctx, station); err != nil {
+		t.Fatalf("InsertStation: %v", err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	older := &model.Review{ID: primitive.NewObjectID(), StationID: station.ID, UserID: "u1", Rating: 4, Status: model.ReviewPublished, CreatedAt: day}
+	newer := &model.Review{ID: primitive.NewObjectID(), StationID: station.ID, UserID: "u2", Rating: 2, Status: model.ReviewPublished, CreatedAt: day.Add(time.Hour)}
+	for _, review := range []*model.Review{older, newer} {
+		if err := store.InsertReview(ctx, review); err != nil {
+			t.Fatalf("InsertReview: %v", err)
+		}
+	}
+	again := &model.Review{ID: primitive.NewObjectID(), StationID: station.ID, UserID: "u1", Rating: 5, Status: model.ReviewPublished, CreatedAt: day}
+	if err := store.InsertReview(ctx, again); !errors.Is(err, repository.ErrDuplicateKey) {
+		t.Fatalf("second InsertReview of a user: got %v, want repository.ErrDuplicateKey", err)
+	}
+
+	reviews, err := store.FindReviewsByFilter(ctx, bson.M{"StationID": station.ID})
+	if err != nil || len(reviews) != 2 || reviews[0].ID != newer.ID {
+		t.Fatalf("FindReviewsByFilter = %+v, %v; want the newer review first", reviews, err)
+	}
+	newer.Status = model.ReviewHidden
+	if err = store.UpdateReview(ctx, newer); err != nil {
+		t.Fatalf("UpdateReview: %v", err)
+	}
+	if published, _ := store.FindReviewsByFilter(ctx, bson.M{"Status": model.ReviewPublished}); len(published) != 1 || published[0].ID != older.ID {
+		t.Fatalf("FindReviewsByFilter after hiding = %+v; want the older review", published)
+	}
+
+	if err = store.SetStationRating(ctx, station.ID, &model.StationRating{Average: 4, Count: 1}); err != nil {
+		t.Fatalf("SetStationRating: %v", err)
+	}
+	if got, _ := store.GetStationById(ctx, station.ID.Hex()); got.Rating == nil || got.Rating.Count != 1 {
+		t.Fatalf("station rating = %+v, want 1 review", got.Rating)
+	}
+	if err = store.SetStationRating(ctx, station.ID, nil); err != nil {
+		t.Fatalf("SetStationRating(nil): %v", err)
+	}
+	if got, _ := store.GetStationById(ctx, station.ID.Hex()); got.Rating != nil {
+		t.Fatalf("station rating = %+v, want none", got.Rating)
+	}
+
+	if err = store.DeleteReview(ctx, older.ID); err != nil {
+		t.Fatalf("DeleteReview: %v", err)
+	}
+	if err = store.DeleteReview(ctx, older.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("second DeleteReview: got %v, want mongo.ErrNoDocuments", err)
+	}
+	if _, err = store.GetReviewById(ctx, older.ID.Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetReviewById after delete: got %v, want mongo.ErrNoDocuments", err)
+	}
+}
+
+func testIssueReports(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	socket := model.Socket{ID: primitive.NewObjectID(), Name: "ZES", Status: model.Available}
+	station := &model.Station{ID: primitive.NewObjectID(), Brand: "ZES", Sockets: []model.Socket{socket}}
+	if _, err := store.InsertStation(ctx, station); err != nil {
+		t.Fatalf("InsertStation: %v", err)
+	}
+	if err := store.InsertSocket(ctx, &socket); err != nil {
+		t.Fatalf("InsertSocket: %v", err)
+	}
+
+	report := &model.IssueReport{
+		ID:        primitive.NewObjectID(),
+		StationID: station.ID,
+		SocketID:  socket.ID,
+		UserID:    "u1",
+		Kind:      model.IssueCableDamaged,
+		Photos:    []model.Photo{{URL: "https://img.example.com/1.jpg", ContentType: "image/jpeg", Size: 1024}},
+		Status:    model.IssueOpen,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := store.InsertIssueReport(ctx, report); err != nil {
+		t.Fatalf("InsertIssueReport: %v", err)
+	}
+	got, err := store.GetIssueReportById(ctx, report.ID.Hex())
+	if err != nil || got.Kind != model.IssueCableDamaged || len(got.Photos) != 1 {
+		t.Fatalf("GetIssueReportById = %+v, %v", got, err)
+	}
+
+	closed, err := store.CloseIssueReport(ctx, report.ID, model.IssueResolved, time.Now())
+	if err != nil || !closed {
+		t.Fatalf("CloseIssueReport = %v, %v; want true", closed, err)
+	}
+	if closed, _ = store.CloseIssueReport(ctx, report.ID, model.IssueRejected, time.Now()); closed {
+		t.Fatal("CloseIssueReport closed a report which was not open")
+	}
+	if open, _ := store.FindIssueReportsByFilter(ctx, bson.M{"Status": model.IssueOpen}); len(open) != 0 {
+		t.Fatalf("FindIssueReportsByFilter(open) = %d reports, want 0", len(open))
+	}
+
+	if err = store.SetSocketSuspected(ctx, socket.ID, true); err != nil {
+		t.Fatalf("SetSocketSuspected: %v", err)
+	}
+	stored, _ := store.GetStationById(ctx, station.ID.Hex())
+	sockets, _ := store.ListSockets(ctx)
+	if !stored.Sockets[0].SuspectedBroken || len(sockets) != 1 || !sockets[0].SuspectedBroken {
+		t.Fatalf("socket not suspected after SetSocketSuspected: %+v, %+v", stored.Sockets, sockets)
+	}
+}
+
+func testImportJobs(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	station := newStation("ZES", 41.0082, 28.9784)
+	station.ExternalID = "TR*ZES*LOC1"
+	if _, err := store.InsertStation(ctx, station); err != nil {
+		t.Fatalf("InsertStation: %v", err)
+	}
+	station.ExternalID = "TR*ZES*LOC2"
+	if err := store.UpdateStationInfo(ctx, station, station.ID.Hex()); err != nil {
+		t.Fatalf("UpdateStationInfo: %v", err)
+	}
+	found, err := store.FindStationByFilter(ctx, bson.M{"ExternalID": "TR*ZES*LOC2"})
+	if err != nil || len(found) != 1 || found[0].ID != station.ID {
+		t.Fatalf("FindStationByFilter(ExternalID) = %v, %v", found, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	older := &model.ImportJob{ID: primitive.NewObjectID(), Format: model.ImportCSV, DryRun: true, CreatedAt: now.Add(-time.Hour)}
+	older.Count(model.ImportRow{Lines: []int{2, 3}, Action: model.ImportFailed, Errors: []string{"line 3: kw \"x\" is not a number"}})
+	newer := &model.ImportJob{ID: primitive.NewObjectID(), Format: model.ImportOCPI, CreatedAt: now}
+	newer.Count(model.ImportRow{Lines: []int{1}, Action: model.ImportCreated, StationID: station.ID, ExternalID: station.ExternalID})
+	for _, job := range []*model.ImportJob{older, newer} {
+		if err = store.InsertImportJob(ctx, job); err != nil {
+			t.Fatalf("InsertImportJob: %v", err)
+		}
+	}
+
+	got, err := store.GetImportJobById(ctx, older.ID.Hex())
+	if err != nil || !got.DryRun || got.Failed != 1 || len(got.Rows) != 1 || len(got.Rows[0].Lines) != 2 || len(got.Rows[0].Errors) != 1 {
+		t.Fatalf("GetImportJobById = %+v, %v", got, err)
+	}
+	if _, err = store.GetImportJobById(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("GetImportJobById for a missing job: got %v, want mongo.ErrNoDocuments", err)
+	}
+	jobs, err := store.FindImportJobsByFilter(ctx, bson.M{})
+	if err != nil || len(jobs) != 2 || jobs[0].ID != newer.ID || jobs[0].Rows[0].StationID != station.ID {
+		t.Fatalf("FindImportJobsByFilter = %v, %v; want the newest first", jobs, err)
+	}
+	if jobs, _ = store.FindImportJobsByFilter(ctx, bson.M{"DryRun": true}); len(jobs) != 1 || jobs[0].ID != older.ID {
+		t.Fatalf("FindImportJobsByFilter(dry run) = %v", jobs)
+	}
+}
@@ -0,0 +1,144 @@
+package stationimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"california/pkg/model"
+)
+
+// ReadCSV reads stations from a CSV file with a header row and a socket per row. The columns are matched by name
+// (id, brand, address, latitude, longitude, charge_point_id, amenities, socket_id, socket_type, kw, current_type,
+// price, connector_id) and may be in any order. The rows of a station are grouped by its id, or by its coordinates
+// when it has none, and its details are taken from its first row. Current types are AC or DC and amenities are separated by "|".
+func ReadCSV(r io.Reader) ([]*Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"brand", "latitude", "longitude", "socket_type", "kw", "current_type"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrInvalidFile, required)
+		}
+	}
+
+	var records []*Record
+	byKey := make(map[string]*Record)
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		parsed := parseRow(row, columns, line)
+		key := parsed.Station.ExternalID
+		if key == "" {
+			if len(parsed.Errors) > 0 {
+				// The coordinates of the row may be wrong, so it is not grouped with another station.
+				records = append(records, parsed)
+				continue
+			}
+			key = fmt.Sprintf("%g,%g", parsed.Station.Latitude, parsed.Station.Longitude)
+		}
+		record, ok := byKey[key]
+		if !ok {
+			byKey[key] = parsed
+			records = append(records, parsed)
+			continue
+		}
+		record.merge(parsed, line)
+	}
+	return records, nil
+}
+
+// merge adds the socket of a later row of the station to the record.
+func (r *Record) merge(row *Record, line int) {
+	r.Lines = append(r.Lines, line)
+	r.Errors = append(r.Errors, row.Errors...)
+	first, station := r.Station, row.Station
+	if station.Brand != first.Brand || station.Latitude != first.Latitude || station.Longitude != first.Longitude {
+		r.errorf("line %d: brand or coordinates differ from line %d", line, r.Lines[0])
+	}
+	for _, socket := range station.Sockets {
+		for _, other := range first.Sockets {
+			if socket.ExternalID != "" && socket.ExternalID == other.ExternalID {
+				r.errorf("line %d: duplicate socket_id %q", line, socket.ExternalID)
+			}
+		}
+		first.Sockets = append(first.Sockets, socket)
+	}
+}
+
+func parseRow(row []string, columns map[string]int, line int) *Record {
+	record := &Record{Lines: []int{line}}
+	text := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	number := func(name string) float64 {
+		value := text(name)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			record.errorf("line %d: %s %q is not a number", line, name, value)
+		}
+		return n
+	}
+
+	station := &model.Station{
+		ExternalID:    text("id"),
+		Brand:         text("brand"),
+		Address:       text("address"),
+		Latitude:      number("latitude"),
+		Longitude:     number("longitude"),
+		ChargePointID: text("charge_point_id"),
+	}
+	for _, amenity := range strings.Split(text("amenities"), "|") {
+		if amenity = strings.ToLower(strings.TrimSpace(amenity)); amenity != "" {
+			station.Amenities = append(station.Amenities, model.Amenity(amenity))
+		}
+	}
+
+	socket := model.Socket{
+		Name:       station.Brand,
+		SocketType: text("socket_type"),
+		KW:         number("kw"),
+		Price:      number("price"),
+		Status:     model.Available,
+		ExternalID: text("socket_id"),
+	}
+	switch strings.ToUpper(text("current_type")) {
+	case "AC":
+		socket.CurrentType = model.AC
+	case "DC":
+		socket.CurrentType = model.DC
+	default:
+		record.errorf("line %d: current_type %q is not AC or DC", line, text("current_type"))
+	}
+	if connector := text("connector_id"); connector != "" {
+		id, err := strconv.Atoi(connector)
+		if err != nil {
+			record.errorf("line %d: connector_id %q is not a number", line, connector)
+		}
+		socket.ConnectorID = id
+	}
+	station.Sockets = []model.Socket{socket}
+	record.Station = station
+	return record
+}
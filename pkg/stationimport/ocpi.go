@@ -0,0 +1,260 @@
+package stationimport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"california/pkg/model"
+)
+
+// location is the part of an OCPI 2.2 Location the stations are made of.
+type location struct {
+	CountryCode string `json:"country_code"`
+	PartyID     string `json:"party_id"`
+	ID          string `json:"id"`
+	Publish     *bool  `json:"publish"`
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	City        string `json:"city"`
+	PostalCode  string `json:"postal_code"`
+	Coordinates struct {
+		Latitude  string `json:"latitude"`
+		Longitude string `json:"longitude"`
+	} `json:"coordinates"`
+	Operator *struct {
+		Name string `json:"name"`
+	} `json:"operator"`
+	EVSEs        []evse        `json:"evses"`
+	ParkingType  string        `json:"parking_type"`
+	Facilities   []string      `json:"facilities"`
+	TimeZone     string        `json:"time_zone"`
+	OpeningTimes *openingTimes `json:"opening_times"`
+}
+
+type evse struct {
+	UID                 string      `json:"uid"`
+	Status              string      `json:"status"`
+	Connectors          []connector `json:"connectors"`
+	ParkingRestrictions []string    `json:"parking_restrictions"`
+}
+
+type connector struct {
+	ID               string  `json:"id"`
+	Standard         string  `json:"standard"`
+	PowerType        string  `json:"power_type"`
+	MaxVoltage       float64 `json:"max_voltage"`
+	MaxAmperage      float64 `json:"max_amperage"`
+	MaxElectricPower float64 `json:"max_electric_power"` // W
+}
+
+type openingTimes struct {
+	TwentyFourSeven bool `json:"twentyfourseven"`
+	RegularHours    []struct {
+		Weekday     int    `json:"weekday"` // 1 is Monday, 7 is Sunday.
+		PeriodBegin string `json:"period_begin"`
+		PeriodEnd   string `json:"period_end"`
+	} `json:"regular_hours"`
+	ExceptionalClosings []struct {
+		PeriodBegin time.Time `json:"period_begin"`
+		PeriodEnd   time.Time `json:"period_end"`
+	} `json:"exceptional_closings"`
+}
+
+// socketTypes are the names of the OCPI connector standards, as the socket types are named by the stations.
+// The other standards keep their OCPI name.
+var socketTypes = map[string]string{
+	"CHADEMO":            "CHAdeMO",
+	"IEC_62196_T1":       "Type 1",
+	"IEC_62196_T1_COMBO": "CCS1",
+	"IEC_62196_T2":       "Type 2",
+	"IEC_62196_T2_COMBO": "CCS",
+	"GBT_AC":             "GB/T AC",
+	"GBT_DC":             "GB/T DC",
+	"DOMESTIC_F":         "Schuko",
+	"TESLA_S":            "Tesla",
+}
+
+var socketStatuses = map[string]model.SocketStatus{
+	"AVAILABLE":  model.Available,
+	"CHARGING":   model.Occupied,
+	"BLOCKED":    model.Occupied,
+	"RESERVED":   model.Reserved,
+	"OUTOFORDER": model.Faulted,
+}
+
+var facilities = map[string]model.Amenity{
+	"CAFE":       model.Cafe,
+	"RESTAURANT": model.Restaurant,
+	"WIFI":       model.WiFi,
+}
+
+// ReadOCPI reads stations from OCPI 2.2 Locations: a single Location, an array of them,
+// or an OCPI response with them as its data. The EVSEs which are removed are left out.
+func ReadOCPI(r io.Reader) ([]*Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err = json.Unmarshal(data, &envelope); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		if len(envelope.Data) > 0 {
+			data = bytes.TrimSpace(envelope.Data)
+		}
+	}
+	if len(data) > 0 && data[0] == '{' {
+		data = append(append([]byte{'['}, data...), ']')
+	}
+
+	var locations []json.RawMessage
+	if err = json.Unmarshal(data, &locations); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	records := make([]*Record, 0, len(locations))
+	for i, raw := range locations {
+		record := &Record{Lines: []int{i + 1}, Station: &model.Station{}}
+		var loc location
+		if err = json.Unmarshal(raw, &loc); err != nil {
+			record.errorf("location %d: %v", i+1, err)
+		} else {
+			record.Station = loc.station(record)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (loc *location) station(record *Record) *model.Station {
+	station := &model.Station{ExternalID: loc.ID, Brand: loc.Name}
+	if loc.CountryCode != "" && loc.PartyID != "" {
+		// Location ids are only unique for their operator.
+		station.ExternalID = loc.CountryCode + "*" + loc.PartyID + "*" + loc.ID
+	}
+	if loc.Operator != nil && loc.Operator.Name != "" {
+		station.Brand = loc.Operator.Name
+	}
+	if loc.ID == "" {
+		record.errorf("location %d: missing id", record.Lines[0])
+	}
+	if loc.Publish != nil && !*loc.Publish {
+		record.Skip = "location is not published"
+	}
+
+	var address []string
+	for _, part := range []string{loc.Address, strings.TrimSpace(loc.PostalCode + " " + loc.City)} {
+		if part != "" {
+			address = append(address, part)
+		}
+	}
+	station.Address = strings.Join(address, ", ")
+
+	var err error
+	if station.Latitude, err = strconv.ParseFloat(loc.Coordinates.Latitude, 64); err != nil {
+		record.errorf("location %d: latitude %q is not a number", record.Lines[0], loc.Coordinates.Latitude)
+	}
+	if station.Longitude, err = strconv.ParseFloat(loc.Coordinates.Longitude, 64); err != nil {
+		record.errorf("location %d: longitude %q is not a number", record.Lines[0], loc.Coordinates.Longitude)
+	}
+
+	for _, facility := range loc.Facilities {
+		if amenity, ok := facilities[facility]; ok {
+			station.Amenities = append(station.Amenities, amenity)
+		}
+	}
+	if loc.ParkingType == "PARKING_GARAGE" || loc.ParkingType == "UNDERGROUND_GARAGE" {
+		station.Amenities = append(station.Amenities, model.CoveredParking)
+	}
+	station.OpeningHours = loc.openingHours()
+
+	for _, e := range loc.EVSEs {
+		if e.Status == "REMOVED" {
+			continue
+		}
+		for _, restriction := range e.ParkingRestrictions {
+			if restriction == "CUSTOMERS" {
+				station.Access = &model.StationAccess{CustomersOnly: true}
+			}
+		}
+		for _, c := range e.Connectors {
+			station.Sockets = append(station.Sockets, e.socket(c, station.Brand, record))
+		}
+	}
+	return station
+}
+
+func (e *evse) socket(c connector, brand string, record *Record) model.Socket {
+	socket := model.Socket{
+		Name:       brand,
+		SocketType: c.Standard,
+		Status:     socketStatuses[e.Status],
+		ExternalID: e.UID + "/" + c.ID,
+	}
+	if name, ok := socketTypes[c.Standard]; ok {
+		socket.SocketType = name
+	}
+	if id, err := strconv.Atoi(c.ID); err == nil {
+		socket.ConnectorID = id
+	}
+
+	switch {
+	case c.PowerType == "DC":
+		socket.CurrentType = model.DC
+	case strings.HasPrefix(c.PowerType, "AC"):
+		socket.CurrentType = model.AC
+	default:
+		record.errorf("location %d: connector %s has power_type %q", record.Lines[0], socket.ExternalID, c.PowerType)
+	}
+	watts := c.MaxElectricPower
+	if watts <= 0 {
+		watts = c.MaxVoltage * c.MaxAmperage
+		if c.PowerType == "AC_3_PHASE" {
+			watts *= 3
+		}
+	}
+	socket.KW = math.Round(watts/100) / 10
+	return socket
+}
+
+// openingHours returns the opening hours of the location, or nil when it does not give any.
+// Only the exceptional closings lasting whole days are kept, as holidays.
+func (loc *location) openingHours() *model.OpeningHours {
+	times := loc.OpeningTimes
+	if times == nil {
+		return nil
+	}
+	hours := &model.OpeningHours{TwentyFourSeven: times.TwentyFourSeven, TimeZone: loc.TimeZone}
+	for _, period := range times.RegularHours {
+		hours.Periods = append(hours.Periods, model.OpeningPeriod{
+			Days:  []time.Weekday{time.Weekday(period.Weekday % 7)},
+			Start: period.PeriodBegin,
+			End:   period.PeriodEnd,
+		})
+	}
+
+	zone := time.UTC
+	if tz, err := time.LoadLocation(loc.TimeZone); err == nil && loc.TimeZone != "" {
+		zone = tz
+	}
+	for _, closing := range times.ExceptionalClosings {
+		begin, end := closing.PeriodBegin.In(zone), closing.PeriodEnd.In(zone)
+		day := time.Date(begin.Year(), begin.Month(), begin.Day(), 0, 0, 0, 0, zone)
+		if !day.Equal(begin) {
+			day = day.AddDate(0, 0, 1)
+		}
+		for ; !day.AddDate(0, 0, 1).After(end); day = day.AddDate(0, 0, 1) {
+			hours.Holidays = append(hours.Holidays, model.Holiday{Date: day.Format(time.DateOnly)})
+		}
+	}
+	return hours
+}
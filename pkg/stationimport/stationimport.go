@@ -0,0 +1,39 @@
+// Package stationimport reads the charging stations of CSV and OCPI 2.2 Location files,
+// so they can be checked and imported in bulk.
+package stationimport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"california/pkg/model"
+)
+
+var ErrInvalidFile = errors.New("invalid station import file")
+
+// Record is a station read from an import file. The station is left out of the import when
+// the record has errors or a reason to be skipped.
+type Record struct {
+	// Lines are the lines of the station in a CSV file, or its position in an OCPI file, starting from 1.
+	Lines   []int
+	Station *model.Station
+	Errors  []string
+	Skip    string
+}
+
+func (r *Record) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// Read reads the records of a file in the given format.
+func Read(format model.ImportFormat, r io.Reader) ([]*Record, error) {
+	switch format {
+	case model.ImportCSV:
+		return ReadCSV(r)
+	case model.ImportOCPI:
+		return ReadOCPI(r)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidFile, format)
+	}
+}
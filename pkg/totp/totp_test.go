@@ -0,0 +1,91 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC 6238 vectors have eight digits; the codes here are their last six.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCode(t *testing.T) {
+	for _, tt := range rfcVectors {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestCodeLowerCaseSecret(t *testing.T) {
+	got, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", Step(time.Unix(59, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "287082" {
+		t.Errorf("Code = %s, want 287082", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// 1111111111 is the 37037037th step; its code is checked from the steps around it.
+	at := time.Unix(1111111111, 0)
+	step := Step(at)
+	tests := []struct {
+		name     string
+		at       time.Time
+		code     string
+		wantOK   bool
+		wantStep int64
+	}{
+		{name: "same step", at: at, code: "050471", wantOK: true, wantStep: step},
+		{name: "one step late", at: at.Add(Period), code: "050471", wantOK: true, wantStep: step},
+		{name: "one step early", at: at.Add(-Period), code: "050471", wantOK: true, wantStep: step},
+		{name: "two steps late", at: at.Add(2 * Period), code: "050471"},
+		{name: "two steps early", at: at.Add(-2 * Period), code: "050471"},
+		{name: "wrong code", at: at, code: "123456"},
+		{name: "eight digits", at: at, code: "14050471"},
+		{name: "empty", at: at, code: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := Validate(rfcSecret, tt.code, tt.at)
+			if ok != tt.wantOK {
+				t.Fatalf("Validate ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && gotStep != tt.wantStep {
+				t.Errorf("Validate step = %d, want %d", gotStep, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+}
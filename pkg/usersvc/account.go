@@ -0,0 +1,204 @@
+package usersvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/mailer"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	verificationTokenTTL = 48 * time.Hour
+	resetTokenTTL        = time.Hour
+	minPasswordLength    = 8
+)
+
+var (
+	ErrInvalidEmail         = errors.New("invalid email address")
+	ErrInvalidPassword      = fmt.Errorf("password must have at least %d characters", minPasswordLength)
+	ErrInvalidUserToken     = errors.New("invalid or expired token")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+// SendVerificationEmail mails a new email verification link to the current user.
+func (s *userService) SendVerificationEmail(ctx context.Context) error {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// VerifyEmail marks the email address of the user the token was mailed to as verified.
+func (s *userService) VerifyEmail(ctx context.Context, token string) error {
+	_, user, err := s.redeemUserToken(ctx, token, model.VerifyEmail)
+	if err != nil {
+		return err
+	}
+	return s.store.SetUserEmailVerified(ctx, user.ID)
+}
+
+// ForgotPassword mails a password reset link to the user with the email address. It succeeds when there is
+// no such user too, so it does not tell which addresses are registered.
+func (s *userService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.store.GetUserByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	token, err := s.issueUserToken(ctx, user, model.ResetPassword, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"Choose a new password with the link below within an hour:\n\n%s\n\n"+
+			"If it was not you, you can ignore this email and your password stays the same.",
+			user.Name, s.link("/reset-password", token)),
+	})
+}
+
+// ResetPassword replaces the password of the user the token was mailed to and ends all the sessions of the user.
+// As the user got the token by email, the email address is verified as well.
+func (s *userService) ResetPassword(ctx context.Context, token, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	_, user, err := s.redeemUserToken(ctx, token, model.ResetPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedPass, err := helpers.HashRegisterPassword(password)
+	if err != nil {
+		return err
+	}
+	if err = s.store.SetUserPassword(ctx, user.ID, hashedPass); err != nil {
+		return err
+	}
+	if err = s.store.SetUserEmailVerified(ctx, user.ID); err != nil {
+		return err
+	}
+	return s.store.RevokeUserRefreshTokens(ctx, user.ID)
+}
+
+func (s *userService) sendVerification(ctx context.Context, user *model.User) error {
+	token, err := s.issueUserToken(ctx, user, model.VerifyEmail, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address with the link below within two days:\n\n%s",
+			user.Name, s.link("/verify-email", token)),
+	})
+}
+
+// issueUserToken stores a new token of the user for the purpose and returns it.
+func (s *userService) issueUserToken(ctx context.Context, user *model.User, purpose model.TokenPurpose, ttl time.Duration) (string, error) {
+	token, err := helpers.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = s.store.InsertUserToken(ctx, &model.UserToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: helpers.HashToken(token),
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeemUserToken uses up the token and returns it with its user.
+func (s *userService) redeemUserToken(ctx context.Context, token string, purpose model.TokenPurpose) (*model.UserToken, *model.User, error) {
+	stored, user, err := s.lookupUserToken(ctx, token, purpose)
+	if err != nil {
+		return nil, nil, err
+	}
+	used, err := s.store.UseUserToken(ctx, stored.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !used {
+		return nil, nil, ErrInvalidUserToken
+	}
+	return stored, user, nil
+}
+
+// lookupUserToken returns the token with its user, unless the token is no good. A token is only good once,
+// before it expires, for one of the purposes and while its user keeps the email address it was given for.
+func (s *userService) lookupUserToken(ctx context.Context, token string, purposes ...model.TokenPurpose) (*model.UserToken, *model.User, error) {
+	stored, err := s.store.GetUserTokenByHash(ctx, helpers.HashToken(token))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrInvalidUserToken
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if !slices.Contains(purposes, stored.Purpose) || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, nil, ErrInvalidUserToken
+	}
+
+	user, err := s.store.GetUserById(ctx, stored.UserID.Hex())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrInvalidUserToken
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if user.Email != stored.Email {
+		return nil, nil, ErrInvalidUserToken
+	}
+	return stored, user, nil
+}
+
+// link returns the link of the client page handling the token, or the token alone when the address
+// of the client is not configured.
+func (s *userService) link(path, token string) string {
+	if s.appURL == "" {
+		return token
+	}
+	return strings.TrimSuffix(s.appURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// validateEmail returns the address without the spaces around it, or ErrInvalidEmail when it is not
+// a bare email address.
+func validateEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// validatePassword returns ErrInvalidPassword when the password is too short to be chosen.
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return ErrInvalidPassword
+	}
+	return nil
+}
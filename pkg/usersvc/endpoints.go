@@ -3,47 +3,105 @@ package usersvc
 import (
 	"context"
 
+	"california/pkg/auth"
 	"california/pkg/model"
+	"california/pkg/repository"
 	"github.com/go-kit/kit/endpoint"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type EndPoints struct {
-	RegisterEndpoint        endpoint.Endpoint
-	LoginEndpoint           endpoint.Endpoint
-	VehicleRegisterEndpoint endpoint.Endpoint
-	GetMeEndpoint           endpoint.Endpoint
-	UpdateUserEndpoint      endpoint.Endpoint
-	UpdateVehicleEndpoint   endpoint.Endpoint
-	GetUsersEndpoint        endpoint.Endpoint
-	SearchUsers             endpoint.Endpoint
-	DeleteUser              endpoint.Endpoint
-}
-
-func MakeServerEndpoints(c context.Context, s UserService) EndPoints {
+	RegisterEndpoint         endpoint.Endpoint
+	LoginEndpoint            endpoint.Endpoint
+	VehicleRegisterEndpoint  endpoint.Endpoint
+	GetMeEndpoint            endpoint.Endpoint
+	UpdateUserEndpoint       endpoint.Endpoint
+	UpdateVehicleEndpoint    endpoint.Endpoint
+	GetUsersEndpoint         endpoint.Endpoint
+	SearchUsers              endpoint.Endpoint
+	DeleteUser               endpoint.Endpoint
+	CatalogMakesEndpoint     endpoint.Endpoint
+	CatalogModelsEndpoint    endpoint.Endpoint
+	CatalogTrimsEndpoint     endpoint.Endpoint
+	ListVehiclesEndpoint     endpoint.Endpoint
+	GetVehicleEndpoint       endpoint.Endpoint
+	DeleteVehicleEndpoint    endpoint.Endpoint
+	DefaultVehicleEndpoint   endpoint.Endpoint
+	ListFavoritesEndpoint    endpoint.Endpoint
+	AddFavoriteEndpoint      endpoint.Endpoint
+	RemoveFavoriteEndpoint   endpoint.Endpoint
+	OrderFavoritesEndpoint   endpoint.Endpoint
+	ListPlacesEndpoint       endpoint.Endpoint
+	AddPlaceEndpoint         endpoint.Endpoint
+	UpdatePlaceEndpoint      endpoint.Endpoint
+	DeletePlaceEndpoint      endpoint.Endpoint
+	OrderPlacesEndpoint      endpoint.Endpoint
+	SendVerificationEndpoint endpoint.Endpoint
+	VerifyEmailEndpoint      endpoint.Endpoint
+	ForgotPasswordEndpoint   endpoint.Endpoint
+	ResetPasswordEndpoint    endpoint.Endpoint
+	VerifyLoginEndpoint      endpoint.Endpoint
+	LoginEnrollmentEndpoint  endpoint.Endpoint
+	EnrollTwoFactorEndpoint  endpoint.Endpoint
+	ConfirmTwoFactorEndpoint endpoint.Endpoint
+	RecoveryCodesEndpoint    endpoint.Endpoint
+	DisableTwoFactorEndpoint endpoint.Endpoint
+	UnlockAccountEndpoint    endpoint.Endpoint
+	UnlockUserEndpoint       endpoint.Endpoint
+}
+
+func MakeServerEndpoints(s UserService, keys jwt.Keyfunc) EndPoints {
+	authenticated := auth.NewParser(keys)
 	return EndPoints{
-		RegisterEndpoint:        MakeRegisterEndpoint(s),
-		LoginEndpoint:           MakeLoginEndpoint(s),
-		VehicleRegisterEndpoint: MakeVehicleRegisterEndpoint(c, s),
-		GetMeEndpoint:           MakeGetMeEndpoint(c, s),
-		UpdateUserEndpoint:      MakeUpdateUserEndpoint(c, s),
-		UpdateVehicleEndpoint:   MakeUpdateVehicleEndpoint(c, s),
-		GetUsersEndpoint:        MakeListAllUsersEndpoint(c, s),
-		SearchUsers:             MakeSearchUsersEndpoint(c, s),
-		DeleteUser:              MakeDeleteUserEndpoint(c, s),
+		RegisterEndpoint:         MakeRegisterEndpoint(s),
+		LoginEndpoint:            MakeLoginEndpoint(s),
+		VehicleRegisterEndpoint:  authenticated(MakeVehicleRegisterEndpoint(s)),
+		GetMeEndpoint:            authenticated(MakeGetMeEndpoint(s)),
+		UpdateUserEndpoint:       authenticated(MakeUpdateUserEndpoint(s)),
+		UpdateVehicleEndpoint:    authenticated(MakeUpdateVehicleEndpoint(s)),
+		GetUsersEndpoint:         authenticated(MakeListAllUsersEndpoint(s)),
+		SearchUsers:              authenticated(MakeSearchUsersEndpoint(s)),
+		DeleteUser:               authenticated(MakeDeleteUserEndpoint(s)),
+		CatalogMakesEndpoint:     authenticated(MakeCatalogMakesEndpoint(s)),
+		CatalogModelsEndpoint:    authenticated(MakeCatalogModelsEndpoint(s)),
+		CatalogTrimsEndpoint:     authenticated(MakeCatalogTrimsEndpoint(s)),
+		ListVehiclesEndpoint:     authenticated(MakeListVehiclesEndpoint(s)),
+		GetVehicleEndpoint:       authenticated(MakeGetVehicleEndpoint(s)),
+		DeleteVehicleEndpoint:    authenticated(MakeDeleteVehicleEndpoint(s)),
+		DefaultVehicleEndpoint:   authenticated(MakeDefaultVehicleEndpoint(s)),
+		ListFavoritesEndpoint:    authenticated(MakeListFavoritesEndpoint(s)),
+		AddFavoriteEndpoint:      authenticated(MakeAddFavoriteEndpoint(s)),
+		RemoveFavoriteEndpoint:   authenticated(MakeRemoveFavoriteEndpoint(s)),
+		OrderFavoritesEndpoint:   authenticated(MakeOrderFavoritesEndpoint(s)),
+		ListPlacesEndpoint:       authenticated(MakeListPlacesEndpoint(s)),
+		AddPlaceEndpoint:         authenticated(MakeAddPlaceEndpoint(s)),
+		UpdatePlaceEndpoint:      authenticated(MakeUpdatePlaceEndpoint(s)),
+		DeletePlaceEndpoint:      authenticated(MakeDeletePlaceEndpoint(s)),
+		OrderPlacesEndpoint:      authenticated(MakeOrderPlacesEndpoint(s)),
+		SendVerificationEndpoint: authenticated(MakeSendVerificationEndpoint(s)),
+		VerifyEmailEndpoint:      MakeVerifyEmailEndpoint(s),
+		ForgotPasswordEndpoint:   MakeForgotPasswordEndpoint(s),
+		ResetPasswordEndpoint:    MakeResetPasswordEndpoint(s),
+		VerifyLoginEndpoint:      MakeVerifyLoginEndpoint(s),
+		LoginEnrollmentEndpoint:  MakeLoginEnrollmentEndpoint(s),
+		EnrollTwoFactorEndpoint:  authenticated(MakeEnrollTwoFactorEndpoint(s)),
+		ConfirmTwoFactorEndpoint: authenticated(MakeConfirmTwoFactorEndpoint(s)),
+		RecoveryCodesEndpoint:    authenticated(MakeRecoveryCodesEndpoint(s)),
+		DisableTwoFactorEndpoint: authenticated(MakeDisableTwoFactorEndpoint(s)),
+		UnlockAccountEndpoint:    MakeUnlockAccountEndpoint(s),
+		UnlockUserEndpoint:       authenticated(MakeUnlockUserEndpoint(s)),
 	}
 }
 
 type BaseResponse struct {
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"` // Asks for the next page of a list as its after parameter.
 }
 
-func MakeDeleteUserEndpoint(c context.Context, s UserService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(deleteUserRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		e := s.DeleteUser(c)
+func MakeDeleteUserEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		e := s.DeleteUser(ctx)
 		if e != nil {
 			return deleteUserResponse{
 				Err: e,
@@ -58,9 +116,7 @@ func MakeDeleteUserEndpoint(c context.Context, s UserService) endpoint.Endpoint
 	}
 }
 
-type deleteUserRequest struct {
-	Context context.Context
-}
+type deleteUserRequest struct{}
 
 type deleteUserResponse struct {
 	*BaseResponse
@@ -69,19 +125,18 @@ type deleteUserResponse struct {
 
 func (e deleteUserResponse) error() error { return e.Err }
 
-func MakeSearchUsersEndpoint(c context.Context, s UserService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeSearchUsersEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(searchUsersRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		users, e := s.SearchUsers(c, req.Name)
+		users, nextCursor, e := s.SearchUsers(ctx, req.Name, req.Page)
 		if e != nil {
 			return searchUsersResponse{
 				Err: e,
 			}, e
 		}
 		return BaseResponse{
-			Message: "success",
+			Message:    "success",
+			NextCursor: nextCursor,
 			Data: searchUsersResponse{
 				Users: users,
 				Err:   e,
@@ -91,8 +146,8 @@ func MakeSearchUsersEndpoint(c context.Context, s UserService) endpoint.Endpoint
 }
 
 type searchUsersRequest struct {
-	Context context.Context
-	Name    string
+	Name string
+	Page repository.Page
 }
 
 type searchUsersResponse struct {
@@ -106,7 +161,7 @@ func (e searchUsersResponse) error() error { return e.Err }
 func MakeRegisterEndpoint(s UserService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(registerRequest)
-		insertedUser, e := s.Register(ctx, req.User)
+		insertedUser, tokens, e := s.Register(ctx, req.User, req.DeviceID)
 		if e != nil {
 			return registerResponse{
 				Err: e,
@@ -117,30 +172,25 @@ func MakeRegisterEndpoint(s UserService) endpoint.Endpoint {
 		return BaseResponse{
 			Message: "success",
 			Data: registerResponse{
-				UserType: insertedUser.UserType,
-				Token:    req.User.RefreshToken,
-				Err:      e,
+				UserType:  insertedUser.UserType,
+				TokenPair: tokens,
+				Err:       e,
 			},
 		}, nil
-
-		//return registerResponse{
-		//	UserType: insertedUser.UserType,
-		//	Token:    req.User.RefreshToken,
-		//	Err:      e,
-		//}, nil
 	}
 }
 
 // registerRequest is used to decode json request body of register endpoint's.
 type registerRequest struct {
-	User *model.User
+	User     *model.User
+	DeviceID string
 }
 
 // registerResponse is used to encode json response body of register endpoint's.
 type registerResponse struct {
 	*BaseResponse
+	*model.TokenPair
 	UserType model.UserType `json:"user_type,omitempty"`
-	Token    string         `json:"token,omitempty"`
 	Err      error          `json:"err,omitempty"`
 }
 
@@ -149,7 +199,8 @@ func (e registerResponse) error() error { return e.Err }
 func MakeLoginEndpoint(s UserService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(loginRequest)
-		user, e := s.Login(ctx, req.Email, req.Password)
+		ctx = context.WithValue(ctx, clientIPKey, req.ClientIP)
+		user, tokens, challenge, e := s.Login(ctx, req.Email, req.Password, req.DeviceID)
 		if e != nil {
 			return loginResponse{
 				Err: e,
@@ -159,9 +210,10 @@ func MakeLoginEndpoint(s UserService) endpoint.Endpoint {
 		return BaseResponse{
 			Message: "success",
 			Data: loginResponse{
-				UserType: user.UserType,
-				Token:    user.RefreshToken,
-				Err:      e,
+				UserType:  user.UserType,
+				TokenPair: tokens,
+				Challenge: challenge,
+				Err:       e,
 			},
 		}, nil
 	}
@@ -170,23 +222,27 @@ func MakeLoginEndpoint(s UserService) endpoint.Endpoint {
 type loginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	DeviceID string `json:"-"`
+	ClientIP string `json:"-"`
 }
 
+// loginResponse holds the tokens of the user, or the challenge to finish the login with when the user needs
+// a second factor. The recovery codes are only there when the login finished an enrolment.
 type loginResponse struct {
 	*BaseResponse
-	UserType model.UserType `json:"user_type,omitempty"`
-	Token    string         `json:"token,omitempty"`
-	Err      error          `json:"err,omitempty"`
+	*model.TokenPair
+	UserType      model.UserType   `json:"user_type,omitempty"`
+	Challenge     *model.Challenge `json:"challenge,omitempty"`
+	RecoveryCodes []string         `json:"recovery_codes,omitempty"`
+	Err           error            `json:"err,omitempty"`
 }
 
 func (e loginResponse) error() error { return e.Err }
 
-func MakeVehicleRegisterEndpoint(c context.Context, s UserService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeVehicleRegisterEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(vehicleRegisterRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		e := s.VehicleRegister(c, req.Vehicle)
+		vehicle, e := s.VehicleRegister(ctx, req.Vehicle)
 		if e != nil {
 			return vehicleRegisterResponse{
 				Err: e,
@@ -195,30 +251,28 @@ func MakeVehicleRegisterEndpoint(c context.Context, s UserService) endpoint.Endp
 		return BaseResponse{
 			Message: "success",
 			Data: vehicleRegisterResponse{
-				Err: e,
+				Vehicle: vehicle,
+				Err:     e,
 			},
 		}, nil
 	}
 }
 
 type vehicleRegisterRequest struct {
-	Context context.Context
 	Vehicle *model.Vehicle
 }
 
 type vehicleRegisterResponse struct {
 	*BaseResponse
-	Err error `json:"err,omitempty"`
+	Vehicle *model.Vehicle `json:"vehicle,omitempty"`
+	Err     error          `json:"err,omitempty"`
 }
 
 func (e vehicleRegisterResponse) error() error { return e.Err }
 
-func MakeGetMeEndpoint(c context.Context, s UserService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(getMeRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		user, e := s.GetMe(c)
+func MakeGetMeEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		user, e := s.GetMe(ctx)
 		if e != nil {
 			return getMeResponse{
 				Err: e,
@@ -235,9 +289,7 @@ func MakeGetMeEndpoint(c context.Context, s UserService) endpoint.Endpoint {
 	}
 }
 
-type getMeRequest struct {
-	Context context.Context
-}
+type getMeRequest struct{}
 
 type getMeResponse struct {
 	*BaseResponse
@@ -247,12 +299,10 @@ type getMeResponse struct {
 
 func (e getMeResponse) error() error { return e.Err }
 
-func MakeUpdateUserEndpoint(c context.Context, s UserService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeUpdateUserEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(updateUserRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		e := s.UpdateUserInfo(c, req.User)
+		e := s.UpdateUserInfo(ctx, req.User)
 		if e != nil {
 			return updateUserResponse{
 				Err: e,
@@ -268,8 +318,7 @@ func MakeUpdateUserEndpoint(c context.Context, s UserService) endpoint.Endpoint
 }
 
 type updateUserRequest struct {
-	Context context.Context
-	User    *model.User
+	User *model.User
 }
 
 type updateUserResponse struct {
@@ -279,12 +328,10 @@ type updateUserResponse struct {
 
 func (e updateUserResponse) error() error { return e.Err }
 
-func MakeUpdateVehicleEndpoint(c context.Context, s UserService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeUpdateVehicleEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(updateVehicleRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		e := s.UpdateVehicleInfo(c, req.Vehicle)
+		e := s.UpdateVehicleInfo(ctx, req.Vehicle, req.VehicleID)
 		if e != nil {
 			return updateVehicleResponse{
 				Err: e,
@@ -300,8 +347,8 @@ func MakeUpdateVehicleEndpoint(c context.Context, s UserService) endpoint.Endpoi
 }
 
 type updateVehicleRequest struct {
-	Context context.Context
-	Vehicle *model.Vehicle
+	Vehicle   *model.Vehicle
+	VehicleID string // The default vehicle is updated when empty.
 }
 
 type updateVehicleResponse struct {
@@ -311,19 +358,18 @@ type updateVehicleResponse struct {
 
 func (e updateVehicleResponse) error() error { return e.Err }
 
-func MakeListAllUsersEndpoint(c context.Context, s UserService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (response interface{}, err error) {
+func MakeListAllUsersEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(listAllUsersRequest)
-		jwt := req.Context.Value("jwt")
-		c = context.WithValue(c, "Authorization", jwt)
-		users, e := s.ListAllUsers(c)
+		users, nextCursor, e := s.ListAllUsers(ctx, req.Page)
 		if e != nil {
 			return listAllUsersResponse{
 				Err: e,
 			}, e
 		}
 		return BaseResponse{
-			Message: "success",
+			Message:    "success",
+			NextCursor: nextCursor,
 			Data: listAllUsersResponse{
 				Users: users,
 				Err:   e,
@@ -333,7 +379,7 @@ func MakeListAllUsersEndpoint(c context.Context, s UserService) endpoint.Endpoin
 }
 
 type listAllUsersRequest struct {
-	Context context.Context
+	Page repository.Page
 }
 
 type listAllUsersResponse struct {
@@ -343,3 +389,608 @@ type listAllUsersResponse struct {
 }
 
 func (e listAllUsersResponse) error() error { return e.Err }
+
+func MakeCatalogMakesEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		makes, e := s.CatalogMakes(ctx)
+		if e != nil {
+			return catalogResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: catalogResponse{
+				Makes: makes,
+				Err:   e,
+			},
+		}, nil
+	}
+}
+
+func MakeCatalogModelsEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(catalogRequest)
+		models, e := s.CatalogModels(ctx, req.Make)
+		if e != nil {
+			return catalogResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: catalogResponse{
+				Models: models,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+func MakeCatalogTrimsEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(catalogRequest)
+		trims, e := s.CatalogTrims(ctx, req.Make, req.Model)
+		if e != nil {
+			return catalogResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: catalogResponse{
+				Trims: trims,
+				Err:   e,
+			},
+		}, nil
+	}
+}
+
+type catalogRequest struct {
+	Make  string
+	Model string
+}
+
+type catalogResponse struct {
+	*BaseResponse
+	Makes  []string                `json:"makes,omitempty"`
+	Models []string                `json:"models,omitempty"`
+	Trims  []*model.CatalogVehicle `json:"trims,omitempty"`
+	Err    error                   `json:"err,omitempty"`
+}
+
+func (e catalogResponse) error() error { return e.Err }
+
+func MakeListVehiclesEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		vehicles, e := s.ListVehicles(ctx)
+		if e != nil {
+			return vehicleResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: vehicleResponse{
+				Vehicles: vehicles,
+				Err:      e,
+			},
+		}, nil
+	}
+}
+
+func MakeGetVehicleEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(vehicleRequest)
+		vehicle, e := s.GetVehicle(ctx, req.VehicleID)
+		if e != nil {
+			return vehicleResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: vehicleResponse{
+				Vehicle: vehicle,
+				Err:     e,
+			},
+		}, nil
+	}
+}
+
+func MakeDeleteVehicleEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(vehicleRequest)
+		e := s.DeleteVehicle(ctx, req.VehicleID)
+		if e != nil {
+			return vehicleResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: vehicleResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeDefaultVehicleEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(vehicleRequest)
+		e := s.SetDefaultVehicle(ctx, req.VehicleID)
+		if e != nil {
+			return vehicleResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: vehicleResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type vehicleRequest struct {
+	VehicleID string
+}
+
+type vehicleResponse struct {
+	*BaseResponse
+	Vehicle  *model.Vehicle  `json:"vehicle,omitempty"`
+	Vehicles []model.Vehicle `json:"vehicles,omitempty"`
+	Err      error           `json:"err,omitempty"`
+}
+
+func (e vehicleResponse) error() error { return e.Err }
+
+func MakeListFavoritesEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		favorites, e := s.ListFavorites(ctx)
+		if e != nil {
+			return favoriteResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: favoriteResponse{
+				Favorites: favorites,
+				Err:       e,
+			},
+		}, nil
+	}
+}
+
+func MakeAddFavoriteEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(favoriteRequest)
+		e := s.AddFavorite(ctx, req.StationID)
+		if e != nil {
+			return favoriteResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: favoriteResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeRemoveFavoriteEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(favoriteRequest)
+		e := s.RemoveFavorite(ctx, req.StationID)
+		if e != nil {
+			return favoriteResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: favoriteResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeOrderFavoritesEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(favoriteRequest)
+		e := s.ReorderFavorites(ctx, req.Order)
+		if e != nil {
+			return favoriteResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: favoriteResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type favoriteRequest struct {
+	StationID string   `json:"station_id"`
+	Order     []string `json:"order"` // The ids of all the favourite stations, in their new order.
+}
+
+type favoriteResponse struct {
+	*BaseResponse
+	Favorites []*model.Station `json:"favorites,omitempty"`
+	Err       error            `json:"err,omitempty"`
+}
+
+func (e favoriteResponse) error() error { return e.Err }
+
+func MakeListPlacesEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		places, e := s.ListPlaces(ctx)
+		if e != nil {
+			return placeResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: placeResponse{
+				Places: places,
+				Err:    e,
+			},
+		}, nil
+	}
+}
+
+func MakeAddPlaceEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(placeRequest)
+		place, e := s.AddPlace(ctx, req.Place)
+		if e != nil {
+			return placeResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: placeResponse{
+				Place: place,
+				Err:   e,
+			},
+		}, nil
+	}
+}
+
+func MakeUpdatePlaceEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(placeRequest)
+		e := s.UpdatePlace(ctx, req.PlaceID, req.Place)
+		if e != nil {
+			return placeResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: placeResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeDeletePlaceEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(placeRequest)
+		e := s.DeletePlace(ctx, req.PlaceID)
+		if e != nil {
+			return placeResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: placeResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeOrderPlacesEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(placeRequest)
+		e := s.ReorderPlaces(ctx, req.Order)
+		if e != nil {
+			return placeResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: placeResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+type placeRequest struct {
+	PlaceID string
+	Place   *model.Place
+	Order   []string // The ids of all the places, in their new order.
+}
+
+type placeResponse struct {
+	*BaseResponse
+	Place  *model.Place  `json:"place,omitempty"`
+	Places []model.Place `json:"places,omitempty"`
+	Err    error         `json:"err,omitempty"`
+}
+
+func (e placeResponse) error() error { return e.Err }
+
+func MakeSendVerificationEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		e := s.SendVerificationEmail(ctx)
+		if e != nil {
+			return accountResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: accountResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeVerifyEmailEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(accountRequest)
+		e := s.VerifyEmail(ctx, req.Token)
+		if e != nil {
+			return accountResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: accountResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeForgotPasswordEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(accountRequest)
+		e := s.ForgotPassword(ctx, req.Email)
+		if e != nil {
+			return accountResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: accountResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeResetPasswordEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(accountRequest)
+		e := s.ResetPassword(ctx, req.Token, req.Password)
+		if e != nil {
+			return accountResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: accountResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+// accountRequest is used to decode the json request bodies of the email verification, password reset
+// and unlock endpoints.
+type accountRequest struct {
+	Email    string `json:"email"`
+	Token    string `json:"token"`
+	Password string `json:"password"`
+	UserID   string `json:"-"`
+}
+
+type accountResponse struct {
+	*BaseResponse
+	Err error `json:"err,omitempty"`
+}
+
+func (e accountResponse) error() error { return e.Err }
+
+func MakeVerifyLoginEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(twoFactorRequest)
+		ctx = context.WithValue(ctx, clientIPKey, req.ClientIP)
+		user, tokens, recoveryCodes, e := s.VerifyLogin(ctx, req.ChallengeToken, req.Code, req.DeviceID)
+		if e != nil {
+			return loginResponse{
+				Err: e,
+			}, e
+		}
+
+		return BaseResponse{
+			Message: "success",
+			Data: loginResponse{
+				UserType:      user.UserType,
+				TokenPair:     tokens,
+				RecoveryCodes: recoveryCodes,
+				Err:           e,
+			},
+		}, nil
+	}
+}
+
+func MakeLoginEnrollmentEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(twoFactorRequest)
+		enrollment, e := s.StartLoginEnrollment(ctx, req.ChallengeToken)
+		if e != nil {
+			return twoFactorResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: twoFactorResponse{
+				TOTPEnrollment: enrollment,
+				Err:            e,
+			},
+		}, nil
+	}
+}
+
+func MakeEnrollTwoFactorEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		enrollment, e := s.EnrollTwoFactor(ctx)
+		if e != nil {
+			return twoFactorResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: twoFactorResponse{
+				TOTPEnrollment: enrollment,
+				Err:            e,
+			},
+		}, nil
+	}
+}
+
+func MakeConfirmTwoFactorEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(twoFactorRequest)
+		recoveryCodes, e := s.ConfirmTwoFactor(ctx, req.Code)
+		if e != nil {
+			return twoFactorResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: twoFactorResponse{
+				RecoveryCodes: recoveryCodes,
+				Err:           e,
+			},
+		}, nil
+	}
+}
+
+func MakeRecoveryCodesEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(twoFactorRequest)
+		recoveryCodes, e := s.RegenerateRecoveryCodes(ctx, req.Code)
+		if e != nil {
+			return twoFactorResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: twoFactorResponse{
+				RecoveryCodes: recoveryCodes,
+				Err:           e,
+			},
+		}, nil
+	}
+}
+
+func MakeDisableTwoFactorEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(twoFactorRequest)
+		e := s.DisableTwoFactor(ctx, req.Code)
+		if e != nil {
+			return twoFactorResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: twoFactorResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeUnlockAccountEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(accountRequest)
+		e := s.UnlockAccount(ctx, req.Token)
+		if e != nil {
+			return accountResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: accountResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+func MakeUnlockUserEndpoint(s UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(accountRequest)
+		e := s.UnlockUser(ctx, req.UserID)
+		if e != nil {
+			return accountResponse{
+				Err: e,
+			}, e
+		}
+		return BaseResponse{
+			Message: "success",
+			Data: accountResponse{
+				Err: e,
+			},
+		}, nil
+	}
+}
+
+// twoFactorRequest is used to decode the json request bodies of the two-factor authentication endpoints.
+// The code is a TOTP code or a recovery code.
+type twoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	DeviceID       string `json:"-"`
+	ClientIP       string `json:"-"`
+}
+
+type twoFactorResponse struct {
+	*BaseResponse
+	*model.TOTPEnrollment
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	Err           error    `json:"err,omitempty"`
+}
+
+func (e twoFactorResponse) error() error { return e.Err }
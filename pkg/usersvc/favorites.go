@@ -0,0 +1,247 @@
+package usersvc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"california/pkg/auth"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxFavorites     = 100
+	maxPlaces        = 20
+	maxPlaceNameSize = 50
+)
+
+var (
+	ErrStationNotFound  = errors.New("station not found")
+	ErrFavoriteNotFound = errors.New("station is not a favourite")
+	ErrTooManyFavorites = errors.New("too many favourite stations")
+	ErrPlaceNotFound    = errors.New("place not found")
+	ErrInvalidPlace     = errors.New("invalid place")
+	ErrTooManyPlaces    = errors.New("too many places")
+	ErrInvalidOrder     = errors.New("order must list every item once")
+)
+
+// ListFavorites returns the favourite stations of the user in the user's order.
+// Stations which have been removed since they were bookmarked are left out.
+func (s *userService) ListFavorites(ctx context.Context) ([]*model.Station, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.FavoriteStations) == 0 {
+		return []*model.Station{}, nil
+	}
+	stations, err := s.store.FindStationByFilter(ctx, bson.M{"_id": bson.M{"$in": user.FavoriteStations}})
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[primitive.ObjectID]*model.Station, len(stations))
+	for _, station := range stations {
+		byId[station.ID] = station
+	}
+	favorites := make([]*model.Station, 0, len(stations))
+	for _, id := range user.FavoriteStations {
+		if station, ok := byId[id]; ok {
+			favorites = append(favorites, station)
+		}
+	}
+	return favorites, nil
+}
+
+// AddFavorite bookmarks the station at the end of the favourites. Bookmarking a favourite again does nothing.
+func (s *userService) AddFavorite(ctx context.Context, stationId string) error {
+	oid, err := primitive.ObjectIDFromHex(stationId)
+	if err != nil {
+		return ErrStationNotFound
+	}
+	if _, err = s.store.GetStationById(ctx, stationId); errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrStationNotFound
+	} else if err != nil {
+		return err
+	}
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if user.IsFavorite(oid) {
+		return nil
+	}
+	if len(user.FavoriteStations) >= maxFavorites {
+		return ErrTooManyFavorites
+	}
+	return s.store.SetFavoriteStations(ctx, append(user.FavoriteStations, oid))
+}
+
+func (s *userService) RemoveFavorite(ctx context.Context, stationId string) error {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	oid, err := primitive.ObjectIDFromHex(stationId)
+	if err != nil || !user.IsFavorite(oid) {
+		return ErrFavoriteNotFound
+	}
+	favorites := make([]primitive.ObjectID, 0, len(user.FavoriteStations)-1)
+	for _, id := range user.FavoriteStations {
+		if id != oid {
+			favorites = append(favorites, id)
+		}
+	}
+	return s.store.SetFavoriteStations(ctx, favorites)
+}
+
+// ReorderFavorites sorts the favourites in the given order, which must list each of them once.
+func (s *userService) ReorderFavorites(ctx context.Context, stationIds []string) error {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	order, err := reorder(user.FavoriteStations, stationIds)
+	if err != nil {
+		return err
+	}
+	return s.store.SetFavoriteStations(ctx, order)
+}
+
+func (s *userService) ListPlaces(ctx context.Context) ([]model.Place, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Places == nil {
+		return []model.Place{}, nil
+	}
+	return user.Places, nil
+}
+
+// AddPlace saves the place at the end of the places of the user.
+func (s *userService) AddPlace(ctx context.Context, place *model.Place) (*model.Place, error) {
+	if err := validatePlace(place); err != nil {
+		return nil, err
+	}
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.Places) >= maxPlaces {
+		return nil, ErrTooManyPlaces
+	}
+	place.ID = primitive.NewObjectID()
+	if err = s.store.SetPlaces(ctx, append(user.Places, *place)); err != nil {
+		return nil, err
+	}
+	return place, nil
+}
+
+// UpdatePlace replaces the place with the given id, keeping its position.
+func (s *userService) UpdatePlace(ctx context.Context, placeId string, place *model.Place) error {
+	if err := validatePlace(place); err != nil {
+		return err
+	}
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	stored, err := placeOf(user, placeId)
+	if err != nil {
+		return err
+	}
+	place.ID = stored.ID
+	*stored = *place
+	return s.store.SetPlaces(ctx, user.Places)
+}
+
+func (s *userService) DeletePlace(ctx context.Context, placeId string) error {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	stored, err := placeOf(user, placeId)
+	if err != nil {
+		return err
+	}
+	places := make([]model.Place, 0, len(user.Places)-1)
+	for _, place := range user.Places {
+		if place.ID != stored.ID {
+			places = append(places, place)
+		}
+	}
+	return s.store.SetPlaces(ctx, places)
+}
+
+// ReorderPlaces sorts the places in the given order, which must list each of them once.
+func (s *userService) ReorderPlaces(ctx context.Context, placeIds []string) error {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]primitive.ObjectID, len(user.Places))
+	for i, place := range user.Places {
+		ids[i] = place.ID
+	}
+	order, err := reorder(ids, placeIds)
+	if err != nil {
+		return err
+	}
+	places := make([]model.Place, len(order))
+	for i, id := range order {
+		places[i] = *user.PlaceById(id)
+	}
+	return s.store.SetPlaces(ctx, places)
+}
+
+func (s *userService) currentUser(ctx context.Context) (*model.User, error) {
+	email := auth.Email(ctx)
+	return s.store.GetUserByEmail(ctx, email)
+}
+
+// reorder returns the ids in the given order, which must be a permutation of them.
+func reorder(ids []primitive.ObjectID, order []string) ([]primitive.ObjectID, error) {
+	if len(order) != len(ids) {
+		return nil, ErrInvalidOrder
+	}
+	remaining := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	sorted := make([]primitive.ObjectID, 0, len(ids))
+	for _, hex := range order {
+		oid, err := primitive.ObjectIDFromHex(hex)
+		if err != nil || !remaining[oid] {
+			return nil, ErrInvalidOrder
+		}
+		delete(remaining, oid)
+		sorted = append(sorted, oid)
+	}
+	return sorted, nil
+}
+
+func validatePlace(place *model.Place) error {
+	place.Name = strings.TrimSpace(place.Name)
+	if place.Name == "" || len(place.Name) > maxPlaceNameSize {
+		return ErrInvalidPlace
+	}
+	if place.Lat < -90 || place.Lat > 90 || place.Long < -180 || place.Long > 180 {
+		return ErrInvalidPlace
+	}
+	return nil
+}
+
+func placeOf(user *model.User, placeId string) (*model.Place, error) {
+	oid, err := primitive.ObjectIDFromHex(placeId)
+	if err != nil {
+		return nil, ErrPlaceNotFound
+	}
+	place := user.PlaceById(oid)
+	if place == nil {
+		return nil, ErrPlaceNotFound
+	}
+	return place, nil
+}
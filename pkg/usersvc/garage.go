@@ -0,0 +1,177 @@
+package usersvc
+
+import (
+	"context"
+	"errors"
+
+	"california/pkg/auth"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxVehicles is the size of the garage of a user.
+const maxVehicles = 10
+
+var (
+	ErrVehicleNotFound = errors.New("vehicle not found")
+	ErrGarageFull      = errors.New("garage is full")
+)
+
+// VehicleRegister adds the vehicle to the garage of the user. The first vehicle of a user is its default vehicle.
+func (s *userService) VehicleRegister(ctx context.Context, vehicle *model.Vehicle) (*model.Vehicle, error) {
+	if err := s.fillFromCatalog(vehicle); err != nil {
+		return nil, err
+	}
+	user, err := s.garage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.Vehicles) >= maxVehicles {
+		return nil, ErrGarageFull
+	}
+
+	vehicle.ID = primitive.NewObjectID()
+	if err = s.store.InsertVehicleToUser(ctx, user, vehicle); err != nil {
+		return nil, err
+	}
+	vehicle.Default = len(user.Vehicles) == 0
+	return vehicle, nil
+}
+
+// UpdateVehicleInfo replaces the vehicle with the given id, or the default vehicle when the id is empty.
+func (s *userService) UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle, vehicleId string) error {
+	if err := s.fillFromCatalog(vehicle); err != nil {
+		return err
+	}
+	user, err := s.garage(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stored *model.Vehicle
+	if vehicleId == "" {
+		stored = user.DefaultVehicle()
+	} else {
+		stored, err = vehicleOf(user, vehicleId)
+		if err != nil {
+			return err
+		}
+	}
+	if stored == nil {
+		// Users without a vehicle used to create it with an update.
+		_, err = s.VehicleRegister(ctx, vehicle)
+		return err
+	}
+
+	vehicle.ID = stored.ID
+	if err = s.store.UpdateVehicle(ctx, vehicle); errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrVehicleNotFound
+	}
+	return err
+}
+
+func (s *userService) ListVehicles(ctx context.Context) ([]model.Vehicle, error) {
+	user, err := s.garage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return markDefault(user), nil
+}
+
+func (s *userService) GetVehicle(ctx context.Context, vehicleId string) (*model.Vehicle, error) {
+	user, err := s.garage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vehicle, err := vehicleOf(user, vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	vehicle.Default = vehicle.ID == user.DefaultVehicle().ID
+	return vehicle, nil
+}
+
+// DeleteVehicle removes the vehicle from the garage. When it was the default vehicle, the first remaining one takes its place.
+func (s *userService) DeleteVehicle(ctx context.Context, vehicleId string) error {
+	user, err := s.garage(ctx)
+	if err != nil {
+		return err
+	}
+	vehicle, err := vehicleOf(user, vehicleId)
+	if err != nil {
+		return err
+	}
+	if err = s.store.DeleteVehicle(ctx, vehicle.ID); errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrVehicleNotFound
+	}
+	return err
+}
+
+func (s *userService) SetDefaultVehicle(ctx context.Context, vehicleId string) error {
+	user, err := s.garage(ctx)
+	if err != nil {
+		return err
+	}
+	vehicle, err := vehicleOf(user, vehicleId)
+	if err != nil {
+		return err
+	}
+	if err = s.store.SetDefaultVehicle(ctx, vehicle.ID); errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrVehicleNotFound
+	}
+	return err
+}
+
+// garage returns the user of the context. A vehicle registered before the garage is moved into it first,
+// so every vehicle the user sees has an id, and the empty one of the users who registered none is removed.
+func (s *userService) garage(ctx context.Context) (*model.User, error) {
+	email := auth.Email(ctx)
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.Vehicles) > 0 || user.Vehicle == nil {
+		return user, nil
+	}
+	legacy := user.LegacyVehicle()
+	if legacy == nil {
+		if err = s.store.UnsetLegacyVehicle(ctx, user.Email); err != nil {
+			return nil, err
+		}
+		user.Vehicle = nil
+		return user, nil
+	}
+
+	vehicle := *legacy
+	vehicle.ID = primitive.NewObjectID()
+	if err = s.store.InsertVehicleToUser(ctx, user, &vehicle); err != nil {
+		return nil, err
+	}
+	user.Vehicles = []model.Vehicle{vehicle}
+	user.Vehicle = nil
+	return user, nil
+}
+
+func vehicleOf(user *model.User, vehicleId string) (*model.Vehicle, error) {
+	oid, err := primitive.ObjectIDFromHex(vehicleId)
+	if err != nil {
+		return nil, ErrVehicleNotFound
+	}
+	vehicle := user.VehicleById(oid)
+	if vehicle == nil {
+		return nil, ErrVehicleNotFound
+	}
+	return vehicle, nil
+}
+
+// markDefault returns the vehicles of the user with the default one flagged.
+func markDefault(user *model.User) []model.Vehicle {
+	vehicles := append([]model.Vehicle(nil), user.Garage()...)
+	if def := user.DefaultVehicle(); def != nil {
+		for i := range vehicles {
+			vehicles[i].Default = vehicles[i].ID == def.ID
+		}
+	}
+	return vehicles
+}
@@ -0,0 +1,50 @@
+package usersvc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"california/pkg/auth"
+	"california/pkg/mailer"
+	"california/pkg/model"
+	"california/pkg/repository"
+)
+
+func TestGarageIgnoresEmptyLegacyVehicle(t *testing.T) {
+	store := repository.NewMemoryStore()
+	// The users registered before the garage all have a Vehicle, which is empty when they registered none.
+	user, err := store.InsertUser(context.Background(), &model.User{Email: testEmail, UserType: model.Normal, Vehicle: &model.Vehicle{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewUserService(store, nil, mailer.NewLogMailer(io.Discard, "noreply@example.com"), "https://example.com")
+	ctx := auth.WithClaims(context.Background(), &auth.Claims{Email: user.Email, UserID: user.ID.Hex(), UserType: user.UserType})
+
+	vehicles, err := s.ListVehicles(ctx)
+	if err != nil {
+		t.Fatalf("ListVehicles: %v", err)
+	}
+	if len(vehicles) != 0 {
+		t.Fatalf("ListVehicles = %+v, want no vehicle", vehicles)
+	}
+	stored, _ := store.GetUserByEmail(ctx, user.Email)
+	if stored.Vehicle != nil {
+		t.Fatalf("the empty legacy vehicle is still stored: %+v", stored.Vehicle)
+	}
+
+	vehicle, err := s.VehicleRegister(ctx, &model.Vehicle{Brand: "Togg", Model: "T10X", EngineType: model.Electric})
+	if err != nil {
+		t.Fatalf("VehicleRegister: %v", err)
+	}
+	if !vehicle.Default {
+		t.Error("the first vehicle registered is not the default one")
+	}
+	vehicles, err = s.ListVehicles(ctx)
+	if err != nil {
+		t.Fatalf("ListVehicles: %v", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].ID != vehicle.ID || !vehicles[0].Default {
+		t.Errorf("ListVehicles = %+v, want only the default Togg", vehicles)
+	}
+}
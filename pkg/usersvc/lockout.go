@@ -0,0 +1,167 @@
+package usersvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"california/pkg/mailer"
+	"california/pkg/model"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// An account waits longer and longer between its logins after accountFreeFailures failures in a row,
+	// and is locked after accountLockFailures of them.
+	accountFreeFailures = 3
+	accountLockFailures = 10
+	accountWindow       = 24 * time.Hour
+	lockDuration        = 30 * time.Minute
+	// An IP address may be shared by many users, so it fails more before it waits, and is never locked.
+	ipFreeFailures = 20
+	ipWindow       = time.Hour
+
+	baseLoginDelay = time.Second
+	maxLoginDelay  = 5 * time.Minute
+	unlockTokenTTL = 24 * time.Hour
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed logins, try again later")
+	ErrAccountLocked   = errors.New("account is locked after too many failed logins")
+)
+
+type contextKey int
+
+// clientIPKey is the context key of the IP address of the client logging in.
+const clientIPKey contextKey = iota
+
+// RetryError is a refused login, which may be tried again after RetryAfter.
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string { return e.Err.Error() }
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// UnlockAccount lifts the lock of the account the unlock link was mailed for.
+func (s *userService) UnlockAccount(ctx context.Context, token string) error {
+	_, user, err := s.redeemUserToken(ctx, token, model.UnlockAccount)
+	if err != nil {
+		return err
+	}
+	return s.store.ResetLoginAttempts(ctx, accountKey(user.Email))
+}
+
+// UnlockUser lifts the lock of the account of a user and forgets its failed logins.
+func (s *userService) UnlockUser(ctx context.Context, userId string) error {
+	user, err := s.store.GetUserById(ctx, userId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return s.store.ResetLoginAttempts(ctx, accountKey(user.Email))
+}
+
+// checkLoginAllowed refuses a login, before its password is compared, when the account is locked or when
+// the account or the IP address failed too recently.
+func (s *userService) checkLoginAllowed(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	account, err := s.loginAttempts(ctx, accountKey(email))
+	if err != nil {
+		return err
+	}
+	if account != nil && account.Locked(now) {
+		return &RetryError{Err: ErrAccountLocked, RetryAfter: account.LockedUntil.Sub(now)}
+	}
+	if wait := loginDelay(account, accountFreeFailures, now); wait > 0 {
+		return &RetryError{Err: ErrTooManyAttempts, RetryAfter: wait}
+	}
+	if ip == "" {
+		return nil
+	}
+	address, err := s.loginAttempts(ctx, ipKey(ip))
+	if err != nil {
+		return err
+	}
+	if wait := loginDelay(address, ipFreeFailures, now); wait > 0 {
+		return &RetryError{Err: ErrTooManyAttempts, RetryAfter: wait}
+	}
+	return nil
+}
+
+// loginFailed counts a failed login of the email from the IP address, and locks the account when it failed
+// too many times. The user is nil when there is no account with the email.
+func (s *userService) loginFailed(ctx context.Context, user *model.User, email, ip string) error {
+	now := time.Now()
+	if ip != "" {
+		if _, err := s.store.RecordLoginFailure(ctx, ipKey(ip), now, ipWindow); err != nil {
+			return err
+		}
+	}
+	account, err := s.store.RecordLoginFailure(ctx, accountKey(email), now, accountWindow)
+	if err != nil {
+		return err
+	}
+	if account.Failures < accountLockFailures {
+		return nil
+	}
+	if err = s.store.LockLogin(ctx, account.Key, now.Add(lockDuration)); err != nil {
+		return err
+	}
+	if user != nil {
+		// The lock lifts by itself, so the login does not fail when the unlock link cannot be sent.
+		_ = s.sendUnlock(ctx, user)
+	}
+	return nil
+}
+
+func (s *userService) sendUnlock(ctx context.Context, user *model.User) error {
+	token, err := s.issueUserToken(ctx, user, model.UnlockAccount, unlockTokenTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account is locked",
+		Body: fmt.Sprintf("Hi %s,\n\nYour account was locked for %d minutes after too many failed logins. "+
+			"If it was you, unlock it now with the link below:\n\n%s\n\n"+
+			"If it was not you, someone may be trying to guess your password: consider changing it.",
+			user.Name, int(lockDuration.Minutes()), s.link("/unlock", token)),
+	})
+}
+
+// loginAttempts returns the failed logins of the key, or nil when there are none.
+func (s *userService) loginAttempts(ctx context.Context, key string) (*model.LoginAttempts, error) {
+	attempts, err := s.store.GetLoginAttempts(ctx, key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return attempts, err
+}
+
+// loginDelay returns how long a login must still wait after the failures, which doubles with every failure
+// after the free ones.
+func loginDelay(attempts *model.LoginAttempts, free int, now time.Time) time.Duration {
+	if attempts == nil || attempts.Failures < free {
+		return 0
+	}
+	delay := maxLoginDelay
+	if shift := attempts.Failures - free; shift < 16 {
+		delay = min(baseLoginDelay<<shift, maxLoginDelay)
+	}
+	return attempts.LastFailureAt.Add(delay).Sub(now)
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,86 @@
+package usersvc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/mailer"
+	"california/pkg/model"
+	"california/pkg/repository"
+	"california/pkg/totp"
+)
+
+const (
+	testEmail    = "driver@example.com"
+	testPassword = "correct horse battery"
+)
+
+// newTwoFactorUser returns a service over a memory store with a user who logs in with a second factor.
+func newTwoFactorUser(t *testing.T) (*userService, repository.Store, string) {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := helpers.HashRegisterPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.InsertUser(context.Background(), &model.User{
+		Email:     testEmail,
+		Password:  hash,
+		TwoFactor: &model.TwoFactor{Secret: secret, Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewUserService(store, nil, mailer.NewLogMailer(io.Discard, "noreply@example.com"), "https://example.com")
+	return s.(*userService), store, secret
+}
+
+func TestWrongTwoFactorCodesLockTheAccount(t *testing.T) {
+	s, store, secret := newTwoFactorUser(t)
+	ctx := context.WithValue(context.Background(), clientIPKey, "192.0.2.1")
+
+	// The failures are old enough for the logins not to wait, so the lock is what refuses them.
+	for i := 0; i < accountLockFailures-1; i++ {
+		if _, err := store.RecordLoginFailure(ctx, accountKey(testEmail), time.Now().Add(-time.Hour), accountWindow); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, tokens, challenge, err := s.Login(ctx, testEmail, testPassword, "phone")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if tokens != nil || challenge == nil {
+		t.Fatalf("Login returned tokens %v and challenge %v, want only a challenge", tokens, challenge)
+	}
+	attempts, err := store.GetLoginAttempts(ctx, accountKey(testEmail))
+	if err != nil {
+		t.Fatalf("GetLoginAttempts after the password: %v", err)
+	}
+	if attempts.Failures != accountLockFailures-1 {
+		t.Fatalf("failures after the password = %d, want %d: the password alone must not reset them", attempts.Failures, accountLockFailures-1)
+	}
+
+	if _, _, _, err = s.VerifyLogin(ctx, challenge.Token, "abcdef", "phone"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("VerifyLogin with a wrong code: got %v, want %v", err, ErrInvalidCode)
+	}
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err = s.VerifyLogin(ctx, challenge.Token, code, "phone"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("VerifyLogin of a locked account: got %v, want %v", err, ErrAccountLocked)
+	}
+	if _, _, _, err = s.Login(ctx, testEmail, testPassword, "phone"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Login of a locked account: got %v, want %v", err, ErrAccountLocked)
+	}
+}
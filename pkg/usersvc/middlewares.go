@@ -2,12 +2,12 @@ package usersvc
 
 import (
 	"context"
-	"strings"
 	"time"
 
+	"california/pkg/auth"
 	"california/pkg/model"
+	"california/pkg/repository"
 	"github.com/go-kit/kit/log"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type Middleware func(UserService) UserService
@@ -36,7 +36,7 @@ func (mw loggingMiddleware) DeleteUser(ctx context.Context) (err error) {
 	return mw.next.DeleteUser(ctx)
 }
 
-func (mw loggingMiddleware) Register(ctx context.Context, user *model.User) (insertedUser *model.User, err error) {
+func (mw loggingMiddleware) Register(ctx context.Context, user *model.User, deviceId string) (insertedUser *model.User, tokens *model.TokenPair, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "Register",
@@ -44,17 +44,139 @@ func (mw loggingMiddleware) Register(ctx context.Context, user *model.User) (ins
 			"took", time.Since(begin),
 			"err", err)
 	}(time.Now())
-	return mw.next.Register(ctx, user)
+	return mw.next.Register(ctx, user, deviceId)
 }
 
-func (mw loggingMiddleware) Login(ctx context.Context, email string, password string) (insertedUser *model.User, err error) {
+func (mw loggingMiddleware) Login(ctx context.Context, email string, password string, deviceId string) (user *model.User, tokens *model.TokenPair, challenge *model.Challenge, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log("method", "Login", "email", email, "took", time.Since(begin), "err", err)
 	}(time.Now())
-	return mw.next.Login(ctx, email, password)
+	return mw.next.Login(ctx, email, password, deviceId)
 }
 
-func (mw loggingMiddleware) VehicleRegister(ctx context.Context, vehicle *model.Vehicle) (err error) {
+func (mw loggingMiddleware) SendVerificationEmail(ctx context.Context) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "SendVerificationEmail",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.SendVerificationEmail(ctx)
+}
+
+func (mw loggingMiddleware) VerifyEmail(ctx context.Context, token string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "VerifyEmail",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.VerifyEmail(ctx, token)
+}
+
+func (mw loggingMiddleware) ForgotPassword(ctx context.Context, email string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ForgotPassword",
+			"email", email,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ForgotPassword(ctx, email)
+}
+
+func (mw loggingMiddleware) ResetPassword(ctx context.Context, token, password string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ResetPassword",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ResetPassword(ctx, token, password)
+}
+
+func (mw loggingMiddleware) VerifyLogin(ctx context.Context, challengeToken, code, deviceId string) (user *model.User, tokens *model.TokenPair, recoveryCodes []string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "VerifyLogin",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.VerifyLogin(ctx, challengeToken, code, deviceId)
+}
+
+func (mw loggingMiddleware) StartLoginEnrollment(ctx context.Context, challengeToken string) (enrollment *model.TOTPEnrollment, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "StartLoginEnrollment",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.StartLoginEnrollment(ctx, challengeToken)
+}
+
+func (mw loggingMiddleware) EnrollTwoFactor(ctx context.Context) (enrollment *model.TOTPEnrollment, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "EnrollTwoFactor",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.EnrollTwoFactor(ctx)
+}
+
+func (mw loggingMiddleware) ConfirmTwoFactor(ctx context.Context, code string) (recoveryCodes []string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ConfirmTwoFactor",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ConfirmTwoFactor(ctx, code)
+}
+
+func (mw loggingMiddleware) RegenerateRecoveryCodes(ctx context.Context, code string) (recoveryCodes []string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "RegenerateRecoveryCodes",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.RegenerateRecoveryCodes(ctx, code)
+}
+
+func (mw loggingMiddleware) DisableTwoFactor(ctx context.Context, code string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "DisableTwoFactor",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.DisableTwoFactor(ctx, code)
+}
+
+func (mw loggingMiddleware) UnlockAccount(ctx context.Context, token string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "UnlockAccount",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.UnlockAccount(ctx, token)
+}
+
+func (mw loggingMiddleware) UnlockUser(ctx context.Context, userId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "UnlockUser",
+			"userId", userId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.UnlockUser(ctx, userId)
+}
+
+func (mw loggingMiddleware) VehicleRegister(ctx context.Context, vehicle *model.Vehicle) (insertedVehicle *model.Vehicle, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "VehicleRegister",
@@ -85,139 +207,381 @@ func (mw loggingMiddleware) UpdateUserInfo(ctx context.Context, user *model.User
 	return mw.next.UpdateUserInfo(ctx, user)
 }
 
-func (mw loggingMiddleware) UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle) (err error) {
+func (mw loggingMiddleware) UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle, vehicleId string) (err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "UpdateVehicleInfo",
+			"vehicleId", vehicleId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.UpdateVehicleInfo(ctx, vehicle, vehicleId)
+}
+
+func (mw loggingMiddleware) ListVehicles(ctx context.Context) (vehicles []model.Vehicle, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListVehicles",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ListVehicles(ctx)
+}
+
+func (mw loggingMiddleware) GetVehicle(ctx context.Context, vehicleId string) (vehicle *model.Vehicle, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "GetVehicle",
+			"vehicleId", vehicleId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.GetVehicle(ctx, vehicleId)
+}
+
+func (mw loggingMiddleware) DeleteVehicle(ctx context.Context, vehicleId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "DeleteVehicle",
+			"vehicleId", vehicleId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.DeleteVehicle(ctx, vehicleId)
+}
+
+func (mw loggingMiddleware) SetDefaultVehicle(ctx context.Context, vehicleId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "SetDefaultVehicle",
+			"vehicleId", vehicleId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.SetDefaultVehicle(ctx, vehicleId)
+}
+
+func (mw loggingMiddleware) ListFavorites(ctx context.Context) (favorites []*model.Station, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListFavorites",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ListFavorites(ctx)
+}
+
+func (mw loggingMiddleware) AddFavorite(ctx context.Context, stationId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "AddFavorite",
+			"stationId", stationId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.AddFavorite(ctx, stationId)
+}
+
+func (mw loggingMiddleware) RemoveFavorite(ctx context.Context, stationId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "RemoveFavorite",
+			"stationId", stationId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.RemoveFavorite(ctx, stationId)
+}
+
+func (mw loggingMiddleware) ReorderFavorites(ctx context.Context, stationIds []string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ReorderFavorites",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ReorderFavorites(ctx, stationIds)
+}
+
+func (mw loggingMiddleware) ListPlaces(ctx context.Context) (places []model.Place, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListPlaces",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ListPlaces(ctx)
+}
+
+func (mw loggingMiddleware) AddPlace(ctx context.Context, place *model.Place) (insertedPlace *model.Place, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "AddPlace",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.AddPlace(ctx, place)
+}
+
+func (mw loggingMiddleware) UpdatePlace(ctx context.Context, placeId string, place *model.Place) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "UpdatePlace",
+			"placeId", placeId,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.UpdatePlace(ctx, placeId, place)
+}
+
+func (mw loggingMiddleware) DeletePlace(ctx context.Context, placeId string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "DeletePlace",
+			"placeId", placeId,
 			"took", time.Since(begin),
 			"err", err)
 	}(time.Now())
-	return mw.next.UpdateVehicleInfo(ctx, vehicle)
+	return mw.next.DeletePlace(ctx, placeId)
 }
 
-func (mw loggingMiddleware) ListAllUsers(ctx context.Context) (users []*model.User, err error) {
+func (mw loggingMiddleware) ReorderPlaces(ctx context.Context, placeIds []string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ReorderPlaces",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.ReorderPlaces(ctx, placeIds)
+}
+
+func (mw loggingMiddleware) ListAllUsers(ctx context.Context, page repository.Page) (users []*model.User, nextCursor string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "ListAllUsers",
 			"took", time.Since(begin),
 			"err", err)
 	}(time.Now())
-	return mw.next.ListAllUsers(ctx)
+	return mw.next.ListAllUsers(ctx, page)
 }
 
-func (mw loggingMiddleware) SearchUsers(ctx context.Context, name string) (users []*model.User, err error) {
+func (mw loggingMiddleware) SearchUsers(ctx context.Context, name string, page repository.Page) (users []*model.User, nextCursor string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "SearchUsers",
 			"took", time.Since(begin),
 			"err", err)
 	}(time.Now())
-	return mw.next.SearchUsers(ctx, name)
+	return mw.next.SearchUsers(ctx, name, page)
 }
 
-type authMiddleware struct {
-	next       UserService
-	signingKey string
-	c          context.Context
+func (mw loggingMiddleware) CatalogMakes(ctx context.Context) (makes []string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CatalogMakes",
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.CatalogMakes(ctx)
 }
 
-func (aw authMiddleware) Register(ctx context.Context, user *model.User) (insertedUser *model.User, err error) {
-	return aw.next.Register(ctx, user)
+func (mw loggingMiddleware) CatalogModels(ctx context.Context, makeName string) (models []string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CatalogModels",
+			"make", makeName,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.CatalogModels(ctx, makeName)
 }
 
-func (aw authMiddleware) Login(ctx context.Context, email string, password string) (user *model.User, err error) {
-	return aw.next.Login(ctx, email, password)
+func (mw loggingMiddleware) CatalogTrims(ctx context.Context, makeName, modelName string) (trims []*model.CatalogVehicle, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CatalogTrims",
+			"make", makeName,
+			"model", modelName,
+			"took", time.Since(begin),
+			"err", err)
+	}(time.Now())
+	return mw.next.CatalogTrims(ctx, makeName, modelName)
 }
 
-func (aw authMiddleware) VehicleRegister(ctx context.Context, vehicle *model.Vehicle) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return e
-	}
-	return aw.next.VehicleRegister(ctx, vehicle)
+type authorizationMiddleware struct {
+	next UserService
+}
 
+func (am authorizationMiddleware) Register(ctx context.Context, user *model.User, deviceId string) (insertedUser *model.User, tokens *model.TokenPair, err error) {
+	return am.next.Register(ctx, user, deviceId)
 }
 
-func (aw authMiddleware) GetMe(ctx context.Context) (user *model.User, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return nil, e
-	}
-	return aw.next.GetMe(ctx)
+func (am authorizationMiddleware) Login(ctx context.Context, email string, password string, deviceId string) (user *model.User, tokens *model.TokenPair, challenge *model.Challenge, err error) {
+	return am.next.Login(ctx, email, password, deviceId)
 }
 
-func (aw authMiddleware) UpdateUserInfo(ctx context.Context, user *model.User) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return e
-	}
-	return aw.next.UpdateUserInfo(ctx, user)
+func (am authorizationMiddleware) SendVerificationEmail(ctx context.Context) (err error) {
+	return am.next.SendVerificationEmail(ctx)
 }
 
-func (aw authMiddleware) UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return e
+func (am authorizationMiddleware) VerifyEmail(ctx context.Context, token string) (err error) {
+	return am.next.VerifyEmail(ctx, token)
+}
+
+func (am authorizationMiddleware) ForgotPassword(ctx context.Context, email string) (err error) {
+	return am.next.ForgotPassword(ctx, email)
+}
+
+func (am authorizationMiddleware) ResetPassword(ctx context.Context, token, password string) (err error) {
+	return am.next.ResetPassword(ctx, token, password)
+}
+
+func (am authorizationMiddleware) VerifyLogin(ctx context.Context, challengeToken, code, deviceId string) (user *model.User, tokens *model.TokenPair, recoveryCodes []string, err error) {
+	return am.next.VerifyLogin(ctx, challengeToken, code, deviceId)
+}
+
+func (am authorizationMiddleware) StartLoginEnrollment(ctx context.Context, challengeToken string) (enrollment *model.TOTPEnrollment, err error) {
+	return am.next.StartLoginEnrollment(ctx, challengeToken)
+}
+
+// Two-factor authentication is offered to the admin and premium accounts.
+func (am authorizationMiddleware) EnrollTwoFactor(ctx context.Context) (enrollment *model.TOTPEnrollment, err error) {
+	if e := auth.Authorize(ctx, model.Admin, model.Premium); e != nil {
+		return nil, e
 	}
-	return aw.next.UpdateVehicleInfo(ctx, vehicle)
+	return am.next.EnrollTwoFactor(ctx)
 }
 
-func (aw authMiddleware) ListAllUsers(ctx context.Context) (users []*model.User, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) ConfirmTwoFactor(ctx context.Context, code string) (recoveryCodes []string, err error) {
+	if e := auth.Authorize(ctx, model.Admin, model.Premium); e != nil {
 		return nil, e
 	}
-	return aw.next.ListAllUsers(ctx)
+	return am.next.ConfirmTwoFactor(ctx, code)
+}
+
+func (am authorizationMiddleware) RegenerateRecoveryCodes(ctx context.Context, code string) (recoveryCodes []string, err error) {
+	return am.next.RegenerateRecoveryCodes(ctx, code)
+}
+
+func (am authorizationMiddleware) DisableTwoFactor(ctx context.Context, code string) (err error) {
+	return am.next.DisableTwoFactor(ctx, code)
+}
+
+func (am authorizationMiddleware) UnlockAccount(ctx context.Context, token string) (err error) {
+	return am.next.UnlockAccount(ctx, token)
 }
 
-func (aw authMiddleware) DeleteUser(ctx context.Context) (err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
+func (am authorizationMiddleware) UnlockUser(ctx context.Context, userId string) (err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
 		return e
 	}
-	return aw.next.DeleteUser(ctx)
+	return am.next.UnlockUser(ctx, userId)
 }
 
-func (aw authMiddleware) SearchUsers(ctx context.Context, name string) (users []*model.User, err error) {
-	ctx, e := isAuthenticated(ctx, aw.signingKey)
-	if e != nil {
-		return nil, e
-	}
-	return aw.next.SearchUsers(ctx, name)
+func (am authorizationMiddleware) VehicleRegister(ctx context.Context, vehicle *model.Vehicle) (insertedVehicle *model.Vehicle, err error) {
+	return am.next.VehicleRegister(ctx, vehicle)
 }
 
-func AuthMiddleware(signingKey string) Middleware {
-	return func(next UserService) UserService {
-		return &authMiddleware{
-			next:       next,
-			signingKey: signingKey,
-		}
-	}
+func (am authorizationMiddleware) GetMe(ctx context.Context) (user *model.User, err error) {
+	return am.next.GetMe(ctx)
+}
+
+func (am authorizationMiddleware) UpdateUserInfo(ctx context.Context, user *model.User) (err error) {
+	return am.next.UpdateUserInfo(ctx, user)
+}
+
+func (am authorizationMiddleware) UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle, vehicleId string) (err error) {
+	return am.next.UpdateVehicleInfo(ctx, vehicle, vehicleId)
+}
+
+func (am authorizationMiddleware) ListVehicles(ctx context.Context) (vehicles []model.Vehicle, err error) {
+	return am.next.ListVehicles(ctx)
+}
+
+func (am authorizationMiddleware) GetVehicle(ctx context.Context, vehicleId string) (vehicle *model.Vehicle, err error) {
+	return am.next.GetVehicle(ctx, vehicleId)
+}
+
+func (am authorizationMiddleware) DeleteVehicle(ctx context.Context, vehicleId string) (err error) {
+	return am.next.DeleteVehicle(ctx, vehicleId)
+}
+
+func (am authorizationMiddleware) SetDefaultVehicle(ctx context.Context, vehicleId string) (err error) {
+	return am.next.SetDefaultVehicle(ctx, vehicleId)
+}
+
+func (am authorizationMiddleware) ListFavorites(ctx context.Context) (favorites []*model.Station, err error) {
+	return am.next.ListFavorites(ctx)
+}
+
+func (am authorizationMiddleware) AddFavorite(ctx context.Context, stationId string) (err error) {
+	return am.next.AddFavorite(ctx, stationId)
+}
+
+func (am authorizationMiddleware) RemoveFavorite(ctx context.Context, stationId string) (err error) {
+	return am.next.RemoveFavorite(ctx, stationId)
+}
+
+func (am authorizationMiddleware) ReorderFavorites(ctx context.Context, stationIds []string) (err error) {
+	return am.next.ReorderFavorites(ctx, stationIds)
+}
+
+func (am authorizationMiddleware) ListPlaces(ctx context.Context) (places []model.Place, err error) {
+	return am.next.ListPlaces(ctx)
+}
+
+func (am authorizationMiddleware) AddPlace(ctx context.Context, place *model.Place) (insertedPlace *model.Place, err error) {
+	return am.next.AddPlace(ctx, place)
+}
+
+func (am authorizationMiddleware) UpdatePlace(ctx context.Context, placeId string, place *model.Place) (err error) {
+	return am.next.UpdatePlace(ctx, placeId, place)
+}
+
+func (am authorizationMiddleware) DeletePlace(ctx context.Context, placeId string) (err error) {
+	return am.next.DeletePlace(ctx, placeId)
 }
 
-func isAuthenticated(ctx context.Context, signingKey string) (context.Context, error) {
-	// Extract the JWT token from the request header and validate it.
-	tokenString := ctx.Value("Authorization").(string)
-	if tokenString == "" {
-		return nil, ErrNoAuthTokenHeader
+func (am authorizationMiddleware) ReorderPlaces(ctx context.Context, placeIds []string) (err error) {
+	return am.next.ReorderPlaces(ctx, placeIds)
+}
+
+func (am authorizationMiddleware) ListAllUsers(ctx context.Context, page repository.Page) (users []*model.User, nextCursor string, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return nil, "", e
 	}
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the token algorithm is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrUnexpectedSigningMethod
-		}
-		return []byte(signingKey), nil
-	})
-	if err != nil {
-		return nil, ErrInvalidToken
+	return am.next.ListAllUsers(ctx, page)
+}
+
+func (am authorizationMiddleware) DeleteUser(ctx context.Context) (err error) {
+	return am.next.DeleteUser(ctx)
+}
+
+func (am authorizationMiddleware) CatalogMakes(ctx context.Context) (makes []string, err error) {
+	return am.next.CatalogMakes(ctx)
+}
+
+func (am authorizationMiddleware) CatalogModels(ctx context.Context, makeName string) (models []string, err error) {
+	return am.next.CatalogModels(ctx, makeName)
+}
+
+func (am authorizationMiddleware) CatalogTrims(ctx context.Context, makeName, modelName string) (trims []*model.CatalogVehicle, err error) {
+	return am.next.CatalogTrims(ctx, makeName, modelName)
+}
+
+func (am authorizationMiddleware) SearchUsers(ctx context.Context, name string, page repository.Page) (users []*model.User, nextCursor string, err error) {
+	if e := auth.Authorize(ctx, model.Admin); e != nil {
+		return nil, "", e
 	}
+	return am.next.SearchUsers(ctx, name, page)
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		ctx = context.WithValue(ctx, "email", claims["Email"])
-		ctx = context.WithValue(ctx, "userId", claims["userId"])
-		return ctx, nil
+// AuthorizationMiddleware restricts the methods of the service to the user types allowed to call them.
+// It relies on the claims put into the context by the auth.NewParser endpoint middleware.
+func AuthorizationMiddleware() Middleware {
+	return func(next UserService) UserService {
+		return &authorizationMiddleware{
+			next: next,
+		}
 	}
-	return nil, ErrInvalidToken
 }
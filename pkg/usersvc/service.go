@@ -5,19 +5,48 @@ import (
 	"errors"
 
 	"california/internal/helpers"
+	"california/pkg/auth"
+	"california/pkg/authsvc"
+	"california/pkg/mailer"
 	"california/pkg/model"
 	"california/pkg/repository"
+	"california/pkg/vehiclecatalog"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserService interface {
 	// Register and Login are public methods of the user.
-	Register(context.Context, *model.User) (insertedUser *model.User, err error)
-	Login(ctx context.Context, email string, password string) (*model.User, error)
+	Register(ctx context.Context, user *model.User, deviceId string) (insertedUser *model.User, tokens *model.TokenPair, err error)
+	// Login returns a challenge instead of the tokens when the user needs a second factor.
+	// It is refused for a while after too many failures of the account or of the client IP address.
+	Login(ctx context.Context, email string, password string, deviceId string) (user *model.User, tokens *model.TokenPair, challenge *model.Challenge, err error)
+
+	// SendVerificationEmail, VerifyEmail, ForgotPassword and ResetPassword verify the email address of the user
+	// and reset a forgotten password, with single-use tokens mailed to the user.
+	SendVerificationEmail(ctx context.Context) error
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+
+	// UnlockAccount and UnlockUser lift the lock of an account after too many failed logins, with the link
+	// mailed to its user or by an admin.
+	UnlockAccount(ctx context.Context, token string) error
+	UnlockUser(ctx context.Context, userId string) error
+
+	// VerifyLogin and StartLoginEnrollment finish a login which returned a challenge.
+	VerifyLogin(ctx context.Context, challengeToken, code, deviceId string) (user *model.User, tokens *model.TokenPair, recoveryCodes []string, err error)
+	StartLoginEnrollment(ctx context.Context, challengeToken string) (*model.TOTPEnrollment, error)
+
+	// EnrollTwoFactor, ConfirmTwoFactor, RegenerateRecoveryCodes and DisableTwoFactor manage the TOTP
+	// two-factor authentication of the user.
+	EnrollTwoFactor(ctx context.Context) (*model.TOTPEnrollment, error)
+	ConfirmTwoFactor(ctx context.Context, code string) (recoveryCodes []string, err error)
+	RegenerateRecoveryCodes(ctx context.Context, code string) (recoveryCodes []string, err error)
+	DisableTwoFactor(ctx context.Context, code string) error
 
 	// VehicleRegister and VehicleUpdate are public methods of the vehicle.
-	VehicleRegister(ctx context.Context, vehicle *model.Vehicle) error
+	VehicleRegister(ctx context.Context, vehicle *model.Vehicle) (*model.Vehicle, error)
 
 	// GetMe is used to get the user's information and fill the blanks in the client.
 	GetMe(ctx context.Context) (*model.User, error)
@@ -26,20 +55,47 @@ type UserService interface {
 	UpdateUserInfo(ctx context.Context, user *model.User) error
 
 	// UpdateVehicleInfo is used to update the vehicle's information.
-	UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle) error
+	UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle, vehicleId string) error
+
+	// ListVehicles, GetVehicle, DeleteVehicle and SetDefaultVehicle manage the garage of the user.
+	ListVehicles(ctx context.Context) ([]model.Vehicle, error)
+	GetVehicle(ctx context.Context, vehicleId string) (*model.Vehicle, error)
+	DeleteVehicle(ctx context.Context, vehicleId string) error
+	SetDefaultVehicle(ctx context.Context, vehicleId string) error
+
+	// ListFavorites, AddFavorite, RemoveFavorite and ReorderFavorites manage the stations bookmarked by the user.
+	ListFavorites(ctx context.Context) ([]*model.Station, error)
+	AddFavorite(ctx context.Context, stationId string) error
+	RemoveFavorite(ctx context.Context, stationId string) error
+	ReorderFavorites(ctx context.Context, stationIds []string) error
+
+	// ListPlaces, AddPlace, UpdatePlace, DeletePlace and ReorderPlaces manage the places saved by the user, e.g. home or work.
+	ListPlaces(ctx context.Context) ([]model.Place, error)
+	AddPlace(ctx context.Context, place *model.Place) (*model.Place, error)
+	UpdatePlace(ctx context.Context, placeId string, place *model.Place) error
+	DeletePlace(ctx context.Context, placeId string) error
+	ReorderPlaces(ctx context.Context, placeIds []string) error
 
-	// ListAllUsers is used to list all users.
-	ListAllUsers(ctx context.Context) ([]*model.User, error)
+	// ListAllUsers is used to list all users, a page at a time.
+	ListAllUsers(ctx context.Context, page repository.Page) ([]*model.User, string, error)
 
-	// SearchUsers is used to search users by their name.
-	SearchUsers(ctx context.Context, name string) ([]*model.User, error)
+	// SearchUsers is used to search users by their name, a page at a time.
+	SearchUsers(ctx context.Context, name string, page repository.Page) ([]*model.User, string, error)
 
 	// DeleteUser is used to delete a user.
 	DeleteUser(ctx context.Context) error
+
+	// CatalogMakes, CatalogModels and CatalogTrims browse the vehicle catalog.
+	CatalogMakes(ctx context.Context) ([]string, error)
+	CatalogModels(ctx context.Context, makeName string) ([]string, error)
+	CatalogTrims(ctx context.Context, makeName, modelName string) ([]*model.CatalogVehicle, error)
 }
 
 type userService struct {
-	store repository.Store
+	store   repository.Store
+	catalog *vehiclecatalog.Catalog
+	mailer  mailer.Mailer
+	appURL  string
 }
 
 var (
@@ -48,135 +104,193 @@ var (
 	ErrNotFound                  = errors.New("not found")
 	ErrAuthentication            = errors.New("authentication failed")
 	ErrPasswordEmailDoesNotMatch = errors.New("password and email does not match")
-	ErrNoAuthTokenHeader         = errors.New("no auth token in the header")
+	ErrNoAuthTokenHeader         = auth.ErrNoAuthTokenHeader
 	ErrInternalDb                = errors.New("internal db error")
-	ErrUnexpectedSigningMethod   = errors.New("unexpected signing method")
-	ErrInvalidToken              = errors.New("invalid token")
+	ErrInvalidToken              = auth.ErrInvalidToken
+	ErrForbidden                 = auth.ErrForbidden
+	ErrUnknownCatalogVehicle     = errors.New("unknown catalog vehicle")
 )
 
-// Register TODO Add here to create a refresh token and return it to client.
-func (s *userService) Register(ctx context.Context, user *model.User) (*model.User, error) {
+// Register stores a new user, mails it an email verification link and logs it in on the given device.
+func (s *userService) Register(ctx context.Context, user *model.User, deviceId string) (*model.User, *model.TokenPair, error) {
+	email, err := validateEmail(user.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+	user.Email = email
+	if err = validatePassword(user.Password); err != nil {
+		return nil, nil, err
+	}
 	exists, err := s.store.UserExists(ctx, user.Email)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if exists {
-		return nil, ErrAlreadyExists
+		return nil, nil, ErrAlreadyExists
 	}
 
-	// Here we create a token for the user and return it to the client.
-	// Later on client will have to use this token to send requests to the server.
-
-	oidStr := primitive.NewObjectID().Hex()
-	token, err := helpers.GenerateToken(user.Email, oidStr)
-	if err != nil {
-		return nil, err
-	}
-	user.RefreshToken = token
-	user.ID, _ = primitive.ObjectIDFromHex(oidStr)
+	// Elevated user types are granted by an admin, never chosen at registration.
+	user.UserType = model.Normal
+	user.EmailVerified = false
+	user.ID = primitive.NewObjectID()
 
 	// We need to hash the password before storing it in the database.
 	hashedPass, err := helpers.HashRegisterPassword(user.Password)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	user.Password = hashedPass
 	insertedUser, err := s.store.InsertUser(ctx, user)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	// The user is registered whether the email goes out or not; another one can be asked for.
+	_ = s.sendVerification(ctx, insertedUser)
 
-	return insertedUser, nil
+	// Here we create the tokens of the stored user and return them to the client.
+	// Later on client will have to use the access token to send requests to the server.
+	tokens, err := authsvc.IssueTokenPair(ctx, s.store, insertedUser, deviceId, primitive.NilObjectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return insertedUser, tokens, nil
 }
 
-func (s *userService) Login(ctx context.Context, email string, password string) (*model.User, error) {
+func (s *userService) Login(ctx context.Context, email string, password string, deviceId string) (*model.User, *model.TokenPair, *model.Challenge, error) {
+	// The client IP address is put into the context by the login endpoint.
+	ip, _ := ctx.Value(clientIPKey).(string)
+	if err := s.checkLoginAllowed(ctx, email, ip); err != nil {
+		return nil, nil, nil, err
+	}
+
 	// Get user by given email.
 	user, err := s.store.GetUserByEmail(ctx, email)
 	if err != nil {
-		return nil, ErrNotFound
+		if err = s.loginFailed(ctx, nil, email, ip); err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, ErrNotFound
 	}
 
 	// We need to verify the given password with user's password.
 	err = helpers.CompareLoginPasswordAndHash(password, user.Password)
 	if err != nil {
-		return nil, ErrPasswordEmailDoesNotMatch
+		if err = s.loginFailed(ctx, user, email, ip); err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, ErrPasswordEmailDoesNotMatch
 	}
 
-	// Email and password matched, so we generate an access token and return it to the client.
-	token, err := helpers.GenerateToken(user.Email, user.ID.Hex())
-	if err != nil {
-		return nil, err
+	// The users with a second factor, or who must have one, finish their login with VerifyLogin, which forgets
+	// the failed logins once the code matched too.
+	if user.TwoFactorEnabled() || user.RequiresTwoFactor() {
+		challenge, err := s.challenge(ctx, user)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return user, nil, challenge, nil
 	}
-
-	// Use the created token to update the user's refresh token.
-	user.RefreshToken = token
-	return user, nil
-}
-
-func (s *userService) VehicleRegister(ctx context.Context, vehicle *model.Vehicle) error {
-	email := ctx.Value("email").(string)
-	user, err := s.store.GetUserByEmail(ctx, email)
-	if err != nil {
-		return err
+	if err = s.store.ResetLoginAttempts(ctx, accountKey(email)); err != nil {
+		return nil, nil, nil, err
 	}
 
-	if err = s.store.InsertVehicleToUser(ctx, user, vehicle); err != nil {
-		return err
+	// Email and password matched, so we start a new session for the device and return its tokens to the client.
+	tokens, err := authsvc.IssueTokenPair(ctx, s.store, user, deviceId, primitive.NilObjectID)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return nil
+	return user, tokens, nil, nil
 }
 
 func (s *userService) GetMe(ctx context.Context) (*model.User, error) {
-	email := ctx.Value("email").(string)
+	email := auth.Email(ctx)
 	user, err := s.store.GetUserByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
+	user.Vehicles = markDefault(user)
 	return user, nil
 }
 
 func (s *userService) UpdateUserInfo(ctx context.Context, user *model.User) error {
-	if err := s.store.UpdateUser(ctx, user); err != nil {
+	// The password is only changed when a new one is given.
+	if user.Password == "" {
+		return s.store.UpdateUser(ctx, user)
+	}
+	if err := validatePassword(user.Password); err != nil {
 		return err
 	}
-	return nil
-}
-
-func (s *userService) UpdateVehicleInfo(ctx context.Context, vehicle *model.Vehicle) error {
-	if err := s.store.UpdateVehicle(ctx, vehicle); err != nil {
+	userId, err := primitive.ObjectIDFromHex(auth.UserID(ctx))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err = s.store.UpdateUser(ctx, user); err != nil {
 		return err
 	}
-	return nil
+	// Like a password reset, a new password ends the sessions opened with the old one.
+	return s.store.RevokeUserRefreshTokens(ctx, userId)
 }
 
-func (s *userService) ListAllUsers(ctx context.Context) ([]*model.User, error) {
-	users, err := s.store.GetAllUsers(ctx)
+func (s *userService) ListAllUsers(ctx context.Context, page repository.Page) ([]*model.User, string, error) {
+	users, nextCursor, err := s.store.FindUsersPage(ctx, bson.M{}, page)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return users, nil
+	return users, nextCursor, nil
 }
 
-func (s *userService) SearchUsers(ctx context.Context, name string) ([]*model.User, error) {
+func (s *userService) SearchUsers(ctx context.Context, name string, page repository.Page) ([]*model.User, string, error) {
 	filter := bson.M{"Name": bson.M{"$regex": primitive.Regex{Pattern: name, Options: "i"}}}
-	users, err := s.store.FindUsersByFilter(ctx, filter)
+	users, nextCursor, err := s.store.FindUsersPage(ctx, filter, page)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return users, nil
+	return users, nextCursor, nil
 }
 
 func (s *userService) DeleteUser(ctx context.Context) error {
-	email := ctx.Value("email").(string)
+	email := auth.Email(ctx)
 	if err := s.store.DeleteUser(ctx, email); err != nil {
 		return err
 	}
 	return nil
 }
 
-func NewUserService(store repository.Store) UserService {
+func (s *userService) CatalogMakes(_ context.Context) ([]string, error) {
+	return s.catalog.Makes(), nil
+}
+
+func (s *userService) CatalogModels(_ context.Context, makeName string) ([]string, error) {
+	return s.catalog.Models(makeName), nil
+}
+
+func (s *userService) CatalogTrims(_ context.Context, makeName, modelName string) ([]*model.CatalogVehicle, error) {
+	return s.catalog.Trims(makeName, modelName), nil
+}
+
+// fillFromCatalog replaces the specs of a vehicle referring to a catalog entry with the entry's.
+func (s *userService) fillFromCatalog(vehicle *model.Vehicle) error {
+	if vehicle.CatalogID == "" {
+		return nil
+	}
+	entry, ok := s.catalog.Get(vehicle.CatalogID)
+	if !ok {
+		return ErrUnknownCatalogVehicle
+	}
+	name := vehicle.Name
+	*vehicle = entry.Vehicle()
+	vehicle.Name = name
+	return nil
+}
+
+// NewUserService returns the user service. The catalog may be nil, in which case it is empty.
+// The links of the emails point to the client at appURL.
+func NewUserService(store repository.Store, catalog *vehiclecatalog.Catalog, mail mailer.Mailer, appURL string) UserService {
 	return &userService{
-		store: store,
+		store:   store,
+		catalog: catalog,
+		mailer:  mail,
+		appURL:  appURL,
 	}
 }
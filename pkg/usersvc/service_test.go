@@ -0,0 +1,68 @@
+package usersvc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"california/internal/helpers"
+	"california/pkg/auth"
+	"california/pkg/mailer"
+	"california/pkg/model"
+	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUpdateUserInfoRevokesSessionsOnNewPassword(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	user, err := store.InsertUser(ctx, &model.User{ID: primitive.NewObjectID(), Name: "Driver", Email: testEmail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewUserService(store, nil, mailer.NewLogMailer(io.Discard, "noreply@example.com"), "https://example.com")
+	userCtx := auth.WithClaims(ctx, &auth.Claims{Email: user.Email, UserID: user.ID.Hex()})
+
+	refresh := func() *model.RefreshToken {
+		t.Helper()
+		token, err := helpers.GenerateRefreshToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored := &model.RefreshToken{ID: primitive.NewObjectID(), UserID: user.ID, FamilyID: primitive.NewObjectID(), TokenHash: helpers.HashToken(token)}
+		if err = store.InsertRefreshToken(ctx, stored); err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+	revoked := func(token *model.RefreshToken) bool {
+		t.Helper()
+		stored, err := store.GetRefreshTokenByHash(ctx, token.TokenHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.RevokedAt != nil
+	}
+
+	kept := refresh()
+	if err = s.UpdateUserInfo(userCtx, &model.User{Name: "New name"}); err != nil {
+		t.Fatalf("UpdateUserInfo without a password: %v", err)
+	}
+	if revoked(kept) {
+		t.Error("a name change revoked the sessions")
+	}
+
+	if err = s.UpdateUserInfo(userCtx, &model.User{Name: "New name", Password: testPassword}); err != nil {
+		t.Fatalf("UpdateUserInfo with a password: %v", err)
+	}
+	if !revoked(kept) {
+		t.Error("the sessions opened with the old password were not revoked")
+	}
+	stored, err := store.GetUserById(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if helpers.CompareLoginPasswordAndHash(testPassword, stored.Password) != nil {
+		t.Error("the new password was not stored")
+	}
+}
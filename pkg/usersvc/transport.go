@@ -4,32 +4,78 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"math"
+	"net"
 	"net/http"
-	"strings"
+	"strconv"
 
+	"california/pkg/auth"
+	"california/pkg/repository"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 )
 
-func MakeHTTPHandler(c context.Context, s UserService, log log.Logger) http.Handler {
+// MakeHTTPHandler serves the endpoints of the service. The tokens of the requests are verified with the keys.
+func MakeHTTPHandler(s UserService, keys jwt.Keyfunc, log log.Logger) http.Handler {
 	r := mux.NewRouter()
-	e := MakeServerEndpoints(c, s)
+	e := MakeServerEndpoints(s, keys)
 	options := []httptransport.ServerOption{
+		httptransport.ServerBefore(auth.HTTPToContext),
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(log)),
 		httptransport.ServerErrorEncoder(encodeError),
 	}
 
-	// POST /register adds a new user to the database.
-	// POST /login logs in a user and returns a token.
-	// POST /vehicle/register adds a new vehicle to the database.
+	// POST /register adds a new user to the database and returns its tokens.
+	// POST /login logs in a user and returns an access token and a refresh token.
+	// Both accept an optional X-Device-ID header naming the device the session belongs to.
+	// POST /vehicle/register adds a new vehicle to the user's garage.
 	// GET /me returns the user's information.
 	// PUT /user updates the user's information.
-	// PUT /vehicle updates the vehicle's information.
+	// PUT /vehicle updates the information of the user's default vehicle.
 	// GET /users returns all users.
-	// GET /users/search returns users by their name.
+	// GET /users/search?name=<name> returns users by their name.
+	// Both return a page of at most limit=<n> users, 50 by default, and the next page is asked for with after=<next_cursor>.
 	// DEL /user deletes a user.
+	// GET /catalog/makes lists the vehicle makes of the catalog.
+	// GET /catalog/models?make=<make> lists the models of a make.
+	// GET /catalog/trims?make=<make>&model=<model> lists the trims of a model with their specs.
+	// Vehicles can be registered with the id of a trim as catalog_id instead of their specs.
+	// GET /vehicles lists the vehicles of the user's garage.
+	// POST /vehicles adds a vehicle to the garage. The first vehicle becomes the default one.
+	// GET /vehicles/{id} returns a vehicle of the garage.
+	// PUT /vehicles/{id} updates a vehicle of the garage.
+	// DEL /vehicles/{id} removes a vehicle from the garage.
+	// PUT /vehicles/{id}/default makes the vehicle the default one, used when a request names no vehicle.
+	// GET /me/favorites lists the user's favourite stations in the user's order.
+	// POST /me/favorites bookmarks the station with the given station_id.
+	// PUT /me/favorites/order sorts the favourites, with the ids of all of them in the new order.
+	// DEL /me/favorites/{id} removes the station from the favourites.
+	// GET /me/places lists the user's saved places, e.g. home or work.
+	// POST /me/places saves a new place with its name and coordinates.
+	// PUT /me/places/order sorts the places, with the ids of all of them in the new order.
+	// PUT /me/places/{id} updates a place.
+	// DEL /me/places/{id} deletes a place.
+	// POST /email/verification mails the user a new email verification link.
+	// POST /email/verify verifies the email address of the user the token was mailed to.
+	// POST /password/forgot mails a password reset link to the user with the given email.
+	// POST /password/reset sets a new password with a token mailed by /password/forgot and ends the sessions of the user.
+	// POST /login returns a challenge_token instead of the tokens when the user has two-factor authentication,
+	// or is an admin, who must enrol. The login is finished with:
+	// POST /login/2fa, the challenge_token and a TOTP or recovery code, which returns the tokens. When enrolment is
+	// required, the code confirms it and the recovery codes are returned too.
+	// POST /login/2fa/enrollment, the challenge_token, which starts the enrolment and returns the secret and its otpauth URI.
+	// POST /me/2fa starts the enrolment of an admin or premium user and returns the secret and its otpauth URI.
+	// POST /me/2fa/confirm enables two-factor authentication with a first code and returns the recovery codes.
+	// POST /me/2fa/recovery-codes replaces the recovery codes, given a code.
+	// DEL /me/2fa turns two-factor authentication off, given a code. Admins cannot turn it off.
+	// POST /login answers 429 after too many failed logins of the account or of the client, and 423 once the
+	// account is locked, both with a Retry-After header. The lock is lifted early with:
+	// POST /login/unlock, the token of the unlock link mailed to the user when the account was locked.
+	// POST /users/{id}/unlock, by an admin.
 
 	r.Methods("POST").Path("/register").Handler(httptransport.NewServer(
 		e.RegisterEndpoint,
@@ -43,6 +89,78 @@ func MakeHTTPHandler(c context.Context, s UserService, log log.Logger) http.Hand
 		encodeResponse,
 		options...,
 	))
+	r.Methods("POST").Path("/login/unlock").Handler(httptransport.NewServer(
+		e.UnlockAccountEndpoint,
+		decodeAccountRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/users/{id}/unlock").Handler(httptransport.NewServer(
+		e.UnlockUserEndpoint,
+		decodeAuthenticatedAccountRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/email/verification").Handler(httptransport.NewServer(
+		e.SendVerificationEndpoint,
+		decodeAuthenticatedAccountRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/email/verify").Handler(httptransport.NewServer(
+		e.VerifyEmailEndpoint,
+		decodeAccountRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/password/forgot").Handler(httptransport.NewServer(
+		e.ForgotPasswordEndpoint,
+		decodeAccountRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/password/reset").Handler(httptransport.NewServer(
+		e.ResetPasswordEndpoint,
+		decodeAccountRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/login/2fa").Handler(httptransport.NewServer(
+		e.VerifyLoginEndpoint,
+		decodeTwoFactorRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/login/2fa/enrollment").Handler(httptransport.NewServer(
+		e.LoginEnrollmentEndpoint,
+		decodeTwoFactorRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/me/2fa").Handler(httptransport.NewServer(
+		e.EnrollTwoFactorEndpoint,
+		decodeAuthenticatedTwoFactorRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/me/2fa/confirm").Handler(httptransport.NewServer(
+		e.ConfirmTwoFactorEndpoint,
+		decodeAuthenticatedTwoFactorRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/me/2fa/recovery-codes").Handler(httptransport.NewServer(
+		e.RecoveryCodesEndpoint,
+		decodeAuthenticatedTwoFactorRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/me/2fa").Handler(httptransport.NewServer(
+		e.DisableTwoFactorEndpoint,
+		decodeAuthenticatedTwoFactorRequest,
+		encodeResponse,
+		options...,
+	))
 	r.Methods("POST").Path("/vehicle/register").Handler(httptransport.NewServer(
 		e.VehicleRegisterEndpoint,
 		decodeVehicleRegisterRequest,
@@ -85,59 +203,146 @@ func MakeHTTPHandler(c context.Context, s UserService, log log.Logger) http.Hand
 		encodeResponse,
 		options...,
 	))
+	r.Methods("GET").Path("/vehicles").Handler(httptransport.NewServer(
+		e.ListVehiclesEndpoint,
+		decodeVehicleRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/vehicles").Handler(httptransport.NewServer(
+		e.VehicleRegisterEndpoint,
+		decodeVehicleRegisterRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/vehicles/{id}").Handler(httptransport.NewServer(
+		e.GetVehicleEndpoint,
+		decodeVehicleRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/vehicles/{id}").Handler(httptransport.NewServer(
+		e.UpdateVehicleEndpoint,
+		decodeUpdateVehicleRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/vehicles/{id}").Handler(httptransport.NewServer(
+		e.DeleteVehicleEndpoint,
+		decodeVehicleRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/vehicles/{id}/default").Handler(httptransport.NewServer(
+		e.DefaultVehicleEndpoint,
+		decodeVehicleRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/me/favorites").Handler(httptransport.NewServer(
+		e.ListFavoritesEndpoint,
+		decodeFavoriteRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/me/favorites").Handler(httptransport.NewServer(
+		e.AddFavoriteEndpoint,
+		decodeFavoriteRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/me/favorites/order").Handler(httptransport.NewServer(
+		e.OrderFavoritesEndpoint,
+		decodeFavoriteRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/me/favorites/{id}").Handler(httptransport.NewServer(
+		e.RemoveFavoriteEndpoint,
+		decodeFavoriteRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/me/places").Handler(httptransport.NewServer(
+		e.ListPlacesEndpoint,
+		decodePlaceRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/me/places").Handler(httptransport.NewServer(
+		e.AddPlaceEndpoint,
+		decodePlaceRequest,
+		encodeResponse,
+		options...,
+	))
+	// The order route is registered before /me/places/{id}, which would match it too.
+	r.Methods("PUT").Path("/me/places/order").Handler(httptransport.NewServer(
+		e.OrderPlacesEndpoint,
+		decodeOrderPlacesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/me/places/{id}").Handler(httptransport.NewServer(
+		e.UpdatePlaceEndpoint,
+		decodePlaceRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/me/places/{id}").Handler(httptransport.NewServer(
+		e.DeletePlaceEndpoint,
+		decodePlaceRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/catalog/makes").Handler(httptransport.NewServer(
+		e.CatalogMakesEndpoint,
+		decodeCatalogRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/catalog/models").Handler(httptransport.NewServer(
+		e.CatalogModelsEndpoint,
+		decodeCatalogRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/catalog/trims").Handler(httptransport.NewServer(
+		e.CatalogTrimsEndpoint,
+		decodeCatalogRequest,
+		encodeResponse,
+		options...,
+	))
 	return r
 }
 
 func decodeDeleteUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
-	}
-	ctx = context.WithValue(ctx, "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req deleteUserRequest
-	req.Context = c
 	return req, nil
 }
 
 func decodeSearchUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
+	var req searchUsersRequest
+	req.Name = r.URL.Query().Get("name")
+	page, err := repository.ParsePage(r.URL.Query())
+	if err != nil {
+		return nil, err
 	}
-
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-	var req deleteUserRequest
-	req.Context = c
+	req.Page = page
 	return req, nil
 }
 
 func decodeGetUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
-	}
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req listAllUsersRequest
-	req.Context = c
+	page, err := repository.ParsePage(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	req.Page = page
 	return req, nil
 }
 
 func decodeGetMeRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
-	}
-	ctx = context.WithValue(r.Context(), "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
 	var req getMeRequest
-	req.Context = c
 	return req, nil
 }
 
@@ -146,6 +351,7 @@ func decodeRegisterRequest(_ context.Context, r *http.Request) (interface{}, err
 	if e := json.NewDecoder(r.Body).Decode(&req.User); e != nil {
 		return nil, e
 	}
+	req.DeviceID = r.Header.Get("X-Device-ID")
 	return req, nil
 
 }
@@ -155,20 +361,55 @@ func decodeLoginRequest(_ context.Context, r *http.Request) (interface{}, error)
 	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
 		return nil, e
 	}
+	req.DeviceID = r.Header.Get("X-Device-ID")
+	req.ClientIP = clientIP(r)
 	return req, nil
 }
 
-func decodeVehicleRegisterRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
+// clientIP returns the IP address of the peer of the request, which failed logins are counted against.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func decodeAccountRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req accountRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
 	}
-	ctx = context.WithValue(ctx, "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
+	return req, nil
+}
+
+func decodeAuthenticatedAccountRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req accountRequest
+	req.UserID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeTwoFactorRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req twoFactorRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	req.DeviceID = r.Header.Get("X-Device-ID")
+	req.ClientIP = clientIP(r)
+	return req, nil
+}
 
+func decodeAuthenticatedTwoFactorRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req twoFactorRequest
+	// Enrolling sends no body.
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil && !errors.Is(e, io.EOF) {
+		return nil, e
+	}
+	return req, nil
+}
+
+func decodeVehicleRegisterRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var req vehicleRegisterRequest
-	req.Context = c
 
 	if e := json.NewDecoder(r.Body).Decode(&req.Vehicle); e != nil {
 		return nil, e
@@ -178,16 +419,7 @@ func decodeVehicleRegisterRequest(ctx context.Context, r *http.Request) (interfa
 }
 
 func decodeUpdateUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
-	}
-	ctx = context.WithValue(ctx, "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-
 	var req updateUserRequest
-	req.Context = c
 
 	if e := json.NewDecoder(r.Body).Decode(&req.User); e != nil {
 		return nil, e
@@ -197,16 +429,8 @@ func decodeUpdateUserRequest(ctx context.Context, r *http.Request) (interface{},
 }
 
 func decodeUpdateVehicleRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	authHeader := r.Header.Get("Authorization")
-	jwtToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authHeader == "" {
-		return nil, ErrNoAuthTokenHeader
-	}
-	ctx = context.WithValue(ctx, "jwt", jwtToken)
-	c := context.WithValue(r.Context(), "jwt", jwtToken)
-
 	var req updateVehicleRequest
-	req.Context = c
+	req.VehicleID = mux.Vars(r)["id"]
 
 	if e := json.NewDecoder(r.Body).Decode(&req.Vehicle); e != nil {
 		return nil, e
@@ -214,6 +438,57 @@ func decodeUpdateVehicleRequest(ctx context.Context, r *http.Request) (interface
 	return req, nil
 }
 
+func decodeVehicleRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req vehicleRequest
+	req.VehicleID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+// decodeFavoriteRequest reads the station id from the path, or the body holding the station id or the new order.
+func decodeFavoriteRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req favoriteRequest
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+			return nil, e
+		}
+	}
+	if id, ok := mux.Vars(r)["id"]; ok {
+		req.StationID = id
+	}
+	return req, nil
+}
+
+// decodePlaceRequest reads the place id from the path and the place from the body of the requests which change it.
+func decodePlaceRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req placeRequest
+	req.PlaceID = mux.Vars(r)["id"]
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		if e := json.NewDecoder(r.Body).Decode(&req.Place); e != nil {
+			return nil, e
+		}
+	}
+	return req, nil
+}
+
+func decodeOrderPlacesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		Order []string `json:"order"`
+	}
+	if e := json.NewDecoder(r.Body).Decode(&body); e != nil {
+		return nil, e
+	}
+	return placeRequest{
+		Order: body.Order,
+	}, nil
+}
+
+func decodeCatalogRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req catalogRequest
+	req.Make = r.URL.Query().Get("make")
+	req.Model = r.URL.Query().Get("model")
+	return req, nil
+}
+
 type errorer interface {
 	error() error
 }
@@ -234,6 +509,10 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 		panic("encodeError with nil error")
 	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	var retry *RetryError
+	if errors.As(err, &retry) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.RetryAfter.Seconds()))))
+	}
 
 	w.WriteHeader(codeFrom(err))
 
@@ -245,16 +524,41 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 
 func codeFrom(err error) int {
 	switch {
-	case errors.Is(err, ErrNotFound):
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrVehicleNotFound):
+		return http.StatusNotFound // 404
+	case errors.Is(err, ErrStationNotFound), errors.Is(err, ErrFavoriteNotFound), errors.Is(err, ErrPlaceNotFound):
 		return http.StatusNotFound // 404
 	case errors.Is(err, ErrAlreadyExists), errors.Is(err, ErrInconsistentIDs):
 		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrUnknownCatalogVehicle), errors.Is(err, ErrGarageFull):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrTooManyFavorites), errors.Is(err, ErrTooManyPlaces), errors.Is(err, ErrInvalidPlace), errors.Is(err, ErrInvalidOrder):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrInvalidEmail), errors.Is(err, ErrInvalidPassword), errors.Is(err, ErrInvalidUserToken):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrEmailAlreadyVerified), errors.Is(err, ErrTwoFactorEnabled):
+		return http.StatusConflict // 409
+	case errors.Is(err, ErrTwoFactorNotEnabled):
+		return http.StatusBadRequest // 400
+	case errors.Is(err, ErrInvalidCode):
+		return http.StatusUnauthorized // 401
+	case errors.Is(err, ErrTwoFactorRequired):
+		return http.StatusForbidden // 403
+	case errors.Is(err, ErrTooManyAttempts):
+		return http.StatusTooManyRequests // 429
+	case errors.Is(err, ErrAccountLocked):
+		return http.StatusLocked // 423
+	case errors.Is(err, repository.ErrInvalidCursor), errors.Is(err, repository.ErrInvalidSort), errors.Is(err, repository.ErrInvalidLimit),
+		errors.Is(err, repository.ErrInvalidLocation):
+		return http.StatusBadRequest // 400
 	case errors.Is(err, ErrAuthentication):
 		return http.StatusUnauthorized // 401
 	case errors.Is(err, ErrPasswordEmailDoesNotMatch):
 		return http.StatusUnauthorized // 401
-	case errors.Is(err, ErrNoAuthTokenHeader):
+	case errors.Is(err, ErrNoAuthTokenHeader), errors.Is(err, ErrInvalidToken):
 		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden // 403
 	default:
 		return http.StatusInternalServerError // 500
 	}
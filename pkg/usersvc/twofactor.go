@@ -0,0 +1,267 @@
+package usersvc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"california/internal/helpers"
+	"california/pkg/authsvc"
+	"california/pkg/model"
+	"california/pkg/totp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	totpIssuer           = "California"
+	challengeTTL         = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+var (
+	ErrInvalidCode         = errors.New("invalid two-factor code")
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorRequired   = errors.New("two-factor authentication is required for this account")
+)
+
+// VerifyLogin finishes a login which returned a challenge, with a TOTP or a recovery code, and logs the user in
+// on the given device. When the challenge required enrolment, the code confirms it and the recovery codes
+// of the user are returned as well. The challenge is given up after too many wrong codes, and every wrong
+// code counts as a failed login of the account.
+func (s *userService) VerifyLogin(ctx context.Context, challengeToken, code, deviceId string) (*model.User, *model.TokenPair, []string, error) {
+	challenge, user, err := s.lookupUserToken(ctx, challengeToken, model.LoginChallenge, model.EnrollmentChallenge)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// The client IP address is put into the context by the verify login endpoint.
+	ip, _ := ctx.Value(clientIPKey).(string)
+	if err = s.checkLoginAllowed(ctx, user.Email, ip); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var recoveryCodes []string
+	if challenge.Purpose == model.EnrollmentChallenge {
+		recoveryCodes, err = s.confirmTwoFactor(ctx, user, code)
+	} else {
+		err = s.checkCode(ctx, user, code)
+	}
+	if errors.Is(err, ErrInvalidCode) {
+		if e := s.loginFailed(ctx, user, user.Email, ip); e != nil {
+			return nil, nil, nil, e
+		}
+		attempts, e := s.store.FailUserToken(ctx, challenge.ID)
+		if e != nil {
+			return nil, nil, nil, e
+		}
+		if attempts >= maxChallengeAttempts {
+			if _, e = s.store.UseUserToken(ctx, challenge.ID); e != nil {
+				return nil, nil, nil, e
+			}
+		}
+		return nil, nil, nil, err
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+
+	used, err := s.store.UseUserToken(ctx, challenge.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !used {
+		return nil, nil, nil, ErrInvalidUserToken
+	}
+	if err = s.store.ResetLoginAttempts(ctx, accountKey(user.Email)); err != nil {
+		return nil, nil, nil, err
+	}
+	tokens, err := authsvc.IssueTokenPair(ctx, s.store, user, deviceId, primitive.NilObjectID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return user, tokens, recoveryCodes, nil
+}
+
+// StartLoginEnrollment starts the enrolment of a user whose login requires it.
+func (s *userService) StartLoginEnrollment(ctx context.Context, challengeToken string) (*model.TOTPEnrollment, error) {
+	_, user, err := s.lookupUserToken(ctx, challengeToken, model.EnrollmentChallenge)
+	if err != nil {
+		return nil, err
+	}
+	return s.startEnrollment(ctx, user)
+}
+
+// EnrollTwoFactor starts the enrolment of the current user, replacing an enrolment which was not confirmed.
+func (s *userService) EnrollTwoFactor(ctx context.Context) (*model.TOTPEnrollment, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.startEnrollment(ctx, user)
+}
+
+// ConfirmTwoFactor enables the two-factor authentication of the current user with a first code
+// and returns the recovery codes of the user.
+func (s *userService) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirmTwoFactor(ctx, user, code)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the current user.
+func (s *userService) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	// The code moved the last step of the user forward, which must not be undone.
+	if user, err = s.store.GetUserById(ctx, user.ID.Hex()); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	twoFactor := *user.TwoFactor
+	twoFactor.RecoveryCodes = hashes
+	if err = s.store.SetTwoFactor(ctx, user.ID, &twoFactor); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns the two-factor authentication of the current user off, or cancels an enrolment
+// which was not confirmed. The users who require it cannot turn it off.
+func (s *userService) DisableTwoFactor(ctx context.Context, code string) error {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactor == nil {
+		return ErrTwoFactorNotEnabled
+	}
+	if user.TwoFactor.Enabled {
+		if user.RequiresTwoFactor() {
+			return ErrTwoFactorRequired
+		}
+		if err = s.checkCode(ctx, user, code); err != nil {
+			return err
+		}
+	}
+	return s.store.SetTwoFactor(ctx, user.ID, nil)
+}
+
+// challenge returns the challenge finishing the login of a user who needs a second factor.
+func (s *userService) challenge(ctx context.Context, user *model.User) (*model.Challenge, error) {
+	purpose := model.LoginChallenge
+	if !user.TwoFactorEnabled() {
+		purpose = model.EnrollmentChallenge
+	}
+	token, err := s.issueUserToken(ctx, user, purpose, challengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Challenge{
+		Token:              token,
+		ExpiresIn:          int64(challengeTTL.Seconds()),
+		EnrollmentRequired: purpose == model.EnrollmentChallenge,
+	}, nil
+}
+
+func (s *userService) startEnrollment(ctx context.Context, user *model.User) (*model.TOTPEnrollment, error) {
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.store.SetTwoFactor(ctx, user.ID, &model.TwoFactor{Secret: secret}); err != nil {
+		return nil, err
+	}
+	return &model.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *userService) confirmTwoFactor(ctx context.Context, user *model.User, code string) ([]string, error) {
+	if user.TwoFactor == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if user.TwoFactor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	step, ok := totp.Validate(user.TwoFactor.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.SetTwoFactor(ctx, user.ID, &model.TwoFactor{
+		Secret:        user.TwoFactor.Secret,
+		Enabled:       true,
+		LastStep:      step,
+		RecoveryCodes: hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkCode uses up a TOTP code or a recovery code of the user.
+func (s *userService) checkCode(ctx context.Context, user *model.User, code string) error {
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+	code = strings.TrimSpace(code)
+	var used bool
+	if step, ok := totp.Validate(user.TwoFactor.Secret, code, time.Now()); ok {
+		var err error
+		if used, err = s.store.UseTwoFactorStep(ctx, user.ID, step); err != nil {
+			return err
+		}
+	} else if len(code) != totp.Digits {
+		var err error
+		if used, err = s.store.UseRecoveryCode(ctx, user.ID, helpers.HashToken(normalizeRecoveryCode(code))); err != nil {
+			return err
+		}
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// newRecoveryCodes returns new recovery codes, like "k3j9x-2mfq7", with the hashes they are stored by.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	b := make([]byte, 7)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = helpers.HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,245 @@
+// Package vehiclecatalog serves the official specs of vehicle makes, models and trims
+// from a dataset file, so users pick their vehicle instead of typing its specs.
+package vehiclecatalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"california/pkg/model"
+)
+
+var (
+	ErrUnknownFormat = errors.New("vehicle catalog must be a .json or .csv file")
+	ErrInvalidEntry  = errors.New("invalid vehicle catalog entry")
+
+	slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Catalog is a read-only set of catalog vehicles. A nil catalog is empty.
+type Catalog struct {
+	vehicles []*model.CatalogVehicle
+	byID     map[string]*model.CatalogVehicle
+}
+
+// Load reads the dataset file at path, in JSON or CSV depending on its extension.
+func Load(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ReadJSON(f)
+	case ".csv":
+		return ReadCSV(f)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// ReadJSON reads an array of catalog vehicles.
+func ReadJSON(r io.Reader) (*Catalog, error) {
+	var vehicles []*model.CatalogVehicle
+	if err := json.NewDecoder(r).Decode(&vehicles); err != nil {
+		return nil, err
+	}
+	return New(vehicles)
+}
+
+// ReadCSV reads catalog vehicles from a CSV file with a header row. The columns are matched by name
+// (id, make, model, trim, year, engine_type, engine_size, average_consumption, battery_capacity,
+// energy_consumption, socket_types, max_ac_power_kw, max_dc_power_kw) and may be in any order.
+// Engine types are given by name and socket types are separated by "|".
+func ReadCSV(r io.Reader) (*Catalog, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"make", "model", "engine_type"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrInvalidEntry, required)
+		}
+	}
+
+	var vehicles []*model.CatalogVehicle
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		vehicle, err := parseRecord(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+	return New(vehicles)
+}
+
+func parseRecord(record []string, columns map[string]int) (*model.CatalogVehicle, error) {
+	text := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var parseErr error
+	number := func(name string) float64 {
+		value := text(name)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil && parseErr == nil {
+			parseErr = fmt.Errorf("%w: %s %q", ErrInvalidEntry, name, value)
+		}
+		return n
+	}
+
+	engineType, ok := model.ParseEngineType(text("engine_type"))
+	if !ok {
+		return nil, fmt.Errorf("%w: engine_type %q", ErrInvalidEntry, text("engine_type"))
+	}
+	vehicle := &model.CatalogVehicle{
+		ID:                 text("id"),
+		Make:               text("make"),
+		Model:              text("model"),
+		Trim:               text("trim"),
+		Year:               int(number("year")),
+		EngineType:         engineType,
+		EngineSize:         number("engine_size"),
+		AverageConsumption: number("average_consumption"),
+		BatteryCapacity:    number("battery_capacity"),
+		EnergyConsumption:  number("energy_consumption"),
+		MaxACPowerKW:       number("max_ac_power_kw"),
+		MaxDCPowerKW:       number("max_dc_power_kw"),
+	}
+	for _, socketType := range strings.Split(text("socket_types"), "|") {
+		if socketType = strings.TrimSpace(socketType); socketType != "" {
+			vehicle.SocketTypes = append(vehicle.SocketTypes, socketType)
+		}
+	}
+	return vehicle, parseErr
+}
+
+// New builds a catalog from the given vehicles. Entries without an ID get one made of
+// their make, model, trim and year.
+func New(vehicles []*model.CatalogVehicle) (*Catalog, error) {
+	c := &Catalog{byID: make(map[string]*model.CatalogVehicle, len(vehicles))}
+	for _, vehicle := range vehicles {
+		if vehicle == nil || vehicle.Make == "" || vehicle.Model == "" {
+			return nil, ErrInvalidEntry
+		}
+		if vehicle.EngineType < model.Petrol || vehicle.EngineType > model.LPG {
+			return nil, fmt.Errorf("%w: %s %s has no engine type", ErrInvalidEntry, vehicle.Make, vehicle.Model)
+		}
+		if vehicle.EngineType == model.Electric && (vehicle.BatteryCapacity <= 0 || vehicle.EnergyConsumption <= 0) {
+			return nil, fmt.Errorf("%w: %s %s has no battery capacity or energy consumption", ErrInvalidEntry, vehicle.Make, vehicle.Model)
+		}
+		if vehicle.ID == "" {
+			vehicle.ID = slug(vehicle.Make, vehicle.Model, vehicle.Trim, strconv.Itoa(vehicle.Year))
+		}
+		if _, ok := c.byID[vehicle.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate id %s", ErrInvalidEntry, vehicle.ID)
+		}
+		c.byID[vehicle.ID] = vehicle
+		c.vehicles = append(c.vehicles, vehicle)
+	}
+	sort.SliceStable(c.vehicles, func(i, j int) bool {
+		a, b := c.vehicles[i], c.vehicles[j]
+		if !strings.EqualFold(a.Make, b.Make) {
+			return strings.ToLower(a.Make) < strings.ToLower(b.Make)
+		}
+		if !strings.EqualFold(a.Model, b.Model) {
+			return strings.ToLower(a.Model) < strings.ToLower(b.Model)
+		}
+		if a.Trim != b.Trim {
+			return a.Trim < b.Trim
+		}
+		return a.Year > b.Year
+	})
+	return c, nil
+}
+
+func slug(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part != "" && part != "0" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(strings.Join(kept, " ")), "-"), "-")
+}
+
+// Makes lists the makes of the catalog in alphabetical order.
+func (c *Catalog) Makes() []string {
+	makes := []string{}
+	if c == nil {
+		return makes
+	}
+	for _, vehicle := range c.vehicles {
+		if len(makes) == 0 || !strings.EqualFold(makes[len(makes)-1], vehicle.Make) {
+			makes = append(makes, vehicle.Make)
+		}
+	}
+	return makes
+}
+
+// Models lists the models of the make, regardless of case.
+func (c *Catalog) Models(makeName string) []string {
+	models := []string{}
+	if c == nil {
+		return models
+	}
+	for _, vehicle := range c.vehicles {
+		if !strings.EqualFold(vehicle.Make, makeName) {
+			continue
+		}
+		if len(models) == 0 || !strings.EqualFold(models[len(models)-1], vehicle.Model) {
+			models = append(models, vehicle.Model)
+		}
+	}
+	return models
+}
+
+// Trims lists the entries of the make and model, regardless of case.
+func (c *Catalog) Trims(makeName, modelName string) []*model.CatalogVehicle {
+	trims := []*model.CatalogVehicle{}
+	if c == nil {
+		return trims
+	}
+	for _, vehicle := range c.vehicles {
+		if strings.EqualFold(vehicle.Make, makeName) && strings.EqualFold(vehicle.Model, modelName) {
+			trims = append(trims, vehicle)
+		}
+	}
+	return trims
+}
+
+func (c *Catalog) Get(id string) (*model.CatalogVehicle, bool) {
+	if c == nil {
+		return nil, false
+	}
+	vehicle, ok := c.byID[id]
+	return vehicle, ok
+}
@@ -8,22 +8,26 @@ import (
 	"california/internal/config"
 	model2 "california/pkg/model"
 	"california/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func main() {
 	cfg := config.NewConfig()
 	mongoStore := repository.NewMongoStore(cfg)
 	insertOneResult, err := mongoStore.UsersColl.InsertOne(context.Background(), &model2.User{
-		Name:     "John Doe",
-		Email:    "",
-		UserType: model2.Normal,
-		Vehicle: model2.Vehicle{
+		Name:  "John Doe",
+		Email: "john.doe@example.com",
+		// The seeded user cannot receive the verification email.
+		EmailVerified: true,
+		UserType:      model2.Normal,
+		Vehicles: []model2.Vehicle{{
+			ID:                 primitive.NewObjectID(),
 			Brand:              "BMW",
 			Model:              "X5",
 			EngineType:         model2.Petrol,
 			EngineSize:         2.0,
 			AverageConsumption: 4.5,
-		},
+		}},
 	})
 	if err != nil {
 		log.Fatal(err)